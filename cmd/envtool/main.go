@@ -31,6 +31,7 @@ import (
 
 	"github.com/FerretDB/FerretDB/internal/clientconn"
 	"github.com/FerretDB/FerretDB/internal/handlers"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/util/debug"
 	"github.com/FerretDB/FerretDB/internal/util/logging"
@@ -165,8 +166,9 @@ func setupMonila(ctx context.Context, pgPool *pg.Pool) {
 
 	logger.Infof("Importing database...")
 
-	listenerMetrics := clientconn.NewListenerMetrics()
-	handlersMetrics := handlers.NewMetrics()
+	counters := common.NewCounters()
+	listenerMetrics := clientconn.NewListenerMetrics(counters)
+	handlersMetrics := handlers.NewMetrics(counters)
 	prometheus.DefaultRegisterer.MustRegister(listenerMetrics, handlersMetrics)
 
 	// listen on all interfaces to make mongoimport below work from inside Docker
@@ -28,6 +28,7 @@ import (
 
 	"github.com/FerretDB/FerretDB/internal/clientconn"
 	"github.com/FerretDB/FerretDB/internal/handlers"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/util/debug"
 	"github.com/FerretDB/FerretDB/internal/util/logging"
@@ -36,14 +37,16 @@ import (
 
 //nolint:gochecknoglobals // flags are defined there to be visible in `bin/ferretdb-testcover -h` output
 var (
-	debugAddrF       = flag.String("debug-addr", "127.0.0.1:8088", "debug address")
-	listenAddrF      = flag.String("listen-addr", "127.0.0.1:27017", "listen address")
-	modeF            = flag.String("mode", string(clientconn.AllModes[0]), fmt.Sprintf("operation mode: %v", clientconn.AllModes))
-	postgresqlURLF   = flag.String("postgresql-url", "postgres://postgres@127.0.0.1:5432/ferretdb", "PostgreSQL URL")
-	proxyAddrF       = flag.String("proxy-addr", "127.0.0.1:37017", "")
-	tlsF             = flag.Bool("tls", false, "enable insecure TLS")
-	versionF         = flag.Bool("version", false, "print version to stdout (full version, commit, branch, dirty flag) and exit")
-	testConnTimeoutF = flag.Duration("test-conn-timeout", 0, "test: set connection timeout")
+	changeLogRetentionF = flag.Duration("change-log-retention", pg.DefaultChangeLogRetention, "change streams history retention")
+	debugAddrF          = flag.String("debug-addr", "127.0.0.1:8088", "debug address")
+	listenAddrF         = flag.String("listen-addr", "127.0.0.1:27017", "listen address")
+	modeF               = flag.String("mode", string(clientconn.AllModes[0]), fmt.Sprintf("operation mode: %v", clientconn.AllModes))
+	postgresqlURLF      = flag.String("postgresql-url", "postgres://postgres@127.0.0.1:5432/ferretdb", "PostgreSQL URL")
+	proxyAddrF          = flag.String("proxy-addr", "127.0.0.1:37017", "")
+	sessionTimeoutF     = flag.Duration("session-timeout", common.LogicalSessionTimeout, "logical session inactivity timeout")
+	tlsF                = flag.Bool("tls", false, "enable insecure TLS")
+	versionF            = flag.Bool("version", false, "print version to stdout (full version, commit, branch, dirty flag) and exit")
+	testConnTimeoutF    = flag.Duration("test-conn-timeout", 0, "test: set connection timeout")
 )
 
 func main() {
@@ -99,20 +102,23 @@ func main() {
 	}
 	defer pgPool.Close()
 
-	listenerMetrics := clientconn.NewListenerMetrics()
-	handlersMetrics := handlers.NewMetrics()
+	counters := common.NewCounters()
+	listenerMetrics := clientconn.NewListenerMetrics(counters)
+	handlersMetrics := handlers.NewMetrics(counters)
 	prometheus.DefaultRegisterer.MustRegister(listenerMetrics, handlersMetrics)
 
 	l := clientconn.NewListener(&clientconn.NewListenerOpts{
-		ListenAddr:      *listenAddrF,
-		TLS:             *tlsF,
-		ProxyAddr:       *proxyAddrF,
-		Mode:            clientconn.Mode(*modeF),
-		PgPool:          pgPool,
-		Logger:          logger.Named("listener"),
-		Metrics:         listenerMetrics,
-		HandlersMetrics: handlersMetrics,
-		TestConnTimeout: *testConnTimeoutF,
+		ListenAddr:         *listenAddrF,
+		TLS:                *tlsF,
+		ProxyAddr:          *proxyAddrF,
+		Mode:               clientconn.Mode(*modeF),
+		PgPool:             pgPool,
+		Logger:             logger.Named("listener"),
+		Metrics:            listenerMetrics,
+		HandlersMetrics:    handlersMetrics,
+		SessionTimeout:     *sessionTimeoutF,
+		ChangeLogRetention: *changeLogRetentionF,
+		TestConnTimeout:    *testConnTimeoutF,
 	})
 
 	err = l.Run(ctx)
@@ -74,8 +74,24 @@ func fromBSON(v bsontype) any {
 		return types.Timestamp(*v)
 	case *Int64:
 		return int64(*v)
+	case *Decimal128:
+		return types.Decimal128(*v)
 	case *CString:
 		return types.CString(*v)
+	case *MinKey:
+		return types.MinKey(*v)
+	case *MaxKey:
+		return types.MaxKey(*v)
+	case *Undefined:
+		return types.Undefined(*v)
+	case *Symbol:
+		return types.Symbol(*v)
+	case *JavaScript:
+		return types.JavaScript(*v)
+	case *JavaScriptScope:
+		return types.JavaScriptScope(*v)
+	case *DBPointer:
+		return types.DBPointer(*v)
 	}
 
 	panic("not reached") // for go-sumtype to work
@@ -110,8 +126,24 @@ func toBSON(v any) bsontype {
 		return pointer.To(Timestamp(v))
 	case int64:
 		return pointer.To(Int64(v))
+	case types.Decimal128:
+		return pointer.To(Decimal128(v))
 	case types.CString:
 		return pointer.To(CString(v))
+	case types.MinKey:
+		return pointer.To(MinKey(v))
+	case types.MaxKey:
+		return pointer.To(MaxKey(v))
+	case types.Undefined:
+		return pointer.To(Undefined(v))
+	case types.Symbol:
+		return pointer.To(Symbol(v))
+	case types.JavaScript:
+		return pointer.To(JavaScript(v))
+	case types.JavaScriptScope:
+		return pointer.To(JavaScriptScope(v))
+	case types.DBPointer:
+		return pointer.To(DBPointer(v))
 	}
 
 	panic("not reached")
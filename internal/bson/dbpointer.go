@@ -0,0 +1,101 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+	"bytes"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// DBPointer represents BSON DBPointer (deprecated) data type.
+type DBPointer types.DBPointer
+
+func (dbp *DBPointer) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+func (dbp *DBPointer) ReadFrom(r *bufio.Reader) error {
+	var ns String
+	if err := ns.ReadFrom(r); err != nil {
+		return lazyerrors.Errorf("bson.DBPointer.ReadFrom (namespace): %w", err)
+	}
+
+	var id ObjectID
+	if err := id.ReadFrom(r); err != nil {
+		return lazyerrors.Errorf("bson.DBPointer.ReadFrom (ObjectID): %w", err)
+	}
+
+	*dbp = DBPointer{
+		Namespace: string(ns),
+		ID:        types.ObjectID(id),
+	}
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+func (dbp DBPointer) WriteTo(w *bufio.Writer) error {
+	v, err := dbp.MarshalBinary()
+	if err != nil {
+		return lazyerrors.Errorf("bson.DBPointer.WriteTo: %w", err)
+	}
+
+	_, err = w.Write(v)
+	if err != nil {
+		return lazyerrors.Errorf("bson.DBPointer.WriteTo: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (dbp DBPointer) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	bufw := bufio.NewWriter(&buf)
+
+	if err := String(dbp.Namespace).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+	if err := ObjectID(dbp.ID).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+
+	bufw.Flush()
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (dbp *DBPointer) UnmarshalJSON(data []byte) error {
+	var dbpJ fjson.DBPointer
+	if err := dbpJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*dbp = DBPointer(dbpJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (dbp DBPointer) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&dbp))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*DBPointer)(nil)
+)
@@ -0,0 +1,49 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"testing"
+
+	"github.com/AlekSi/pointer"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+var dBPointerTestCases = []testCase{{
+	name: "test.foo",
+	v:    pointer.To(DBPointer{Namespace: "test.foo", ID: types.ObjectID{0x42}}),
+	b: []byte{
+		0x09, 0x00, 0x00, 0x00, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x66, 0x6f, 0x6f, 0x00,
+		0x42, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	},
+}, {
+	name: "EOF",
+	b:    []byte{0x09, 0x00, 0x00, 0x00, 0x74, 0x65, 0x73, 0x74, 0x2e, 0x66, 0x6f, 0x6f, 0x00, 0x42},
+	bErr: `unexpected EOF`,
+}}
+
+func TestDBPointer(t *testing.T) {
+	t.Parallel()
+	testBinary(t, dBPointerTestCases, func() bsontype { return new(DBPointer) })
+}
+
+func FuzzDBPointer(f *testing.F) {
+	fuzzBinary(f, dBPointerTestCases, func() bsontype { return new(DBPointer) })
+}
+
+func BenchmarkDBPointer(b *testing.B) {
+	benchmark(b, dBPointerTestCases, func() bsontype { return new(DBPointer) })
+}
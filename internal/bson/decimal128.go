@@ -0,0 +1,88 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Decimal128 represents BSON Decimal128 data type.
+type Decimal128 types.Decimal128
+
+func (d *Decimal128) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+func (d *Decimal128) ReadFrom(r *bufio.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &d.L); err != nil {
+		return lazyerrors.Errorf("bson.Decimal128.ReadFrom (binary.Read): %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &d.H); err != nil {
+		return lazyerrors.Errorf("bson.Decimal128.ReadFrom (binary.Read): %w", err)
+	}
+
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+func (d Decimal128) WriteTo(w *bufio.Writer) error {
+	v, err := d.MarshalBinary()
+	if err != nil {
+		return lazyerrors.Errorf("bson.Decimal128.WriteTo: %w", err)
+	}
+
+	_, err = w.Write(v)
+	if err != nil {
+		return lazyerrors.Errorf("bson.Decimal128.WriteTo: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (d Decimal128) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.LittleEndian, d.L)
+	binary.Write(&buf, binary.LittleEndian, d.H)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (d *Decimal128) UnmarshalJSON(data []byte) error {
+	var dJ fjson.Decimal128
+	if err := dJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*d = Decimal128(dJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (d Decimal128) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&d))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*Decimal128)(nil)
+)
@@ -180,7 +180,7 @@ func (doc *Document) ReadFrom(r *bufio.Reader) error {
 			doc.m[string(ename)] = types.Binary(v)
 
 		case tagUndefined:
-			return lazyerrors.Errorf("bson.Document.ReadFrom: unhandled element type `Undefined (value) — Deprecated`")
+			doc.m[string(ename)] = types.Undefined{}
 
 		case tagObjectID:
 			var v ObjectID
@@ -234,8 +234,47 @@ func (doc *Document) ReadFrom(r *bufio.Reader) error {
 			}
 			doc.m[string(ename)] = int64(v)
 
-		case tagDBPointer, tagDecimal, tagJavaScript, tagJavaScriptScope, tagMaxKey, tagMinKey, tagSymbol:
-			return lazyerrors.Errorf("bson.Document.ReadFrom: unhandled element type %#02x (%s)", t, tag(t))
+		case tagDecimal:
+			var v Decimal128
+			if err := v.ReadFrom(bufr); err != nil {
+				return lazyerrors.Errorf("bson.Document.ReadFrom (Decimal128): %w", err)
+			}
+			doc.m[string(ename)] = types.Decimal128(v)
+
+		case tagMinKey:
+			doc.m[string(ename)] = types.MinKey{}
+
+		case tagMaxKey:
+			doc.m[string(ename)] = types.MaxKey{}
+
+		case tagSymbol:
+			var v Symbol
+			if err := v.ReadFrom(bufr); err != nil {
+				return lazyerrors.Errorf("bson.Document.ReadFrom (Symbol): %w", err)
+			}
+			doc.m[string(ename)] = types.Symbol(v)
+
+		case tagJavaScript:
+			var v JavaScript
+			if err := v.ReadFrom(bufr); err != nil {
+				return lazyerrors.Errorf("bson.Document.ReadFrom (JavaScript): %w", err)
+			}
+			doc.m[string(ename)] = types.JavaScript(v)
+
+		case tagJavaScriptScope:
+			var v JavaScriptScope
+			if err := v.ReadFrom(bufr); err != nil {
+				return lazyerrors.Errorf("bson.Document.ReadFrom (JavaScriptScope): %w", err)
+			}
+			doc.m[string(ename)] = types.JavaScriptScope(v)
+
+		case tagDBPointer:
+			var v DBPointer
+			if err := v.ReadFrom(bufr); err != nil {
+				return lazyerrors.Errorf("bson.Document.ReadFrom (DBPointer): %w", err)
+			}
+			doc.m[string(ename)] = types.DBPointer(v)
+
 		default:
 			return lazyerrors.Errorf("bson.Document.ReadFrom: unhandled element type %#02x (%s)", t, tag(t))
 		}
@@ -394,6 +433,69 @@ func (doc Document) MarshalBinary() ([]byte, error) {
 				return nil, lazyerrors.Error(err)
 			}
 
+		case types.Decimal128:
+			bufw.WriteByte(byte(tagDecimal))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+			if err := Decimal128(elV).WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.MinKey:
+			bufw.WriteByte(byte(tagMinKey))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.MaxKey:
+			bufw.WriteByte(byte(tagMaxKey))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.Undefined:
+			bufw.WriteByte(byte(tagUndefined))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.Symbol:
+			bufw.WriteByte(byte(tagSymbol))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+			if err := Symbol(elV).WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.JavaScript:
+			bufw.WriteByte(byte(tagJavaScript))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+			if err := JavaScript(elV).WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.JavaScriptScope:
+			bufw.WriteByte(byte(tagJavaScriptScope))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+			if err := JavaScriptScope(elV).WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
+		case types.DBPointer:
+			bufw.WriteByte(byte(tagDBPointer))
+			if err := ename.WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+			if err := DBPointer(elV).WriteTo(bufw); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+
 		default:
 			return nil, lazyerrors.Errorf("bson.Document.MarshalBinary: unhandled element type %T", elV)
 		}
@@ -155,13 +155,27 @@ var (
 		b: testutil.MustParseDumpFile("testdata", "all.hex"),
 	}
 
+	legacy = testCase{
+		name: "legacy",
+		v: MustConvertDocument(types.MustMakeDocument(
+			"minKey", types.MinKey{},
+			"maxKey", types.MaxKey{},
+			"undefined", types.Undefined{},
+			"symbol", types.Symbol("foo"),
+			"javascript", types.JavaScript("x = 1"),
+			"javascriptWithScope", types.JavaScriptScope{Code: "x", Scope: types.MustMakeDocument("x", int32(1))},
+			"dbPointer", types.DBPointer{Namespace: "test.foo", ID: types.ObjectID{0x42}},
+		)),
+		b: testutil.MustParseDumpFile("testdata", "legacy.hex"),
+	}
+
 	eof = testCase{
 		name: "EOF",
 		b:    []byte{0x00},
 		bErr: `unexpected EOF`,
 	}
 
-	documentTestCases = []testCase{handshake1, handshake2, handshake3, handshake4, all, eof}
+	documentTestCases = []testCase{handshake1, handshake2, handshake3, handshake4, all, legacy, eof}
 )
 
 func TestDocument(t *testing.T) {
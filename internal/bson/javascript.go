@@ -0,0 +1,82 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// JavaScript represents BSON JavaScript code data type.
+//
+// It is encoded in the same way as String.
+type JavaScript types.JavaScript
+
+func (js *JavaScript) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+func (js *JavaScript) ReadFrom(r *bufio.Reader) error {
+	var str String
+	if err := str.ReadFrom(r); err != nil {
+		return lazyerrors.Errorf("bson.JavaScript.ReadFrom: %w", err)
+	}
+
+	*js = JavaScript(str)
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+func (js JavaScript) WriteTo(w *bufio.Writer) error {
+	v, err := js.MarshalBinary()
+	if err != nil {
+		return lazyerrors.Errorf("bson.JavaScript.WriteTo: %w", err)
+	}
+
+	_, err = w.Write(v)
+	if err != nil {
+		return lazyerrors.Errorf("bson.JavaScript.WriteTo: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (js JavaScript) MarshalBinary() ([]byte, error) {
+	return String(js).MarshalBinary()
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (js *JavaScript) UnmarshalJSON(data []byte) error {
+	var jsJ fjson.JavaScript
+	if err := jsJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*js = JavaScript(jsJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (js JavaScript) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&js))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*JavaScript)(nil)
+)
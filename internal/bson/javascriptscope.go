@@ -0,0 +1,139 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// JavaScriptScope represents BSON JavaScript code with scope (deprecated) data type.
+type JavaScriptScope types.JavaScriptScope
+
+func (js *JavaScriptScope) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+func (js *JavaScriptScope) ReadFrom(r *bufio.Reader) error {
+	var l int32
+	if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom (binary.Read): %w", err)
+	}
+
+	// total length, code string (length, terminating 0x0), empty scope document
+	if l < 14 || l > MaxDocumentLen {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom: invalid length %d", l)
+	}
+
+	b := make([]byte, l-4)
+	if n, err := io.ReadFull(r, b); err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom: expected %d, read %d: %w", len(b), n, err)
+	}
+
+	br := bytes.NewReader(b)
+	bufr := bufio.NewReader(br)
+
+	var code String
+	if err := code.ReadFrom(bufr); err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom (code): %w", err)
+	}
+
+	var scope Document
+	if err := scope.ReadFrom(bufr); err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom (scope): %w", err)
+	}
+
+	if rest := bufr.Buffered() + br.Len(); rest != 0 {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom: %d bytes remain", rest)
+	}
+
+	td, err := types.ConvertDocument(&scope)
+	if err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.ReadFrom (scope): %w", err)
+	}
+
+	*js = JavaScriptScope{
+		Code:  string(code),
+		Scope: td,
+	}
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+func (js JavaScriptScope) WriteTo(w *bufio.Writer) error {
+	v, err := js.MarshalBinary()
+	if err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.WriteTo: %w", err)
+	}
+
+	_, err = w.Write(v)
+	if err != nil {
+		return lazyerrors.Errorf("bson.JavaScriptScope.WriteTo: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (js JavaScriptScope) MarshalBinary() ([]byte, error) {
+	code, err := String(js.Code).MarshalBinary()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	doc, err := ConvertDocument(js.Scope)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	scope, err := doc.MarshalBinary()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.LittleEndian, int32(4+len(code)+len(scope)))
+	buf.Write(code)
+	buf.Write(scope)
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (js *JavaScriptScope) UnmarshalJSON(data []byte) error {
+	var jsJ fjson.JavaScriptScope
+	if err := jsJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*js = JavaScriptScope(jsJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (js JavaScriptScope) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&js))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*JavaScriptScope)(nil)
+)
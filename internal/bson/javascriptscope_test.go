@@ -0,0 +1,58 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"testing"
+
+	"github.com/AlekSi/pointer"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+var javaScriptScopeTestCases = []testCase{{
+	name: "x",
+	v:    pointer.To(JavaScriptScope{Code: "x", Scope: types.MustMakeDocument("x", int32(1))}),
+	b: []byte{
+		0x16, 0x00, 0x00, 0x00,
+		0x02, 0x00, 0x00, 0x00, 0x78, 0x00,
+		0x0c, 0x00, 0x00, 0x00, 0x10, 0x78, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+	},
+}, {
+	name: "EmptyScope",
+	v:    pointer.To(JavaScriptScope{Code: "", Scope: types.MustMakeDocument()}),
+	b:    []byte{0x0e, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00},
+}, {
+	name: "InvalidLength",
+	b:    []byte{0x0d, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00},
+	bErr: `bson.JavaScriptScope.ReadFrom: invalid length 13`,
+}, {
+	name: "EOF",
+	b:    []byte{0x00},
+	bErr: `unexpected EOF`,
+}}
+
+func TestJavaScriptScope(t *testing.T) {
+	t.Parallel()
+	testBinary(t, javaScriptScopeTestCases, func() bsontype { return new(JavaScriptScope) })
+}
+
+func FuzzJavaScriptScope(f *testing.F) {
+	fuzzBinary(f, javaScriptScopeTestCases, func() bsontype { return new(JavaScriptScope) })
+}
+
+func BenchmarkJavaScriptScope(b *testing.B) {
+	benchmark(b, javaScriptScopeTestCases, func() bsontype { return new(JavaScriptScope) })
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// MaxKey represents BSON MaxKey data type.
+type MaxKey types.MaxKey
+
+func (mk *MaxKey) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+//
+// MaxKey has no value, so nothing is read.
+func (mk *MaxKey) ReadFrom(r *bufio.Reader) error {
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+//
+// MaxKey has no value, so nothing is written.
+func (mk MaxKey) WriteTo(w *bufio.Writer) error {
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (mk MaxKey) MarshalBinary() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (mk *MaxKey) UnmarshalJSON(data []byte) error {
+	var mkJ fjson.MaxKey
+	if err := mkJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*mk = MaxKey(mkJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (mk MaxKey) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&mk))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*MaxKey)(nil)
+)
@@ -35,7 +35,7 @@ func (str *String) ReadFrom(r *bufio.Reader) error {
 	if err := binary.Read(r, binary.LittleEndian, &l); err != nil {
 		return lazyerrors.Error(err)
 	}
-	if l <= 0 {
+	if l <= 0 || l > MaxDocumentLen {
 		return lazyerrors.Errorf("invalid length %d", l)
 	}
 
@@ -0,0 +1,82 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Symbol represents BSON Symbol (deprecated) data type.
+//
+// It is encoded in the same way as String.
+type Symbol types.Symbol
+
+func (sym *Symbol) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+func (sym *Symbol) ReadFrom(r *bufio.Reader) error {
+	var str String
+	if err := str.ReadFrom(r); err != nil {
+		return lazyerrors.Errorf("bson.Symbol.ReadFrom: %w", err)
+	}
+
+	*sym = Symbol(str)
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+func (sym Symbol) WriteTo(w *bufio.Writer) error {
+	v, err := sym.MarshalBinary()
+	if err != nil {
+		return lazyerrors.Errorf("bson.Symbol.WriteTo: %w", err)
+	}
+
+	_, err = w.Write(v)
+	if err != nil {
+		return lazyerrors.Errorf("bson.Symbol.WriteTo: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (sym Symbol) MarshalBinary() ([]byte, error) {
+	return String(sym).MarshalBinary()
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (sym *Symbol) UnmarshalJSON(data []byte) error {
+	var symJ fjson.Symbol
+	if err := symJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*sym = Symbol(symJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (sym Symbol) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&sym))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*Symbol)(nil)
+)
@@ -0,0 +1,44 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"testing"
+
+	"github.com/AlekSi/pointer"
+)
+
+var symbolTestCases = []testCase{{
+	name: "foo",
+	v:    pointer.To(Symbol("foo")),
+	b:    []byte{0x04, 0x00, 0x00, 0x00, 0x66, 0x6f, 0x6f, 0x00},
+}, {
+	name: "EOF",
+	b:    []byte{0x00},
+	bErr: `unexpected EOF`,
+}}
+
+func TestSymbol(t *testing.T) {
+	t.Parallel()
+	testBinary(t, symbolTestCases, func() bsontype { return new(Symbol) })
+}
+
+func FuzzSymbol(f *testing.F) {
+	fuzzBinary(f, symbolTestCases, func() bsontype { return new(Symbol) })
+}
+
+func BenchmarkSymbol(b *testing.B) {
+	benchmark(b, symbolTestCases, func() bsontype { return new(Symbol) })
+}
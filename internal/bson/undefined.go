@@ -0,0 +1,67 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bson
+
+import (
+	"bufio"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// Undefined represents BSON Undefined (deprecated) data type.
+type Undefined types.Undefined
+
+func (u *Undefined) bsontype() {}
+
+// ReadFrom implements bsontype interface.
+//
+// Undefined has no value, so nothing is read.
+func (u *Undefined) ReadFrom(r *bufio.Reader) error {
+	return nil
+}
+
+// WriteTo implements bsontype interface.
+//
+// Undefined has no value, so nothing is written.
+func (u Undefined) WriteTo(w *bufio.Writer) error {
+	return nil
+}
+
+// MarshalBinary implements bsontype interface.
+func (u Undefined) MarshalBinary() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// UnmarshalJSON implements bsontype interface.
+func (u *Undefined) UnmarshalJSON(data []byte) error {
+	var uJ fjson.Undefined
+	if err := uJ.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	*u = Undefined(uJ)
+	return nil
+}
+
+// MarshalJSON implements bsontype interface.
+func (u Undefined) MarshalJSON() ([]byte, error) {
+	return fjson.Marshal(fromBSON(&u))
+}
+
+// check interfaces
+var (
+	_ bsontype = (*Undefined)(nil)
+)
@@ -26,6 +26,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/FerretDB/FerretDB/internal/handlers"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/handlers/jsonb1"
 	"github.com/FerretDB/FerretDB/internal/handlers/proxy"
 	"github.com/FerretDB/FerretDB/internal/handlers/shared"
@@ -55,11 +56,12 @@ var AllModes = []Mode{NormalMode, ProxyMode, DiffNormalMode, DiffProxyMode}
 
 // conn represents client connection.
 type conn struct {
-	netConn net.Conn
-	mode    Mode
-	h       *handlers.Handler
-	proxy   *proxy.Handler
-	l       *zap.SugaredLogger
+	netConn  net.Conn
+	mode     Mode
+	h        *handlers.Handler
+	proxy    *proxy.Handler
+	counters *common.Counters
+	l        *zap.SugaredLogger
 }
 
 // newConnOpts represents newConn options.
@@ -69,6 +71,10 @@ type newConnOpts struct {
 	proxyAddr       string
 	mode            Mode
 	handlersMetrics *handlers.Metrics
+	counters        *common.Counters
+	cursors         *common.Cursors
+	sessions        *common.Sessions
+	connectionID    int32
 }
 
 // newConn creates a new client connection for given net.Conn.
@@ -77,9 +83,16 @@ func newConn(opts *newConnOpts) (*conn, error) {
 	l := zap.L().Named(prefix)
 
 	peerAddr := opts.netConn.RemoteAddr().String()
-	shared := shared.NewHandler(opts.pgPool, peerAddr)
-	sqlH := sql.NewStorage(opts.pgPool, l.Sugar())
-	jsonb1H := jsonb1.NewStorage(opts.pgPool, l)
+	shared := shared.NewHandler(&shared.NewOpts{
+		PgPool:       opts.pgPool,
+		PeerAddr:     peerAddr,
+		ConnectionID: opts.connectionID,
+		Cursors:      opts.cursors,
+		Sessions:     opts.sessions,
+		Counters:     opts.counters,
+	})
+	sqlH := sql.NewStorage(opts.pgPool, l.Sugar(), opts.cursors)
+	jsonb1H := jsonb1.NewStorage(opts.pgPool, l, opts.cursors)
 
 	var p *proxy.Handler
 	if opts.mode != NormalMode {
@@ -96,13 +109,15 @@ func newConn(opts *newConnOpts) (*conn, error) {
 		SQLStorage:    sqlH,
 		JSONB1Storage: jsonb1H,
 		Metrics:       opts.handlersMetrics,
+		Sessions:      opts.sessions,
 	}
 	return &conn{
-		netConn: opts.netConn,
-		mode:    opts.mode,
-		h:       handlers.New(handlerOpts),
-		proxy:   p,
-		l:       l.Sugar(),
+		netConn:  opts.netConn,
+		mode:     opts.mode,
+		h:        handlers.New(handlerOpts),
+		proxy:    p,
+		counters: opts.counters,
+		l:        l.Sugar(),
 	}, nil
 }
 
@@ -163,6 +178,11 @@ func (c *conn) run(ctx context.Context) (err error) {
 			c.l.Debugf("Request message:\n%s\n\n\n", wire.DumpMsgBody(reqBody))
 		}
 
+		// streaming replies can't be diffed or proxied, so the client gets regular replies
+		if msg, ok := reqBody.(*wire.OpMsg); ok && c.mode != NormalMode {
+			msg.FlagBits &^= wire.OpMsgFlags(wire.OpMsgExhaustAllowed)
+		}
+
 		// handle request unless we are in proxy mode
 		var resHeader *wire.MsgHeader
 		var resBody wire.MsgBody
@@ -198,6 +218,18 @@ func (c *conn) run(ctx context.Context) (err error) {
 			}
 		}
 
+		// the client does not expect a reply, for example, for unacknowledged (w:0) writes and legacy opcodes
+		if !wire.ExpectsReply(reqBody) {
+			c.counters.AddNetwork(int64(reqHeader.MessageLength), 0)
+
+			if closeConn {
+				err = errors.New("internal error")
+				return
+			}
+
+			continue
+		}
+
 		// diff in diff mode
 		if c.mode == DiffNormalMode || c.mode == DiffProxyMode {
 			res := difflib.SplitLines(wire.DumpMsgHeader(resHeader) + "\n" + wire.DumpMsgBody(resBody))
@@ -237,9 +269,39 @@ func (c *conn) run(ctx context.Context) (err error) {
 			return
 		}
 
+		c.counters.AddNetwork(int64(reqHeader.MessageLength), int64(resHeader.MessageLength))
+
+		// stream the next replies without waiting for requests while the handler sets moreToCome flag,
+		// see exhaustAllowed flag; each reply is a response to the previous one
+		for !closeConn && isMoreToCome(resBody) {
+			reqHeader = &wire.MsgHeader{
+				MessageLength: reqHeader.MessageLength,
+				RequestID:     resHeader.RequestID,
+				OpCode:        reqHeader.OpCode,
+			}
+
+			resHeader, resBody, closeConn = c.h.HandleMoreToCome(ctx, reqHeader, reqBody)
+
+			if err = wire.WriteMessage(bufw, resHeader, resBody); err != nil {
+				return
+			}
+
+			if err = bufw.Flush(); err != nil {
+				return
+			}
+
+			c.counters.AddNetwork(0, int64(resHeader.MessageLength))
+		}
+
 		if closeConn {
 			err = errors.New("internal error")
 			return
 		}
 	}
 }
+
+// isMoreToCome returns true if the reply is OP_MSG with moreToCome flag set.
+func isMoreToCome(resBody wire.MsgBody) bool {
+	msg, ok := resBody.(*wire.OpMsg)
+	return ok && msg.FlagBits.FlagSet(wire.OpMsgMoreToCome)
+}
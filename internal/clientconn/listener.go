@@ -21,11 +21,13 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/FerretDB/FerretDB/internal/handlers"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/util/ctxutil"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
@@ -33,26 +35,36 @@ import (
 
 // Listener accepts incoming client connections.
 type Listener struct {
-	opts *NewListenerOpts
+	opts     *NewListenerOpts
+	cursors  *common.Cursors
+	sessions *common.Sessions
+
+	lastConnectionID int32
 }
 
 // NewListenerOpts represents listener configuration.
 type NewListenerOpts struct {
-	ListenAddr      string
-	TLS             bool
-	ProxyAddr       string
-	Mode            Mode
-	PgPool          *pg.Pool
-	Logger          *zap.Logger
-	Metrics         *ListenerMetrics
-	HandlersMetrics *handlers.Metrics
-	TestConnTimeout time.Duration
+	ListenAddr         string
+	TLS                bool
+	ProxyAddr          string
+	Mode               Mode
+	PgPool             *pg.Pool
+	Logger             *zap.Logger
+	Metrics            *ListenerMetrics
+	HandlersMetrics    *handlers.Metrics
+	SessionTimeout     time.Duration // zero means common.LogicalSessionTimeout
+	ChangeLogRetention time.Duration // zero means pg.DefaultChangeLogRetention
+	TTLMonitorInterval time.Duration // zero means defaultTTLMonitorInterval
+	TestConnTimeout    time.Duration
 }
 
 // NewListener returns a new listener, configured by the NewListenerOpts argument.
 func NewListener(opts *NewListenerOpts) *Listener {
+	cursors := common.NewCursors()
 	return &Listener{
-		opts: opts,
+		opts:     opts,
+		cursors:  cursors,
+		sessions: common.NewSessions(cursors, opts.SessionTimeout),
 	}
 }
 
@@ -86,6 +98,16 @@ func (l *Listener) Run(ctx context.Context) error {
 		lis.Close()
 	}()
 
+	go l.cursors.Run(ctx)
+	go l.sessions.Run(ctx)
+	go l.runCatalogCleanup(ctx)
+
+	ttlMonitorDone := make(chan struct{})
+	go func() {
+		defer close(ttlMonitorDone)
+		l.runTTLMonitor(ctx)
+	}()
+
 	const delay = 3 * time.Second
 
 	var wg sync.WaitGroup
@@ -104,13 +126,13 @@ func (l *Listener) Run(ctx context.Context) error {
 		}
 
 		wg.Add(1)
-		l.opts.Metrics.ConnectedClients.Inc()
+		l.opts.Metrics.Counters.ConnectionOpened()
 
 		// run connection
 		go func() {
 			defer func() {
 				netConn.Close()
-				l.opts.Metrics.ConnectedClients.Dec()
+				l.opts.Metrics.Counters.ConnectionClosed()
 				wg.Done()
 			}()
 
@@ -120,6 +142,10 @@ func (l *Listener) Run(ctx context.Context) error {
 				proxyAddr:       l.opts.ProxyAddr,
 				mode:            l.opts.Mode,
 				handlersMetrics: l.opts.HandlersMetrics,
+				counters:        l.opts.Metrics.Counters,
+				cursors:         l.cursors,
+				sessions:        l.sessions,
+				connectionID:    atomic.AddInt32(&l.lastConnectionID, 1),
 			}
 			conn, e := newConn(opts)
 			if e != nil {
@@ -146,6 +172,50 @@ func (l *Listener) Run(ctx context.Context) error {
 
 	l.opts.Logger.Info("Waiting for all connections to stop...")
 	wg.Wait()
+	<-ttlMonitorDone
 
 	return ctx.Err()
 }
+
+// runCatalogCleanup periodically deletes old records of FerretDB catalog tables until ctx is canceled.
+//
+// Drivers retry writes only within a session, so retryable writes records older than the session timeout
+// are not needed. Change log records are kept for the configured retention time.
+func (l *Listener) runCatalogCleanup(ctx context.Context) {
+	timeout := l.sessions.Timeout()
+	interval := timeout
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+
+	retention := l.opts.ChangeLogRetention
+	if retention == 0 {
+		retention = pg.DefaultChangeLogRetention
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := l.opts.PgPool.DeleteRetryableWrites(ctx, now.Add(-timeout))
+			l.logCleanup(ctx, "Retryable writes", n, err)
+
+			n, err = l.opts.PgPool.DeleteChangeLog(ctx, now.Add(-retention))
+			l.logCleanup(ctx, "Change log", n, err)
+		}
+	}
+}
+
+// logCleanup logs the result of catalog table cleanup.
+func (l *Listener) logCleanup(ctx context.Context, what string, n int64, err error) {
+	switch {
+	case err != nil && ctx.Err() == nil:
+		l.opts.Logger.Warn(what+" records deletion failed", zap.Error(err))
+	case n > 0:
+		l.opts.Logger.Debug(what+" records deleted", zap.Int64("count", n))
+	}
+}
@@ -14,7 +14,11 @@
 
 package clientconn
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+)
 
 const (
 	namespace = "ferretdb"
@@ -22,20 +26,75 @@ const (
 )
 
 // ListenerMetrics represents listener metrics.
+//
+// Connection and network metrics are collected from counters shared with the serverStatus command.
 type ListenerMetrics struct {
-	ConnectedClients prometheus.Gauge
+	Counters *common.Counters
+
+	ConnectedClients    prometheus.GaugeFunc
+	Connections         prometheus.CounterFunc
+	BytesIn             prometheus.CounterFunc
+	BytesOut            prometheus.CounterFunc
+	TTLPasses           prometheus.Counter
+	TTLDeletedDocuments *prometheus.CounterVec
 }
 
-// NewListenerMetrics creates new listener metrics.
-func NewListenerMetrics() *ListenerMetrics {
+// NewListenerMetrics creates new listener metrics for the given counters.
+func NewListenerMetrics(counters *common.Counters) *ListenerMetrics {
 	return &ListenerMetrics{
-		ConnectedClients: prometheus.NewGauge(
+		Counters: counters,
+		ConnectedClients: prometheus.NewGaugeFunc(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
 				Subsystem: subsystem,
 				Name:      "connected",
 				Help:      "The current number of connected clients.",
 			},
+			func() float64 { return float64(counters.Snapshot().ConnectionsCurrent) },
+		),
+		Connections: prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "connections_total",
+				Help:      "The total number of accepted client connections.",
+			},
+			func() float64 { return float64(counters.Snapshot().ConnectionsTotal) },
+		),
+		BytesIn: prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "received_bytes_total",
+				Help:      "The total number of bytes received from clients.",
+			},
+			func() float64 { return float64(counters.Snapshot().BytesIn) },
+		),
+		BytesOut: prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "sent_bytes_total",
+				Help:      "The total number of bytes sent to clients.",
+			},
+			func() float64 { return float64(counters.Snapshot().BytesOut) },
+		),
+		TTLPasses: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "ttl",
+				Name:      "passes_total",
+				Help:      "The total number of TTL monitor passes.",
+			},
+		),
+		TTLDeletedDocuments: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "ttl",
+				Name:      "deleted_documents_total",
+				Help:      "The total number of documents deleted by TTL indexes.",
+			},
+			[]string{"db", "collection"},
 		),
 	}
 }
@@ -43,11 +102,21 @@ func NewListenerMetrics() *ListenerMetrics {
 // Describe implements prometheus.Collector.
 func (lm *ListenerMetrics) Describe(ch chan<- *prometheus.Desc) {
 	lm.ConnectedClients.Describe(ch)
+	lm.Connections.Describe(ch)
+	lm.BytesIn.Describe(ch)
+	lm.BytesOut.Describe(ch)
+	lm.TTLPasses.Describe(ch)
+	lm.TTLDeletedDocuments.Describe(ch)
 }
 
 // Collect implements prometheus.Collector.
 func (lm *ListenerMetrics) Collect(ch chan<- prometheus.Metric) {
 	lm.ConnectedClients.Collect(ch)
+	lm.Connections.Collect(ch)
+	lm.BytesIn.Collect(ch)
+	lm.BytesOut.Collect(ch)
+	lm.TTLPasses.Collect(ch)
+	lm.TTLDeletedDocuments.Collect(ch)
 }
 
 // check interfaces
@@ -0,0 +1,107 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientconn
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/FerretDB/FerretDB/internal/pg"
+)
+
+const (
+	// defaultTTLMonitorInterval is the default interval between TTL monitor passes, as in MongoDB.
+	defaultTTLMonitorInterval = time.Minute
+
+	// ttlDeleteBatch is the maximal number of expired documents deleted by a single statement,
+	// so TTL monitor does not hold locks of big collections for too long.
+	ttlDeleteBatch = 1000
+)
+
+// runTTLMonitor periodically deletes expired documents of collections with TTL indexes until ctx is canceled.
+func (l *Listener) runTTLMonitor(ctx context.Context) {
+	interval := l.opts.TTLMonitorInterval
+	if interval == 0 {
+		interval = defaultTTLMonitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.ttlPass(ctx, now)
+		}
+	}
+}
+
+// ttlPass deletes documents that are expired at the given time for all TTL indexes.
+func (l *Listener) ttlPass(ctx context.Context, now time.Time) {
+	indexes, err := l.opts.PgPool.TTLIndexes(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			l.opts.Logger.Warn("Failed to get TTL indexes", zap.Error(err))
+		}
+		return
+	}
+
+	for i := range indexes {
+		if ctx.Err() != nil {
+			return
+		}
+
+		l.ttlDelete(ctx, &indexes[i], now)
+	}
+
+	l.opts.Metrics.TTLPasses.Inc()
+}
+
+// ttlDelete deletes documents that are expired at the given time for the TTL index in batches.
+func (l *Listener) ttlDelete(ctx context.Context, idx *pg.TTLIndex, now time.Time) {
+	deleted := l.opts.Metrics.TTLDeletedDocuments.WithLabelValues(idx.DB, idx.Collection)
+
+	var total int64
+	for ctx.Err() == nil {
+		n, err := l.opts.PgPool.DeleteExpired(ctx, idx, now, ttlDeleteBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				l.opts.Logger.Warn(
+					"Failed to delete expired documents",
+					zap.String("ns", idx.DB+"."+idx.Collection), zap.String("index", idx.Name), zap.Error(err),
+				)
+			}
+			break
+		}
+
+		deleted.Add(float64(n))
+		total += n
+
+		if n < ttlDeleteBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		l.opts.Logger.Debug(
+			"Expired documents deleted",
+			zap.String("ns", idx.DB+"."+idx.Collection), zap.String("index", idx.Name), zap.Int64("count", total),
+		)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fjson
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Decimal128 represents BSON Decimal128 data type.
+type Decimal128 types.Decimal128
+
+// fjsontype implements fjsontype interface.
+func (d *Decimal128) fjsontype() {}
+
+// decimal128JSON stores the value as a string that PostgreSQL can cast to numeric.
+type decimal128JSON struct {
+	N string `json:"$n"`
+}
+
+// UnmarshalJSON implements fjsontype interface.
+func (d *Decimal128) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		panic("null data")
+	}
+
+	r := bytes.NewReader(data)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var o decimal128JSON
+	if err := dec.Decode(&o); err != nil {
+		return lazyerrors.Error(err)
+	}
+	if err := checkConsumed(dec, r); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	v, err := types.ParseDecimal128(o.N)
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	*d = Decimal128(v)
+	return nil
+}
+
+// MarshalJSON implements fjsontype interface.
+func (d *Decimal128) MarshalJSON() ([]byte, error) {
+	res, err := json.Marshal(decimal128JSON{
+		N: types.Decimal128(*d).String(),
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	return res, nil
+}
+
+// check interfaces
+var (
+	_ fjsontype = (*Decimal128)(nil)
+)
@@ -0,0 +1,58 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fjson
+
+import (
+	"testing"
+
+	"github.com/AlekSi/pointer"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+var decimal128TestCases = []testCase{{
+	name: "1",
+	v:    pointer.To(Decimal128(types.NewDecimal128FromInt64(1))),
+	j:    `{"$n":"1"}`,
+}, {
+	name: "-1.5",
+	v:    pointer.To(Decimal128(types.MustParseDecimal128("-1.5"))),
+	j:    `{"$n":"-1.5"}`,
+}, {
+	name: "exponent",
+	v:    pointer.To(Decimal128(types.MustParseDecimal128("1.0E+6112"))),
+	j:    `{"$n":"1.0E+6112"}`,
+}, {
+	name: "NaN",
+	v:    pointer.To(Decimal128(types.Decimal128NaN())),
+	j:    `{"$n":"NaN"}`,
+}, {
+	name: "EOF",
+	j:    `{`,
+	jErr: `unexpected EOF`,
+}}
+
+func TestDecimal128(t *testing.T) {
+	t.Parallel()
+	testJSON(t, decimal128TestCases, func() fjsontype { return new(Decimal128) })
+}
+
+func FuzzDecimal128(f *testing.F) {
+	fuzzJSON(f, decimal128TestCases, func() fjsontype { return new(Decimal128) })
+}
+
+func BenchmarkDecimal128(b *testing.B) {
+	benchmark(b, decimal128TestCases, func() fjsontype { return new(Decimal128) })
+}
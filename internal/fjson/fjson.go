@@ -41,6 +41,13 @@
 //  Int64:      {"$l": "<number as string>"}
 //  Decimal128: {"$n": "<number as string>"}
 //  CString:    {"$c": "<string without terminating 0x0>"}
+//  MinKey:     {"$mi": 1}
+//  MaxKey:     {"$ma": 1}
+//  Undefined:  {"$u": true}
+//  Symbol:     {"$y": "<string>"}
+//  JavaScript: {"$j": "<code>"}
+//  JavaScriptScope: {"$js": "<code>", "s": <scope document>}
+//  DBPointer:  {"$p": "<namespace>", "i": "<ObjectID as 24 character hex string>"}
 package fjson
 
 import (
@@ -107,8 +114,24 @@ func fromFJSON(v fjsontype) any {
 		return types.Timestamp(*v)
 	case *Int64:
 		return int64(*v)
+	case *Decimal128:
+		return types.Decimal128(*v)
 	case *CString:
 		return types.CString(*v)
+	case *MinKey:
+		return types.MinKey(*v)
+	case *MaxKey:
+		return types.MaxKey(*v)
+	case *Undefined:
+		return types.Undefined(*v)
+	case *Symbol:
+		return types.Symbol(*v)
+	case *JavaScript:
+		return types.JavaScript(*v)
+	case *JavaScriptScope:
+		return types.JavaScriptScope(*v)
+	case *DBPointer:
+		return types.DBPointer(*v)
 	}
 
 	panic("not reached") // for go-sumtype to work
@@ -142,8 +165,24 @@ func toFJSON(v any) fjsontype {
 		return pointer.To(Timestamp(v))
 	case int64:
 		return pointer.To(Int64(v))
+	case types.Decimal128:
+		return pointer.To(Decimal128(v))
 	case types.CString:
 		return pointer.To(CString(v))
+	case types.MinKey:
+		return pointer.To(MinKey(v))
+	case types.MaxKey:
+		return pointer.To(MaxKey(v))
+	case types.Undefined:
+		return pointer.To(Undefined(v))
+	case types.Symbol:
+		return pointer.To(Symbol(v))
+	case types.JavaScript:
+		return pointer.To(JavaScript(v))
+	case types.JavaScriptScope:
+		return pointer.To(JavaScriptScope(v))
+	case types.DBPointer:
+		return pointer.To(DBPointer(v))
 	}
 
 	panic("not reached")
@@ -198,10 +237,42 @@ func Unmarshal(data []byte) (any, error) {
 			var o Int64
 			err = o.UnmarshalJSON(data)
 			res = &o
+		case v["$n"] != nil:
+			var o Decimal128
+			err = o.UnmarshalJSON(data)
+			res = &o
 		case v["$c"] != nil:
 			var o CString
 			err = o.UnmarshalJSON(data)
 			res = &o
+		case v["$mi"] != nil:
+			var o MinKey
+			err = o.UnmarshalJSON(data)
+			res = &o
+		case v["$ma"] != nil:
+			var o MaxKey
+			err = o.UnmarshalJSON(data)
+			res = &o
+		case v["$u"] != nil:
+			var o Undefined
+			err = o.UnmarshalJSON(data)
+			res = &o
+		case v["$y"] != nil:
+			var o Symbol
+			err = o.UnmarshalJSON(data)
+			res = &o
+		case v["$j"] != nil:
+			var o JavaScript
+			err = o.UnmarshalJSON(data)
+			res = &o
+		case v["$js"] != nil:
+			var o JavaScriptScope
+			err = o.UnmarshalJSON(data)
+			res = &o
+		case v["$p"] != nil:
+			var o DBPointer
+			err = o.UnmarshalJSON(data)
+			res = &o
 		default:
 			err = lazyerrors.Errorf("fjson.Unmarshal: unhandled map %v", v)
 		}
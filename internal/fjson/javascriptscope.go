@@ -0,0 +1,89 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fjson
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// JavaScriptScope represents BSON JavaScript code with scope (deprecated) data type.
+type JavaScriptScope types.JavaScriptScope
+
+// fjsontype implements fjsontype interface.
+func (js *JavaScriptScope) fjsontype() {}
+
+type javaScriptScopeJSON struct {
+	JS string          `json:"$js"`
+	S  json.RawMessage `json:"s"`
+}
+
+// UnmarshalJSON implements fjsontype interface.
+func (js *JavaScriptScope) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		panic("null data")
+	}
+
+	r := bytes.NewReader(data)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var o javaScriptScopeJSON
+	if err := dec.Decode(&o); err != nil {
+		return lazyerrors.Error(err)
+	}
+	if err := checkConsumed(dec, r); err != nil {
+		return lazyerrors.Error(err)
+	}
+	if len(o.S) == 0 || bytes.Equal(o.S, []byte("null")) {
+		return lazyerrors.Errorf("fjson.JavaScriptScope.UnmarshalJSON: missing scope")
+	}
+
+	var scope Document
+	if err := scope.UnmarshalJSON(o.S); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	*js = JavaScriptScope{
+		Code:  o.JS,
+		Scope: types.Document(scope),
+	}
+	return nil
+}
+
+// MarshalJSON implements fjsontype interface.
+func (js *JavaScriptScope) MarshalJSON() ([]byte, error) {
+	scope, err := Marshal(js.Scope)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	res, err := json.Marshal(javaScriptScopeJSON{
+		JS: js.Code,
+		S:  scope,
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	return res, nil
+}
+
+// check interfaces
+var (
+	_ fjsontype = (*JavaScriptScope)(nil)
+)
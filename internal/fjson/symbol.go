@@ -0,0 +1,71 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fjson
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Symbol represents BSON Symbol (deprecated) data type.
+type Symbol types.Symbol
+
+// fjsontype implements fjsontype interface.
+func (sym *Symbol) fjsontype() {}
+
+type symbolJSON struct {
+	V string `json:"$y"`
+}
+
+// UnmarshalJSON implements fjsontype interface.
+func (sym *Symbol) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		panic("null data")
+	}
+
+	r := bytes.NewReader(data)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var o symbolJSON
+	if err := dec.Decode(&o); err != nil {
+		return lazyerrors.Error(err)
+	}
+	if err := checkConsumed(dec, r); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	*sym = Symbol(o.V)
+	return nil
+}
+
+// MarshalJSON implements fjsontype interface.
+func (sym *Symbol) MarshalJSON() ([]byte, error) {
+	res, err := json.Marshal(symbolJSON{
+		V: string(*sym),
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	return res, nil
+}
+
+// check interfaces
+var (
+	_ fjsontype = (*Symbol)(nil)
+)
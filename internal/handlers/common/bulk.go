@@ -0,0 +1,264 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// WriteError represents a single failed statement of insert, update or delete command.
+type WriteError struct {
+	Index int32
+	Err   *Error
+}
+
+// BulkWriteResult represents the result of insert, update or delete command.
+type BulkWriteResult struct {
+	N           int64
+	NModified   int64
+	WriteErrors []WriteError
+}
+
+// MaxWriteBatchSize is the maximal number of statements in a single insert, update or delete command.
+//
+// It is reported to clients by hello command.
+const MaxWriteBatchSize = 100000
+
+// BulkStatement executes a single statement of insert, update or delete command with the given index.
+//
+// It returns the number of matched (or inserted, or deleted) and modified documents.
+type BulkStatement func(tx pgx.Tx, i int) (n, nModified int64, err error)
+
+// BulkBatch executes statements with indexes in [from, to) range at once.
+//
+// It returns the number of matched (or inserted, or deleted) and modified documents.
+// It either succeeds or fails as a whole.
+type BulkBatch func(tx pgx.Tx, from, to int) (n, nModified int64, err error)
+
+// BulkWrite executes count statements in a single transaction with MongoDB bulk write semantics.
+//
+// Each statement runs in its own savepoint, so a failed statement doesn't affect others.
+// Failed statements are reported in the result's WriteErrors.
+// If ordered is true, the execution stops at the first failed statement; otherwise, it continues.
+// If retry is not nil, successful statements are recorded in the same transaction,
+// and statements recorded by the previous attempt are not executed again; their recorded counts are used instead.
+// The returned error is not nil only if the whole command failed.
+func BulkWrite(
+	ctx context.Context, pgPool *pg.Pool, ns string, count int, ordered bool, retry *RetryableWrite, f BulkStatement,
+) (*BulkWriteResult, error) {
+	batchEnd := func(from int) int { return from + 1 }
+
+	// batch function is not called for single-statement batches
+	return BulkWriteBatches(ctx, pgPool, ns, count, ordered, retry, batchEnd, nil, f)
+}
+
+// BulkWriteBatches is a variant of BulkWrite that executes statements in batches for performance.
+//
+// The batchEnd function returns the end (exclusive) of the batch starting at the given index.
+// Each batch runs in its own savepoint. If a batch fails, its statements are executed again
+// one by one with the f function to find and report failed statements with the same semantics as BulkWrite.
+func BulkWriteBatches(
+	ctx context.Context, pgPool *pg.Pool, ns string, count int, ordered bool, retry *RetryableWrite,
+	batchEnd func(from int) int, batch BulkBatch, f BulkStatement,
+) (*BulkWriteResult, error) {
+	if count < 1 || count > MaxWriteBatchSize {
+		return nil, NewErrorMessage(
+			ErrInvalidLength, "Write batch sizes must be between 1 and %d. Got %d operations.", MaxWriteBatchSize, count,
+		)
+	}
+
+	var res BulkWriteResult
+
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		var executed map[int]pg.RetryableWrite
+		if retry != nil {
+			var err error
+			if executed, err = retry.executed(ctx, pgPool, tx); err != nil {
+				return err
+			}
+		}
+
+		// record wraps the function to record executed statements with indexes in [from, to) range
+		record := func(from, to int, f func(pgx.Tx) (int64, int64, error)) func(pgx.Tx) (int64, int64, error) {
+			if retry == nil {
+				return f
+			}
+
+			return func(sp pgx.Tx) (int64, int64, error) {
+				n, nModified, err := f(sp)
+				if err != nil {
+					return 0, 0, err
+				}
+
+				if err = retry.record(ctx, pgPool, sp, from, to, n, nModified); err != nil {
+					return 0, 0, err
+				}
+
+				return n, nModified, nil
+			}
+		}
+
+		var to int
+		for from := 0; from < count; from = to {
+			if to = batchEnd(from); to > count {
+				to = count
+			}
+
+			if to-from > 1 && !anyExecuted(executed, from, to) {
+				stmtErr, err := res.execSavepoint(ctx, tx, record(from, to, func(sp pgx.Tx) (int64, int64, error) {
+					return batch(sp, from, to)
+				}))
+				if err != nil {
+					return err
+				}
+				if stmtErr == nil {
+					continue
+				}
+
+				// execute batch statements one by one to find failed ones
+			}
+
+			for i := from; i < to; i++ {
+				if r, ok := executed[i]; ok {
+					res.N += r.N
+					res.NModified += r.NModified
+					continue
+				}
+
+				i := i
+				stmtErr, err := res.execSavepoint(ctx, tx, record(i, i+1, func(sp pgx.Tx) (int64, int64, error) {
+					return f(sp, i)
+				}))
+				if err != nil {
+					return err
+				}
+				if stmtErr == nil {
+					continue
+				}
+
+				res.WriteErrors = append(res.WriteErrors, WriteError{
+					Index: int32(i),
+					Err:   writeError(ns, stmtErr),
+				})
+
+				if ordered {
+					return nil
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// anyExecuted returns true if any statement with index in [from, to) range was already executed.
+func anyExecuted(executed map[int]pg.RetryableWrite, from, to int) bool {
+	for i := from; i < to; i++ {
+		if _, ok := executed[i]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// execSavepoint runs the given function in a savepoint and adds returned counts to the result.
+//
+// If the function fails, the savepoint is rolled back, and the function's error is returned as stmtErr.
+// The returned err is not nil only if the whole transaction failed.
+func (r *BulkWriteResult) execSavepoint(
+	ctx context.Context, tx pgx.Tx, f func(pgx.Tx) (int64, int64, error),
+) (stmtErr, err error) {
+	// pgx implements nested transactions with savepoints
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	n, nModified, stmtErr := f(sp)
+	if stmtErr != nil {
+		if err = sp.Rollback(ctx); err != nil {
+			return nil, lazyerrors.Errorf("%w (rollback error: %s)", stmtErr, err)
+		}
+		return stmtErr, nil
+	}
+
+	if err = sp.Commit(ctx); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	r.N += n
+	r.NModified += nModified
+
+	return nil, nil
+}
+
+// writeError converts statement's error to wire protocol error.
+func writeError(ns string, err error) *Error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return NewErrorMessage(
+			ErrDuplicateKey, "E11000 duplicate key error collection: %s index: _id_ dup key: %s", ns, pgErr.Detail,
+		).(*Error)
+	}
+
+	protoErr, _ := ProtocolError(err)
+	return protoErr
+}
+
+// Reply returns insert, update or delete command reply document.
+//
+// If withModified is true, nModified field is added, as for update command.
+func (r *BulkWriteResult) Reply(withModified bool) types.Document {
+	pairs := []any{"n", int32(r.N)}
+
+	if withModified {
+		pairs = append(pairs, "nModified", int32(r.NModified))
+	}
+
+	if len(r.WriteErrors) > 0 {
+		writeErrors := make([]any, len(r.WriteErrors))
+		for i, we := range r.WriteErrors {
+			d := types.MustMakeDocument(
+				"index", we.Index,
+				"code", int32(we.Err.code),
+			)
+			if we.Err.info != nil {
+				d.Set("errInfo", *we.Err.info)
+			}
+			d.Set("errmsg", we.Err.err.Error())
+			writeErrors[i] = d
+		}
+		pairs = append(pairs, "writeErrors", types.MustNewArray(writeErrors...))
+	}
+
+	pairs = append(pairs, "ok", float64(1))
+
+	return types.MustMakeDocument(pairs...)
+}
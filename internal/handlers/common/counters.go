@@ -0,0 +1,215 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// RequestKey identifies requests counted by Counters.
+type RequestKey struct {
+	OpCode  string
+	Command string // lowercased command name; empty for legacy opcodes other than OP_QUERY
+}
+
+// Counters is a process-wide set of counters of connections, network traffic, requests and documents.
+//
+// They are reported by the serverStatus command and exported as Prometheus metrics
+// by handlers.Metrics and clientconn.ListenerMetrics, so both always agree.
+//
+// It is safe for concurrent use.
+type Counters struct {
+	start time.Time
+
+	m                  sync.Mutex
+	connectionsCurrent int64
+	connectionsTotal   int64
+	bytesIn            int64
+	bytesOut           int64
+	numRequests        int64
+	requests           map[RequestKey]int64
+	failed             map[string]int64
+	documents          map[string]int64
+}
+
+// CountersSnapshot is a copy of all counters at some moment.
+type CountersSnapshot struct {
+	Uptime             time.Duration
+	ConnectionsCurrent int64
+	ConnectionsTotal   int64
+	BytesIn            int64
+	BytesOut           int64
+	NumRequests        int64
+	Requests           map[RequestKey]int64
+	Failed             map[string]int64 // failed commands by name
+	Documents          map[string]int64 // "deleted", "inserted", "returned" and "updated" documents
+}
+
+// NewCounters creates a new set of zero counters; uptime is counted from that moment.
+func NewCounters() *Counters {
+	return &Counters{
+		start:     time.Now(),
+		requests:  make(map[RequestKey]int64),
+		failed:    make(map[string]int64),
+		documents: make(map[string]int64),
+	}
+}
+
+// ConnectionOpened counts a new client connection.
+func (c *Counters) ConnectionOpened() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.connectionsCurrent++
+	c.connectionsTotal++
+}
+
+// ConnectionClosed counts a closed client connection.
+func (c *Counters) ConnectionClosed() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.connectionsCurrent--
+}
+
+// AddNetwork counts sizes in bytes of a received message and its reply.
+//
+// Zero in means a streamed reply without a request, and zero out means no reply.
+func (c *Counters) AddNetwork(in, out int64) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if in > 0 {
+		c.numRequests++
+	}
+	c.bytesIn += in
+	c.bytesOut += out
+}
+
+// AddRequest counts a handled request.
+func (c *Counters) AddRequest(opCode, command string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.requests[RequestKey{OpCode: opCode, Command: command}]++
+}
+
+// AddFailed counts a failed command.
+func (c *Counters) AddFailed(command string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.failed[command]++
+}
+
+// AddDocuments counts documents that were "deleted", "inserted", "returned" or "updated".
+func (c *Counters) AddDocuments(kind string, n int64) {
+	if n <= 0 {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.documents[kind] += n
+}
+
+// Snapshot returns a copy of all counters.
+func (c *Counters) Snapshot() *CountersSnapshot {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	res := &CountersSnapshot{
+		Uptime:             time.Since(c.start),
+		ConnectionsCurrent: c.connectionsCurrent,
+		ConnectionsTotal:   c.connectionsTotal,
+		BytesIn:            c.bytesIn,
+		BytesOut:           c.bytesOut,
+		NumRequests:        c.numRequests,
+		Requests:           make(map[RequestKey]int64, len(c.requests)),
+		Failed:             make(map[string]int64, len(c.failed)),
+		Documents:          make(map[string]int64, len(c.documents)),
+	}
+
+	for k, v := range c.requests {
+		res.Requests[k] = v
+	}
+	for k, v := range c.failed {
+		res.Failed[k] = v
+	}
+	for k, v := range c.documents {
+		res.Documents[k] = v
+	}
+
+	return res
+}
+
+// OpCounters returns numbers of requests by MongoDB opcounters kinds:
+// "insert", "query", "update", "delete", "getmore" and "command".
+//
+// Unlike MongoDB, write commands are counted once, not once per statement.
+func (s *CountersSnapshot) OpCounters() map[string]int64 {
+	res := map[string]int64{
+		"insert":  0,
+		"query":   0,
+		"update":  0,
+		"delete":  0,
+		"getmore": 0,
+		"command": 0,
+	}
+
+	for k, v := range s.Requests {
+		switch k.OpCode {
+		case wire.OP_INSERT.String():
+			res["insert"] += v
+			continue
+		case wire.OP_UPDATE.String():
+			res["update"] += v
+			continue
+		case wire.OP_DELETE.String():
+			res["delete"] += v
+			continue
+		case wire.OP_GET_MORE.String():
+			res["getmore"] += v
+			continue
+		}
+
+		switch k.Command {
+		case "insert", "update", "delete", "getmore":
+			res[k.Command] += v
+		case "find":
+			res["query"] += v
+		default:
+			res["command"] += v
+		}
+	}
+
+	return res
+}
+
+// Commands returns total numbers of commands by name, including commands sent with OP_QUERY.
+func (s *CountersSnapshot) Commands() map[string]int64 {
+	res := make(map[string]int64)
+	for k, v := range s.Requests {
+		if k.Command != "" {
+			res[k.Command] += v
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+func TestCounters(t *testing.T) {
+	t.Parallel()
+
+	c := NewCounters()
+
+	c.ConnectionOpened()
+	c.ConnectionOpened()
+	c.ConnectionClosed()
+
+	c.AddNetwork(100, 200)
+	c.AddNetwork(50, 0)
+	c.AddNetwork(0, 300)
+
+	c.AddRequest(wire.OP_MSG.String(), "find")
+	c.AddRequest(wire.OP_MSG.String(), "find")
+	c.AddRequest(wire.OP_MSG.String(), "insert")
+	c.AddRequest(wire.OP_MSG.String(), "getmore")
+	c.AddRequest(wire.OP_MSG.String(), "ping")
+	c.AddRequest(wire.OP_QUERY.String(), "ismaster")
+	c.AddRequest(wire.OP_QUERY.String(), "find")
+	c.AddRequest(wire.OP_INSERT.String(), "")
+	c.AddRequest(wire.OP_GET_MORE.String(), "")
+	c.AddRequest(wire.OP_KILL_CURSORS.String(), "")
+
+	c.AddFailed("insert")
+	c.AddDocuments("inserted", 3)
+	c.AddDocuments("returned", 0)
+
+	s := c.Snapshot()
+
+	// further changes do not affect the snapshot
+	c.AddRequest(wire.OP_MSG.String(), "ping")
+	c.AddFailed("ping")
+
+	assert.Equal(t, int64(1), s.ConnectionsCurrent)
+	assert.Equal(t, int64(2), s.ConnectionsTotal)
+	assert.Equal(t, int64(150), s.BytesIn)
+	assert.Equal(t, int64(500), s.BytesOut)
+	assert.Equal(t, int64(2), s.NumRequests)
+	assert.Equal(t, map[string]int64{"insert": 1}, s.Failed)
+	assert.Equal(t, map[string]int64{"inserted": 3}, s.Documents)
+
+	for name, tc := range map[string]struct {
+		actual   map[string]int64
+		expected map[string]int64
+	}{
+		"OpCounters": {
+			actual: s.OpCounters(),
+			expected: map[string]int64{
+				"insert":  2,
+				"query":   3,
+				"update":  0,
+				"delete":  0,
+				"getmore": 2,
+				"command": 3,
+			},
+		},
+		"Commands": {
+			actual: s.Commands(),
+			expected: map[string]int64{
+				"find":     3,
+				"insert":   1,
+				"getmore":  1,
+				"ping":     1,
+				"ismaster": 1,
+			},
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.actual)
+		})
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// DefaultBatchSize is the number of documents returned in the first batch when batchSize is not set.
+const DefaultBatchSize = 101
+
+// CursorTimeout is the time after which idle cursors are closed, as MongoDB's cursorTimeoutMillis.
+const CursorTimeout = 10 * time.Minute
+
+// MaxCursorSize is the maximum total BSON size of documents buffered by a single cursor.
+//
+// Cursors are not backed by PostgreSQL cursors: all documents are read by the command that creates the cursor,
+// and documents that are not returned in the first batch are kept in memory until getMore returns them,
+// or the cursor is killed or expired.
+// Commands that would buffer more fail with ErrQueryExceededMemoryLimit;
+// clients should use more selective filters, projections, or limits instead.
+const MaxCursorSize = 100 * 1024 * 1024
+
+// cursorsCleanupInterval is the interval between idle cursors cleanups.
+const cursorsCleanupInterval = time.Minute
+
+// changeLogPollInterval is the interval of reading the change log or tables while waiting for notifications.
+//
+// Changes of transactions that were committed before older ones are not notified again,
+// so they are found by polling.
+const changeLogPollInterval = 100 * time.Millisecond
+
+// Tail is a source of new documents for tailable cursors, such as change streams.
+type Tail interface {
+	// Next returns up to batchSize new documents (zero batchSize means any number),
+	// waiting for them up to maxAwait if there are none yet.
+	// It returns done = true if the cursor should be closed after that.
+	Next(ctx context.Context, batchSize int64, maxAwait time.Duration) (docs []types.Document, done bool, err error)
+
+	// ResumeToken returns the resume token for the current position, if cursor supports resuming.
+	ResumeToken() (types.Document, bool)
+}
+
+// Cursor represents server-side cursor with documents that were not returned to the client yet.
+type Cursor struct {
+	ID int64
+	NS string
+
+	docs []types.Document
+
+	// protected by Cursors' lock
+	lastUsed time.Time
+	active   bool       // getMore on tailable cursor is in progress
+	session  *SessionID // owning session, if any
+
+	// for tailable cursors; tailM serializes concurrent getMores
+	tail  Tail
+	tailM sync.Mutex
+}
+
+// nextBatch returns up to batchSize remaining documents, and true if the cursor is exhausted after that.
+//
+// Zero batchSize means all remaining documents.
+func (c *Cursor) nextBatch(batchSize int64) (*types.Array, bool) {
+	n := int64(len(c.docs))
+	if batchSize > 0 && batchSize < n {
+		n = batchSize
+	}
+
+	batch := types.MakeArray(int(n))
+	for _, doc := range c.docs[:n] {
+		if err := batch.Append(doc); err != nil {
+			panic(err)
+		}
+	}
+
+	c.docs = c.docs[n:]
+
+	return batch, len(c.docs) == 0
+}
+
+// Cursors is a process-wide registry of open cursors.
+//
+// Cursors that were not used for CursorTimeout are closed by Run.
+//
+// It is safe for concurrent use.
+type Cursors struct {
+	maxSize int // MaxCursorSize, changed by tests
+
+	rw sync.RWMutex
+	m  map[int64]*Cursor
+}
+
+// NewCursors creates a new empty cursors registry.
+func NewCursors() *Cursors {
+	return &Cursors{
+		maxSize: MaxCursorSize,
+		m:       make(map[int64]*Cursor),
+	}
+}
+
+// FirstBatch returns the first batch of given documents for the given namespace.
+//
+// If not all documents fit into the first batch, and singleBatch is false,
+// the rest is stored in a new cursor, and its ID is returned.
+// Otherwise, the returned ID is 0.
+// Zero batchSize means an empty first batch, as in MongoDB.
+// It returns an error if the rest is larger than MaxCursorSize.
+func (cs *Cursors) FirstBatch(
+	ns string, docs []types.Document, batchSize int64, singleBatch bool,
+) (*types.Array, int64, error) {
+	c := &Cursor{
+		NS:       ns,
+		docs:     docs,
+		lastUsed: time.Now(),
+	}
+
+	batch := new(types.Array)
+	exhausted := len(docs) == 0
+	if batchSize > 0 {
+		batch, exhausted = c.nextBatch(batchSize)
+	}
+
+	if exhausted || singleBatch {
+		return batch, 0, nil
+	}
+
+	var size int
+	for _, doc := range c.docs {
+		b, err := bson.MustConvertDocument(doc).MarshalBinary()
+		if err != nil {
+			return nil, 0, lazyerrors.Error(err)
+		}
+
+		if size += len(b); size > cs.maxSize {
+			return nil, 0, NewErrorMessage(
+				ErrQueryExceededMemoryLimit,
+				"Cursor for %s would buffer more than the limit of %d bytes; use a more selective query or a limit",
+				ns, cs.maxSize,
+			)
+		}
+	}
+
+	return batch, cs.add(c), nil
+}
+
+// AddTail registers a new tailable cursor for the given namespace and returns its ID.
+func (cs *Cursors) AddTail(ns string, tail Tail) int64 {
+	return cs.add(&Cursor{
+		NS:       ns,
+		lastUsed: time.Now(),
+		tail:     tail,
+	})
+}
+
+// add assigns a new unique ID to the cursor and registers it.
+func (cs *Cursors) add(c *Cursor) int64 {
+	cs.rw.Lock()
+	defer cs.rw.Unlock()
+
+	for c.ID == 0 {
+		id := rand.Int63() //nolint:gosec // we don't need a cryptographically secure ID there
+		if _, ok := cs.m[id]; !ok {
+			c.ID = id
+		}
+	}
+	cs.m[c.ID] = c
+
+	return c.ID
+}
+
+// NextBatch returns the next batch of documents for the cursor with given ID and namespace.
+//
+// The returned ID is 0 if the cursor is exhausted and removed.
+// Zero batchSize means all remaining documents.
+// Tailable cursors wait for new documents up to maxAwait; other cursors ignore it.
+func (cs *Cursors) NextBatch(
+	ctx context.Context, id int64, ns string, batchSize int64, maxAwait time.Duration,
+) (*types.Array, int64, error) {
+	cs.rw.Lock()
+
+	c, ok := cs.m[id]
+	if !ok {
+		cs.rw.Unlock()
+		return nil, 0, NewErrorMessage(ErrCursorNotFound, "cursor id %d not found", id)
+	}
+
+	if c.NS != ns {
+		cs.rw.Unlock()
+		return nil, 0, NewErrorMessage(
+			ErrUnauthorized, "Requested getMore on namespace '%s', but cursor belongs to a different namespace %s", ns, c.NS,
+		)
+	}
+
+	c.lastUsed = time.Now()
+
+	if c.tail == nil {
+		defer cs.rw.Unlock()
+
+		batch, exhausted := c.nextBatch(batchSize)
+		if exhausted {
+			delete(cs.m, id)
+			return batch, 0, nil
+		}
+
+		return batch, id, nil
+	}
+
+	// do not block other cursors while waiting for new documents
+	c.active = true
+	cs.rw.Unlock()
+
+	c.tailM.Lock()
+	defer c.tailM.Unlock()
+
+	docs, done, err := c.tail.Next(ctx, batchSize, maxAwait)
+
+	cs.rw.Lock()
+	c.active = false
+	c.lastUsed = time.Now()
+	cs.rw.Unlock()
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	c.docs = docs
+	batch, _ := c.nextBatch(0)
+
+	if done {
+		cs.Kill(id)
+		return batch, 0, nil
+	}
+
+	return batch, id, nil
+}
+
+// ResumeToken returns the resume token of the tailable cursor with given ID, if it supports resuming.
+func (cs *Cursors) ResumeToken(id int64) (types.Document, bool) {
+	cs.rw.RLock()
+	c, ok := cs.m[id]
+	cs.rw.RUnlock()
+
+	if !ok || c.tail == nil {
+		return types.Document{}, false
+	}
+
+	c.tailM.Lock()
+	defer c.tailM.Unlock()
+
+	return c.tail.ResumeToken()
+}
+
+// Kill removes cursor with given ID and returns true if it existed.
+func (cs *Cursors) Kill(id int64) bool {
+	cs.rw.Lock()
+	defer cs.rw.Unlock()
+
+	_, ok := cs.m[id]
+	delete(cs.m, id)
+
+	return ok
+}
+
+// Expire removes cursors that were not used for CursorTimeout before now.
+//
+// It returns the number of removed cursors.
+func (cs *Cursors) Expire(now time.Time) int {
+	cs.rw.Lock()
+	defer cs.rw.Unlock()
+
+	var n int
+	for id, c := range cs.m {
+		if !c.active && now.Sub(c.lastUsed) >= CursorTimeout {
+			delete(cs.m, id)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Run removes idle cursors periodically until ctx is canceled.
+func (cs *Cursors) Run(ctx context.Context) {
+	ticker := time.NewTicker(cursorsCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cs.Expire(now)
+		}
+	}
+}
+
+// setSession marks the cursor with given ID as owned by the session.
+func (cs *Cursors) setSession(id int64, session SessionID) {
+	cs.rw.Lock()
+	defer cs.rw.Unlock()
+
+	if c, ok := cs.m[id]; ok {
+		c.session = &session
+	}
+}
+
+// killSessions removes cursors owned by given sessions.
+func (cs *Cursors) killSessions(sessions map[SessionID]struct{}) {
+	cs.rw.Lock()
+	defer cs.rw.Unlock()
+
+	for id, c := range cs.m {
+		if c.session == nil {
+			continue
+		}
+
+		if _, ok := sessions[*c.session]; ok {
+			delete(cs.m, id)
+		}
+	}
+}
+
+// Len returns the number of open cursors.
+func (cs *Cursors) Len() int {
+	cs.rw.RLock()
+	defer cs.rw.RUnlock()
+
+	return len(cs.m)
+}
+
+// AwaitChangeLog calls read until it returns some documents or done = true,
+// waiting for new change log records between calls up to maxAwait.
+// Zero maxAwait means a single call.
+func AwaitChangeLog(
+	ctx context.Context, pgPool *pg.Pool, maxAwait time.Duration, read func() ([]types.Document, bool, error),
+) ([]types.Document, bool, error) {
+	var timeout <-chan time.Time
+	if maxAwait > 0 {
+		timer := time.NewTimer(maxAwait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		// get the channel before reading, so changes committed after that are not missed
+		notify := pgPool.ChangeLogNotify()
+
+		docs, done, err := read()
+		if err != nil || len(docs) > 0 || done || timeout == nil {
+			return docs, done, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false, lazyerrors.Error(ctx.Err())
+		case <-timeout:
+			return nil, false, nil
+		case <-notify:
+		case <-time.After(changeLogPollInterval):
+		}
+	}
+}
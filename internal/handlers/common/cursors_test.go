@@ -0,0 +1,209 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+// testTail is a Tail that returns given documents and then is done.
+type testTail struct {
+	docs     []types.Document
+	pos      int
+	maxAwait time.Duration
+}
+
+func (tt *testTail) Next(ctx context.Context, batchSize int64, maxAwait time.Duration) ([]types.Document, bool, error) {
+	tt.maxAwait = maxAwait
+
+	n := len(tt.docs) - tt.pos
+	if batchSize > 0 && int(batchSize) < n {
+		n = int(batchSize)
+	}
+
+	res := tt.docs[tt.pos : tt.pos+n]
+	tt.pos += n
+
+	return res, tt.pos == len(tt.docs), nil
+}
+
+func (tt *testTail) ResumeToken() (types.Document, bool) {
+	return types.MustMakeDocument("pos", int32(tt.pos)), true
+}
+
+func TestCursors(t *testing.T) {
+	t.Parallel()
+
+	docs := []types.Document{
+		types.MustMakeDocument("_id", int32(1)),
+		types.MustMakeDocument("_id", int32(2)),
+		types.MustMakeDocument("_id", int32(3)),
+	}
+
+	t.Run("Exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		cs := NewCursors()
+		batch, id, err := cs.FirstBatch("db.c", docs, 101, false)
+		require.NoError(t, err)
+		assert.Equal(t, 3, batch.Len())
+		assert.Zero(t, id)
+		assert.Zero(t, cs.Len())
+	})
+
+	t.Run("SingleBatch", func(t *testing.T) {
+		t.Parallel()
+
+		cs := NewCursors()
+		batch, id, err := cs.FirstBatch("db.c", docs, 2, true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, batch.Len())
+		assert.Zero(t, id)
+		assert.Zero(t, cs.Len())
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		t.Parallel()
+
+		cs := NewCursors()
+		cs.maxSize = 20
+
+		// the first batch is not buffered
+		_, id, err := cs.FirstBatch("db.c", docs, 2, false)
+		require.NoError(t, err)
+		require.NotZero(t, id)
+
+		_, _, err = cs.FirstBatch("db.c", docs, 1, false)
+		var e *Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrQueryExceededMemoryLimit, e.code)
+		assert.Equal(t, 1, cs.Len())
+	})
+
+	t.Run("GetMore", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := testutil.Ctx(t)
+		cs := NewCursors()
+		batch, id, err := cs.FirstBatch("db.c", docs, 0, false)
+		require.NoError(t, err)
+		assert.Zero(t, batch.Len())
+		require.NotZero(t, id)
+		assert.Equal(t, 1, cs.Len())
+
+		_, _, err = cs.NextBatch(ctx, id, "db.other", 1, 0)
+		var e *Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrUnauthorized, e.code)
+
+		batch, nextID, err := cs.NextBatch(ctx, id, "db.c", 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, types.MustNewArray(docs[0], docs[1]), batch)
+		assert.Equal(t, id, nextID)
+
+		batch, nextID, err = cs.NextBatch(ctx, id, "db.c", 0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, types.MustNewArray(docs[2]), batch)
+		assert.Zero(t, nextID)
+		assert.Zero(t, cs.Len())
+
+		_, _, err = cs.NextBatch(ctx, id, "db.c", 0, 0)
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrCursorNotFound, e.code)
+	})
+
+	t.Run("Tail", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := testutil.Ctx(t)
+		cs := NewCursors()
+		tail := &testTail{docs: docs}
+		id := cs.AddTail("db.c", tail)
+		require.NotZero(t, id)
+
+		token, ok := cs.ResumeToken(id)
+		require.True(t, ok)
+		assert.Equal(t, types.MustMakeDocument("pos", int32(0)), token)
+
+		batch, nextID, err := cs.NextBatch(ctx, id, "db.c", 2, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, types.MustNewArray(docs[0], docs[1]), batch)
+		assert.Equal(t, id, nextID)
+		assert.Equal(t, time.Second, tail.maxAwait)
+
+		token, ok = cs.ResumeToken(id)
+		require.True(t, ok)
+		assert.Equal(t, types.MustMakeDocument("pos", int32(2)), token)
+
+		batch, nextID, err = cs.NextBatch(ctx, id, "db.c", 2, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, types.MustNewArray(docs[2]), batch)
+		assert.Zero(t, nextID, "tail is done")
+		assert.Zero(t, cs.Len())
+	})
+
+	t.Run("Kill", func(t *testing.T) {
+		t.Parallel()
+
+		cs := NewCursors()
+		_, id, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, id)
+		assert.True(t, cs.Kill(id))
+		assert.False(t, cs.Kill(id))
+		assert.Zero(t, cs.Len())
+	})
+	t.Run("Expire", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := testutil.Ctx(t)
+		cs := NewCursors()
+		_, idle, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, idle)
+		_, used, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, used)
+
+		start := time.Now()
+		assert.Zero(t, cs.Expire(start))
+		assert.Equal(t, 2, cs.Len())
+
+		time.Sleep(10 * time.Millisecond)
+		_, _, err = cs.NextBatch(ctx, used, "db.c", 1, 0)
+		require.NoError(t, err)
+
+		// only the cursor that was not used since start is idle for CursorTimeout there
+		assert.Equal(t, 1, cs.Expire(start.Add(CursorTimeout+5*time.Millisecond)))
+		assert.Equal(t, 1, cs.Len())
+
+		_, _, err = cs.NextBatch(ctx, idle, "db.c", 1, 0)
+		var e *Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrCursorNotFound, e.code)
+
+		assert.Equal(t, 1, cs.Expire(time.Now().Add(CursorTimeout)))
+		assert.Zero(t, cs.Len())
+	})
+}
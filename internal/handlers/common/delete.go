@@ -0,0 +1,204 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// WhereFunc returns SQL WHERE clause with its arguments for the given filter.
+type WhereFunc func(filter types.Document, p *pg.Placeholder) (sql string, args []any, err error)
+
+// DeleteParams represents a single statement of delete command.
+type DeleteParams struct {
+	Filter types.Document
+	Single bool // true for limit: 1, false for limit: 0 (all matching documents)
+	Hint   any  // index name, index key pattern document, or nil
+}
+
+// GetDeleteParams returns the parameters of the given statement of delete command's deletes array.
+func GetDeleteParams(statement types.Document) (*DeleteParams, error) {
+	var res DeleteParams
+
+	m := statement.Map()
+
+	q, ok := m["q"]
+	if !ok {
+		return nil, NewErrorMessage(ErrMissingField, "BSON field 'delete.deletes.q' is missing but a required field")
+	}
+	if res.Filter, ok = q.(types.Document); !ok {
+		return nil, NewErrorMessage(
+			ErrTypeMismatch, "BSON field 'delete.deletes.q' is the wrong type '%s', expected type 'object'", aliasFromType(q),
+		)
+	}
+
+	if _, ok = m["limit"]; !ok {
+		return nil, NewErrorMessage(ErrMissingField, "BSON field 'delete.deletes.limit' is missing but a required field")
+	}
+
+	limit, err := GetWholeNumberParam(statement, "limit", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	switch limit {
+	case 0:
+	case 1:
+		res.Single = true
+	default:
+		return nil, NewErrorMessage(ErrFailedToParse, "The limit field in delete objects must be 0 or 1. Got %d", limit)
+	}
+
+	if res.Hint, err = getHint(statement); err != nil {
+		return nil, err
+	}
+
+	if err = CheckCollation(statement); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Delete implements delete command for storages that differ only by the filter translation.
+//
+// Single-document deletes pick the first matching row ordered by the given SQL expression.
+func Delete(ctx context.Context, pgPool *pg.Pool, msg *wire.OpMsg, where WhereFunc, order string) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	collection := m[document.Command()].(string)
+	db := m["$db"].(string)
+	docs, _ := m["deletes"].(*types.Array)
+
+	ordered, err := GetBoolParam(document, "ordered", true)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := GetRetryableWrite(document)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := db + "." + collection
+	res, err := BulkWrite(ctx, pgPool, ns, docs.Len(), ordered, retry, func(tx pgx.Tx, i int) (int64, int64, error) {
+		doc, err := docs.Get(i)
+		if err != nil {
+			return 0, 0, lazyerrors.Error(err)
+		}
+
+		statement, ok := doc.(types.Document)
+		if !ok {
+			return 0, 0, NewErrorMessage(ErrTypeMismatch, "BSON field 'delete.deletes' is the wrong type")
+		}
+
+		params, err := GetDeleteParams(statement)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		table := pg.TableIdentifier(db, collection).Sanitize()
+		var placeholder pg.Placeholder
+
+		elSQL, args, err := where(params.Filter, &placeholder)
+		if err != nil {
+			return 0, 0, lazyerrors.Error(err)
+		}
+
+		if !params.Single {
+			return deleteRows(ctx, tx, "DELETE FROM "+table+elSQL, args)
+		}
+
+		// the row to delete is locked, so a concurrent update or delete can't change its ctid
+		sql := "DELETE FROM " + table + " WHERE ctid = (SELECT ctid FROM " + table + elSQL +
+			" ORDER BY " + order + " LIMIT 1 FOR UPDATE)"
+
+		return deleteRows(ctx, tx, sql, args)
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{res.Reply(false)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// deleteRows executes the given DELETE statement and returns the number of deleted rows.
+func deleteRows(ctx context.Context, tx pgx.Tx, sql string, args []any) (int64, int64, error) {
+	tag, err := tx.Exec(ctx, sql, args...)
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.UndefinedTable {
+		return 0, 0, NewErrorMessage(ErrNamespaceNotFound, "MsgDelete: ns not found: %s", e.Message)
+	}
+	if err != nil {
+		return 0, 0, lazyerrors.Error(err)
+	}
+
+	return tag.RowsAffected(), 0, nil
+}
+
+// CheckCollation checks the collation field of the given document.
+//
+// Only the simple binary comparison that PostgreSQL uses for jsonb and text values is supported.
+func CheckCollation(doc types.Document) error {
+	v, ok := doc.Map()["collation"]
+	if !ok {
+		return nil
+	}
+
+	collation, ok := v.(types.Document)
+	if !ok {
+		return NewErrorMessage(
+			ErrTypeMismatch, "BSON field 'collation' is the wrong type '%s', expected type 'object'", aliasFromType(v),
+		)
+	}
+
+	if len(collation.Keys()) == 0 {
+		return nil
+	}
+
+	locale, ok := collation.Map()["locale"]
+	if !ok {
+		return NewErrorMessage(ErrMissingField, "BSON field 'locale' is missing but a required field")
+	}
+
+	if locale != "simple" {
+		return NewErrorMessage(ErrNotImplemented, "collation locale %v is not implemented", locale)
+	}
+
+	return nil
+}
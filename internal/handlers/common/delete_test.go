@@ -0,0 +1,84 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestGetDeleteParams(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		statement types.Document
+		expected  *DeleteParams
+		err       error
+	}{
+		"All": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument(), "limit", int32(0)),
+			expected:  &DeleteParams{Filter: types.MustMakeDocument()},
+		},
+		"Single": {
+			statement: types.MustMakeDocument(
+				"q", types.MustMakeDocument("a", "b"),
+				"limit", int64(1),
+				"hint", "a_1",
+				"collation", types.MustMakeDocument("locale", "simple"),
+			),
+			expected: &DeleteParams{Filter: types.MustMakeDocument("a", "b"), Single: true, Hint: "a_1"},
+		},
+		"MissingQuery": {
+			statement: types.MustMakeDocument("limit", int32(0)),
+			err:       NewErrorMessage(ErrMissingField, "BSON field 'delete.deletes.q' is missing but a required field"),
+		},
+		"MissingLimit": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument()),
+			err:       NewErrorMessage(ErrMissingField, "BSON field 'delete.deletes.limit' is missing but a required field"),
+		},
+		"InvalidLimit": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument(), "limit", int32(5)),
+			err:       NewErrorMessage(ErrFailedToParse, "The limit field in delete objects must be 0 or 1. Got 5"),
+		},
+		"InvalidHint": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument(), "limit", int32(0), "hint", int32(1)),
+			err:       NewErrorMessage(ErrBadValue, "hint must be a string or an object, got int32"),
+		},
+		"Collation": {
+			statement: types.MustMakeDocument(
+				"q", types.MustMakeDocument(), "limit", int32(0), "collation", types.MustMakeDocument("locale", "fr"),
+			),
+			err: NewErrorMessage(ErrNotImplemented, "collation locale fr is not implemented"),
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := GetDeleteParams(tc.statement)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -30,18 +30,52 @@ const (
 	// For ProtocolError only.
 	errInternalError = ErrorCode(1) // InternalError
 
-	ErrBadValue          = ErrorCode(2)     // BadValue
-	ErrNamespaceNotFound = ErrorCode(26)    // NamespaceNotFound
-	ErrNamespaceExists   = ErrorCode(48)    // NamespaceExists
-	ErrCommandNotFound   = ErrorCode(59)    // CommandNotFound
-	ErrNotImplemented    = ErrorCode(238)   // NotImplemented
-	ErrRegexOptions      = ErrorCode(51075) // Location51075
+	ErrBadValue                   = ErrorCode(2)     // BadValue
+	ErrFailedToParse              = ErrorCode(9)     // FailedToParse
+	ErrUnauthorized               = ErrorCode(13)    // Unauthorized
+	ErrTypeMismatch               = ErrorCode(14)    // TypeMismatch
+	ErrInvalidLength              = ErrorCode(16)    // InvalidLength
+	ErrIllegalOperation           = ErrorCode(20)    // IllegalOperation
+	ErrNamespaceNotFound          = ErrorCode(26)    // NamespaceNotFound
+	ErrIndexNotFound              = ErrorCode(27)    // IndexNotFound
+	ErrPathNotViable              = ErrorCode(28)    // PathNotViable
+	ErrConflictingUpdateOperators = ErrorCode(40)    // ConflictingUpdateOperators
+	ErrCursorNotFound             = ErrorCode(43)    // CursorNotFound
+	ErrNamespaceExists            = ErrorCode(48)    // NamespaceExists
+	ErrMaxTimeMSExpired           = ErrorCode(50)    // MaxTimeMSExpired
+	ErrEmptyFieldName             = ErrorCode(56)    // EmptyFieldName
+	ErrCommandNotFound            = ErrorCode(59)    // CommandNotFound
+	ErrImmutableField             = ErrorCode(66)    // ImmutableField
+	ErrCannotCreateIndex          = ErrorCode(67)    // CannotCreateIndex
+	ErrInvalidOptions             = ErrorCode(72)    // InvalidOptions
+	ErrInvalidNamespace           = ErrorCode(73)    // InvalidNamespace
+	ErrIndexOptionsConflict       = ErrorCode(85)    // IndexOptionsConflict
+	ErrDocumentValidationFailure  = ErrorCode(121)   // DocumentValidationFailure
+	ErrViewDepthLimitExceeded     = ErrorCode(149)   // ViewDepthLimitExceeded
+	ErrCommandNotSupportedOnView  = ErrorCode(166)   // CommandNotSupportedOnView
+	ErrInvalidPipelineOperator    = ErrorCode(168)   // InvalidPipelineOperator
+	ErrTransactionTooOld          = ErrorCode(225)   // TransactionTooOld
+	ErrNotImplemented             = ErrorCode(238)   // NotImplemented
+	ErrInvalidResumeToken         = ErrorCode(260)   // InvalidResumeToken
+	ErrTooManyLogicalSessions     = ErrorCode(261)   // TooManyLogicalSessions
+	ErrChangeStreamFatalError     = ErrorCode(280)   // ChangeStreamFatalError
+	ErrChangeStreamHistoryLost    = ErrorCode(286)   // ChangeStreamHistoryLost
+	ErrQueryExceededMemoryLimit   = ErrorCode(292)   // QueryExceededMemoryLimitNoDiskUseAllowed
+	ErrDuplicateKey               = ErrorCode(11000) // DuplicateKey
+	ErrExpressionArgs             = ErrorCode(16020) // Location16020
+	ErrProjectionPathCollision    = ErrorCode(31249) // Location31249
+	ErrProjectionExIn             = ErrorCode(31253) // Location31253
+	ErrProjectionInEx             = ErrorCode(31254) // Location31254
+	ErrMissingField               = ErrorCode(40414) // Location40414
+	ErrRegexOptions               = ErrorCode(51075) // Location51075
+	ErrPositionalNoMatch          = ErrorCode(51246) // Location51246
 )
 
 // Error represents wire protocol error.
 type Error struct {
 	code ErrorCode
 	err  error
+	info *types.Document
 }
 
 // NewError creates a new wire protocol error.
@@ -70,6 +104,14 @@ func NewErrorMessage(code ErrorCode, msg string, args ...any) error {
 	return NewError(code, fmt.Errorf(msg, args...))
 }
 
+// NewErrorInfo creates a new wire protocol error with message and additional information
+// that is returned to the client in errInfo field.
+func NewErrorInfo(code ErrorCode, info types.Document, msg string, args ...any) error {
+	e := NewErrorMessage(code, msg, args...).(*Error)
+	e.info = &info
+	return e
+}
+
 // Error implements error interface.
 func (e *Error) Error() string {
 	return fmt.Sprintf("%[1]s (%[1]d): %[2]v", e.code, e.err)
@@ -80,14 +122,37 @@ func (e *Error) Unwrap() error {
 	return e.err
 }
 
+// Code returns wire protocol error code.
+func (e *Error) Code() ErrorCode {
+	return e.code
+}
+
+// Info returns additional error information, or nil.
+func (e *Error) Info() *types.Document {
+	return e.info
+}
+
 // Document returns wire protocol error document.
 func (e *Error) Document() types.Document {
-	return types.MustMakeDocument(
+	d := types.MustMakeDocument(
 		"ok", float64(0),
 		"errmsg", e.err.Error(),
 		"code", int32(e.code),
 		"codeName", e.code.String(),
 	)
+	if e.info != nil {
+		d.Set("errInfo", *e.info)
+	}
+
+	return d
+}
+
+// QueryFailureDocument returns error document for legacy OP_REPLY with QueryFailure flag.
+func (e *Error) QueryFailureDocument() types.Document {
+	return types.MustMakeDocument(
+		"$err", e.err.Error(),
+		"code", int32(e.code),
+	)
 }
 
 // ProtocolError converts any error to wire protocol error.
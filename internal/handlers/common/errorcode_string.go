@@ -10,42 +10,94 @@ func _() {
 	var x [1]struct{}
 	_ = x[errInternalError-1]
 	_ = x[ErrBadValue-2]
+	_ = x[ErrFailedToParse-9]
+	_ = x[ErrUnauthorized-13]
+	_ = x[ErrTypeMismatch-14]
+	_ = x[ErrInvalidLength-16]
+	_ = x[ErrIllegalOperation-20]
 	_ = x[ErrNamespaceNotFound-26]
+	_ = x[ErrIndexNotFound-27]
+	_ = x[ErrPathNotViable-28]
+	_ = x[ErrConflictingUpdateOperators-40]
+	_ = x[ErrCursorNotFound-43]
 	_ = x[ErrNamespaceExists-48]
+	_ = x[ErrMaxTimeMSExpired-50]
+	_ = x[ErrEmptyFieldName-56]
 	_ = x[ErrCommandNotFound-59]
+	_ = x[ErrImmutableField-66]
+	_ = x[ErrCannotCreateIndex-67]
+	_ = x[ErrInvalidOptions-72]
+	_ = x[ErrInvalidNamespace-73]
+	_ = x[ErrIndexOptionsConflict-85]
+	_ = x[ErrDocumentValidationFailure-121]
+	_ = x[ErrViewDepthLimitExceeded-149]
+	_ = x[ErrCommandNotSupportedOnView-166]
+	_ = x[ErrInvalidPipelineOperator-168]
+	_ = x[ErrTransactionTooOld-225]
 	_ = x[ErrNotImplemented-238]
+	_ = x[ErrInvalidResumeToken-260]
+	_ = x[ErrTooManyLogicalSessions-261]
+	_ = x[ErrChangeStreamFatalError-280]
+	_ = x[ErrChangeStreamHistoryLost-286]
+	_ = x[ErrQueryExceededMemoryLimit-292]
+	_ = x[ErrDuplicateKey-11000]
+	_ = x[ErrExpressionArgs-16020]
+	_ = x[ErrProjectionPathCollision-31249]
+	_ = x[ErrProjectionExIn-31253]
+	_ = x[ErrProjectionInEx-31254]
+	_ = x[ErrMissingField-40414]
 	_ = x[ErrRegexOptions-51075]
+	_ = x[ErrPositionalNoMatch-51246]
 }
 
-const (
-	_ErrorCode_name_0 = "InternalErrorBadValue"
-	_ErrorCode_name_1 = "NamespaceNotFound"
-	_ErrorCode_name_2 = "NamespaceExists"
-	_ErrorCode_name_3 = "CommandNotFound"
-	_ErrorCode_name_4 = "NotImplemented"
-	_ErrorCode_name_5 = "Location51075"
-)
+const _ErrorCode_name = "InternalErrorBadValueFailedToParseUnauthorizedTypeMismatchInvalidLengthIllegalOperationNamespaceNotFoundIndexNotFoundPathNotViableConflictingUpdateOperatorsCursorNotFoundNamespaceExistsMaxTimeMSExpiredEmptyFieldNameCommandNotFoundImmutableFieldCannotCreateIndexInvalidOptionsInvalidNamespaceIndexOptionsConflictDocumentValidationFailureViewDepthLimitExceededCommandNotSupportedOnViewInvalidPipelineOperatorTransactionTooOldNotImplementedInvalidResumeTokenTooManyLogicalSessionsChangeStreamFatalErrorChangeStreamHistoryLostQueryExceededMemoryLimitNoDiskUseAllowedDuplicateKeyLocation16020Location31249Location31253Location31254Location40414Location51075Location51246"
 
-var (
-	_ErrorCode_index_0 = [...]uint8{0, 13, 21}
-)
+var _ErrorCode_map = map[ErrorCode]string{
+	1:     _ErrorCode_name[0:13],
+	2:     _ErrorCode_name[13:21],
+	9:     _ErrorCode_name[21:34],
+	13:    _ErrorCode_name[34:46],
+	14:    _ErrorCode_name[46:58],
+	16:    _ErrorCode_name[58:71],
+	20:    _ErrorCode_name[71:87],
+	26:    _ErrorCode_name[87:104],
+	27:    _ErrorCode_name[104:117],
+	28:    _ErrorCode_name[117:130],
+	40:    _ErrorCode_name[130:156],
+	43:    _ErrorCode_name[156:170],
+	48:    _ErrorCode_name[170:185],
+	50:    _ErrorCode_name[185:201],
+	56:    _ErrorCode_name[201:215],
+	59:    _ErrorCode_name[215:230],
+	66:    _ErrorCode_name[230:244],
+	67:    _ErrorCode_name[244:261],
+	72:    _ErrorCode_name[261:275],
+	73:    _ErrorCode_name[275:291],
+	85:    _ErrorCode_name[291:311],
+	121:   _ErrorCode_name[311:336],
+	149:   _ErrorCode_name[336:358],
+	166:   _ErrorCode_name[358:383],
+	168:   _ErrorCode_name[383:406],
+	225:   _ErrorCode_name[406:423],
+	238:   _ErrorCode_name[423:437],
+	260:   _ErrorCode_name[437:455],
+	261:   _ErrorCode_name[455:477],
+	280:   _ErrorCode_name[477:499],
+	286:   _ErrorCode_name[499:522],
+	292:   _ErrorCode_name[522:562],
+	11000: _ErrorCode_name[562:574],
+	16020: _ErrorCode_name[574:587],
+	31249: _ErrorCode_name[587:600],
+	31253: _ErrorCode_name[600:613],
+	31254: _ErrorCode_name[613:626],
+	40414: _ErrorCode_name[626:639],
+	51075: _ErrorCode_name[639:652],
+	51246: _ErrorCode_name[652:665],
+}
 
 func (i ErrorCode) String() string {
-	switch {
-	case 1 <= i && i <= 2:
-		i -= 1
-		return _ErrorCode_name_0[_ErrorCode_index_0[i]:_ErrorCode_index_0[i+1]]
-	case i == 26:
-		return _ErrorCode_name_1
-	case i == 48:
-		return _ErrorCode_name_2
-	case i == 59:
-		return _ErrorCode_name_3
-	case i == 238:
-		return _ErrorCode_name_4
-	case i == 51075:
-		return _ErrorCode_name_5
-	default:
-		return "ErrorCode(" + strconv.FormatInt(int64(i), 10) + ")"
+	if str, ok := _ErrorCode_map[i]; ok {
+		return str
 	}
+	return "ErrorCode(" + strconv.FormatInt(int64(i), 10) + ")"
 }
@@ -0,0 +1,652 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// missingType represents the value of a missing field in aggregation expressions.
+//
+// It is different from null: fields with missing values are not set at all.
+type missingType struct{}
+
+// missing is the only value of missingType.
+var missing = missingType{}
+
+// EvaluateExpression evaluates the aggregation expression against the given document.
+//
+// Expression is a field path like "$a.b", a system variable like "$$ROOT",
+// an operator expression like {$add: ["$a", 1]}, a document or an array of expressions, or a literal value.
+// The second returned value is false if the expression evaluates to a missing value.
+func EvaluateExpression(doc types.Document, expr any) (any, bool, error) {
+	res, err := evaluate(doc, expr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if res == missing {
+		return nil, false, nil
+	}
+
+	return res, true, nil
+}
+
+// evaluate implements EvaluateExpression. It returns missing for missing values.
+func evaluate(doc types.Document, expr any) (any, error) {
+	switch expr := expr.(type) {
+	case string:
+		switch {
+		case strings.HasPrefix(expr, "$$"):
+			return evaluateVariable(doc, expr[2:])
+		case strings.HasPrefix(expr, "$"):
+			if len(expr) == 1 {
+				return nil, NewErrorMessage(ErrBadValue, "'$' by itself is not a valid FieldPath")
+			}
+			return getExpressionPath(doc, strings.Split(expr[1:], ".")), nil
+		default:
+			return expr, nil
+		}
+
+	case types.Document:
+		keys := expr.Keys()
+		if len(keys) > 0 && strings.HasPrefix(keys[0], "$") {
+			if len(keys) != 1 {
+				return nil, NewErrorMessage(
+					ErrBadValue,
+					"an expression specification must contain exactly one field, the name of the expression. Found %d fields",
+					len(keys),
+				)
+			}
+			return evaluateOperator(doc, keys[0], expr.Map()[keys[0]])
+		}
+
+		res := types.MustMakeDocument()
+		for _, k := range keys {
+			v, err := evaluate(doc, expr.Map()[k])
+			if err != nil {
+				return nil, err
+			}
+			if v == missing {
+				continue
+			}
+			if err = res.Set(k, v); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+		return res, nil
+
+	case *types.Array:
+		res := types.MakeArray(expr.Len())
+		for i := 0; i < expr.Len(); i++ {
+			el, _ := expr.Get(i)
+			v, err := evaluate(doc, el)
+			if err != nil {
+				return nil, err
+			}
+			if v == missing {
+				// missing values in arrays become null
+				v = nil
+			}
+			if err = res.Append(v); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+		return res, nil
+
+	default:
+		return expr, nil
+	}
+}
+
+// evaluateVariable returns the value of the system variable like ROOT or ROOT.a.b.
+func evaluateVariable(doc types.Document, variable string) (any, error) {
+	path := strings.Split(variable, ".")
+	switch path[0] {
+	case "ROOT", "CURRENT":
+		return getExpressionPath(doc, path[1:]), nil
+	default:
+		return nil, NewErrorMessage(ErrBadValue, "Use of undefined variable: %s", path[0])
+	}
+}
+
+// getExpressionPath returns the value at the given path.
+//
+// Unlike query filters, for arrays of documents it returns an array of values for all documents.
+func getExpressionPath(v any, path []string) any {
+	if len(path) == 0 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case types.Document:
+		next, ok := v.Map()[path[0]]
+		if !ok {
+			return missing
+		}
+		return getExpressionPath(next, path[1:])
+
+	case *types.Array:
+		res := types.MakeArray(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			el, _ := v.Get(i)
+			switch el.(type) {
+			case types.Document, *types.Array:
+				if r := getExpressionPath(el, path); r != missing {
+					if err := res.Append(r); err != nil {
+						panic(err)
+					}
+				}
+			}
+		}
+		return res
+
+	default:
+		return missing
+	}
+}
+
+// evaluateArgs evaluates operator arguments; a single non-array argument is allowed.
+//
+// If n is not negative, the exact number of arguments is checked.
+func evaluateArgs(doc types.Document, op string, operand any, n int) ([]any, error) {
+	var exprs []any
+	if arr, ok := operand.(*types.Array); ok {
+		for i := 0; i < arr.Len(); i++ {
+			el, _ := arr.Get(i)
+			exprs = append(exprs, el)
+		}
+	} else {
+		exprs = []any{operand}
+	}
+
+	if n >= 0 && len(exprs) != n {
+		return nil, NewErrorMessage(
+			ErrExpressionArgs, "Expression %s takes exactly %d arguments. %d were passed in.", op, n, len(exprs),
+		)
+	}
+
+	args := make([]any, len(exprs))
+	for i, e := range exprs {
+		v, err := evaluate(doc, e)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	return args, nil
+}
+
+// isNullish returns true for null and missing values.
+func isNullish(v any) bool {
+	return v == nil || v == missing
+}
+
+// evaluateOperator evaluates a single operator expression like {$add: [1, 2]}.
+//
+//nolint:goconst // $op is fine
+func evaluateOperator(doc types.Document, op string, operand any) (any, error) {
+	switch op {
+	case "$literal":
+		return operand, nil
+
+	case "$add", "$multiply":
+		args, err := evaluateArgs(doc, op, operand, -1)
+		if err != nil {
+			return nil, err
+		}
+		var res any = int32(0)
+		if op == "$multiply" {
+			res = int32(1)
+		}
+		for _, arg := range args {
+			if isNullish(arg) {
+				return nil, nil
+			}
+			if res, err = arithmetic(op, res, arg); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+
+	case "$subtract", "$divide", "$mod":
+		args, err := evaluateArgs(doc, op, operand, 2)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) || isNullish(args[1]) {
+			return nil, nil
+		}
+		return arithmetic(op, args[0], args[1])
+
+	case "$abs":
+		args, err := evaluateArgs(doc, op, operand, 1)
+		if err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case nil, missingType:
+			return nil, nil
+		case int32:
+			if v < 0 {
+				return normalizeInt(-int64(v)), nil
+			}
+			return v, nil
+		case int64:
+			if v < 0 {
+				if v == math.MinInt64 {
+					return nil, NewErrorMessage(ErrBadValue, "can't take $abs of long long min")
+				}
+				return -v, nil
+			}
+			return v, nil
+		case float64:
+			return math.Abs(v), nil
+		case types.Decimal128:
+			if v.Signbit() && !v.IsNaN() {
+				return v.Neg(), nil
+			}
+			return v, nil
+		default:
+			return nil, NewErrorMessage(ErrBadValue, "$abs only supports numeric types, not %s", aliasFromType(v))
+		}
+
+	case "$concat":
+		args, err := evaluateArgs(doc, op, operand, -1)
+		if err != nil {
+			return nil, err
+		}
+		var res string
+		for _, arg := range args {
+			switch arg := arg.(type) {
+			case nil, missingType:
+				return nil, nil
+			case string:
+				res += arg
+			default:
+				return nil, NewErrorMessage(ErrBadValue, "$concat only supports strings, not %s", aliasFromType(arg))
+			}
+		}
+		return res, nil
+
+	case "$toUpper", "$toLower":
+		args, err := evaluateArgs(doc, op, operand, 1)
+		if err != nil {
+			return nil, err
+		}
+		var s string
+		switch arg := args[0].(type) {
+		case nil, missingType:
+			return "", nil
+		case string:
+			s = arg
+		case int32, int64, float64, types.Decimal128:
+			s = fmt.Sprint(arg)
+		default:
+			return nil, NewErrorMessage(ErrBadValue, "can't convert from BSON type %s to String", aliasFromType(arg))
+		}
+		if op == "$toUpper" {
+			return strings.ToUpper(s), nil
+		}
+		return strings.ToLower(s), nil
+
+	case "$size":
+		args, err := evaluateArgs(doc, op, operand, 1)
+		if err != nil {
+			return nil, err
+		}
+		arr, ok := args[0].(*types.Array)
+		if !ok {
+			return nil, NewErrorMessage(
+				ErrBadValue, "The argument to $size must be an array. Type of the argument is: %s", aliasFromType(args[0]),
+			)
+		}
+		return int32(arr.Len()), nil
+
+	case "$arrayElemAt":
+		args, err := evaluateArgs(doc, op, operand, 2)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) || isNullish(args[1]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(*types.Array)
+		if !ok {
+			return nil, NewErrorMessage(
+				ErrBadValue, "$arrayElemAt's first argument must be an array, but is %s", aliasFromType(args[0]),
+			)
+		}
+		i, err := getWholeNumber(args[1])
+		if err != nil {
+			return nil, NewErrorMessage(ErrBadValue, "$arrayElemAt's second argument must be a numeric value")
+		}
+		if i < 0 {
+			i += int64(arr.Len())
+		}
+		v, err := arr.Get(int(i))
+		if err != nil {
+			return missing, nil
+		}
+		return v, nil
+
+	case "$ifNull":
+		args, err := evaluateArgs(doc, op, operand, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) < 2 {
+			return nil, NewErrorMessage(ErrBadValue, "$ifNull needs at least two arguments, had: %d", len(args))
+		}
+		for _, arg := range args[:len(args)-1] {
+			if !isNullish(arg) {
+				return arg, nil
+			}
+		}
+		return args[len(args)-1], nil
+
+	case "$cond":
+		var ifExpr, thenExpr, elseExpr any
+		switch operand := operand.(type) {
+		case types.Document:
+			m := operand.Map()
+			for _, k := range operand.Keys() {
+				if k != "if" && k != "then" && k != "else" {
+					return nil, NewErrorMessage(ErrBadValue, "Unrecognized parameter to $cond: %s", k)
+				}
+			}
+			for _, k := range []string{"if", "then", "else"} {
+				if _, ok := m[k]; !ok {
+					return nil, NewErrorMessage(ErrBadValue, "Missing '%s' parameter to $cond", k)
+				}
+			}
+			ifExpr, thenExpr, elseExpr = m["if"], m["then"], m["else"]
+		case *types.Array:
+			if operand.Len() != 3 {
+				return nil, NewErrorMessage(
+					ErrExpressionArgs, "Expression $cond takes exactly 3 arguments. %d were passed in.", operand.Len(),
+				)
+			}
+			ifExpr, _ = operand.Get(0)
+			thenExpr, _ = operand.Get(1)
+			elseExpr, _ = operand.Get(2)
+		default:
+			return nil, NewErrorMessage(ErrExpressionArgs, "Expression $cond takes exactly 3 arguments. 1 were passed in.")
+		}
+		cond, err := evaluate(doc, ifExpr)
+		if err != nil {
+			return nil, err
+		}
+		if cond != missing && isTruthy(cond) {
+			return evaluate(doc, thenExpr)
+		}
+		return evaluate(doc, elseExpr)
+
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$cmp":
+		args, err := evaluateArgs(doc, op, operand, 2)
+		if err != nil {
+			return nil, err
+		}
+		for i := range args {
+			if args[i] == missing {
+				args[i] = nil
+			}
+		}
+		c := types.CompareOrder(args[0], args[1])
+		switch op {
+		case "$eq":
+			return c == types.Equal, nil
+		case "$ne":
+			return c != types.Equal, nil
+		case "$gt":
+			return c == types.Greater, nil
+		case "$gte":
+			return c != types.Less, nil
+		case "$lt":
+			return c == types.Less, nil
+		case "$lte":
+			return c != types.Greater, nil
+		default:
+			return int32(c), nil
+		}
+
+	case "$and", "$or":
+		args, err := evaluateArgs(doc, op, operand, -1)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range args {
+			t := arg != missing && isTruthy(arg)
+			if op == "$and" && !t {
+				return false, nil
+			}
+			if op == "$or" && t {
+				return true, nil
+			}
+		}
+		return op == "$and", nil
+
+	case "$not":
+		args, err := evaluateArgs(doc, op, operand, 1)
+		if err != nil {
+			return nil, err
+		}
+		return args[0] == missing || !isTruthy(args[0]), nil
+
+	default:
+		return nil, NewErrorMessage(ErrInvalidPipelineOperator, "Unrecognized expression '%s'", op)
+	}
+}
+
+// normalizeInt returns int32 if the value fits, int64 otherwise.
+func normalizeInt(v int64) any {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return int32(v)
+	}
+	return v
+}
+
+// arithmetic performs arithmetic operation on two non-null values.
+//
+// Integer results are int32 or int64 depending on the operands' types and the result's size;
+// if int64 overflows, the result is double.
+func arithmetic(op string, a, b any) (any, error) {
+	if t, ok := a.(time.Time); ok {
+		switch op {
+		case "$add":
+			ms, err := getWholeNumber(b)
+			if err != nil {
+				return nil, NewErrorMessage(ErrBadValue, "only one date allowed in an $add expression")
+			}
+			return t.Add(time.Duration(ms) * time.Millisecond), nil
+		case "$subtract":
+			if bt, ok := b.(time.Time); ok {
+				return t.Sub(bt).Milliseconds(), nil
+			}
+			ms, err := getWholeNumber(b)
+			if err != nil {
+				return nil, NewErrorMessage(ErrBadValue, "can't $subtract %s from date", aliasFromType(b))
+			}
+			return t.Add(-time.Duration(ms) * time.Millisecond), nil
+		}
+	}
+	if _, ok := b.(time.Time); ok && op == "$add" {
+		return arithmetic(op, b, a)
+	}
+
+	for _, v := range []any{a, b} {
+		switch v.(type) {
+		case int32, int64, float64, types.Decimal128:
+		default:
+			return nil, NewErrorMessage(ErrBadValue, "%s only supports numeric types, not %s", op, aliasFromType(v))
+		}
+	}
+
+	_, aIsDecimal := a.(types.Decimal128)
+	_, bIsDecimal := b.(types.Decimal128)
+	if aIsDecimal || bIsDecimal {
+		return decimalArithmetic(op, toDecimal128(a), toDecimal128(b))
+	}
+
+	_, aIsFloat := a.(float64)
+	_, bIsFloat := b.(float64)
+	_, aIsLong := a.(int64)
+	_, bIsLong := b.(int64)
+
+	if op == "$divide" || aIsFloat || bIsFloat {
+		af, bf := toFloat64(a), toFloat64(b)
+		switch op {
+		case "$add":
+			return af + bf, nil
+		case "$subtract":
+			return af - bf, nil
+		case "$multiply":
+			return af * bf, nil
+		case "$divide":
+			if bf == 0 {
+				return nil, NewErrorMessage(ErrBadValue, "can't $divide by zero")
+			}
+			return af / bf, nil
+		case "$mod":
+			if bf == 0 {
+				return nil, NewErrorMessage(ErrBadValue, "can't $mod by zero")
+			}
+			return math.Mod(af, bf), nil
+		}
+	}
+
+	ai, bi := toInt64(a), toInt64(b)
+	var res int64
+	overflow := false
+
+	switch op {
+	case "$add":
+		res = ai + bi
+		overflow = (res > ai) != (bi > 0)
+	case "$subtract":
+		res = ai - bi
+		overflow = (res < ai) != (bi > 0)
+	case "$multiply":
+		res = ai * bi
+		overflow = ai != 0 && (res/ai != bi || (ai == -1 && bi == math.MinInt64))
+	case "$mod":
+		if bi == 0 {
+			return nil, NewErrorMessage(ErrBadValue, "can't $mod by zero")
+		}
+		if bi == -1 {
+			res = 0
+		} else {
+			res = ai % bi
+		}
+	}
+
+	if overflow {
+		return arithmetic(op, toFloat64(a), toFloat64(b))
+	}
+
+	if aIsLong || bIsLong {
+		return res, nil
+	}
+
+	return normalizeInt(res), nil
+}
+
+// decimalArithmetic performs arithmetic operation on two Decimal128 values.
+//
+// $divide and $mod are performed with float64 precision.
+func decimalArithmetic(op string, a, b types.Decimal128) (any, error) {
+	switch op {
+	case "$add":
+		return a.Add(b), nil
+	case "$subtract":
+		return a.Add(b.Neg()), nil
+	case "$multiply":
+		return a.Mul(b), nil
+	}
+
+	res, err := arithmetic(op, a.Float64(), b.Float64())
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewDecimal128FromFloat64(res.(float64)), nil
+}
+
+// toDecimal128 converts number to Decimal128.
+func toDecimal128(v any) types.Decimal128 {
+	switch v := v.(type) {
+	case int32:
+		return types.NewDecimal128FromInt64(int64(v))
+	case int64:
+		return types.NewDecimal128FromInt64(v)
+	case float64:
+		return types.NewDecimal128FromFloat64(v)
+	case types.Decimal128:
+		return v
+	default:
+		panic(fmt.Sprintf("toDecimal128: unexpected type %T", v))
+	}
+}
+
+// toFloat64 converts number to float64.
+func toFloat64(v any) float64 {
+	switch v := v.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case types.Decimal128:
+		return v.Float64()
+	default:
+		panic(fmt.Sprintf("toFloat64: unexpected type %T", v))
+	}
+}
+
+// toInt64 converts integer number to int64.
+func toInt64(v any) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	default:
+		panic(fmt.Sprintf("toInt64: unexpected type %T", v))
+	}
+}
+
+// aliasFromType returns BSON type alias of the given value, as used in error messages.
+func aliasFromType(v any) string {
+	if v == missing {
+		return "missing"
+	}
+
+	n := bsonTypeNumber(v)
+	for alias, number := range typeAliases {
+		if number == n {
+			return alias
+		}
+	}
+
+	return fmt.Sprintf("%T", v)
+}
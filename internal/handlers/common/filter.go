@@ -0,0 +1,687 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// FilterDocument returns true if the given document satisfies the given query filter.
+//
+// It implements the same semantics as SQL-based filtering of storages, but in Go.
+// It is used where filtering can't be done by PostgreSQL: for example,
+// by positional and $elemMatch projections.
+func FilterDocument(doc, filter types.Document) (bool, error) {
+	m := filter.Map()
+	for _, key := range filter.Keys() {
+		matches, err := filterDocumentPair(doc, key, m[key])
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// filterDocumentPair checks a single filter key/value pair against the document.
+func filterDocumentPair(doc types.Document, key string, value any) (bool, error) {
+	if strings.HasPrefix(key, "$") {
+		return filterLogical(doc, key, value)
+	}
+
+	values := GetPathValues(doc, key)
+
+	if expr, ok := value.(types.Document); ok && isOperatorDocument(expr) {
+		return filterOperators(values, expr)
+	}
+
+	return matchEq(values, value)
+}
+
+// filterLogical handles top-level $and, $or, and $nor operators.
+func filterLogical(doc types.Document, op string, value any) (bool, error) {
+	switch op {
+	case "$and", "$or", "$nor":
+		// handled below
+	case "$comment":
+		return true, nil
+	default:
+		return false, NewErrorMessage(ErrBadValue, "unknown top level operator: %s", op)
+	}
+
+	arr, ok := value.(*types.Array)
+	if !ok || arr.Len() == 0 {
+		return false, NewErrorMessage(ErrBadValue, "%s must be a nonempty array", op)
+	}
+
+	for i := 0; i < arr.Len(); i++ {
+		el, _ := arr.Get(i)
+		expr, ok := el.(types.Document)
+		if !ok {
+			return false, NewErrorMessage(ErrBadValue, "$or/$and/$nor entries need to be full objects")
+		}
+
+		matches, err := FilterDocument(doc, expr)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case op == "$and" && !matches:
+			return false, nil
+		case op == "$or" && matches:
+			return true, nil
+		case op == "$nor" && matches:
+			return false, nil
+		}
+	}
+
+	return op != "$or", nil
+}
+
+// isOperatorDocument returns true if the given filter value is a document with query operators
+// like {$gt: 42}, not a document to compare with.
+func isOperatorDocument(doc types.Document) bool {
+	keys := doc.Keys()
+	return len(keys) > 0 && strings.HasPrefix(keys[0], "$")
+}
+
+// GetPathValues returns all values at the given dot notation path.
+//
+// Arrays of documents on the path are traversed, so "a.b" returns values of b for all documents in a,
+// and numeric path elements are also used as array indexes, as in MongoDB.
+// An empty result means the path is missing.
+func GetPathValues(doc types.Document, path string) []any {
+	return getPathValues(doc, strings.Split(path, "."))
+}
+
+// getPathValues implements GetPathValues.
+func getPathValues(v any, path []string) []any {
+	if len(path) == 0 {
+		return []any{v}
+	}
+
+	switch v := v.(type) {
+	case types.Document:
+		next, ok := v.Map()[path[0]]
+		if !ok {
+			return nil
+		}
+		return getPathValues(next, path[1:])
+
+	case *types.Array:
+		var res []any
+		if i, err := strconv.Atoi(path[0]); err == nil {
+			if el, err := v.Get(i); err == nil {
+				res = append(res, getPathValues(el, path[1:])...)
+			}
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			el, _ := v.Get(i)
+			if _, ok := el.(types.Document); ok {
+				res = append(res, getPathValues(el, path)...)
+			}
+		}
+		return res
+
+	default:
+		return nil
+	}
+}
+
+// expandValues returns given values and elements of given arrays.
+//
+// Query operators match an array field if the array itself or any of its elements matches.
+func expandValues(values []any) []any {
+	res := make([]any, 0, len(values))
+	for _, v := range values {
+		res = append(res, v)
+		if arr, ok := v.(*types.Array); ok {
+			for i := 0; i < arr.Len(); i++ {
+				el, _ := arr.Get(i)
+				res = append(res, el)
+			}
+		}
+	}
+
+	return res
+}
+
+// matchEq returns true if any of the given values is equal to the given filter value.
+//
+// Regex filter value matches strings; null matches missing fields.
+func matchEq(values []any, filterValue any) (bool, error) {
+	if filterValue == nil && len(values) == 0 {
+		return true, nil
+	}
+
+	if regex, ok := filterValue.(types.Regex); ok {
+		return matchRegex(values, regex)
+	}
+
+	for _, v := range expandValues(values) {
+		if types.Compare(v, filterValue) == types.Equal {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchRegex returns true if any of the given string values matches the given regular expression.
+func matchRegex(values []any, regex types.Regex) (bool, error) {
+	re, err := compileRegex(regex)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range expandValues(values) {
+		switch v := v.(type) {
+		case string:
+			if re.MatchString(v) {
+				return true, nil
+			}
+		case types.Regex:
+			if v == regex {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// compileRegex compiles BSON regular expression to Go regular expression.
+func compileRegex(regex types.Regex) (*regexp.Regexp, error) {
+	var flags string
+	for _, o := range regex.Options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		default:
+			return nil, NewErrorMessage(ErrBadValue, "invalid flag in regex options: %c", o)
+		}
+	}
+
+	pattern := regex.Pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, NewErrorMessage(ErrRegexOptions, "Regular expression is invalid: %s", err)
+	}
+
+	return re, nil
+}
+
+// filterOperators returns true if given values satisfy all query operators of the given document.
+//
+//nolint:goconst // $op is fine
+func filterOperators(values []any, expr types.Document) (bool, error) {
+	m := expr.Map()
+	for _, op := range expr.Keys() {
+		operand := m[op]
+
+		var matches bool
+		var err error
+
+		switch op {
+		case "$eq":
+			matches, err = matchEq(values, operand)
+
+		case "$ne":
+			matches, err = matchEq(values, operand)
+			matches = !matches
+
+		case "$gt", "$gte", "$lt", "$lte":
+			matches = matchCompare(values, op, operand)
+
+		case "$in", "$nin":
+			arr, ok := operand.(*types.Array)
+			if !ok {
+				return false, NewErrorMessage(ErrBadValue, "%s needs an array", op)
+			}
+			for i := 0; i < arr.Len() && !matches; i++ {
+				el, _ := arr.Get(i)
+				if matches, err = matchEq(values, el); err != nil {
+					return false, err
+				}
+			}
+			if op == "$nin" {
+				matches = !matches
+			}
+
+		case "$exists":
+			matches = (len(values) > 0) == isTruthy(operand)
+
+		case "$type":
+			matches, err = matchType(values, operand)
+
+		case "$regex":
+			var regex types.Regex
+			if regex, err = getRegexOperand(expr); err == nil {
+				matches, err = matchRegex(values, regex)
+			}
+
+		case "$options":
+			if _, ok := m["$regex"]; !ok {
+				return false, NewErrorMessage(ErrBadValue, "$options needs a $regex")
+			}
+			matches = true
+
+		case "$not":
+			switch operand := operand.(type) {
+			case types.Document:
+				matches, err = filterOperators(values, operand)
+			case types.Regex:
+				matches, err = matchRegex(values, operand)
+			default:
+				return false, NewErrorMessage(ErrBadValue, "$not needs a regex or a document")
+			}
+			matches = !matches
+
+		case "$elemMatch":
+			operand, ok := operand.(types.Document)
+			if !ok {
+				return false, NewErrorMessage(ErrBadValue, "$elemMatch needs an Object")
+			}
+			for _, v := range values {
+				arr, ok := v.(*types.Array)
+				if !ok {
+					continue
+				}
+				var i int
+				if i, err = ElemMatchIndex(arr, operand); err != nil {
+					return false, err
+				}
+				if i >= 0 {
+					matches = true
+					break
+				}
+			}
+
+		case "$size":
+			var size int64
+			if size, err = getWholeNumber(operand); err != nil {
+				return false, NewErrorMessage(ErrBadValue, "$size needs a number")
+			}
+			for _, v := range values {
+				if arr, ok := v.(*types.Array); ok && int64(arr.Len()) == size {
+					matches = true
+					break
+				}
+			}
+
+		case "$all":
+			arr, ok := operand.(*types.Array)
+			if !ok {
+				return false, NewErrorMessage(ErrBadValue, "$all needs an array")
+			}
+			matches = arr.Len() > 0
+			for i := 0; i < arr.Len() && matches; i++ {
+				el, _ := arr.Get(i)
+				if matches, err = matchEq(values, el); err != nil {
+					return false, err
+				}
+			}
+
+		case "$mod":
+			matches, err = matchMod(values, operand)
+
+		default:
+			return false, NewErrorMessage(ErrBadValue, "unknown operator: %s", op)
+		}
+
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// ElemMatchIndex returns the index of the first array element that matches the given $elemMatch expression,
+// or -1 if there is no such element.
+//
+// The expression is either a query filter for document elements, like {a: 1},
+// or query operators for any elements, like {$gt: 1}.
+func ElemMatchIndex(arr *types.Array, expr types.Document) (int, error) {
+	for i := 0; i < arr.Len(); i++ {
+		el, _ := arr.Get(i)
+
+		var matches bool
+		var err error
+
+		if isOperatorDocument(expr) && !isLogicalOperator(expr.Keys()[0]) {
+			// unlike query operators on fields, $elemMatch doesn't look into nested arrays
+			matches, err = filterOperators([]any{el}, expr)
+		} else if doc, ok := el.(types.Document); ok {
+			matches, err = FilterDocument(doc, expr)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+		if matches {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// isLogicalOperator returns true for top-level logical query operators.
+func isLogicalOperator(op string) bool {
+	switch op {
+	case "$and", "$or", "$nor":
+		return true
+	default:
+		return false
+	}
+}
+
+// getRegexOperand returns regular expression for {$regex: value, $options: string} expression.
+func getRegexOperand(expr types.Document) (types.Regex, error) {
+	m := expr.Map()
+
+	var options string
+	if opts, ok := m["$options"]; ok {
+		if options, ok = opts.(string); !ok {
+			return types.Regex{}, NewErrorMessage(ErrBadValue, "$options has to be a string")
+		}
+	}
+
+	switch value := m["$regex"].(type) {
+	case string:
+		return types.Regex{Pattern: value, Options: options}, nil
+	case types.Regex:
+		if options != "" {
+			if value.Options != "" {
+				return types.Regex{}, NewErrorMessage(ErrRegexOptions, "options set in both $regex and $options")
+			}
+			value.Options = options
+		}
+		return value, nil
+	default:
+		return types.Regex{}, NewErrorMessage(ErrBadValue, "$regex has to be a string")
+	}
+}
+
+// matchCompare returns true if any of the given values satisfies comparison operator.
+func matchCompare(values []any, op string, operand any) bool {
+	for _, v := range expandValues(values) {
+		c := types.Compare(v, operand)
+		if c == types.Incomparable {
+			continue
+		}
+
+		switch op {
+		case "$gt":
+			if c == types.Greater {
+				return true
+			}
+		case "$gte":
+			if c == types.Greater || c == types.Equal {
+				return true
+			}
+		case "$lt":
+			if c == types.Less {
+				return true
+			}
+		case "$lte":
+			if c == types.Less || c == types.Equal {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchMod handles {$mod: [divisor, remainder]} operator.
+func matchMod(values []any, operand any) (bool, error) {
+	arr, ok := operand.(*types.Array)
+	if !ok || arr.Len() != 2 {
+		return false, NewErrorMessage(ErrBadValue, "malformed mod, needs to be an array of two numbers")
+	}
+
+	d, _ := arr.Get(0)
+	r, _ := arr.Get(1)
+
+	divisor, err := getWholeNumber(d)
+	if err != nil {
+		return false, NewErrorMessage(ErrBadValue, "malformed mod, divisor not a number")
+	}
+	remainder, err := getWholeNumber(r)
+	if err != nil {
+		return false, NewErrorMessage(ErrBadValue, "malformed mod, remainder not a number")
+	}
+	if divisor == 0 {
+		return false, NewErrorMessage(ErrBadValue, "divisor cannot be 0")
+	}
+
+	for _, v := range expandValues(values) {
+		var n int64
+		switch v := v.(type) {
+		case int32:
+			n = int64(v)
+		case int64:
+			n = v
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			n = int64(v)
+		case types.Decimal128:
+			if v.IsNaN() || v.IsInf() != 0 {
+				continue
+			}
+			// truncate toward zero, as for doubles
+			r := v.Rat()
+			q := new(big.Int).Quo(r.Num(), r.Denom())
+			if !q.IsInt64() {
+				continue
+			}
+			n = q.Int64()
+		default:
+			continue
+		}
+
+		if n%divisor == remainder {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getWholeNumber returns number without a fractional part as int64.
+func getWholeNumber(v any) (int64, error) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return 0, NewErrorMessage(ErrBadValue, "Expected an integer: %v", v)
+		}
+		return int64(v), nil
+	case types.Decimal128:
+		if v.IsNaN() || v.IsInf() != 0 || !v.Rat().IsInt() || !v.Rat().Num().IsInt64() {
+			return 0, NewErrorMessage(ErrBadValue, "Expected an integer: %s", v)
+		}
+		return v.Rat().Num().Int64(), nil
+	default:
+		return 0, NewErrorMessage(ErrTypeMismatch, "Expected a number, got %T", v)
+	}
+}
+
+// isTruthy returns false for false, null, and zero numbers, and true for everything else.
+func isTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case types.Decimal128:
+		if v.IsNaN() || v.IsInf() != 0 {
+			return true
+		}
+		coef, _ := v.Parts()
+		return coef.Sign() != 0
+	default:
+		return true
+	}
+}
+
+// typeAliases maps $type operator aliases to BSON type numbers.
+var typeAliases = map[string]int32{
+	"double":              1,
+	"string":              2,
+	"object":              3,
+	"array":               4,
+	"binData":             5,
+	"undefined":           6,
+	"objectId":            7,
+	"bool":                8,
+	"date":                9,
+	"null":                10,
+	"regex":               11,
+	"dbPointer":           12,
+	"javascript":          13,
+	"symbol":              14,
+	"javascriptWithScope": 15,
+	"int":                 16,
+	"timestamp":           17,
+	"long":                18,
+	"decimal":             19,
+	"minKey":              -1,
+	"maxKey":              127,
+}
+
+// bsonTypeNumber returns BSON type number of the given value.
+func bsonTypeNumber(v any) int32 {
+	switch v.(type) {
+	case float64:
+		return 1
+	case string, types.CString:
+		return 2
+	case types.Document:
+		return 3
+	case *types.Array:
+		return 4
+	case types.Binary:
+		return 5
+	case types.Undefined:
+		return 6
+	case types.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case time.Time:
+		return 9
+	case nil:
+		return 10
+	case types.Regex:
+		return 11
+	case types.DBPointer:
+		return 12
+	case types.JavaScript:
+		return 13
+	case types.Symbol:
+		return 14
+	case types.JavaScriptScope:
+		return 15
+	case int32:
+		return 16
+	case types.Timestamp:
+		return 17
+	case int64:
+		return 18
+	case types.Decimal128:
+		return 19
+	case types.MinKey:
+		return -1
+	case types.MaxKey:
+		return 127
+	default:
+		return 0
+	}
+}
+
+// matchType handles {$type: type} operator, where type is an alias, a number, or an array of them.
+func matchType(values []any, operand any) (bool, error) {
+	var wanted []any
+	if arr, ok := operand.(*types.Array); ok {
+		for i := 0; i < arr.Len(); i++ {
+			el, _ := arr.Get(i)
+			wanted = append(wanted, el)
+		}
+	} else {
+		wanted = []any{operand}
+	}
+
+	for _, w := range wanted {
+		var number int32
+		isNumber := false
+
+		switch w := w.(type) {
+		case string:
+			if w == "number" {
+				isNumber = true
+				break
+			}
+			var ok bool
+			if number, ok = typeAliases[w]; !ok {
+				return false, NewErrorMessage(ErrBadValue, "Unknown type name alias: %s", w)
+			}
+		default:
+			n, err := getWholeNumber(w)
+			if err != nil {
+				return false, NewErrorMessage(ErrBadValue, "type must be represented as a number or a string")
+			}
+			number = int32(n)
+		}
+
+		for _, v := range expandValues(values) {
+			t := bsonTypeNumber(v)
+			if isNumber && (t == 1 || t == 16 || t == 18 || t == 19) {
+				return true, nil
+			}
+			if t == number {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
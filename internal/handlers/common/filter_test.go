@@ -0,0 +1,93 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestFilterDocument(t *testing.T) {
+	t.Parallel()
+
+	doc := types.MustMakeDocument(
+		"name", "foo",
+		"value", int32(42),
+		"tags", types.MustNewArray("a", "b"),
+		"items", types.MustNewArray(
+			types.MustMakeDocument("sku", "x", "qty", int32(1)),
+			types.MustMakeDocument("sku", "y", "qty", int32(5)),
+		),
+		"null", nil,
+	)
+
+	for name, tc := range map[string]struct {
+		filter   types.Document
+		expected bool
+	}{
+		"Empty":          {types.MustMakeDocument(), true},
+		"Eq":             {types.MustMakeDocument("name", "foo"), true},
+		"EqNumbers":      {types.MustMakeDocument("value", 42.0), true},
+		"EqArrayElement": {types.MustMakeDocument("tags", "b"), true},
+		"EqArray":        {types.MustMakeDocument("tags", types.MustNewArray("a", "b")), true},
+		"EqNull":         {types.MustMakeDocument("missing", nil), true},
+		"Ne":             {types.MustMakeDocument("name", types.MustMakeDocument("$ne", "foo")), false},
+		"Gt":             {types.MustMakeDocument("value", types.MustMakeDocument("$gt", int64(41))), true},
+		"GtBracketing":   {types.MustMakeDocument("value", types.MustMakeDocument("$gt", "41")), false},
+		"DotArray":       {types.MustMakeDocument("items.qty", types.MustMakeDocument("$gte", int32(5))), true},
+		"DotIndex":       {types.MustMakeDocument("items.0.sku", "y"), false},
+		"In":             {types.MustMakeDocument("tags", types.MustMakeDocument("$in", types.MustNewArray("c", "a"))), true},
+		"Nin":            {types.MustMakeDocument("tags", types.MustMakeDocument("$nin", types.MustNewArray("c", "a"))), false},
+		"Exists":         {types.MustMakeDocument("null", types.MustMakeDocument("$exists", true)), true},
+		"NotExists":      {types.MustMakeDocument("missing", types.MustMakeDocument("$exists", false)), true},
+		"Regex":          {types.MustMakeDocument("name", types.Regex{Pattern: "^F", Options: "i"}), true},
+		"RegexOperator":  {types.MustMakeDocument("name", types.MustMakeDocument("$regex", "^f", "$options", "")), true},
+		"Not": {types.MustMakeDocument("value", types.MustMakeDocument(
+			"$not", types.MustMakeDocument("$lt", int32(10)),
+		)), true},
+		"Size":       {types.MustMakeDocument("tags", types.MustMakeDocument("$size", int32(2))), true},
+		"All":        {types.MustMakeDocument("tags", types.MustMakeDocument("$all", types.MustNewArray("b", "a"))), true},
+		"Type":       {types.MustMakeDocument("value", types.MustMakeDocument("$type", "number")), true},
+		"TypeMinKey": {types.MustMakeDocument("value", types.MustMakeDocument("$type", "minKey")), false},
+		"GtMinKey":   {types.MustMakeDocument("name", types.MustMakeDocument("$gt", types.MinKey{})), true},
+		"LtMaxKey":   {types.MustMakeDocument("value", types.MustMakeDocument("$lt", types.MaxKey{})), true},
+		"Mod": {types.MustMakeDocument("value", types.MustMakeDocument(
+			"$mod", types.MustNewArray(int32(5), int32(2)),
+		)), true},
+		"ElemMatch": {types.MustMakeDocument("items", types.MustMakeDocument("$elemMatch", types.MustMakeDocument(
+			"sku", "x", "qty", types.MustMakeDocument("$gt", int32(2)),
+		))), false},
+		"Or": {types.MustMakeDocument("$or", types.MustNewArray(
+			types.MustMakeDocument("name", "bar"),
+			types.MustMakeDocument("value", int32(42)),
+		)), true},
+		"Nor": {types.MustMakeDocument("$nor", types.MustNewArray(
+			types.MustMakeDocument("name", "bar"),
+		)), true},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := FilterDocument(doc, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// FindOptions represents find and count command options that are handled the same way by all storages.
+type FindOptions struct {
+	Skip        int64 // non-negative
+	Limit       int64 // non-negative; 0 means no limit
+	BatchSize   int64 // non-negative
+	SingleBatch bool
+	Comment     string
+	MaxTime     time.Duration // 0 means no limit
+	Hint        any           // index name, index key pattern document, or nil
+	Tailable    bool
+	AwaitData   bool // only with Tailable
+}
+
+// GetFindOptions returns find or count command options from the given document.
+//
+// Negative limit means a single batch of at most that many documents, as in MongoDB.
+func GetFindOptions(doc types.Document) (*FindOptions, error) {
+	var res FindOptions
+	var err error
+
+	if res.Skip, err = GetWholeNumberParam(doc, "skip", 0); err != nil {
+		return nil, err
+	}
+	if res.Skip < 0 {
+		return nil, NewErrorMessage(ErrBadValue, "Skip value must be non-negative, but received: %d", res.Skip)
+	}
+
+	if res.Limit, err = GetWholeNumberParam(doc, "limit", 0); err != nil {
+		return nil, err
+	}
+	if res.Limit < 0 {
+		res.Limit = -res.Limit
+		res.SingleBatch = true
+	}
+
+	if res.BatchSize, err = GetWholeNumberParam(doc, "batchSize", DefaultBatchSize); err != nil {
+		return nil, err
+	}
+	if res.BatchSize < 0 {
+		return nil, NewErrorMessage(ErrBadValue, "BatchSize value must be non-negative, but received: %d", res.BatchSize)
+	}
+
+	singleBatch, err := GetBoolParam(doc, "singleBatch", false)
+	if err != nil {
+		return nil, err
+	}
+	res.SingleBatch = res.SingleBatch || singleBatch
+
+	maxTimeMS, err := GetWholeNumberParam(doc, "maxTimeMS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if maxTimeMS < 0 {
+		return nil, NewErrorMessage(ErrBadValue, "%d value for maxTimeMS is out of range", maxTimeMS)
+	}
+	res.MaxTime = time.Duration(maxTimeMS) * time.Millisecond
+
+	res.Comment, err = GetComment(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Hint, err = getHint(doc); err != nil {
+		return nil, err
+	}
+
+	if res.Tailable, err = GetBoolParam(doc, "tailable", false); err != nil {
+		return nil, err
+	}
+	if res.AwaitData, err = GetBoolParam(doc, "awaitData", false); err != nil {
+		return nil, err
+	}
+	if res.AwaitData && !res.Tailable {
+		return nil, NewErrorMessage(ErrFailedToParse, "Cannot set 'awaitData' without also setting 'tailable'")
+	}
+
+	return &res, nil
+}
+
+// getHint returns the validated hint field of the given document: index name, index key pattern document, or nil.
+//
+// PostgreSQL planner chooses indexes itself, so hint is only validated.
+func getHint(doc types.Document) (any, error) {
+	switch hint := doc.Map()["hint"].(type) {
+	case nil, string:
+		return hint, nil
+	case types.Document:
+		if len(hint.Keys()) == 0 {
+			return nil, nil
+		}
+		return hint, nil
+	default:
+		return nil, NewErrorMessage(ErrBadValue, "hint must be a string or an object, got %T", hint)
+	}
+}
+
+// CountSkipLimit applies skip and limit options to the number of all matching documents.
+func (opts *FindOptions) CountSkipLimit(n int64) int64 {
+	n -= opts.Skip
+	if n < 0 {
+		n = 0
+	}
+
+	if opts.Limit != 0 && n > opts.Limit {
+		n = opts.Limit
+	}
+
+	return n
+}
+
+// CountValue returns count command's n value: int32 if it fits, int64 otherwise.
+func CountValue(n int64) any {
+	if n > math.MaxInt32 {
+		return n
+	}
+
+	return int32(n)
+}
+
+// GetComment returns the command's comment as a string.
+//
+// Non-string comments (MongoDB allows any BSON value there) are converted to their fjson representation.
+func GetComment(doc types.Document) (string, error) {
+	v, ok := doc.Map()["comment"]
+	if !ok {
+		return "", nil
+	}
+
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	b, err := fjson.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("GetComment: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// MaxTimeMSExpired returns the error for the operation that exceeded its time limit.
+func MaxTimeMSExpired() error {
+	return NewErrorMessage(ErrMaxTimeMSExpired, "operation exceeded time limit")
+}
+
+// TailableNotCapped returns the error for tailable find on a collection that is not capped.
+func TailableNotCapped(ns string) error {
+	return NewErrorMessage(ErrBadValue, "error processing query: ns=%s: tailable cursor requested on non capped collection", ns)
+}
@@ -0,0 +1,706 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// schemaRule checks a single $jsonSchema keyword against the value.
+//
+// It returns details of the failure, or nil if the value satisfies the keyword.
+type schemaRule func(v any) *types.Document
+
+// jsonSchema represents a parsed $jsonSchema validator.
+//
+// Only a subset of JSON Schema draft 4 keywords supported by MongoDB is implemented.
+type jsonSchema struct {
+	rules []schemaRule
+}
+
+// jsonTypes maps JSON Schema types to BSON type aliases.
+var jsonTypes = map[string]string{
+	"object":  "object",
+	"array":   "array",
+	"number":  "number",
+	"boolean": "bool",
+	"string":  "string",
+	"null":    "null",
+}
+
+// newJSONSchema parses $jsonSchema specification.
+func newJSONSchema(spec types.Document) (*jsonSchema, error) {
+	m := spec.Map()
+
+	if _, ok := m["type"]; ok {
+		if _, ok = m["bsonType"]; ok {
+			return nil, NewErrorMessage(
+				ErrFailedToParse, "Cannot specify both $jsonSchema keywords 'type' and 'bsonType'",
+			)
+		}
+	}
+
+	var s jsonSchema
+	for _, k := range spec.Keys() {
+		rule, err := newSchemaRule(spec, k)
+		if err != nil {
+			return nil, err
+		}
+		if rule != nil {
+			s.rules = append(s.rules, rule)
+		}
+	}
+
+	return &s, nil
+}
+
+// validate returns details of all failed rules, or nil if the value satisfies the schema.
+func (s *jsonSchema) validate(v any) []any {
+	var res []any
+	for _, rule := range s.rules {
+		if d := rule(v); d != nil {
+			res = append(res, *d)
+		}
+	}
+
+	return res
+}
+
+// newSchemaRule returns the rule for the given keyword of $jsonSchema specification;
+// nil rule is returned for annotation keywords.
+//
+//nolint:gocyclo // one case per keyword
+func newSchemaRule(spec types.Document, keyword string) (schemaRule, error) {
+	m := spec.Map()
+	value := m[keyword]
+	specifiedAs := types.MustMakeDocument(keyword, value)
+
+	failure := func(pairs ...any) *types.Document {
+		d := types.MustMakeDocument(append([]any{"operatorName", keyword, "specifiedAs", specifiedAs}, pairs...)...)
+		return &d
+	}
+
+	switch keyword {
+	case "title", "description":
+		if _, ok := value.(string); !ok {
+			return nil, schemaTypeError(keyword, "a string")
+		}
+		return nil, nil
+
+	case "bsonType", "type":
+		aliases, err := schemaTypes(keyword, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			for _, alias := range aliases {
+				if matchesAlias(v, alias) {
+					return nil
+				}
+			}
+			return failure("reason", "type did not match", "consideredValue", v, "consideredType", aliasFromType(v))
+		}, nil
+
+	case "required":
+		names, err := schemaStrings(keyword, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			doc, ok := v.(types.Document)
+			if !ok {
+				return nil
+			}
+
+			docM := doc.Map()
+			var missingProperties []any
+			for _, name := range names {
+				if _, ok := docM[name]; !ok {
+					missingProperties = append(missingProperties, name)
+				}
+			}
+			if missingProperties == nil {
+				return nil
+			}
+
+			return failure("missingProperties", types.MustNewArray(missingProperties...))
+		}, nil
+
+	case "properties":
+		properties, names, err := schemaProperties(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			doc, ok := v.(types.Document)
+			if !ok {
+				return nil
+			}
+
+			docM := doc.Map()
+			var propertiesNotSatisfied []any
+			for _, name := range names {
+				pv, ok := docM[name]
+				if !ok {
+					continue
+				}
+
+				if details := properties[name].validate(pv); details != nil {
+					propertiesNotSatisfied = append(propertiesNotSatisfied, types.MustMakeDocument(
+						"propertyName", name,
+						"details", types.MustNewArray(details...),
+					))
+				}
+			}
+			if propertiesNotSatisfied == nil {
+				return nil
+			}
+
+			d := types.MustMakeDocument(
+				"operatorName", keyword,
+				"propertiesNotSatisfied", types.MustNewArray(propertiesNotSatisfied...),
+			)
+			return &d
+		}, nil
+
+	case "additionalProperties":
+		var known map[string]*jsonSchema
+		if p, ok := m["properties"]; ok {
+			var err error
+			if known, _, err = schemaProperties(p); err != nil {
+				return nil, err
+			}
+		}
+
+		var additional *jsonSchema
+		switch value := value.(type) {
+		case bool:
+			if value {
+				return nil, nil
+			}
+		case types.Document:
+			var err error
+			if additional, err = newJSONSchema(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, schemaTypeError(keyword, "a boolean or an object")
+		}
+
+		return func(v any) *types.Document {
+			doc, ok := v.(types.Document)
+			if !ok {
+				return nil
+			}
+
+			docM := doc.Map()
+			var names []any
+			for _, k := range doc.Keys() {
+				if _, ok := known[k]; ok {
+					continue
+				}
+
+				if additional == nil {
+					names = append(names, k)
+					continue
+				}
+
+				if details := additional.validate(docM[k]); details != nil {
+					d := types.MustMakeDocument(
+						"operatorName", keyword,
+						"reason", "at least one additional property did not match the subschema",
+						"failingProperty", k,
+						"details", types.MustNewArray(details...),
+					)
+					return &d
+				}
+			}
+			if names == nil {
+				return nil
+			}
+
+			return failure("additionalProperties", types.MustNewArray(names...))
+		}, nil
+
+	case "minProperties", "maxProperties":
+		n, err := schemaCount(keyword, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			doc, ok := v.(types.Document)
+			if !ok {
+				return nil
+			}
+
+			l := int64(len(doc.Keys()))
+			if (keyword == "minProperties" && l >= n) || (keyword == "maxProperties" && l <= n) {
+				return nil
+			}
+
+			return failure("reason", "specified number of properties was not satisfied", "numberOfProperties", int32(l))
+		}, nil
+
+	case "minimum", "maximum":
+		if !isNumber(value) {
+			return nil, schemaTypeError(keyword, "a number")
+		}
+
+		exclusive := "exclusiveMinimum"
+		if keyword == "maximum" {
+			exclusive = "exclusiveMaximum"
+		}
+		exclusiveValue, _ := m[exclusive].(bool)
+		if exclusiveValue {
+			specifiedAs.Set(exclusive, true)
+		}
+
+		return func(v any) *types.Document {
+			if !isNumber(v) {
+				return nil
+			}
+
+			switch types.CompareOrder(v, value) {
+			case types.Equal:
+				if !exclusiveValue {
+					return nil
+				}
+			case types.Greater:
+				if keyword == "minimum" {
+					return nil
+				}
+			case types.Less:
+				if keyword == "maximum" {
+					return nil
+				}
+			}
+
+			return failure("reason", "comparison failed", "consideredValue", v)
+		}, nil
+
+	case "exclusiveMinimum", "exclusiveMaximum":
+		if _, ok := value.(bool); !ok {
+			return nil, schemaTypeError(keyword, "a boolean")
+		}
+
+		bound := "minimum"
+		if keyword == "exclusiveMaximum" {
+			bound = "maximum"
+		}
+		if _, ok := m[bound]; !ok {
+			return nil, NewErrorMessage(
+				ErrFailedToParse, "$jsonSchema keyword '%s' must be present if %s is present", bound, keyword,
+			)
+		}
+
+		// handled by minimum and maximum rules
+		return nil, nil
+
+	case "minLength", "maxLength":
+		n, err := schemaCount(keyword, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			s, ok := v.(string)
+			if !ok {
+				return nil
+			}
+
+			l := int64(utf8.RuneCountInString(s))
+			if (keyword == "minLength" && l >= n) || (keyword == "maxLength" && l <= n) {
+				return nil
+			}
+
+			return failure("reason", "specified string length was not satisfied", "consideredValue", v)
+		}, nil
+
+	case "pattern":
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, schemaTypeError(keyword, "a string")
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, NewErrorMessage(ErrRegexOptions, "Regular expression is invalid: %s", err)
+		}
+
+		return func(v any) *types.Document {
+			s, ok := v.(string)
+			if !ok || re.MatchString(s) {
+				return nil
+			}
+
+			return failure("reason", "regular expression did not match", "consideredValue", v)
+		}, nil
+
+	case "enum":
+		values, ok := value.(*types.Array)
+		if !ok {
+			return nil, schemaTypeError(keyword, "an array")
+		}
+		if values.Len() == 0 {
+			return nil, NewErrorMessage(ErrFailedToParse, "$jsonSchema keyword 'enum' cannot be an empty array")
+		}
+
+		return func(v any) *types.Document {
+			for i := 0; i < values.Len(); i++ {
+				if e, _ := values.Get(i); types.CompareOrder(v, e) == types.Equal {
+					return nil
+				}
+			}
+
+			return failure("reason", "value was not found in enum", "consideredValue", v)
+		}, nil
+
+	case "items":
+		var schemas []*jsonSchema
+		var tuple bool
+		switch value := value.(type) {
+		case types.Document:
+			s, err := newJSONSchema(value)
+			if err != nil {
+				return nil, err
+			}
+			schemas = []*jsonSchema{s}
+		case *types.Array:
+			var err error
+			if schemas, err = schemaArray(keyword, value); err != nil {
+				return nil, err
+			}
+			tuple = true
+		default:
+			return nil, schemaTypeError(keyword, "an array or an object")
+		}
+
+		return func(v any) *types.Document {
+			arr, ok := v.(*types.Array)
+			if !ok {
+				return nil
+			}
+
+			for i := 0; i < arr.Len(); i++ {
+				s := schemas[0]
+				if tuple {
+					if i >= len(schemas) {
+						break
+					}
+					s = schemas[i]
+				}
+
+				item, _ := arr.Get(i)
+				if details := s.validate(item); details != nil {
+					d := types.MustMakeDocument(
+						"operatorName", keyword,
+						"reason", "At least one item did not match the sub-schema",
+						"itemIndex", int32(i),
+						"details", types.MustNewArray(details...),
+					)
+					return &d
+				}
+			}
+
+			return nil
+		}, nil
+
+	case "minItems", "maxItems":
+		n, err := schemaCount(keyword, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			arr, ok := v.(*types.Array)
+			if !ok {
+				return nil
+			}
+
+			l := int64(arr.Len())
+			if (keyword == "minItems" && l >= n) || (keyword == "maxItems" && l <= n) {
+				return nil
+			}
+
+			return failure("reason", "array did not match specified length", "consideredValue", v)
+		}, nil
+
+	case "uniqueItems":
+		unique, ok := value.(bool)
+		if !ok {
+			return nil, schemaTypeError(keyword, "a boolean")
+		}
+		if !unique {
+			return nil, nil
+		}
+
+		return func(v any) *types.Document {
+			arr, ok := v.(*types.Array)
+			if !ok {
+				return nil
+			}
+
+			for i := 0; i < arr.Len(); i++ {
+				a, _ := arr.Get(i)
+				for j := 0; j < i; j++ {
+					if b, _ := arr.Get(j); types.CompareOrder(a, b) == types.Equal {
+						return failure("reason", "found a duplicate item", "consideredValue", v, "duplicatedValue", a)
+					}
+				}
+			}
+
+			return nil
+		}, nil
+
+	case "allOf", "anyOf", "oneOf":
+		arr, ok := value.(*types.Array)
+		if !ok {
+			return nil, schemaTypeError(keyword, "an array")
+		}
+
+		schemas, err := schemaArray(keyword, arr)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			var schemasNotSatisfied, matchingSchemaIndexes []any
+			for i, s := range schemas {
+				details := s.validate(v)
+				if details == nil {
+					matchingSchemaIndexes = append(matchingSchemaIndexes, int32(i))
+					continue
+				}
+
+				schemasNotSatisfied = append(schemasNotSatisfied, types.MustMakeDocument(
+					"index", int32(i),
+					"details", types.MustNewArray(details...),
+				))
+			}
+
+			switch {
+			case keyword == "allOf" && schemasNotSatisfied == nil:
+				return nil
+			case keyword == "anyOf" && matchingSchemaIndexes != nil:
+				return nil
+			case keyword == "oneOf" && len(matchingSchemaIndexes) == 1:
+				return nil
+			case keyword == "oneOf" && len(matchingSchemaIndexes) > 1:
+				d := types.MustMakeDocument(
+					"operatorName", keyword,
+					"reason", "more than one subschema matched",
+					"matchingSchemaIndexes", types.MustNewArray(matchingSchemaIndexes...),
+				)
+				return &d
+			}
+
+			d := types.MustMakeDocument(
+				"operatorName", keyword,
+				"schemasNotSatisfied", types.MustNewArray(schemasNotSatisfied...),
+			)
+			return &d
+		}, nil
+
+	case "not":
+		doc, ok := value.(types.Document)
+		if !ok {
+			return nil, schemaTypeError(keyword, "an object")
+		}
+
+		s, err := newJSONSchema(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(v any) *types.Document {
+			if s.validate(v) != nil {
+				return nil
+			}
+
+			d := types.MustMakeDocument(
+				"operatorName", keyword,
+				"reason", "child expression matched",
+			)
+			return &d
+		}, nil
+
+	case "$ref", "$schema", "default", "definitions", "format", "id", "additionalItems", "dependencies", "patternProperties":
+		return nil, NewErrorMessage(ErrFailedToParse, "$jsonSchema keyword '%s' is not currently supported", keyword)
+
+	default:
+		return nil, NewErrorMessage(ErrFailedToParse, "Unknown $jsonSchema keyword: %s", keyword)
+	}
+}
+
+// schemaTypeError returns the error for $jsonSchema keyword value of the wrong type.
+func schemaTypeError(keyword, expected string) error {
+	return NewErrorMessage(ErrTypeMismatch, "$jsonSchema keyword '%s' must be %s", keyword, expected)
+}
+
+// schemaTypes returns BSON type aliases of bsonType or type keyword value.
+func schemaTypes(keyword string, value any) ([]string, error) {
+	var names []string
+	switch value := value.(type) {
+	case string:
+		names = []string{value}
+	case *types.Array:
+		var err error
+		if names, err = schemaStrings(keyword, value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, schemaTypeError(keyword, "either a string or an array of strings")
+	}
+
+	aliases := make([]string, len(names))
+	for i, name := range names {
+		if keyword == "type" {
+			alias, ok := jsonTypes[name]
+			if !ok {
+				return nil, NewErrorMessage(ErrBadValue, "Unknown type name alias: %s", name)
+			}
+			aliases[i] = alias
+			continue
+		}
+
+		if _, ok := typeAliases[name]; !ok && name != "number" {
+			return nil, NewErrorMessage(ErrBadValue, "Unknown type name alias: %s", name)
+		}
+		aliases[i] = name
+	}
+
+	return aliases, nil
+}
+
+// matchesAlias returns true if the value has the type with the given BSON alias, or is a number for "number".
+func matchesAlias(v any, alias string) bool {
+	if alias == "number" {
+		return isNumber(v)
+	}
+
+	return bsonTypeNumber(v) == typeAliases[alias]
+}
+
+// isNumber returns true if the value has one of BSON numeric types.
+func isNumber(v any) bool {
+	switch v.(type) {
+	case float64, int32, int64, types.Decimal128:
+		return true
+	default:
+		return false
+	}
+}
+
+// schemaStrings returns a non-empty array of unique strings of the keyword value.
+func schemaStrings(keyword string, value any) ([]string, error) {
+	arr, ok := value.(*types.Array)
+	if !ok {
+		return nil, schemaTypeError(keyword, "an array")
+	}
+	if arr.Len() == 0 {
+		return nil, NewErrorMessage(ErrFailedToParse, "$jsonSchema keyword '%s' cannot be an empty array", keyword)
+	}
+
+	res := make([]string, arr.Len())
+	for i := range res {
+		v, _ := arr.Get(i)
+		s, ok := v.(string)
+		if !ok {
+			return nil, schemaTypeError(keyword, "an array of strings")
+		}
+
+		for _, prev := range res[:i] {
+			if prev == s {
+				return nil, NewErrorMessage(
+					ErrFailedToParse, "$jsonSchema keyword '%s' array cannot contain duplicate values", keyword,
+				)
+			}
+		}
+
+		res[i] = s
+	}
+
+	return res, nil
+}
+
+// schemaProperties returns schemas of properties keyword value, and their names in the specification order.
+func schemaProperties(value any) (map[string]*jsonSchema, []string, error) {
+	doc, ok := value.(types.Document)
+	if !ok {
+		return nil, nil, schemaTypeError("properties", "an object")
+	}
+
+	docM := doc.Map()
+	res := make(map[string]*jsonSchema, len(docM))
+	for _, k := range doc.Keys() {
+		spec, ok := docM[k].(types.Document)
+		if !ok {
+			return nil, nil, NewErrorMessage(
+				ErrTypeMismatch, "Nested schema for $jsonSchema property '%s' must be an object", k,
+			)
+		}
+
+		s, err := newJSONSchema(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		res[k] = s
+	}
+
+	return res, doc.Keys(), nil
+}
+
+// schemaArray returns schemas of a non-empty array keyword value.
+func schemaArray(keyword string, arr *types.Array) ([]*jsonSchema, error) {
+	if arr.Len() == 0 {
+		return nil, NewErrorMessage(ErrFailedToParse, "$jsonSchema keyword '%s' must be a non-empty array", keyword)
+	}
+
+	res := make([]*jsonSchema, arr.Len())
+	for i := range res {
+		v, _ := arr.Get(i)
+		spec, ok := v.(types.Document)
+		if !ok {
+			return nil, schemaTypeError(keyword, "an array of objects")
+		}
+
+		var err error
+		if res[i], err = newJSONSchema(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// schemaCount returns a non-negative whole number of the keyword value.
+func schemaCount(keyword string, value any) (int64, error) {
+	n, err := getWholeNumber(value)
+	if err != nil {
+		return 0, NewErrorMessage(ErrTypeMismatch, "$jsonSchema keyword '%s' must be a number", keyword)
+	}
+	if n < 0 {
+		return 0, NewErrorMessage(ErrFailedToParse, "$jsonSchema keyword '%s' must be a non-negative integer", keyword)
+	}
+
+	return n, nil
+}
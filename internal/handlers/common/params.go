@@ -0,0 +1,95 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"strings"
+
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// GetWholeNumberParam returns the value of the given document field as int64.
+//
+// It accepts int32, int64, and float64 values without a fractional part.
+// If the field is absent or null, defaultValue is returned.
+func GetWholeNumberParam(doc types.Document, key string, defaultValue int64) (int64, error) {
+	v, ok := doc.Map()[key]
+	if !ok || v == nil {
+		return defaultValue, nil
+	}
+
+	switch v := v.(type) {
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return 0, NewErrorMessage(ErrBadValue, "Expected an integer: %s: %v", key, v)
+		}
+		return int64(v), nil
+	default:
+		return 0, NewErrorMessage(ErrTypeMismatch, "Expected a number for %s, got %T", key, v)
+	}
+}
+
+// GetBoolParam returns the value of the given document field as bool.
+//
+// Numbers are accepted as well, as MongoDB does.
+// If the field is absent or null, defaultValue is returned.
+func GetBoolParam(doc types.Document, key string, defaultValue bool) (bool, error) {
+	v, ok := doc.Map()[key]
+	if !ok || v == nil {
+		return defaultValue, nil
+	}
+
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case int32:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	default:
+		return false, NewErrorMessage(ErrTypeMismatch, "Expected a boolean for %s, got %T", key, v)
+	}
+}
+
+// SplitNamespace splits full collection name into database and collection names.
+func SplitNamespace(ns string) (string, string, error) {
+	db, collection, ok := strings.Cut(ns, ".")
+	if !ok || db == "" || collection == "" {
+		return "", "", NewErrorMessage(ErrInvalidNamespace, "Invalid namespace specified '%s'", ns)
+	}
+
+	return db, collection, nil
+}
+
+// NamespaceError converts errors about invalid database or collection names returned by pg
+// into protocol errors; other errors are returned as is.
+func NamespaceError(err error, db, collection string) error {
+	switch err {
+	case pg.ErrInvalidDatabaseName:
+		return NewErrorMessage(ErrInvalidNamespace, "Invalid database name: '%s'", db)
+	case pg.ErrInvalidCollectionName:
+		return NewErrorMessage(ErrInvalidNamespace, "Invalid collection name: '%s.%s'", db, collection)
+	default:
+		return err
+	}
+}
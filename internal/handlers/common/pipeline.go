@@ -0,0 +1,458 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Pipeline represents a parsed aggregation pipeline that is evaluated in Go.
+//
+// Only stages that do not need other collections are supported.
+type Pipeline struct {
+	stages []pipelineStage
+}
+
+// pipelineStage represents a parsed pipeline stage.
+type pipelineStage struct {
+	name string
+	spec any // filter document for $match, number of documents for $skip and $limit
+	run  func(docs []types.Document) ([]types.Document, error)
+}
+
+// streamed returns true if the stage can run on documents in batches.
+func (s *pipelineStage) streamed() bool {
+	switch s.name {
+	case "$match", "$project", "$addFields", "$set", "$unset", "$skip", "$limit":
+		return true
+	default:
+		return false
+	}
+}
+
+// NewPipeline parses and validates the given pipeline stages.
+func NewPipeline(stages *types.Array) (*Pipeline, error) {
+	p := &Pipeline{
+		stages: make([]pipelineStage, 0, stages.Len()),
+	}
+
+	for i := 0; i < stages.Len(); i++ {
+		v, err := stages.Get(i)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		stage, ok := v.(types.Document)
+		if !ok {
+			return nil, NewErrorMessage(ErrTypeMismatch, "Each element of the 'pipeline' array must be an object")
+		}
+
+		keys := stage.Keys()
+		if len(keys) != 1 {
+			return nil, NewErrorMessage(ErrFailedToParse, "A pipeline stage specification object must contain exactly one field.")
+		}
+
+		s, err := newPipelineStage(keys[0], stage.Map()[keys[0]])
+		if err != nil {
+			return nil, err
+		}
+
+		p.stages = append(p.stages, *s)
+	}
+
+	return p, nil
+}
+
+// Run runs the pipeline on the given documents; they may be modified.
+func (p *Pipeline) Run(docs []types.Document) ([]types.Document, error) {
+	var err error
+	for _, s := range p.stages {
+		if docs, err = s.run(docs); err != nil {
+			return nil, err
+		}
+	}
+
+	return docs, nil
+}
+
+// Leading returns the filter of the leading $match stage and the limit of the $limit stage that follows it
+// (or leads the pipeline), so they could be applied by the storage, and the pipeline of remaining stages.
+// Empty filter and zero limit are returned for missing stages.
+func (p *Pipeline) Leading() (filter types.Document, limit int64, rest *Pipeline) {
+	stages := p.stages
+
+	if len(stages) != 0 && stages[0].name == "$match" {
+		filter = stages[0].spec.(types.Document)
+		stages = stages[1:]
+	}
+
+	if len(stages) != 0 && stages[0].name == "$limit" {
+		limit = stages[0].spec.(int64)
+		stages = stages[1:]
+	}
+
+	return filter, limit, &Pipeline{stages: stages}
+}
+
+// PipelineStream runs the pipeline on documents given in batches,
+// so they are not read at once by stages that do not need all of them.
+type PipelineStream struct {
+	p        *Pipeline
+	streamed int              // number of leading stages that run on batches
+	passed   map[int]int64    // number of documents passed by streamed $skip and $limit stages
+	docs     []types.Document // results of streamed stages
+}
+
+// Stream returns a new stream of the pipeline.
+func (p *Pipeline) Stream() *PipelineStream {
+	s := &PipelineStream{
+		p:      p,
+		passed: make(map[int]int64),
+	}
+
+	for s.streamed < len(p.stages) && p.stages[s.streamed].streamed() {
+		s.streamed++
+	}
+
+	return s
+}
+
+// Push runs streamed stages on the given documents; they may be modified.
+//
+// It returns true if following documents are not needed.
+func (s *PipelineStream) Push(docs []types.Document) (bool, error) {
+	var done bool
+	var err error
+
+	for i, stage := range s.p.stages[:s.streamed] {
+		switch stage.name {
+		case "$skip":
+			if skip := stage.spec.(int64) - s.passed[i]; skip > 0 {
+				if skip > int64(len(docs)) {
+					skip = int64(len(docs))
+				}
+				s.passed[i] += skip
+				docs = docs[skip:]
+			}
+
+		case "$limit":
+			if left := stage.spec.(int64) - s.passed[i]; left < int64(len(docs)) {
+				docs = docs[:left]
+			}
+			s.passed[i] += int64(len(docs))
+			done = done || s.passed[i] == stage.spec.(int64)
+
+		default:
+			if len(docs) == 0 {
+				continue
+			}
+			if docs, err = stage.run(docs); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	s.docs = append(s.docs, docs...)
+
+	return done, nil
+}
+
+// Result runs remaining stages on results of streamed stages and returns the final result.
+func (s *PipelineStream) Result() ([]types.Document, error) {
+	return (&Pipeline{stages: s.p.stages[s.streamed:]}).Run(s.docs)
+}
+
+// newPipelineStage returns the stage for the given name and specification.
+func newPipelineStage(name string, spec any) (*pipelineStage, error) {
+	stage := &pipelineStage{
+		name: name,
+		spec: spec,
+	}
+
+	switch name {
+	case "$match":
+		filter, ok := spec.(types.Document)
+		if !ok {
+			return nil, NewErrorMessage(ErrBadValue, "the match filter must be an expression in an object")
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			res := docs[:0]
+			for _, doc := range docs {
+				matches, err := FilterDocument(doc, filter)
+				if err != nil {
+					return nil, err
+				}
+				if matches {
+					res = append(res, doc)
+				}
+			}
+			return res, nil
+		}
+
+		return stage, nil
+
+	case "$project":
+		projection, ok := spec.(types.Document)
+		if !ok || len(projection.Keys()) == 0 {
+			return nil, NewErrorMessage(ErrBadValue, "$project specification must be an object with at least one field")
+		}
+
+		p, err := NewProjection(projection)
+		if err != nil {
+			return nil, err
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			for i, doc := range docs {
+				if docs[i], err = p.Project(doc, types.MustMakeDocument()); err != nil {
+					return nil, err
+				}
+			}
+			return docs, nil
+		}
+
+		return stage, nil
+
+	case "$addFields", "$set":
+		fields, ok := spec.(types.Document)
+		if !ok {
+			return nil, NewErrorMessage(ErrBadValue, "%s specification stage must be an object", name)
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			for i := range docs {
+				if err := addFields(&docs[i], fields); err != nil {
+					return nil, err
+				}
+			}
+			return docs, nil
+		}
+
+		return stage, nil
+
+	case "$unset":
+		var paths []string
+		switch spec := spec.(type) {
+		case string:
+			paths = []string{spec}
+		case *types.Array:
+			for i := 0; i < spec.Len(); i++ {
+				v, _ := spec.Get(i)
+				path, ok := v.(string)
+				if !ok {
+					return nil, NewErrorMessage(ErrBadValue, "$unset specification must be a string or an array of strings")
+				}
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, NewErrorMessage(ErrBadValue, "$unset specification must be a string or an array with at least one field")
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			for i := range docs {
+				for _, path := range paths {
+					unsetByPath(&docs[i], strings.Split(path, "."))
+				}
+			}
+			return docs, nil
+		}
+
+		return stage, nil
+
+	case "$sort":
+		sortSpec, ok := spec.(types.Document)
+		if !ok || len(sortSpec.Keys()) == 0 {
+			return nil, NewErrorMessage(ErrBadValue, "$sort stage must have at least one sort key")
+		}
+		if err := validateSort(sortSpec); err != nil {
+			return nil, err
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			return docs, SortDocuments(docs, sortSpec)
+		}
+
+		return stage, nil
+
+	case "$skip", "$limit":
+		n, err := getWholeNumber(spec)
+		if err != nil {
+			return nil, NewErrorMessage(ErrBadValue, "invalid argument to %s stage: %v", name, err)
+		}
+		stage.spec = n
+
+		if name == "$skip" {
+			if n < 0 {
+				return nil, NewErrorMessage(ErrBadValue, "invalid argument to $skip stage: Expected a non-negative number")
+			}
+
+			stage.run = func(docs []types.Document) ([]types.Document, error) {
+				if n >= int64(len(docs)) {
+					return nil, nil
+				}
+				return docs[n:], nil
+			}
+
+			return stage, nil
+		}
+
+		if n <= 0 {
+			return nil, NewErrorMessage(ErrBadValue, "invalid argument to $limit stage: Expected a positive number")
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			if n < int64(len(docs)) {
+				docs = docs[:n]
+			}
+			return docs, nil
+		}
+
+		return stage, nil
+
+	case "$count":
+		field, ok := spec.(string)
+		if !ok || field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+			return nil, NewErrorMessage(
+				ErrBadValue, "the count field must be a non-empty string that does not start with $ and does not contain '.'",
+			)
+		}
+
+		stage.run = func(docs []types.Document) ([]types.Document, error) {
+			if len(docs) == 0 {
+				return nil, nil
+			}
+			return []types.Document{types.MustMakeDocument(field, CountValue(int64(len(docs))))}, nil
+		}
+
+		return stage, nil
+
+	default:
+		return nil, NewErrorMessage(ErrNotImplemented, "aggregation stage %s is not implemented yet", name)
+	}
+}
+
+// addFields sets fields of the document to values of expressions evaluated against the original document.
+//
+// Fields of expressions that evaluate to missing values are not set.
+func addFields(doc *types.Document, fields types.Document) error {
+	m := fields.Map()
+
+	values := make([]any, len(fields.Keys()))
+	set := make([]bool, len(values))
+	for i, path := range fields.Keys() {
+		var err error
+		if values[i], set[i], err = EvaluateExpression(*doc, m[path]); err != nil {
+			return err
+		}
+	}
+
+	for i, path := range fields.Keys() {
+		if !set[i] {
+			continue
+		}
+
+		if err := setByPath(doc, strings.Split(path, "."), values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSort checks that all sort directions are 1 or -1.
+func validateSort(sortSpec types.Document) error {
+	m := sortSpec.Map()
+	for _, k := range sortSpec.Keys() {
+		if n, err := getWholeNumber(m[k]); err != nil || (n != 1 && n != -1) {
+			return NewErrorMessage(ErrBadValue, "$sort key ordering must be 1 (for ascending) or -1 (for descending)")
+		}
+	}
+
+	return nil
+}
+
+// SortDocuments sorts documents in place by the given sort specification, using BSON comparison order.
+//
+// Missing fields sort as null. Arrays sort by their smallest element in ascending order,
+// and by their largest element in descending order, as in MongoDB.
+func SortDocuments(docs []types.Document, sortSpec types.Document) error {
+	if err := validateSort(sortSpec); err != nil {
+		return err
+	}
+
+	m := sortSpec.Map()
+	keys := sortSpec.Keys()
+	asc := make([]bool, len(keys))
+	for i, k := range keys {
+		n, _ := getWholeNumber(m[k])
+		asc[i] = n == 1
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		for k, key := range keys {
+			a, b := sortValue(docs[i], key, asc[k]), sortValue(docs[j], key, asc[k])
+			switch types.CompareOrder(a, b) {
+			case types.Less:
+				return asc[k]
+			case types.Greater:
+				return !asc[k]
+			}
+		}
+		return false
+	})
+
+	return nil
+}
+
+// sortValue returns the value of the document used for sorting by the given path.
+func sortValue(doc types.Document, path string, asc bool) any {
+	values := GetPathValues(doc, path)
+	if len(values) == 0 {
+		return nil
+	}
+
+	var res any
+	var found bool
+	for _, v := range values {
+		candidates := []any{v}
+		if arr, ok := v.(*types.Array); ok && arr.Len() > 0 {
+			candidates = candidates[:0]
+			for i := 0; i < arr.Len(); i++ {
+				el, _ := arr.Get(i)
+				candidates = append(candidates, el)
+			}
+		}
+
+		for _, c := range candidates {
+			if !found {
+				res, found = c, true
+				continue
+			}
+
+			cmp := types.CompareOrder(c, res)
+			if (asc && cmp == types.Less) || (!asc && cmp == types.Greater) {
+				res = c
+			}
+		}
+	}
+
+	return res
+}
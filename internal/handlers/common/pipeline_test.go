@@ -0,0 +1,229 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	// documents are modified by the pipeline, so they are created for each test
+	docs := func() []types.Document {
+		return []types.Document{
+			types.MustMakeDocument("_id", int32(1), "v", int32(3), "tags", types.MustNewArray("b", "z")),
+			types.MustMakeDocument("_id", int32(2), "v", "foo"),
+			types.MustMakeDocument("_id", int32(3), "v", int32(1), "tags", types.MustNewArray("a")),
+			types.MustMakeDocument("_id", int32(4)),
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		pipeline *types.Array
+		expected []types.Document
+		err      ErrorCode
+	}{
+		"Empty": {
+			pipeline: types.MustNewArray(),
+			expected: docs(),
+		},
+		"MatchSortLimit": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$match", types.MustMakeDocument("_id", types.MustMakeDocument("$gt", int32(1)))),
+				types.MustMakeDocument("$sort", types.MustMakeDocument("v", int32(-1))),
+				types.MustMakeDocument("$limit", int32(2)),
+			),
+			expected: []types.Document{
+				types.MustMakeDocument("_id", int32(2), "v", "foo"),
+				types.MustMakeDocument("_id", int32(3), "v", int32(1), "tags", types.MustNewArray("a")),
+			},
+		},
+		"SortArrays": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$match", types.MustMakeDocument("tags", types.MustMakeDocument("$exists", true))),
+				types.MustMakeDocument("$sort", types.MustMakeDocument("tags", int32(-1))),
+				types.MustMakeDocument("$project", types.MustMakeDocument("_id", int32(1))),
+			),
+			expected: []types.Document{
+				types.MustMakeDocument("_id", int32(1)),
+				types.MustMakeDocument("_id", int32(3)),
+			},
+		},
+		"SetUnsetSkip": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$skip", int32(2)),
+				types.MustMakeDocument("$set", types.MustMakeDocument("w", "$v", "x.y", "$missing")),
+				types.MustMakeDocument("$unset", types.MustNewArray("tags", "v")),
+			),
+			expected: []types.Document{
+				types.MustMakeDocument("_id", int32(3), "w", int32(1)),
+				types.MustMakeDocument("_id", int32(4)),
+			},
+		},
+		"Count": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$match", types.MustMakeDocument("v", types.MustMakeDocument("$type", "number"))),
+				types.MustMakeDocument("$count", "n"),
+			),
+			expected: []types.Document{types.MustMakeDocument("n", int32(2))},
+		},
+		"CountNothing": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$match", types.MustMakeDocument("v", "bar")),
+				types.MustMakeDocument("$count", "n"),
+			),
+			expected: nil,
+		},
+		"BadSort": {
+			pipeline: types.MustNewArray(types.MustMakeDocument("$sort", types.MustMakeDocument("v", int32(2)))),
+			err:      ErrBadValue,
+		},
+		"BadLimit": {
+			pipeline: types.MustNewArray(types.MustMakeDocument("$limit", int32(0))),
+			err:      ErrBadValue,
+		},
+		"NotImplemented": {
+			pipeline: types.MustNewArray(types.MustMakeDocument("$lookup", types.MustMakeDocument())),
+			err:      ErrNotImplemented,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := NewPipeline(tc.pipeline)
+			var actual []types.Document
+			if err == nil {
+				actual, err = p.Run(docs())
+			}
+
+			if tc.err != 0 {
+				var e *Error
+				require.True(t, errors.As(err, &e), "%v", err)
+				assert.Equal(t, tc.err, e.code)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+
+			// the same result is returned for documents given one by one
+			s := p.Stream()
+			for _, doc := range docs() {
+				done, err := s.Push([]types.Document{doc})
+				require.NoError(t, err)
+				if done {
+					break
+				}
+			}
+			streamed, err := s.Result()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, streamed)
+		})
+	}
+}
+
+func TestPipelineStream(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPipeline(types.MustNewArray(
+		types.MustMakeDocument("$match", types.MustMakeDocument("v", types.MustMakeDocument("$exists", true))),
+		types.MustMakeDocument("$skip", int32(1)),
+		types.MustMakeDocument("$limit", int64(2)),
+		types.MustMakeDocument("$sort", types.MustMakeDocument("v", int32(-1))),
+	))
+	require.NoError(t, err)
+
+	s := p.Stream()
+
+	done, err := s.Push([]types.Document{types.MustMakeDocument("v", int32(1)), types.MustMakeDocument("w", int32(2))})
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	done, err = s.Push([]types.Document{types.MustMakeDocument("v", int32(3))})
+	require.NoError(t, err)
+	assert.False(t, done)
+
+	done, err = s.Push([]types.Document{types.MustMakeDocument("v", int32(4)), types.MustMakeDocument("v", int32(5))})
+	require.NoError(t, err)
+	assert.True(t, done)
+
+	actual, err := s.Result()
+	require.NoError(t, err)
+	expected := []types.Document{types.MustMakeDocument("v", int32(4)), types.MustMakeDocument("v", int32(3))}
+	assert.Equal(t, expected, actual)
+}
+
+func TestPipelineLeading(t *testing.T) {
+	t.Parallel()
+
+	filter := types.MustMakeDocument("v", int32(1))
+
+	for name, tc := range map[string]struct {
+		pipeline *types.Array
+		filter   types.Document
+		limit    int64
+		rest     int
+	}{
+		"Empty": {
+			pipeline: types.MustNewArray(),
+		},
+		"MatchLimit": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$match", filter),
+				types.MustMakeDocument("$limit", float64(3)),
+				types.MustMakeDocument("$limit", int32(2)),
+			),
+			filter: filter,
+			limit:  3,
+			rest:   1,
+		},
+		"Limit": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$limit", int32(2)),
+				types.MustMakeDocument("$match", filter),
+			),
+			limit: 2,
+			rest:  1,
+		},
+		"SkipLimit": {
+			pipeline: types.MustNewArray(
+				types.MustMakeDocument("$skip", int32(1)),
+				types.MustMakeDocument("$limit", int32(2)),
+			),
+			rest: 2,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := NewPipeline(tc.pipeline)
+			require.NoError(t, err)
+
+			filter, limit, rest := p.Leading()
+			assert.Equal(t, tc.filter, filter)
+			assert.Equal(t, tc.limit, limit)
+			assert.Len(t, rest.stages, tc.rest)
+		})
+	}
+}
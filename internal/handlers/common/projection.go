@@ -0,0 +1,644 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strings"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// projectionKind represents the kind of projection for a single path.
+type projectionKind int
+
+const (
+	projectionIntermediate projectionKind = iota // node with children
+	projectionInclude                            // {field: 1}
+	projectionExclude                            // {field: 0}
+	projectionExpression                         // {field: <aggregation expression>}
+	projectionSlice                              // {field: {$slice: ...}}
+	projectionElemMatch                          // {field: {$elemMatch: ...}}
+	projectionPositional                         // {"field.$": 1}
+)
+
+// projectionNode is a node of the projection tree built from dot notation paths.
+type projectionNode struct {
+	kind     projectionKind
+	value    any // expression, $slice or $elemMatch argument
+	keys     []string
+	children map[string]*projectionNode
+}
+
+// child returns the child node with the given key, creating an intermediate one if needed.
+func (n *projectionNode) child(key string) *projectionNode {
+	c, ok := n.children[key]
+	if !ok {
+		c = &projectionNode{children: map[string]*projectionNode{}}
+		n.children[key] = c
+		n.keys = append(n.keys, key)
+	}
+
+	return c
+}
+
+// hasExpressions returns true if the node or any of its descendants is an expression.
+func (n *projectionNode) hasExpressions() bool {
+	if n.kind == projectionExpression {
+		return true
+	}
+
+	for _, c := range n.children {
+		if c.hasExpressions() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Projection represents a parsed find projection.
+//
+// See https://docs.mongodb.com/manual/reference/method/db.collection.find/#std-label-find-projection.
+type Projection struct {
+	root       *projectionNode
+	inclusion  bool
+	positional string // path of the positional projection without ".$"
+}
+
+// NewProjection parses and validates the given find projection.
+//
+// Nil or empty projection returns documents as is.
+func NewProjection(projection types.Document) (*Projection, error) {
+	p := &Projection{
+		root: &projectionNode{children: map[string]*projectionNode{}},
+	}
+
+	var inclusionPath, exclusionPath string
+	var idKind projectionKind
+
+	var add func(prefix string, spec types.Document) error
+	add = func(prefix string, spec types.Document) error {
+		m := spec.Map()
+		for _, key := range spec.Keys() {
+			path := prefix + key
+			value := m[key]
+
+			node := projectionNode{kind: projectionExpression, value: value}
+
+			switch value := value.(type) {
+			case types.Document:
+				keys := value.Keys()
+				if len(keys) == 0 {
+					return NewErrorMessage(
+						ErrBadValue, "An empty sub-projection is not a valid value. Found empty object at path: %s", path,
+					)
+				}
+
+				if !strings.HasPrefix(keys[0], "$") {
+					if err := add(path+".", value); err != nil {
+						return err
+					}
+					continue
+				}
+
+				switch keys[0] {
+				case "$slice":
+					if err := validateSlice(value.Map()["$slice"]); err != nil {
+						return err
+					}
+					node = projectionNode{kind: projectionSlice, value: value.Map()["$slice"]}
+
+				case "$elemMatch":
+					if strings.Contains(path, ".") {
+						return NewErrorMessage(ErrBadValue, "Cannot use $elemMatch projection on a nested field.")
+					}
+					arg, ok := value.Map()["$elemMatch"].(types.Document)
+					if !ok {
+						return NewErrorMessage(ErrBadValue, "elemMatch: Invalid argument, object required")
+					}
+					node = projectionNode{kind: projectionElemMatch, value: arg}
+				}
+
+			case bool, int32, int64, float64, types.Decimal128:
+				node = projectionNode{kind: projectionInclude}
+				if !isTruthy(value) {
+					node.kind = projectionExclude
+				}
+			}
+
+			if strings.HasSuffix(path, ".$") {
+				if node.kind != projectionInclude {
+					return NewErrorMessage(ErrBadValue, "positional projection cannot be used with exclusion or operators")
+				}
+				if p.positional != "" {
+					return NewErrorMessage(ErrBadValue, "Cannot specify more than one positional projection per query.")
+				}
+				path = strings.TrimSuffix(path, ".$")
+				p.positional = path
+				node.kind = projectionPositional
+			}
+
+			for _, part := range strings.Split(path, ".") {
+				if part == "" || strings.HasPrefix(part, "$") {
+					return NewErrorMessage(ErrBadValue, "FieldPath field names may not be empty or start with '$': %s", path)
+				}
+			}
+
+			if path == "_id" && (node.kind == projectionInclude || node.kind == projectionExclude) {
+				// _id is included by default, so it doesn't define the projection kind
+				idKind = node.kind
+			} else {
+				switch node.kind {
+				case projectionInclude, projectionExpression, projectionElemMatch, projectionPositional:
+					if exclusionPath != "" {
+						return NewErrorMessage(
+							ErrProjectionExIn, "Cannot do inclusion on field %s in exclusion projection", path,
+						)
+					}
+					inclusionPath = path
+				case projectionExclude:
+					if inclusionPath != "" {
+						return NewErrorMessage(
+							ErrProjectionInEx, "Cannot do exclusion on field %s in inclusion projection", path,
+						)
+					}
+					exclusionPath = path
+				}
+			}
+
+			if err := p.insert(path, &node); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := add("", projection); err != nil {
+		return nil, err
+	}
+
+	p.inclusion = inclusionPath != "" || (exclusionPath == "" && idKind == projectionInclude)
+
+	return p, nil
+}
+
+// insert adds the node at the given path to the projection tree.
+func (p *Projection) insert(path string, node *projectionNode) error {
+	parts := strings.Split(path, ".")
+
+	n := p.root
+	for i, part := range parts[:len(parts)-1] {
+		n = n.child(part)
+		if n.kind != projectionIntermediate {
+			return NewErrorMessage(ErrProjectionPathCollision, "Path collision at %s", strings.Join(parts[:i+1], "."))
+		}
+	}
+
+	last := parts[len(parts)-1]
+	if _, ok := n.children[last]; ok {
+		return NewErrorMessage(ErrProjectionPathCollision, "Path collision at %s", path)
+	}
+
+	n.children[last] = node
+	n.keys = append(n.keys, last)
+
+	return nil
+}
+
+// validateSlice checks $slice projection argument: a number or [skip, limit] array.
+func validateSlice(arg any) error {
+	if _, err := getWholeNumber(arg); err == nil {
+		return nil
+	}
+
+	arr, ok := arg.(*types.Array)
+	if !ok || arr.Len() != 2 {
+		return NewErrorMessage(ErrBadValue, "$slice only supports numbers and [skip, limit] arrays")
+	}
+
+	for i := 0; i < 2; i++ {
+		v, _ := arr.Get(i)
+		n, err := getWholeNumber(v)
+		if err != nil {
+			return NewErrorMessage(ErrBadValue, "$slice only supports numbers and [skip, limit] arrays")
+		}
+		if i == 1 && n <= 0 {
+			return NewErrorMessage(ErrBadValue, "$slice limit must be positive")
+		}
+	}
+
+	return nil
+}
+
+// applySlice applies validated $slice projection argument to the value; non-arrays are returned as is.
+func applySlice(v, arg any) any {
+	arr, ok := v.(*types.Array)
+	if !ok {
+		return v
+	}
+
+	l := int64(arr.Len())
+	var start, end int64
+
+	if n, err := getWholeNumber(arg); err == nil {
+		if n >= 0 {
+			start, end = 0, n
+		} else {
+			start, end = l+n, l
+		}
+	} else {
+		a := arg.(*types.Array)
+		s, _ := a.Get(0)
+		c, _ := a.Get(1)
+		skip, _ := getWholeNumber(s)
+		limit, _ := getWholeNumber(c)
+		if skip < 0 {
+			start = l + skip
+		} else {
+			start = skip
+		}
+		if start < 0 {
+			start = 0
+		}
+		end = start + limit
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if start > l {
+		start = l
+	}
+	if end > l {
+		end = l
+	}
+
+	res, err := arr.Subslice(int(start), int(end))
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}
+
+// Project applies the projection to the document.
+//
+// The query filter is used for the positional projection.
+func (p *Projection) Project(doc, filter types.Document) (types.Document, error) {
+	if len(p.root.keys) == 0 {
+		return doc, nil
+	}
+
+	pr := projector{
+		p:        p,
+		root:     doc,
+		position: -1,
+	}
+
+	if p.positional != "" {
+		var err error
+		if pr.position, err = positionalIndex(doc, filter, p.positional); err != nil {
+			return types.Document{}, err
+		}
+	}
+
+	if p.inclusion {
+		return pr.include(doc, p.root, true)
+	}
+
+	return pr.exclude(doc, p.root)
+}
+
+// projector holds the state of a single document projection.
+type projector struct {
+	p        *Projection
+	root     types.Document
+	position int // matched array element index for the positional projection
+}
+
+// include applies inclusion projection tree node to the document.
+func (pr *projector) include(doc types.Document, n *projectionNode, top bool) (types.Document, error) {
+	res := types.MustMakeDocument()
+	m := doc.Map()
+
+	for _, key := range doc.Keys() {
+		c, ok := n.children[key]
+		if !ok {
+			if top && key == "_id" {
+				if err := res.Set(key, m[key]); err != nil {
+					return types.Document{}, lazyerrors.Error(err)
+				}
+			}
+			continue
+		}
+
+		v, ok, err := pr.includeValue(m[key], c)
+		if err != nil {
+			return types.Document{}, err
+		}
+		if !ok {
+			continue
+		}
+
+		if err = res.Set(key, v); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+	}
+
+	// computed fields that are not present in the document are added at the end
+	for _, key := range n.keys {
+		if _, ok := m[key]; ok {
+			continue
+		}
+
+		c := n.children[key]
+		if !c.hasExpressions() {
+			continue
+		}
+
+		var v any = types.MustMakeDocument()
+		if c.kind == projectionExpression {
+			v = missing
+		}
+
+		v, ok, err := pr.includeValue(v, c)
+		if err != nil {
+			return types.Document{}, err
+		}
+		if !ok {
+			continue
+		}
+
+		if err = res.Set(key, v); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+	}
+
+	return res, nil
+}
+
+// includeValue applies inclusion projection tree node to the value.
+//
+// It returns false if the value should not be included.
+func (pr *projector) includeValue(v any, n *projectionNode) (any, bool, error) {
+	switch n.kind {
+	case projectionInclude:
+		return v, true, nil
+
+	case projectionExclude:
+		return nil, false, nil
+
+	case projectionExpression:
+		res, err := evaluate(pr.root, n.value)
+		if err != nil {
+			return nil, false, err
+		}
+		return res, res != missing, nil
+
+	case projectionSlice:
+		return applySlice(v, n.value), true, nil
+
+	case projectionElemMatch:
+		arr, ok := v.(*types.Array)
+		if !ok {
+			return nil, false, nil
+		}
+		i, err := ElemMatchIndex(arr, n.value.(types.Document))
+		if err != nil || i < 0 {
+			return nil, false, err
+		}
+		el, _ := arr.Get(i)
+		return types.MustNewArray(el), true, nil
+
+	case projectionPositional:
+		arr, ok := v.(*types.Array)
+		if !ok {
+			return v, true, nil
+		}
+		el, err := arr.Get(pr.position)
+		if err != nil {
+			return nil, false, NewErrorMessage(
+				ErrPositionalNoMatch,
+				"Executor error during find command :: caused by :: "+
+					"positional operator '.$' couldn't find a matching element in the array",
+			)
+		}
+		return types.MustNewArray(el), true, nil
+
+	case projectionIntermediate:
+		switch v := v.(type) {
+		case types.Document:
+			res, err := pr.include(v, n, false)
+			return res, err == nil, err
+
+		case *types.Array:
+			res := types.MakeArray(v.Len())
+			for i := 0; i < v.Len(); i++ {
+				el, _ := v.Get(i)
+				switch el.(type) {
+				case types.Document, *types.Array:
+				default:
+					// scalars are dropped from arrays in inclusion projections
+					continue
+				}
+
+				r, ok, err := pr.includeValue(el, n)
+				if err != nil {
+					return nil, false, err
+				}
+				if !ok {
+					continue
+				}
+				if err = res.Append(r); err != nil {
+					return nil, false, lazyerrors.Error(err)
+				}
+			}
+			return res, true, nil
+
+		default:
+			if !n.hasExpressions() {
+				return nil, false, nil
+			}
+
+			// computed nested fields replace scalars
+			res, err := pr.include(types.MustMakeDocument(), n, false)
+			return res, err == nil, err
+		}
+
+	default:
+		panic("unexpected projection kind")
+	}
+}
+
+// exclude applies exclusion projection tree node to the document.
+func (pr *projector) exclude(doc types.Document, n *projectionNode) (types.Document, error) {
+	res := types.MustMakeDocument()
+	m := doc.Map()
+
+	for _, key := range doc.Keys() {
+		v := m[key]
+
+		if c, ok := n.children[key]; ok {
+			switch c.kind {
+			case projectionExclude:
+				continue
+			case projectionSlice:
+				v = applySlice(v, c.value)
+			case projectionIntermediate:
+				var err error
+				if v, err = pr.excludeValue(v, c); err != nil {
+					return types.Document{}, err
+				}
+			}
+		}
+
+		if err := res.Set(key, v); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+	}
+
+	return res, nil
+}
+
+// excludeValue applies exclusion projection tree node to the nested value.
+func (pr *projector) excludeValue(v any, n *projectionNode) (any, error) {
+	switch v := v.(type) {
+	case types.Document:
+		return pr.exclude(v, n)
+
+	case *types.Array:
+		res := types.MakeArray(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			el, _ := v.Get(i)
+			r, err := pr.excludeValue(el, n)
+			if err != nil {
+				return nil, err
+			}
+			if err = res.Append(r); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+		return res, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// positionalIndex returns the index of the first array element at the given path
+// that matches the query filter, or -1 if there is no such element or array.
+func positionalIndex(doc, filter types.Document, path string) (int, error) {
+	if !filterReferencesPath(filter, path) {
+		return 0, NewErrorMessage(
+			ErrBadValue,
+			"Executor error during find command :: caused by :: "+
+				"positional operator '.$' requires corresponding field in query specifier",
+		)
+	}
+
+	parts := strings.Split(path, ".")
+
+	v, err := doc.GetByPath(parts...)
+	if err != nil {
+		return -1, nil
+	}
+
+	arr, ok := v.(*types.Array)
+	if !ok {
+		return -1, nil
+	}
+
+	// check the filter against the document with each array element in place of the whole array
+	for i := 0; i < arr.Len(); i++ {
+		el, _ := arr.Get(i)
+
+		candidate, err := replacePath(doc, parts, types.MustNewArray(el))
+		if err != nil {
+			return 0, lazyerrors.Error(err)
+		}
+
+		matches, err := FilterDocument(candidate, filter)
+		if err != nil {
+			return 0, err
+		}
+		if matches {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// filterReferencesPath returns true if the query filter has conditions on the given path or its sub-paths.
+func filterReferencesPath(filter types.Document, path string) bool {
+	m := filter.Map()
+	for _, key := range filter.Keys() {
+		if key == path || strings.HasPrefix(key, path+".") {
+			return true
+		}
+
+		if !isLogicalOperator(key) {
+			continue
+		}
+
+		arr, ok := m[key].(*types.Array)
+		if !ok {
+			continue
+		}
+
+		for i := 0; i < arr.Len(); i++ {
+			el, _ := arr.Get(i)
+			if expr, ok := el.(types.Document); ok && filterReferencesPath(expr, path) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// replacePath returns a copy of the document with the value at the given path of nested documents replaced.
+func replacePath(doc types.Document, path []string, value any) (types.Document, error) {
+	res := types.MustMakeDocument()
+	m := doc.Map()
+
+	for _, key := range doc.Keys() {
+		v := m[key]
+
+		if key == path[0] {
+			if len(path) == 1 {
+				v = value
+			} else {
+				nested, ok := v.(types.Document)
+				if !ok {
+					return types.Document{}, lazyerrors.Errorf("replacePath: %q is not a document", key)
+				}
+
+				var err error
+				if v, err = replacePath(nested, path[1:], value); err != nil {
+					return types.Document{}, err
+				}
+			}
+		}
+
+		if err := res.Set(key, v); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+	}
+
+	return res, nil
+}
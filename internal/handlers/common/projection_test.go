@@ -0,0 +1,218 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestProjection(t *testing.T) {
+	t.Parallel()
+
+	doc := types.MustMakeDocument(
+		"_id", int32(1),
+		"name", "foo",
+		"size", types.MustMakeDocument("h", int32(14), "w", int32(21), "uom", "cm"),
+		"grades", types.MustNewArray(int32(80), int32(85), int32(90)),
+		"items", types.MustNewArray(
+			types.MustMakeDocument("sku", "a", "qty", int32(1)),
+			types.MustMakeDocument("sku", "b", "qty", int32(5)),
+			int32(42),
+		),
+	)
+
+	for name, tc := range map[string]struct {
+		projection types.Document
+		filter     types.Document
+		expected   types.Document
+		err        ErrorCode
+	}{
+		"Empty": {
+			projection: types.MustMakeDocument(),
+			expected:   doc,
+		},
+		"Inclusion": {
+			projection: types.MustMakeDocument("name", int32(1), "grades", true),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"name", "foo",
+				"grades", types.MustNewArray(int32(80), int32(85), int32(90)),
+			),
+		},
+		"InclusionWithoutID": {
+			projection: types.MustMakeDocument("name", 1.0, "_id", false),
+			expected:   types.MustMakeDocument("name", "foo"),
+		},
+		"OnlyID": {
+			projection: types.MustMakeDocument("_id", int32(1)),
+			expected:   types.MustMakeDocument("_id", int32(1)),
+		},
+		"Exclusion": {
+			projection: types.MustMakeDocument("size", int32(0), "items", false),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"name", "foo",
+				"grades", types.MustNewArray(int32(80), int32(85), int32(90)),
+			),
+		},
+		"ExclusionID": {
+			projection: types.MustMakeDocument("_id", int32(0), "grades", int32(0), "items", int32(0), "size", int32(0)),
+			expected:   types.MustMakeDocument("name", "foo"),
+		},
+		"DotNotationInclusion": {
+			projection: types.MustMakeDocument("size.uom", int32(1), "items.sku", int32(1)),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"size", types.MustMakeDocument("uom", "cm"),
+				"items", types.MustNewArray(
+					types.MustMakeDocument("sku", "a"),
+					types.MustMakeDocument("sku", "b"),
+				),
+			),
+		},
+		"NestedDocumentInclusion": {
+			projection: types.MustMakeDocument("size", types.MustMakeDocument("uom", int32(1)), "_id", int32(0)),
+			expected:   types.MustMakeDocument("size", types.MustMakeDocument("uom", "cm")),
+		},
+		"DotNotationExclusion": {
+			projection: types.MustMakeDocument("size.uom", int32(0), "items.qty", int32(0), "grades", int32(0)),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"name", "foo",
+				"size", types.MustMakeDocument("h", int32(14), "w", int32(21)),
+				"items", types.MustNewArray(
+					types.MustMakeDocument("sku", "a"),
+					types.MustMakeDocument("sku", "b"),
+					int32(42),
+				),
+			),
+		},
+		"Slice": {
+			projection: types.MustMakeDocument("grades", types.MustMakeDocument("$slice", int32(-2)), "items", int32(0)),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"name", "foo",
+				"size", types.MustMakeDocument("h", int32(14), "w", int32(21), "uom", "cm"),
+				"grades", types.MustNewArray(int32(85), int32(90)),
+			),
+		},
+		"SliceSkipLimit": {
+			projection: types.MustMakeDocument(
+				"grades", types.MustMakeDocument("$slice", types.MustNewArray(int32(1), int32(5))),
+				"_id", int32(0),
+				"name", int32(1),
+			),
+			expected: types.MustMakeDocument(
+				"name", "foo",
+				"grades", types.MustNewArray(int32(85), int32(90)),
+			),
+		},
+		"ElemMatch": {
+			projection: types.MustMakeDocument(
+				"items", types.MustMakeDocument("$elemMatch", types.MustMakeDocument("qty", types.MustMakeDocument("$gt", int32(2)))),
+			),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"items", types.MustNewArray(types.MustMakeDocument("sku", "b", "qty", int32(5))),
+			),
+		},
+		"ElemMatchNoMatch": {
+			projection: types.MustMakeDocument(
+				"grades", types.MustMakeDocument("$elemMatch", types.MustMakeDocument("$gt", int32(100))),
+			),
+			expected: types.MustMakeDocument("_id", int32(1)),
+		},
+		"Positional": {
+			projection: types.MustMakeDocument("grades.$", int32(1)),
+			filter:     types.MustMakeDocument("grades", types.MustMakeDocument("$gte", int32(85))),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"grades", types.MustNewArray(int32(85)),
+			),
+		},
+		"PositionalNoQuery": {
+			projection: types.MustMakeDocument("grades.$", int32(1)),
+			filter:     types.MustMakeDocument("name", "foo"),
+			err:        ErrBadValue,
+		},
+		"Expressions": {
+			projection: types.MustMakeDocument(
+				"_id", int32(0),
+				"name", types.MustMakeDocument("$toUpper", "$name"),
+				"area", types.MustMakeDocument("$multiply", types.MustNewArray("$size.h", "$size.w")),
+				"label", "literal",
+				"first", types.MustMakeDocument("$arrayElemAt", types.MustNewArray("$grades", int32(0))),
+				"missing", "$no.such.field",
+			),
+			expected: types.MustMakeDocument(
+				"name", "FOO",
+				"area", int32(294),
+				"label", "literal",
+				"first", int32(80),
+			),
+		},
+		"ExclusionInInclusion": {
+			projection: types.MustMakeDocument("name", int32(1), "size", int32(0)),
+			err:        ErrProjectionInEx,
+		},
+		"InclusionInExclusion": {
+			projection: types.MustMakeDocument("name", int32(0), "size", int32(1)),
+			err:        ErrProjectionExIn,
+		},
+		"ExpressionInExclusion": {
+			projection: types.MustMakeDocument("name", int32(0), "size", "$name"),
+			err:        ErrProjectionExIn,
+		},
+		"PathCollision": {
+			projection: types.MustMakeDocument("size", int32(1), "size.h", int32(1)),
+			err:        ErrProjectionPathCollision,
+		},
+		"InvalidSlice": {
+			projection: types.MustMakeDocument("grades", types.MustMakeDocument("$slice", types.MustNewArray(int32(1), int32(0)))),
+			err:        ErrBadValue,
+		},
+		"UnknownExpression": {
+			projection: types.MustMakeDocument("name", types.MustMakeDocument("$foo", int32(1))),
+			err:        ErrInvalidPipelineOperator,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := NewProjection(tc.projection)
+			var actual types.Document
+			if err == nil {
+				actual, err = p.Project(doc, tc.filter)
+			}
+
+			if tc.err != 0 {
+				var e *Error
+				require.True(t, errors.As(err, &e), "%v", err)
+				assert.Equal(t, tc.err, e.code)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// RetryableWrite identifies a retryable insert, update or delete command.
+//
+// Drivers retry such commands once on network errors with the same lsid and txnNumber;
+// statements that were already executed are not executed again.
+// Statement IDs are statements' indexes in the command.
+type RetryableWrite struct {
+	SessionID SessionID
+	TxnNumber int64
+}
+
+// GetRetryableWrite returns retryable write identifiers of the given command document,
+// or nil if it is not a retryable write.
+func GetRetryableWrite(document types.Document) (*RetryableWrite, error) {
+	m := document.Map()
+
+	v, ok := m["txnNumber"]
+	if !ok {
+		return nil, nil
+	}
+
+	txnNumber, ok := v.(int64)
+	if !ok {
+		return nil, NewErrorMessage(
+			ErrTypeMismatch, "BSON field 'OperationSessionInfo.txnNumber' is the wrong type '%s', expected type 'long'",
+			aliasFromType(v),
+		)
+	}
+
+	if txnNumber < 0 {
+		return nil, NewErrorMessage(ErrBadValue, "Transaction number cannot be negative")
+	}
+
+	lsid, ok := m["lsid"]
+	if !ok {
+		return nil, NewErrorMessage(ErrInvalidOptions, "Transaction number requires a session ID to also be specified")
+	}
+
+	id, err := GetSessionID(lsid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RetryableWrite{
+		SessionID: id,
+		TxnNumber: txnNumber,
+	}, nil
+}
+
+// executed returns records of statements of the retryable write that were already executed, keyed by index.
+//
+// It returns an error if a newer retryable write was already executed on the same session.
+func (rw *RetryableWrite) executed(ctx context.Context, pgPool *pg.Pool, tx pgx.Tx) (map[int]pg.RetryableWrite, error) {
+	records, err := pgPool.RetryableWrites(ctx, tx, rw.SessionID, rw.TxnNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[int]pg.RetryableWrite, len(records))
+	for _, r := range records {
+		if r.TxnNumber > rw.TxnNumber {
+			return nil, NewErrorMessage(
+				ErrTransactionTooOld,
+				"Retryable write with txnNumber %d is prohibited on session because "+
+					"a newer retryable write with txnNumber %d has already started on this session.",
+				rw.TxnNumber, r.TxnNumber,
+			)
+		}
+
+		res[int(r.StmtID)] = r
+	}
+
+	return res, nil
+}
+
+// record records executed statements with indexes in [from, to) range.
+//
+// A batch either succeeds or fails as a whole, so its total counts are recorded for the first statement,
+// and zeros for others.
+func (rw *RetryableWrite) record(ctx context.Context, pgPool *pg.Pool, tx pgx.Tx, from, to int, n, nModified int64) error {
+	records := make([]pg.RetryableWrite, 0, to-from)
+	for i := from; i < to; i++ {
+		r := pg.RetryableWrite{
+			TxnNumber: rw.TxnNumber,
+			StmtID:    int32(i),
+		}
+
+		if i == from {
+			r.N = n
+			r.NModified = nModified
+		}
+
+		records = append(records, r)
+	}
+
+	return pgPool.InsertRetryableWrites(ctx, tx, rw.SessionID, records...)
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestGetRetryableWrite(t *testing.T) {
+	t.Parallel()
+
+	id := SessionID{1, 2, 3}
+
+	for name, tc := range map[string]struct {
+		document types.Document
+		expected *RetryableWrite
+		code     ErrorCode
+	}{
+		"NotRetryable": {
+			document: types.MustMakeDocument("insert", "test", "lsid", id.Document()),
+		},
+		"Retryable": {
+			document: types.MustMakeDocument("insert", "test", "lsid", id.Document(), "txnNumber", int64(42)),
+			expected: &RetryableWrite{SessionID: id, TxnNumber: 42},
+		},
+		"NotLong": {
+			document: types.MustMakeDocument("insert", "test", "lsid", id.Document(), "txnNumber", int32(42)),
+			code:     ErrTypeMismatch,
+		},
+		"Negative": {
+			document: types.MustMakeDocument("insert", "test", "lsid", id.Document(), "txnNumber", int64(-1)),
+			code:     ErrBadValue,
+		},
+		"NoSession": {
+			document: types.MustMakeDocument("insert", "test", "txnNumber", int64(42)),
+			code:     ErrInvalidOptions,
+		},
+		"BadSession": {
+			document: types.MustMakeDocument("insert", "test", "lsid", "foo", "txnNumber", int64(42)),
+			code:     ErrTypeMismatch,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := GetRetryableWrite(tc.document)
+			if tc.code == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+				return
+			}
+
+			var e *Error
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, tc.code, e.code)
+		})
+	}
+}
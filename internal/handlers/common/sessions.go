@@ -0,0 +1,262 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+// LogicalSessionTimeout is the default time after which inactive logical sessions expire.
+const LogicalSessionTimeout = 30 * time.Minute
+
+// MaxSessions is the maximal number of logical sessions, as MongoDB's maxSessions default.
+const MaxSessions = 1_000_000
+
+// maxSessionsCleanupInterval is the maximal interval between expired sessions cleanups.
+const maxSessionsCleanupInterval = time.Minute
+
+// SessionID represents logical session ID (UUID).
+type SessionID [16]byte
+
+// session represents logical session state.
+type session struct {
+	lastUsed time.Time
+}
+
+// Sessions is a process-wide registry of logical sessions.
+//
+// Sessions are created by startSession command or implicitly by the first command with lsid,
+// up to MaxSessions.
+// Cursors owned by expired, ended or killed sessions are killed;
+// all other session state is dropped with the session.
+//
+// It is safe for concurrent use.
+type Sessions struct {
+	cursors *Cursors
+	timeout time.Duration
+	max     int
+
+	rw sync.RWMutex
+	m  map[SessionID]*session
+}
+
+// NewSessions creates a new empty sessions registry with the given inactivity timeout.
+//
+// Zero timeout means LogicalSessionTimeout.
+func NewSessions(cursors *Cursors, timeout time.Duration) *Sessions {
+	if timeout == 0 {
+		timeout = LogicalSessionTimeout
+	}
+
+	return &Sessions{
+		cursors: cursors,
+		timeout: timeout,
+		max:     MaxSessions,
+		m:       make(map[SessionID]*session),
+	}
+}
+
+// Timeout returns the inactivity timeout of sessions.
+func (ss *Sessions) Timeout() time.Duration {
+	return ss.timeout
+}
+
+// Start creates a new session with random (version 4) UUID and returns its ID.
+func (ss *Sessions) Start() (SessionID, error) {
+	var id SessionID
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // variant 10
+
+	if err := ss.Refresh(id); err != nil {
+		return SessionID{}, err
+	}
+
+	return id, nil
+}
+
+// Refresh updates the last use time of sessions with given IDs, creating missing sessions.
+//
+// If that would exceed the maximal number of sessions, nothing is changed, and an error is returned.
+func (ss *Sessions) Refresh(ids ...SessionID) error {
+	now := time.Now()
+
+	ss.rw.Lock()
+	defer ss.rw.Unlock()
+
+	missing := make(map[SessionID]struct{})
+	for _, id := range ids {
+		if _, ok := ss.m[id]; !ok {
+			missing[id] = struct{}{}
+		}
+	}
+
+	if len(ss.m)+len(missing) > ss.max {
+		return NewErrorMessage(
+			ErrTooManyLogicalSessions,
+			"Unable to add session into the cache because the number of active sessions is too high",
+		)
+	}
+
+	for _, id := range ids {
+		s, ok := ss.m[id]
+		if !ok {
+			s = new(session)
+			ss.m[id] = s
+		}
+		s.lastUsed = now
+	}
+
+	return nil
+}
+
+// AddCursor marks the cursor with given ID as owned by the session.
+//
+// If the session is missing because it was killed or expired concurrently, the cursor is killed.
+func (ss *Sessions) AddCursor(id SessionID, cursorID int64) {
+	ss.rw.RLock()
+	defer ss.rw.RUnlock()
+
+	if _, ok := ss.m[id]; !ok {
+		ss.cursors.Kill(cursorID)
+		return
+	}
+
+	ss.cursors.setSession(cursorID, id)
+}
+
+// Kill removes sessions with given IDs and kills their cursors.
+//
+// Unknown IDs are ignored.
+func (ss *Sessions) Kill(ids ...SessionID) {
+	ss.rw.Lock()
+	defer ss.rw.Unlock()
+
+	ss.kill(ids...)
+}
+
+// KillAll removes all sessions and kills their cursors.
+func (ss *Sessions) KillAll() {
+	ss.rw.Lock()
+	defer ss.rw.Unlock()
+
+	ids := make([]SessionID, 0, len(ss.m))
+	for id := range ss.m {
+		ids = append(ids, id)
+	}
+
+	ss.kill(ids...)
+}
+
+// Expire removes sessions that were not used for the timeout before now and kills their cursors.
+//
+// It returns the number of expired sessions.
+func (ss *Sessions) Expire(now time.Time) int {
+	ss.rw.Lock()
+	defer ss.rw.Unlock()
+
+	var ids []SessionID
+	for id, s := range ss.m {
+		if now.Sub(s.lastUsed) >= ss.timeout {
+			ids = append(ids, id)
+		}
+	}
+
+	ss.kill(ids...)
+
+	return len(ids)
+}
+
+// Run removes expired sessions periodically until ctx is canceled.
+func (ss *Sessions) Run(ctx context.Context) {
+	interval := ss.timeout
+	if interval > maxSessionsCleanupInterval {
+		interval = maxSessionsCleanupInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ss.Expire(now)
+		}
+	}
+}
+
+// Len returns the number of sessions.
+func (ss *Sessions) Len() int {
+	ss.rw.RLock()
+	defer ss.rw.RUnlock()
+
+	return len(ss.m)
+}
+
+// kill removes sessions with given IDs and kills their cursors.
+//
+// The caller must hold the write lock.
+func (ss *Sessions) kill(ids ...SessionID) {
+	if len(ids) == 0 {
+		return
+	}
+
+	killed := make(map[SessionID]struct{}, len(ids))
+	for _, id := range ids {
+		delete(ss.m, id)
+		killed[id] = struct{}{}
+	}
+
+	ss.cursors.killSessions(killed)
+}
+
+// GetSessionID returns session ID from the given lsid document.
+func GetSessionID(lsid any) (SessionID, error) {
+	var id SessionID
+
+	doc, ok := lsid.(types.Document)
+	if !ok {
+		return id, NewErrorMessage(ErrTypeMismatch, "session id must be a document, got %T", lsid)
+	}
+
+	v, ok := doc.Map()["id"]
+	if !ok {
+		return id, NewErrorMessage(ErrMissingField, "BSON field 'LogicalSessionId.id' is missing but a required field")
+	}
+
+	b, ok := v.(types.Binary)
+	if !ok || b.Subtype != types.BinaryUUID || len(b.B) != len(id) {
+		return id, NewErrorMessage(ErrBadValue, "session id must be a UUID")
+	}
+
+	copy(id[:], b.B)
+	return id, nil
+}
+
+// Document returns lsid document for the session ID.
+func (id SessionID) Document() types.Document {
+	return types.MustMakeDocument(
+		"id", types.Binary{Subtype: types.BinaryUUID, B: id[:]},
+	)
+}
@@ -0,0 +1,210 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+
+	docs := []types.Document{
+		types.MustMakeDocument("_id", int32(1)),
+		types.MustMakeDocument("_id", int32(2)),
+	}
+
+	t.Run("Start", func(t *testing.T) {
+		t.Parallel()
+
+		ss := NewSessions(NewCursors(), 0)
+		assert.Equal(t, LogicalSessionTimeout, ss.Timeout())
+
+		a, err := ss.Start()
+		require.NoError(t, err)
+		b, err := ss.Start()
+		require.NoError(t, err)
+		assert.NotEqual(t, a, b)
+		assert.Equal(t, byte(0x40), a[6]&0xf0, "version 4")
+		assert.Equal(t, byte(0x80), a[8]&0xc0, "variant")
+		assert.Equal(t, 2, ss.Len())
+
+		actual, err := GetSessionID(a.Document())
+		require.NoError(t, err)
+		assert.Equal(t, a, actual)
+	})
+
+	t.Run("Kill", func(t *testing.T) {
+		t.Parallel()
+
+		cs := NewCursors()
+		ss := NewSessions(cs, 0)
+
+		_, cursorID, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, cursorID)
+		_, otherCursorID, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, otherCursorID)
+
+		id, err := ss.Start()
+		require.NoError(t, err)
+		ss.AddCursor(id, cursorID)
+
+		other, err := ss.Start()
+		require.NoError(t, err)
+
+		ss.Kill(id, other)
+		assert.Zero(t, ss.Len())
+		assert.Equal(t, 1, cs.Len(), "only the session cursor should be killed")
+		assert.False(t, cs.Kill(cursorID))
+		assert.True(t, cs.Kill(otherCursorID))
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		t.Parallel()
+
+		cs := NewCursors()
+		ss := NewSessions(cs, time.Minute)
+
+		_, cursorID, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, cursorID)
+
+		id, err := ss.Start()
+		require.NoError(t, err)
+		ss.AddCursor(id, cursorID)
+		_, err = ss.Start()
+		require.NoError(t, err)
+
+		assert.Zero(t, ss.Expire(time.Now()))
+		assert.Equal(t, 2, ss.Len())
+
+		require.NoError(t, ss.Refresh(id))
+		assert.Equal(t, 2, ss.Expire(time.Now().Add(time.Minute)))
+		assert.Zero(t, ss.Len())
+		assert.Zero(t, cs.Len())
+	})
+
+	t.Run("KillAll", func(t *testing.T) {
+		t.Parallel()
+
+		ss := NewSessions(NewCursors(), 0)
+		_, err := ss.Start()
+		require.NoError(t, err)
+		require.NoError(t, ss.Refresh(SessionID{1}, SessionID{2}))
+		assert.Equal(t, 3, ss.Len())
+
+		ss.KillAll()
+		assert.Zero(t, ss.Len())
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		t.Parallel()
+
+		ss := NewSessions(NewCursors(), 0)
+		ss.max = 2
+
+		require.NoError(t, ss.Refresh(SessionID{1}))
+
+		err := ss.Refresh(SessionID{1}, SessionID{2}, SessionID{3})
+		var e *Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrTooManyLogicalSessions, e.code)
+		assert.Equal(t, 1, ss.Len(), "nothing should be created")
+
+		require.NoError(t, ss.Refresh(SessionID{1}, SessionID{2}))
+		_, err = ss.Start()
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrTooManyLogicalSessions, e.code)
+
+		require.NoError(t, ss.Refresh(SessionID{1}, SessionID{2}), "existing sessions can be refreshed")
+	})
+
+	t.Run("ExhaustedCursor", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := testutil.Ctx(t)
+		cs := NewCursors()
+		ss := NewSessions(cs, 0)
+
+		id, err := ss.Start()
+		require.NoError(t, err)
+
+		_, cursorID, err := cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, cursorID)
+		ss.AddCursor(id, cursorID)
+
+		_, nextID, err := cs.NextBatch(ctx, cursorID, "db.c", 0, 0)
+		require.NoError(t, err)
+		assert.Zero(t, nextID)
+		assert.Zero(t, cs.Len(), "exhausted cursor should not be kept by the session")
+
+		ss.Kill(id)
+		assert.Zero(t, ss.Len())
+
+		// cursors added after the session is killed are killed too
+		_, cursorID, err = cs.FirstBatch("db.c", docs, 1, false)
+		require.NoError(t, err)
+		require.NotZero(t, cursorID)
+		ss.AddCursor(id, cursorID)
+		assert.Zero(t, cs.Len())
+	})
+}
+
+func TestGetSessionID(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		lsid any
+		code ErrorCode
+	}{
+		"NotDocument": {
+			lsid: "foo",
+			code: ErrTypeMismatch,
+		},
+		"Missing": {
+			lsid: types.MustMakeDocument(),
+			code: ErrMissingField,
+		},
+		"NotUUID": {
+			lsid: types.MustMakeDocument("id", types.Binary{Subtype: types.BinaryGeneric, B: make([]byte, 16)}),
+			code: ErrBadValue,
+		},
+		"Short": {
+			lsid: types.MustMakeDocument("id", types.Binary{Subtype: types.BinaryUUID, B: make([]byte, 15)}),
+			code: ErrBadValue,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := GetSessionID(tc.lsid)
+			var e *Error
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, tc.code, e.code)
+		})
+	}
+}
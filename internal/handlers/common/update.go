@@ -0,0 +1,298 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// ValidateUpdateOperators checks that the update document contains only supported operators
+// with valid arguments and without conflicting paths.
+func ValidateUpdateOperators(update types.Document) error {
+	updateM := update.Map()
+
+	var paths []string
+	for _, op := range update.Keys() {
+		switch op {
+		case "$set", "$unset", "$inc":
+		default:
+			return NewErrorMessage(ErrNotImplemented, "update operator %q is not implemented", op)
+		}
+
+		fields, ok := updateM[op].(types.Document)
+		if !ok {
+			return NewErrorMessage(ErrBadValue, "Modifiers operate on fields but we found another type instead")
+		}
+
+		for _, path := range fields.Keys() {
+			if path == "" {
+				return NewErrorMessage(ErrEmptyFieldName, "An empty update path is not valid.")
+			}
+			for _, part := range strings.Split(path, ".") {
+				if part == "" {
+					return NewErrorMessage(
+						ErrEmptyFieldName, "The update path '%s' contains an empty field name, which is not allowed.", path,
+					)
+				}
+			}
+
+			if op == "$inc" {
+				switch v := fields.Map()[path].(type) {
+				case int32, int64, float64, types.Decimal128:
+				default:
+					return NewErrorMessage(
+						ErrTypeMismatch, "Cannot increment with non-numeric argument: {%s: %s}", path, aliasFromType(v),
+					)
+				}
+			}
+
+			for _, p := range paths {
+				if p == path || strings.HasPrefix(path, p+".") || strings.HasPrefix(p, path+".") {
+					return NewErrorMessage(
+						ErrConflictingUpdateOperators, "Updating the path '%s' would create a conflict at '%s'", path, p,
+					)
+				}
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	return nil
+}
+
+// UpdateDocument applies $set, $unset and $inc update operators to the document.
+//
+// It returns true if the document was modified.
+func UpdateDocument(doc *types.Document, update types.Document) (bool, error) {
+	if err := ValidateUpdateOperators(update); err != nil {
+		return false, err
+	}
+
+	before, err := fjson.Marshal(*doc)
+	if err != nil {
+		return false, lazyerrors.Error(err)
+	}
+	id := doc.Map()["_id"]
+
+	updateM := update.Map()
+	for _, op := range update.Keys() {
+		fields := updateM[op].(types.Document)
+		fieldsM := fields.Map()
+
+		for _, path := range fields.Keys() {
+			var err error
+			switch op {
+			case "$set":
+				err = setByPath(doc, strings.Split(path, "."), fieldsM[path])
+			case "$unset":
+				unsetByPath(doc, strings.Split(path, "."))
+			case "$inc":
+				err = incByPath(doc, path, fieldsM[path])
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	after, err := fjson.Marshal(*doc)
+	if err != nil {
+		return false, lazyerrors.Error(err)
+	}
+
+	if bytes.Equal(before, after) {
+		return false, nil
+	}
+
+	if newID, ok := doc.Map()["_id"]; !ok || types.Compare(id, newID) != types.Equal {
+		return false, NewErrorMessage(
+			ErrImmutableField, "Performing an update on the path '_id' would modify the immutable field '_id'",
+		)
+	}
+
+	return true, nil
+}
+
+// setByPath sets the value by the dot-separated path, creating missing intermediate documents.
+func setByPath(doc *types.Document, path []string, value any) error {
+	var container any = doc
+	for i, key := range path {
+		last := i == len(path)-1
+
+		switch c := container.(type) {
+		case *types.Document:
+			if last {
+				return c.Set(key, value)
+			}
+
+			next, ok := c.Map()[key]
+			if !ok {
+				next = types.MustMakeDocument()
+			}
+
+			// documents are stored by value, so set the modified copy back on the way up
+			if d, ok := next.(types.Document); ok {
+				if err := setByPath(&d, path[i+1:], value); err != nil {
+					return err
+				}
+				return c.Set(key, d)
+			}
+			container = next
+
+		case *types.Array:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 {
+				return NewErrorMessage(ErrPathNotViable, "Cannot create field '%s' in element {%s: array}", key, path[i-1])
+			}
+
+			for c.Len() <= index {
+				if err := c.Append(nil); err != nil {
+					return lazyerrors.Error(err)
+				}
+			}
+
+			if last {
+				return c.Set(index, value)
+			}
+
+			next, _ := c.Get(index)
+			if next == nil {
+				next = types.MustMakeDocument()
+			}
+
+			if d, ok := next.(types.Document); ok {
+				if err := setByPath(&d, path[i+1:], value); err != nil {
+					return err
+				}
+				return c.Set(index, d)
+			}
+			container = next
+
+		default:
+			return NewErrorMessage(
+				ErrPathNotViable, "Cannot create field '%s' in element {%s: %s}", key, path[i-1], aliasFromType(c),
+			)
+		}
+	}
+
+	return nil
+}
+
+// unsetByPath removes the value by the dot-separated path.
+//
+// Array elements are set to null instead of being removed, as MongoDB does.
+// Missing paths are ignored.
+func unsetByPath(doc *types.Document, path []string) {
+	if len(path) == 1 {
+		doc.Remove(path[0])
+		return
+	}
+
+	switch next := doc.Map()[path[0]].(type) {
+	case types.Document:
+		unsetByPath(&next, path[1:])
+		doc.Set(path[0], next) //nolint:errcheck // key is already valid
+	case *types.Array:
+		unsetArrayByPath(next, path[1:])
+	}
+}
+
+// unsetArrayByPath is unsetByPath for arrays.
+func unsetArrayByPath(arr *types.Array, path []string) {
+	index, err := strconv.Atoi(path[0])
+	if err != nil || index < 0 || index >= arr.Len() {
+		return
+	}
+
+	if len(path) == 1 {
+		arr.Set(index, nil) //nolint:errcheck // index is already checked
+		return
+	}
+
+	v, _ := arr.Get(index)
+	switch next := v.(type) {
+	case types.Document:
+		unsetByPath(&next, path[1:])
+		arr.Set(index, next) //nolint:errcheck // index is already checked
+	case *types.Array:
+		unsetArrayByPath(next, path[1:])
+	}
+}
+
+// incByPath increments the number by the dot-separated path, setting it if it is missing.
+func incByPath(doc *types.Document, path string, inc any) error {
+	parts := strings.Split(path, ".")
+
+	v, err := doc.GetByPath(parts...)
+	if err != nil {
+		return setByPath(doc, parts, inc)
+	}
+
+	switch v.(type) {
+	case int32, int64, float64, types.Decimal128:
+	default:
+		return NewErrorMessage(
+			ErrTypeMismatch,
+			"Cannot apply $inc to a value of non-numeric type. {_id: %v} has the field '%s' of non-numeric type %s",
+			doc.Map()["_id"], parts[len(parts)-1], aliasFromType(v),
+		)
+	}
+
+	res, err := incNumbers(v, inc)
+	if err != nil {
+		return err
+	}
+
+	return setByPath(doc, parts, res)
+}
+
+// incNumbers adds two numbers (int32, int64, float64 or Decimal128) with the $inc operator semantics.
+//
+// int32 overflows are promoted to int64; int64 overflows are errors.
+// Any Decimal128 operand makes the result Decimal128; otherwise, any double operand makes the result double.
+func incNumbers(a, b any) (any, error) {
+	_, aIsDecimal := a.(types.Decimal128)
+	_, bIsDecimal := b.(types.Decimal128)
+	if aIsDecimal || bIsDecimal {
+		return toDecimal128(a).Add(toDecimal128(b)), nil
+	}
+
+	_, aIsFloat := a.(float64)
+	_, bIsFloat := b.(float64)
+	if aIsFloat || bIsFloat {
+		return toFloat64(a) + toFloat64(b), nil
+	}
+
+	ai, bi := toInt64(a), toInt64(b)
+	sum := ai + bi
+	if (bi > 0 && sum < ai) || (bi < 0 && sum > ai) {
+		return nil, NewErrorMessage(ErrBadValue, "Failed to apply $inc operations to current value (%d)", ai)
+	}
+
+	_, aIsLong := a.(int64)
+	_, bIsLong := b.(int64)
+	if aIsLong || bIsLong || sum < math.MinInt32 || sum > math.MaxInt32 {
+		return sum, nil
+	}
+
+	return int32(sum), nil
+}
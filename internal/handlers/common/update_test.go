@@ -0,0 +1,152 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestUpdateDocument(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		update   types.Document
+		expected types.Document
+		modified bool
+		err      error
+	}{
+		"Set": {
+			update: types.MustMakeDocument("$set", types.MustMakeDocument("value", "bar", "new", int32(1))),
+			expected: types.MustMakeDocument(
+				"_id", int32(1), "value", "bar", "sub", types.MustMakeDocument("a", int32(1)), "new", int32(1),
+			),
+			modified: true,
+		},
+		"SetSame": {
+			update:   types.MustMakeDocument("$set", types.MustMakeDocument("value", "foo")),
+			expected: types.MustMakeDocument("_id", int32(1), "value", "foo", "sub", types.MustMakeDocument("a", int32(1))),
+		},
+		"SetDotNotation": {
+			update: types.MustMakeDocument("$set", types.MustMakeDocument("sub.b", int32(2), "x.y", int32(3))),
+			expected: types.MustMakeDocument(
+				"_id", int32(1), "value", "foo",
+				"sub", types.MustMakeDocument("a", int32(1), "b", int32(2)),
+				"x", types.MustMakeDocument("y", int32(3)),
+			),
+			modified: true,
+		},
+		"Unset": {
+			update:   types.MustMakeDocument("$unset", types.MustMakeDocument("value", "", "sub.a", "", "missing", "")),
+			expected: types.MustMakeDocument("_id", int32(1), "sub", types.MustMakeDocument()),
+			modified: true,
+		},
+		"Inc": {
+			update: types.MustMakeDocument("$inc", types.MustMakeDocument("sub.a", int32(41), "n", int64(1))),
+			expected: types.MustMakeDocument(
+				"_id", int32(1), "value", "foo", "sub", types.MustMakeDocument("a", int32(42)), "n", int64(1),
+			),
+			modified: true,
+		},
+		"IncZero": {
+			update:   types.MustMakeDocument("$inc", types.MustMakeDocument("sub.a", int32(0))),
+			expected: types.MustMakeDocument("_id", int32(1), "value", "foo", "sub", types.MustMakeDocument("a", int32(1))),
+		},
+		"IncNonNumeric": {
+			update: types.MustMakeDocument("$inc", types.MustMakeDocument("value", int32(1))),
+			err: NewErrorMessage(
+				ErrTypeMismatch,
+				"Cannot apply $inc to a value of non-numeric type. {_id: 1} has the field 'value' of non-numeric type string",
+			),
+		},
+		"IncNonNumericArgument": {
+			update: types.MustMakeDocument("$inc", types.MustMakeDocument("sub.a", "1")),
+			err:    NewErrorMessage(ErrTypeMismatch, "Cannot increment with non-numeric argument: {sub.a: string}"),
+		},
+		"Conflict": {
+			update: types.MustMakeDocument(
+				"$set", types.MustMakeDocument("sub", int32(1)),
+				"$inc", types.MustMakeDocument("sub.a", int32(1)),
+			),
+			err: NewErrorMessage(ErrConflictingUpdateOperators, "Updating the path 'sub.a' would create a conflict at 'sub'"),
+		},
+		"ImmutableID": {
+			update: types.MustMakeDocument("$set", types.MustMakeDocument("_id", int32(2))),
+			err: NewErrorMessage(
+				ErrImmutableField, "Performing an update on the path '_id' would modify the immutable field '_id'",
+			),
+		},
+		"PathNotViable": {
+			update: types.MustMakeDocument("$set", types.MustMakeDocument("value.a", int32(2))),
+			err:    NewErrorMessage(ErrPathNotViable, "Cannot create field 'a' in element {value: string}"),
+		},
+		"NotImplemented": {
+			update: types.MustMakeDocument("$rename", types.MustMakeDocument("value", "v")),
+			err:    NewErrorMessage(ErrNotImplemented, "update operator \"$rename\" is not implemented"),
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			doc := types.MustMakeDocument("_id", int32(1), "value", "foo", "sub", types.MustMakeDocument("a", int32(1)))
+			modified, err := UpdateDocument(&doc, tc.update)
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.modified, modified)
+			assert.Equal(t, tc.expected, doc)
+		})
+	}
+}
+
+func TestIncNumbers(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		a, b     any
+		expected any
+		err      bool
+	}{
+		"Int32":         {int32(1), int32(2), int32(3), false},
+		"Int32Overflow": {int32(math.MaxInt32), int32(1), int64(math.MaxInt32 + 1), false},
+		"Int64":         {int32(1), int64(2), int64(3), false},
+		"Int64Overflow": {int64(math.MaxInt64), int32(1), nil, true},
+		"Double":        {int64(1), 0.5, 1.5, false},
+		"Decimal":       {int32(1), types.MustParseDecimal128("0.5"), types.MustParseDecimal128("1.5"), false},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := incNumbers(tc.a, tc.b)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
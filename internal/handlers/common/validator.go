@@ -0,0 +1,256 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Validator checks inserted and updated documents against collection's validator.
+//
+// The validator document may contain top-level $jsonSchema and query operators.
+type Validator struct {
+	validator types.Document
+	schema    *jsonSchema
+	level     string
+	action    string
+}
+
+// NewValidator creates a new validator with the given validation level and action;
+// empty values mean defaults "strict" and "error".
+func NewValidator(validator types.Document, level, action string) (*Validator, error) {
+	v := &Validator{
+		validator: validator,
+		level:     "strict",
+		action:    "error",
+	}
+
+	switch level {
+	case "":
+	case "off", "strict", "moderate":
+		v.level = level
+	default:
+		return nil, NewErrorMessage(
+			ErrBadValue, "Enumeration value '%s' for field 'validationLevel' is not a valid value.", level,
+		)
+	}
+
+	switch action {
+	case "":
+	case "error", "warn":
+		v.action = action
+	default:
+		return nil, NewErrorMessage(
+			ErrBadValue, "Enumeration value '%s' for field 'validationAction' is not a valid value.", action,
+		)
+	}
+
+	m := validator.Map()
+	for _, k := range validator.Keys() {
+		switch k {
+		case "$jsonSchema":
+			spec, ok := m[k].(types.Document)
+			if !ok {
+				return nil, NewErrorMessage(ErrTypeMismatch, "$jsonSchema must be an object")
+			}
+
+			var err error
+			if v.schema, err = newJSONSchema(spec); err != nil {
+				return nil, err
+			}
+
+		case "$where", "$near", "$nearSphere", "$text", "$geoNear":
+			return nil, NewErrorMessage(ErrBadValue, "%s is not allowed inside of a validator", k)
+
+		default:
+			// check query operators by matching an empty document
+			if _, err := FilterDocument(types.Document{}, types.MustMakeDocument(k, m[k])); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// GetValidator returns the validator of the collection for insert or update command,
+// or nil if documents should not be validated.
+func GetValidator(ctx context.Context, pgPool *pg.Pool, document types.Document, db, collection string) (*Validator, error) {
+	bypass, err := GetBoolParam(document, "bypassDocumentValidation", false)
+	if err != nil {
+		return nil, err
+	}
+	if bypass {
+		return nil, nil
+	}
+
+	opts, err := pgPool.TableOptions(ctx, db, collection)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return TableValidator(opts)
+}
+
+// TableValidator returns the validator stored in table options, or nil if there is none, or it is turned off.
+func TableValidator(opts *pg.TableOptions) (*Validator, error) {
+	if len(opts.Validator) == 0 || opts.ValidationLevel == "off" {
+		return nil, nil
+	}
+
+	v, err := fjson.Unmarshal(opts.Validator)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	validator, ok := v.(types.Document)
+	if !ok {
+		return nil, lazyerrors.Errorf("expected document, got %T", v)
+	}
+	if len(validator.Keys()) == 0 {
+		return nil, nil
+	}
+
+	return NewValidator(validator, opts.ValidationLevel, opts.ValidationAction)
+}
+
+// Exempt returns true if updates of the existing document are not validated.
+//
+// With moderate validation level, documents that are already invalid can be updated freely.
+func (v *Validator) Exempt(old types.Document) bool {
+	if v == nil {
+		return true
+	}
+
+	return v.level == "moderate" && v.validate(old) != nil
+}
+
+// Check returns DocumentValidationFailure error if the document does not satisfy the validator.
+//
+// With warn validation action, the failure is logged instead. Nil validator accepts all documents.
+func (v *Validator) Check(l *zap.Logger, ns string, doc types.Document) error {
+	if v == nil {
+		return nil
+	}
+
+	details := v.validate(doc)
+	if details == nil {
+		return nil
+	}
+
+	info := types.MustMakeDocument(
+		"failingDocumentId", doc.Map()["_id"],
+		"details", *details,
+	)
+
+	if v.action == "warn" {
+		b, _ := fjson.Marshal(info)
+		l.Warn("Document failed validation.", zap.String("ns", ns), zap.ByteString("errInfo", b))
+		return nil
+	}
+
+	return NewErrorInfo(ErrDocumentValidationFailure, info, "Document failed validation")
+}
+
+// validate returns details of the validation failure, or nil if the document satisfies the validator.
+func (v *Validator) validate(doc types.Document) *types.Document {
+	m := v.validator.Map()
+	keys := v.validator.Keys()
+
+	var clausesNotSatisfied []any
+	for i, k := range keys {
+		var details *types.Document
+		if k == "$jsonSchema" {
+			if failures := v.schema.validate(doc); failures != nil {
+				d := types.MustMakeDocument(
+					"operatorName", k,
+					"schemaRulesNotSatisfied", types.MustNewArray(failures...),
+				)
+				details = &d
+			}
+		} else {
+			details = queryClauseDetails(doc, k, m[k])
+		}
+
+		if details == nil {
+			continue
+		}
+
+		if len(keys) == 1 {
+			return details
+		}
+
+		clausesNotSatisfied = append(clausesNotSatisfied, types.MustMakeDocument(
+			"index", int32(i),
+			"details", *details,
+		))
+	}
+
+	if clausesNotSatisfied == nil {
+		return nil
+	}
+
+	d := types.MustMakeDocument(
+		"operatorName", "$and",
+		"clausesNotSatisfied", types.MustNewArray(clausesNotSatisfied...),
+	)
+	return &d
+}
+
+// queryClauseDetails returns details of the query validator clause failure, or nil if the document matches it.
+func queryClauseDetails(doc types.Document, key string, value any) *types.Document {
+	// operators were checked by NewValidator
+	if matches, _ := FilterDocument(doc, types.MustMakeDocument(key, value)); matches {
+		return nil
+	}
+
+	specifiedAs := types.MustMakeDocument(key, value)
+
+	if isLogicalOperator(key) {
+		d := types.MustMakeDocument(
+			"operatorName", key,
+			"specifiedAs", specifiedAs,
+			"reason", "expression did not match",
+		)
+		return &d
+	}
+
+	operatorName := "$eq"
+	if expr, ok := value.(types.Document); ok && isOperatorDocument(expr) {
+		operatorName = expr.Keys()[0]
+	}
+
+	d := types.MustMakeDocument(
+		"operatorName", operatorName,
+		"specifiedAs", specifiedAs,
+	)
+
+	values := GetPathValues(doc, key)
+	if len(values) == 0 {
+		d.Set("reason", "field was missing")
+		return &d
+	}
+
+	d.Set("reason", "comparison failed")
+	d.Set("consideredValue", values[0])
+	return &d
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+)
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+
+	schema := types.MustMakeDocument(
+		"bsonType", "object",
+		"required", types.MustNewArray("name"),
+		"properties", types.MustMakeDocument(
+			"name", types.MustMakeDocument("bsonType", "string", "minLength", int32(2)),
+			"age", types.MustMakeDocument("bsonType", types.MustNewArray("int", "long"), "minimum", int32(0)),
+			"tags", types.MustMakeDocument("items", types.MustMakeDocument("enum", types.MustNewArray("a", "b"))),
+		),
+	)
+
+	for name, tc := range map[string]struct {
+		validator types.Document
+		doc       types.Document
+		details   any // nil if the document is valid
+		err       ErrorCode
+	}{
+		"SchemaValid": {
+			validator: types.MustMakeDocument("$jsonSchema", schema),
+			doc:       types.MustMakeDocument("_id", int32(1), "name", "foo", "age", int64(42), "tags", types.MustNewArray("a")),
+		},
+		"SchemaRequired": {
+			validator: types.MustMakeDocument("$jsonSchema", schema),
+			doc:       types.MustMakeDocument("_id", int32(1)),
+			details: types.MustMakeDocument(
+				"operatorName", "$jsonSchema",
+				"schemaRulesNotSatisfied", types.MustNewArray(types.MustMakeDocument(
+					"operatorName", "required",
+					"specifiedAs", types.MustMakeDocument("required", types.MustNewArray("name")),
+					"missingProperties", types.MustNewArray("name"),
+				)),
+			),
+		},
+		"SchemaProperties": {
+			validator: types.MustMakeDocument("$jsonSchema", schema),
+			doc:       types.MustMakeDocument("_id", int32(1), "name", "f", "age", "old"),
+			details: types.MustMakeDocument(
+				"operatorName", "$jsonSchema",
+				"schemaRulesNotSatisfied", types.MustNewArray(types.MustMakeDocument(
+					"operatorName", "properties",
+					"propertiesNotSatisfied", types.MustNewArray(
+						types.MustMakeDocument(
+							"propertyName", "name",
+							"details", types.MustNewArray(types.MustMakeDocument(
+								"operatorName", "minLength",
+								"specifiedAs", types.MustMakeDocument("minLength", int32(2)),
+								"reason", "specified string length was not satisfied",
+								"consideredValue", "f",
+							)),
+						),
+						types.MustMakeDocument(
+							"propertyName", "age",
+							"details", types.MustNewArray(types.MustMakeDocument(
+								"operatorName", "bsonType",
+								"specifiedAs", types.MustMakeDocument("bsonType", types.MustNewArray("int", "long")),
+								"reason", "type did not match",
+								"consideredValue", "old",
+								"consideredType", "string",
+							)),
+						),
+					),
+				)),
+			),
+		},
+		"Query": {
+			validator: types.MustMakeDocument("v", types.MustMakeDocument("$gt", int32(0))),
+			doc:       types.MustMakeDocument("_id", int32(1), "v", int32(0)),
+			details: types.MustMakeDocument(
+				"operatorName", "$gt",
+				"specifiedAs", types.MustMakeDocument("v", types.MustMakeDocument("$gt", int32(0))),
+				"reason", "comparison failed",
+				"consideredValue", int32(0),
+			),
+		},
+		"QueryAndSchema": {
+			validator: types.MustMakeDocument(
+				"$jsonSchema", types.MustMakeDocument("required", types.MustNewArray("v")),
+				"w", "foo",
+			),
+			doc: types.MustMakeDocument("_id", int32(1), "v", int32(1)),
+			details: types.MustMakeDocument(
+				"operatorName", "$and",
+				"clausesNotSatisfied", types.MustNewArray(types.MustMakeDocument(
+					"index", int32(1),
+					"details", types.MustMakeDocument(
+						"operatorName", "$eq",
+						"specifiedAs", types.MustMakeDocument("w", "foo"),
+						"reason", "field was missing",
+					),
+				)),
+			),
+		},
+		"UnknownKeyword": {
+			validator: types.MustMakeDocument("$jsonSchema", types.MustMakeDocument("foo", int32(1))),
+			err:       ErrFailedToParse,
+		},
+		"UnknownType": {
+			validator: types.MustMakeDocument("$jsonSchema", types.MustMakeDocument("bsonType", "integer")),
+			err:       ErrBadValue,
+		},
+		"Where": {
+			validator: types.MustMakeDocument("$where", "true"),
+			err:       ErrBadValue,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := NewValidator(tc.validator, "", "")
+			if tc.err != 0 {
+				var e *Error
+				require.True(t, errors.As(err, &e), "%v", err)
+				assert.Equal(t, tc.err, e.code)
+				return
+			}
+			require.NoError(t, err)
+
+			err = v.Check(zap.NewNop(), "db.coll", tc.doc)
+			if tc.details == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var e *Error
+			require.True(t, errors.As(err, &e), "%v", err)
+			assert.Equal(t, ErrDocumentValidationFailure, e.code)
+			expected := types.MustMakeDocument(
+				"failingDocumentId", tc.doc.Map()["_id"],
+				"details", tc.details,
+			)
+			assert.Equal(t, &expected, e.Info())
+		})
+	}
+}
+
+func TestValidatorLevelAction(t *testing.T) {
+	t.Parallel()
+
+	validator := types.MustMakeDocument("v", int32(1))
+	invalid := types.MustMakeDocument("_id", int32(1), "v", int32(2))
+
+	v, err := NewValidator(validator, "moderate", "warn")
+	require.NoError(t, err)
+	assert.True(t, v.Exempt(invalid))
+	assert.NoError(t, v.Check(zap.NewNop(), "db.coll", invalid))
+
+	v, err = NewValidator(validator, "", "")
+	require.NoError(t, err)
+	assert.False(t, v.Exempt(invalid))
+	assert.Error(t, v.Check(zap.NewNop(), "db.coll", invalid))
+
+	_, err = NewValidator(validator, "foo", "")
+	assert.Error(t, err)
+}
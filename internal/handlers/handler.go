@@ -34,12 +34,13 @@ import (
 // Handler data struct.
 type Handler struct {
 	// TODO replace those fields with opts *NewOpts
-	pgPool  *pg.Pool
-	l       *zap.Logger
-	shared  *shared.Handler
-	sql     common.Storage
-	jsonb1  common.Storage
-	metrics *Metrics
+	pgPool   *pg.Pool
+	l        *zap.Logger
+	shared   *shared.Handler
+	sql      common.Storage
+	jsonb1   common.Storage
+	metrics  *Metrics
+	sessions *common.Sessions
 
 	lastRequestID int32
 }
@@ -52,17 +53,19 @@ type NewOpts struct {
 	SQLStorage    common.Storage
 	JSONB1Storage common.Storage
 	Metrics       *Metrics
+	Sessions      *common.Sessions
 }
 
 // New returns a new handler.
 func New(opts *NewOpts) *Handler {
 	return &Handler{
-		pgPool:  opts.PgPool,
-		l:       opts.Logger,
-		shared:  opts.SharedHandler,
-		sql:     opts.SQLStorage,
-		jsonb1:  opts.JSONB1Storage,
-		metrics: opts.Metrics,
+		pgPool:   opts.PgPool,
+		l:        opts.Logger,
+		shared:   opts.SharedHandler,
+		sql:      opts.SQLStorage,
+		jsonb1:   opts.JSONB1Storage,
+		metrics:  opts.Metrics,
+		sessions: opts.Sessions,
 	}
 }
 
@@ -74,54 +77,112 @@ func New(opts *NewOpts) *Handler {
 //  * return any other error - it will be returned to the client as InternalError before terminating connection;
 //  * panic - that will terminate the connection without a response.
 //
+// Errors of legacy opcodes are returned as OP_REPLY with QueryFailure flag.
+// Legacy OP_INSERT, OP_UPDATE, OP_DELETE and OP_KILL_CURSORS messages have no replies;
+// nil header and body are returned for them.
+//
 //nolint:lll // arguments are long
 func (h *Handler) Handle(ctx context.Context, reqHeader *wire.MsgHeader, reqBody wire.MsgBody) (resHeader *wire.MsgHeader, resBody wire.MsgBody, closeConn bool) {
+	return h.handle(ctx, reqHeader, reqBody, true)
+}
+
+// HandleMoreToCome handles the request again to make the next reply streamed after the reply with moreToCome flag.
+//
+// Unlike Handle, it does not count the request, because the client sent it only once.
+// Awaitable hello is handled with the current topologyVersion, see streamedHelloRequest.
+func (h *Handler) HandleMoreToCome(
+	ctx context.Context, reqHeader *wire.MsgHeader, reqBody wire.MsgBody,
+) (resHeader *wire.MsgHeader, resBody wire.MsgBody, closeConn bool) {
+	// the request that can't be decoded is handled as is to get the same error
+	if msg, ok := reqBody.(*wire.OpMsg); ok {
+		if next, err := streamedHelloRequest(msg); err == nil {
+			reqBody = next
+		}
+	}
+
+	return h.handle(ctx, reqHeader, reqBody, false)
+}
+
+// handle handles the message; received is false for requests that are handled again, see HandleMoreToCome.
+func (h *Handler) handle(
+	ctx context.Context, reqHeader *wire.MsgHeader, reqBody wire.MsgBody, received bool,
+) (resHeader *wire.MsgHeader, resBody wire.MsgBody, closeConn bool) {
 	resHeader = new(wire.MsgHeader)
 	var err error
 
 	switch reqHeader.OpCode {
 	case wire.OP_MSG:
 		resHeader.OpCode = wire.OP_MSG
-		resBody, err = h.handleOpMsg(ctx, reqBody.(*wire.OpMsg))
+		resBody, err = h.handleOpMsg(ctx, reqBody.(*wire.OpMsg), received)
 	case wire.OP_QUERY:
 		resHeader.OpCode = wire.OP_REPLY
 		resBody, err = h.handleOpQuery(ctx, reqBody.(*wire.OpQuery))
+	case wire.OP_GET_MORE:
+		resHeader.OpCode = wire.OP_REPLY
+		resBody, err = h.handleOpGetMore(ctx, reqBody.(*wire.OpGetMore))
+
+	case wire.OP_INSERT, wire.OP_UPDATE, wire.OP_DELETE, wire.OP_KILL_CURSORS:
+		switch reqBody := reqBody.(type) {
+		case *wire.OpInsert:
+			err = h.handleOpInsert(ctx, reqBody)
+		case *wire.OpUpdate:
+			err = h.handleOpUpdate(ctx, reqBody)
+		case *wire.OpDelete:
+			err = h.handleOpDelete(ctx, reqBody)
+		case *wire.OpKillCursors:
+			err = h.handleOpKillCursors(ctx, reqBody)
+		}
+
+		// there are no replies for those opcodes, so errors are only logged
+		if err != nil {
+			_, recoverable := common.ProtocolError(err)
+			closeConn = !recoverable
+			h.l.Warn("Legacy request failed.", zap.Stringer("opcode", reqHeader.OpCode), zap.Error(err))
+		}
+
+		return nil, nil, closeConn
+
 	case wire.OP_REPLY:
 		fallthrough
-	case wire.OP_UPDATE:
-		fallthrough
-	case wire.OP_INSERT:
-		fallthrough
 	case wire.OP_GET_BY_OID:
 		fallthrough
-	case wire.OP_GET_MORE:
-		fallthrough
-	case wire.OP_DELETE:
-		fallthrough
-	case wire.OP_KILL_CURSORS:
-		fallthrough
 	case wire.OP_COMPRESSED:
 		fallthrough
 	default:
-		h.metrics.requests.WithLabelValues(reqHeader.OpCode.String(), "").Inc()
+		h.metrics.counters.AddRequest(reqHeader.OpCode.String(), "")
 		panic(fmt.Sprintf("unexpected OpCode %s", reqHeader.OpCode))
 	}
 
 	if err != nil {
-		if resHeader.OpCode != wire.OP_MSG {
-			panic(err)
-		}
-
 		protoErr, recoverable := common.ProtocolError(err)
 		closeConn = !recoverable
-		var res wire.OpMsg
-		err = res.SetSections(wire.OpMsgSection{
-			Documents: []types.Document{protoErr.Document()},
-		})
-		if err != nil {
-			panic(err)
+
+		switch resHeader.OpCode {
+		case wire.OP_MSG:
+			var res wire.OpMsg
+			err = res.SetSections(wire.OpMsgSection{
+				Documents: []types.Document{protoErr.Document()},
+			})
+			if err != nil {
+				panic(err)
+			}
+			resBody = &res
+
+		case wire.OP_REPLY:
+			resBody = &wire.OpReply{
+				ResponseFlags:  wire.OpReplyFlags(wire.OpReplyQueryFailure),
+				NumberReturned: 1,
+				Documents:      []types.Document{protoErr.QueryFailureDocument()},
+			}
+
+		default:
+			panic(fmt.Sprintf("unexpected OpCode %s", resHeader.OpCode))
 		}
-		resBody = &res
+	}
+
+	if reqMsg, ok := reqBody.(*wire.OpMsg); ok && reqMsg.FlagBits.FlagSet(wire.OpMsgChecksumPresent) {
+		// the checksum itself is calculated by wire.WriteMessage
+		resBody.(*wire.OpMsg).FlagBits |= wire.OpMsgFlags(wire.OpMsgChecksumPresent)
 	}
 
 	resHeader.ResponseTo = reqHeader.RequestID
@@ -142,8 +203,8 @@ func (h *Handler) Handle(ctx context.Context, reqHeader *wire.MsgHeader, reqBody
 	return
 }
 
-//nolint:goconst // good enough
-func (h *Handler) handleOpMsg(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+// handleOpMsg handles OP_MSG request; it is counted only if received is true.
+func (h *Handler) handleOpMsg(ctx context.Context, msg *wire.OpMsg, received bool) (*wire.OpMsg, error) {
 	document, err := msg.Document()
 	if err != nil {
 		return nil, lazyerrors.Error(err)
@@ -151,11 +212,76 @@ func (h *Handler) handleOpMsg(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg
 
 	cmd := document.Command()
 
-	h.metrics.requests.WithLabelValues(wire.OP_MSG.String(), cmd).Inc()
+	if received {
+		h.metrics.counters.AddRequest(wire.OP_MSG.String(), cmd)
+	}
+
+	return h.handleSessionCommand(ctx, document, msg)
+}
 
+// handleSessionCommand runs the command given in OP_MSG with handleCommand,
+// refreshing the session given by lsid and adding the opened cursor to it.
+//
+// It is also used for commands converted from legacy opcodes.
+func (h *Handler) handleSessionCommand(ctx context.Context, document types.Document, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	cmd := document.Command()
+
+	lsid, ok := document.Map()["lsid"]
+	if !ok {
+		return h.handleCommand(ctx, cmd, msg)
+	}
+
+	sessionID, err := common.GetSessionID(lsid)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.sessions.Refresh(sessionID); err != nil {
+		return nil, err
+	}
+
+	res, err := h.handleCommand(ctx, cmd, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// cursors opened in the session are killed with it
+	if cursorID, err := replyCursorID(res); err != nil {
+		return nil, lazyerrors.Error(err)
+	} else if cursorID != 0 {
+		h.sessions.AddCursor(sessionID, cursorID)
+	}
+
+	return res, nil
+}
+
+// handleCommand runs the command given in OP_MSG, counting failed commands and affected documents.
+//
+// It is also used for commands converted from legacy opcodes.
+func (h *Handler) handleCommand(ctx context.Context, cmd string, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	res, err := h.execCommand(ctx, cmd, msg)
+	if err != nil {
+		h.metrics.counters.AddFailed(cmd)
+		return nil, err
+	}
+
+	if err = countDocuments(h.metrics.counters, cmd, res); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// execCommand runs the command given in OP_MSG.
+//
+//nolint:goconst // good enough
+func (h *Handler) execCommand(ctx context.Context, cmd string, msg *wire.OpMsg) (*wire.OpMsg, error) {
 	switch cmd {
+	case "aggregate":
+		return h.shared.MsgAggregate(ctx, msg)
 	case "buildinfo":
 		return h.shared.MsgBuildInfo(ctx, msg)
+	case "collmod":
+		return h.shared.MsgCollMod(ctx, msg)
 	case "collstats":
 		// This command implements the follow database methods:
 		// 	- db.collection.stats()
@@ -165,6 +291,8 @@ func (h *Handler) handleOpMsg(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg
 		return h.shared.MsgCreate(ctx, msg)
 	case "dbstats":
 		return h.shared.MsgDBStats(ctx, msg)
+	case "distinct":
+		return h.shared.MsgDistinct(ctx, msg)
 	case "drop":
 		return h.shared.MsgDrop(ctx, msg)
 	case "dropdatabase":
@@ -173,28 +301,73 @@ func (h *Handler) handleOpMsg(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg
 		return h.shared.MsgGetCmdLineOpts(ctx, msg)
 	case "getlog":
 		return h.shared.MsgGetLog(ctx, msg)
+	case "getmore":
+		res, err := h.shared.MsgGetMore(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		if msg.FlagBits.FlagSet(wire.OpMsgExhaustAllowed) {
+			if err = setExhaust(res); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+		return res, nil
 	case "getparameter":
 		return h.shared.MsgGetParameter(ctx, msg)
 	case "hostinfo":
 		return h.shared.MsgHostInfo(ctx, msg)
 	case "ismaster", "hello":
-		return h.shared.MsgHello(ctx, msg)
+		res, err := h.shared.MsgHello(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		if msg.FlagBits.FlagSet(wire.OpMsgExhaustAllowed) {
+			if err = setHelloExhaust(msg, res); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+		return res, nil
+	case "endsessions":
+		return h.shared.MsgEndSessions(ctx, msg)
+	case "killallsessions":
+		return h.shared.MsgKillAllSessions(ctx, msg)
+	case "killcursors":
+		return h.shared.MsgKillCursors(ctx, msg)
+	case "killsessions":
+		return h.shared.MsgKillSessions(ctx, msg)
 	case "listcollections":
 		return h.shared.MsgListCollections(ctx, msg)
 	case "listdatabases":
 		return h.shared.MsgListDatabases(ctx, msg)
 	case "ping":
 		return h.shared.MsgPing(ctx, msg)
+	case "renamecollection":
+		return h.shared.MsgRenameCollection(ctx, msg)
+	case "refreshsessions":
+		return h.shared.MsgRefreshSessions(ctx, msg)
 	case "whatsmyuri":
 		return h.shared.MsgWhatsMyURI(ctx, msg)
 	case "serverstatus":
 		return h.shared.MsgServerStatus(ctx, msg)
+	case "startsession":
+		return h.shared.MsgStartSession(ctx, msg)
 
 	case "createindexes", "delete", "find", "insert", "update", "count":
-		storage, err := h.msgStorage(ctx, msg)
+		document, err := msg.Document()
 		if err != nil {
 			return nil, lazyerrors.Error(err)
 		}
+		if shared.IsOplog(document) {
+			return h.shared.MsgOplog(ctx, msg)
+		}
+
+		storage, view, err := h.msgStorage(ctx, msg)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+		if view != nil {
+			return h.shared.MsgView(ctx, msg, view)
+		}
 
 		switch cmd {
 		case "createindexes":
@@ -221,73 +394,211 @@ func (h *Handler) handleOpMsg(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg
 	}
 }
 
-func (h *Handler) handleOpQuery(ctx context.Context, query *wire.OpQuery) (*wire.OpReply, error) {
-	cmd := query.Query.Command()
-	h.metrics.requests.WithLabelValues(wire.OP_QUERY.String(), cmd).Inc()
+// setExhaust sets moreToCome flag on getMore reply if the cursor is not exhausted yet,
+// so the next replies are streamed to the client without waiting for requests.
+func setExhaust(res *wire.OpMsg) error {
+	document, err := res.Document()
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	cursor, ok := document.Map()["cursor"].(types.Document)
+	if !ok {
+		return lazyerrors.Errorf("setExhaust: no cursor in reply %v", document)
+	}
+
+	if id, _ := cursor.Map()["id"].(int64); id != 0 {
+		res.FlagBits |= wire.OpMsgFlags(wire.OpMsgMoreToCome)
+	}
+
+	return nil
+}
+
+// replyCursorID returns the cursor ID from the command reply, or 0 if there is no open cursor.
+func replyCursorID(res *wire.OpMsg) (int64, error) {
+	document, err := res.Document()
+	if err != nil {
+		return 0, lazyerrors.Error(err)
+	}
+
+	cursor, ok := document.Map()["cursor"].(types.Document)
+	if !ok {
+		return 0, nil
+	}
+
+	id, _ := cursor.Map()["id"].(int64)
+	return id, nil
+}
+
+// countDocuments counts documents affected by the command or returned by it, as reported in the reply.
+func countDocuments(counters *common.Counters, cmd string, res *wire.OpMsg) error {
+	if res == nil {
+		return nil
+	}
+
+	document, err := res.Document()
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	switch cmd {
+	case "insert":
+		n, _ := m["n"].(int32)
+		counters.AddDocuments("inserted", int64(n))
+	case "update":
+		n, _ := m["nModified"].(int32)
+		counters.AddDocuments("updated", int64(n))
+	case "delete":
+		n, _ := m["n"].(int32)
+		counters.AddDocuments("deleted", int64(n))
+	}
+
+	if cursor, ok := m["cursor"].(types.Document); ok {
+		for _, key := range []string{"firstBatch", "nextBatch"} {
+			if batch, ok := cursor.Map()[key].(*types.Array); ok {
+				counters.AddDocuments("returned", int64(batch.Len()))
+			}
+		}
+	}
+
+	return nil
+}
+
+// setHelloExhaust sets moreToCome flag on awaitable hello reply to the request with exhaustAllowed flag,
+// so the next replies are streamed to the client for topology monitoring.
+//
+// Replies are not streamed for zero maxAwaitTimeMS, as they would be sent without any delay.
+func setHelloExhaust(req, res *wire.OpMsg) error {
+	document, err := req.Document()
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	// invalid values are rejected by MsgHello
+	if maxAwaitTimeMS, _ := common.GetWholeNumberParam(document, "maxAwaitTimeMS", 0); maxAwaitTimeMS > 0 {
+		res.FlagBits |= wire.OpMsgFlags(wire.OpMsgMoreToCome)
+	}
+
+	return nil
+}
+
+// streamedHelloRequest returns the request for the next streamed reply to awaitable hello.
+//
+// It has the current topologyVersion, so the reply is sent after maxAwaitTimeMS
+// even if the client's topologyVersion was outdated. Other requests are returned as is.
+func streamedHelloRequest(msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	switch document.Command() {
+	case "hello", "ismaster":
+	default:
+		return msg, nil
+	}
+
+	m := document.Map()
+	if _, ok := m["topologyVersion"]; !ok {
+		return msg, nil
+	}
 
-	if query.FullCollectionName == "admin.$cmd" {
-		return h.shared.QueryCmd(ctx, query)
+	pairs := make([]any, 0, len(m)*2)
+	for _, k := range document.Keys() {
+		v := m[k]
+		if k == "topologyVersion" {
+			v = shared.TopologyVersion()
+		}
+		pairs = append(pairs, k, v)
+	}
+
+	next, err := types.MakeDocument(pairs...)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
 	}
 
-	return nil, common.NewErrorMessage(common.ErrNotImplemented, "handleOpQuery: unhandled collection %q", query.FullCollectionName)
+	res := &wire.OpMsg{FlagBits: msg.FlagBits}
+	if err = res.SetSections(wire.OpMsgSection{Documents: []types.Document{next}}); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
 }
 
-func (h *Handler) msgStorage(ctx context.Context, msg *wire.OpMsg) (common.Storage, error) {
+// msgStorage returns the storage of the collection targeted by the given command,
+// or the view if the collection does not exist and the view does.
+func (h *Handler) msgStorage(ctx context.Context, msg *wire.OpMsg) (common.Storage, *pg.View, error) {
 	document, err := msg.Document()
 	if err != nil {
-		return nil, fmt.Errorf("Handler.msgStorage: %w", err)
+		return nil, nil, fmt.Errorf("Handler.msgStorage: %w", err)
 	}
 
 	m := document.Map()
 	command := document.Command()
 
 	if command == "createindexes" {
+		// createIndexes creates missing tables, so views are checked first
+		view, err := h.shared.GetView(ctx, document)
+		if err != nil || view != nil {
+			return nil, view, err
+		}
+
 		// TODO https://github.com/FerretDB/FerretDB/issues/78
-		return h.jsonb1, nil
+		return h.jsonb1, nil, nil
 	}
 
 	collection := m[command].(string)
 	db := m["$db"].(string)
 
 	var jsonbTableExist bool
+	table := pg.TableIdentifier(db, collection)
 	sql := `SELECT COUNT(*) > 0 FROM information_schema.columns WHERE column_name = $1 AND table_schema = $2 AND table_name = $3`
-	if err := h.pgPool.QueryRow(ctx, sql, "_jsonb", db, collection).Scan(&jsonbTableExist); err != nil {
-		return nil, lazyerrors.Errorf("Handler.msgStorage: %w", err)
+	if err := h.pgPool.QueryRow(ctx, sql, "_jsonb", table[0], table[1]).Scan(&jsonbTableExist); err != nil {
+		return nil, nil, lazyerrors.Errorf("Handler.msgStorage: %w", err)
+	}
+
+	// the catalog of views is queried only for commands targeting non-jsonb1 tables
+	if !jsonbTableExist {
+		view, err := h.shared.GetView(ctx, document)
+		if err != nil || view != nil {
+			return nil, view, err
+		}
 	}
 
 	switch command {
 	case "delete", "find", "count":
 		if jsonbTableExist {
-			return h.jsonb1, nil
+			return h.jsonb1, nil, nil
 		}
-		return h.sql, nil
+		return h.sql, nil, nil
 
 	case "insert", "update":
 		if jsonbTableExist {
-			return h.jsonb1, nil
+			return h.jsonb1, nil, nil
 		}
 
 		// check if SQL table exist
 		tables, err := h.pgPool.Tables(ctx, db)
 		if err != nil {
-			return nil, lazyerrors.Errorf("Handler.msgStorage: %w", err)
+			return nil, nil, lazyerrors.Errorf("Handler.msgStorage: %w", err)
 		}
 		if i := sort.SearchStrings(tables, collection); i < len(tables) && tables[i] == collection {
-			return h.sql, nil
+			return h.sql, nil, nil
 		}
 
 		// create schema if needed
 		if err := h.pgPool.CreateSchema(ctx, db); err != nil && err != pg.ErrAlreadyExist {
-			return nil, lazyerrors.Errorf("Handler.msgStorage: %w", err)
+			return nil, nil, lazyerrors.Errorf("Handler.msgStorage: %w", common.NamespaceError(err, db, collection))
 		}
 
 		// create table
 		if err := h.pgPool.CreateTable(ctx, db, collection); err != nil {
-			return nil, lazyerrors.Errorf("Handler.msgStorage: %w", err)
+			return nil, nil, lazyerrors.Errorf("Handler.msgStorage: %w", common.NamespaceError(err, db, collection))
 		}
 
 		h.l.Info("Created jsonb1 table.", zap.String("schema", db), zap.String("table", collection))
-		return h.jsonb1, nil
+		return h.jsonb1, nil, nil
 
 	default:
 		panic(fmt.Sprintf("unhandled command %q", command))
@@ -0,0 +1,358 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// This file contains handlers for legacy opcodes.
+// They convert requests to commands, run them with the same code as OP_MSG, and convert replies back.
+
+// handleOpQuery handles OP_QUERY messages: commands against db.$cmd and finds against collections.
+func (h *Handler) handleOpQuery(ctx context.Context, query *wire.OpQuery) (*wire.OpReply, error) {
+	db, collection, err := common.SplitNamespace(query.FullCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection == "$cmd" {
+		return h.handleQueryCmd(ctx, db, query)
+	}
+
+	return h.handleQueryFind(ctx, db, collection, query)
+}
+
+// handleQueryCmd runs OP_QUERY command.
+//
+// Recoverable command errors are returned in the reply document, as MongoDB does.
+func (h *Handler) handleQueryCmd(ctx context.Context, db string, query *wire.OpQuery) (*wire.OpReply, error) {
+	// drivers wrap commands with $query when they add $readPreference
+	cmd := query.Query
+	if q, ok := cmd.Map()["$query"].(types.Document); ok {
+		cmd = q
+	}
+
+	name := cmd.Command()
+	h.metrics.counters.AddRequest(wire.OP_QUERY.String(), name)
+
+	if name == "ismaster" {
+		q := *query
+		q.Query = cmd
+		return h.shared.QueryCmd(ctx, &q)
+	}
+
+	res, err := h.runCommand(ctx, db, cmd)
+	if err != nil {
+		protoErr, recoverable := common.ProtocolError(err)
+		if !recoverable {
+			return nil, err
+		}
+		res = protoErr.Document()
+	}
+
+	reply := &wire.OpReply{
+		NumberReturned: 1,
+		Documents:      []types.Document{res},
+	}
+	return reply, nil
+}
+
+// handleQueryFind runs OP_QUERY against collection as find command.
+func (h *Handler) handleQueryFind(ctx context.Context, db, collection string, query *wire.OpQuery) (*wire.OpReply, error) {
+	h.metrics.counters.AddRequest(wire.OP_QUERY.String(), "find")
+
+	cmd := types.MustMakeDocument("find", collection)
+
+	queryM := query.Query.Map()
+	if _, ok := queryM["$query"]; ok {
+		// query with modifiers
+		for _, k := range query.Query.Keys() {
+			var key string
+			switch k {
+			case "$query":
+				key = "filter"
+			case "$orderby":
+				key = "sort"
+			case "$hint":
+				key = "hint"
+			case "$comment":
+				key = "comment"
+			case "$maxTimeMS":
+				key = "maxTimeMS"
+			case "$readPreference":
+				continue
+			default:
+				return nil, common.NewErrorMessage(common.ErrNotImplemented, "query modifier %q is not implemented", k)
+			}
+
+			if err := cmd.Set(key, queryM[k]); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+	} else {
+		if err := cmd.Set("filter", query.Query); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	if query.ReturnFieldsSelector != nil {
+		if err := cmd.Set("projection", *query.ReturnFieldsSelector); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	if query.NumberToSkip != 0 {
+		if err := cmd.Set("skip", query.NumberToSkip); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	if query.Flags.FlagSet(wire.OpQueryTailableCursor) {
+		if err := cmd.Set("tailable", true); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	if query.Flags.FlagSet(wire.OpQueryAwaitData) {
+		if err := cmd.Set("awaitData", true); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	// negative numberToReturn (and 1) means a single batch, positive means batch size
+	var err error
+	switch n := query.NumberToReturn; {
+	case n < 0:
+		err = cmd.Set("limit", n)
+	case n == 1:
+		err = cmd.Set("limit", int32(-1))
+	case n > 1:
+		err = cmd.Set("batchSize", n)
+	}
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	res, err := h.runCommand(ctx, db, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return cursorReply(res, "firstBatch")
+}
+
+// handleOpGetMore handles OP_GET_MORE message as getMore command.
+func (h *Handler) handleOpGetMore(ctx context.Context, getMore *wire.OpGetMore) (*wire.OpReply, error) {
+	h.metrics.counters.AddRequest(wire.OP_GET_MORE.String(), "")
+
+	db, collection, err := common.SplitNamespace(getMore.FullCollectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := types.MustMakeDocument(
+		"getMore", getMore.CursorID,
+		"collection", collection,
+	)
+	if n := getMore.NumberToReturn; n > 0 {
+		if err = cmd.Set("batchSize", n); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	res, err := h.runCommand(ctx, db, cmd)
+	if err != nil {
+		var protoErr *common.Error
+		if errors.As(err, &protoErr) && protoErr.Code() == common.ErrCursorNotFound {
+			return &wire.OpReply{ResponseFlags: wire.OpReplyFlags(wire.OpReplyCursorNotFound)}, nil
+		}
+		return nil, err
+	}
+
+	return cursorReply(res, "nextBatch")
+}
+
+// handleOpInsert handles OP_INSERT message as insert command.
+func (h *Handler) handleOpInsert(ctx context.Context, insert *wire.OpInsert) error {
+	h.metrics.counters.AddRequest(wire.OP_INSERT.String(), "")
+
+	db, collection, err := common.SplitNamespace(insert.FullCollectionName)
+	if err != nil {
+		return err
+	}
+
+	docs := new(types.Array)
+	for _, doc := range insert.Documents {
+		if err = docs.Append(doc); err != nil {
+			return lazyerrors.Error(err)
+		}
+	}
+
+	cmd := types.MustMakeDocument(
+		"insert", collection,
+		"documents", docs,
+		"ordered", !insert.Flags.FlagSet(wire.OpInsertContinueOnError),
+	)
+	return h.runLegacyWrite(ctx, db, cmd)
+}
+
+// handleOpUpdate handles OP_UPDATE message as update command.
+func (h *Handler) handleOpUpdate(ctx context.Context, update *wire.OpUpdate) error {
+	h.metrics.counters.AddRequest(wire.OP_UPDATE.String(), "")
+
+	db, collection, err := common.SplitNamespace(update.FullCollectionName)
+	if err != nil {
+		return err
+	}
+
+	cmd := types.MustMakeDocument(
+		"update", collection,
+		"updates", types.MustNewArray(types.MustMakeDocument(
+			"q", update.Selector,
+			"u", update.Update,
+			"upsert", update.Flags.FlagSet(wire.OpUpdateUpsert),
+			"multi", update.Flags.FlagSet(wire.OpUpdateMultiUpdate),
+		)),
+	)
+	return h.runLegacyWrite(ctx, db, cmd)
+}
+
+// handleOpDelete handles OP_DELETE message as delete command.
+func (h *Handler) handleOpDelete(ctx context.Context, del *wire.OpDelete) error {
+	h.metrics.counters.AddRequest(wire.OP_DELETE.String(), "")
+
+	db, collection, err := common.SplitNamespace(del.FullCollectionName)
+	if err != nil {
+		return err
+	}
+
+	var limit int32
+	if del.Flags.FlagSet(wire.OpDeleteSingleRemove) {
+		limit = 1
+	}
+
+	cmd := types.MustMakeDocument(
+		"delete", collection,
+		"deletes", types.MustNewArray(types.MustMakeDocument(
+			"q", del.Selector,
+			"limit", limit,
+		)),
+	)
+	return h.runLegacyWrite(ctx, db, cmd)
+}
+
+// handleOpKillCursors handles OP_KILL_CURSORS message as killCursors command.
+func (h *Handler) handleOpKillCursors(ctx context.Context, kill *wire.OpKillCursors) error {
+	h.metrics.counters.AddRequest(wire.OP_KILL_CURSORS.String(), "")
+
+	ids := new(types.Array)
+	for _, id := range kill.CursorIDs {
+		if err := ids.Append(id); err != nil {
+			return lazyerrors.Error(err)
+		}
+	}
+
+	// legacy cursor ids are not bound to the namespace
+	cmd := types.MustMakeDocument(
+		"killCursors", "$cmd",
+		"cursors", ids,
+	)
+	_, err := h.runCommand(ctx, "admin", cmd)
+	return err
+}
+
+// runLegacyWrite runs insert, update or delete command for the legacy opcode.
+//
+// The client does not expect replies for legacy writes, so write errors are only logged.
+func (h *Handler) runLegacyWrite(ctx context.Context, db string, cmd types.Document) error {
+	res, err := h.runCommand(ctx, db, cmd)
+	if err != nil {
+		return err
+	}
+
+	writeErrors, _ := res.Map()["writeErrors"].(*types.Array)
+	for i := 0; writeErrors != nil && i < writeErrors.Len(); i++ {
+		v, _ := writeErrors.Get(i)
+		writeError, _ := v.(types.Document)
+		errmsg, _ := writeError.Map()["errmsg"].(string)
+		h.l.Warn(
+			"Legacy write failed.",
+			zap.String("command", cmd.Command()), zap.String("db", db), zap.String("errmsg", errmsg),
+		)
+	}
+
+	return nil
+}
+
+// runCommand runs the command against the given database the same way as OP_MSG and returns the reply document.
+func (h *Handler) runCommand(ctx context.Context, db string, cmd types.Document) (types.Document, error) {
+	if err := cmd.Set("$db", db); err != nil {
+		return types.Document{}, lazyerrors.Error(err)
+	}
+
+	var msg wire.OpMsg
+	if err := msg.SetSections(wire.OpMsgSection{Documents: []types.Document{cmd}}); err != nil {
+		return types.Document{}, lazyerrors.Error(err)
+	}
+
+	res, err := h.handleSessionCommand(ctx, cmd, &msg)
+	if err != nil {
+		return types.Document{}, err
+	}
+
+	return res.Document()
+}
+
+// cursorReply converts find or getMore reply document to OP_REPLY.
+func cursorReply(res types.Document, batchField string) (*wire.OpReply, error) {
+	cursor, ok := res.Map()["cursor"].(types.Document)
+	if !ok {
+		return nil, lazyerrors.Errorf("cursorReply: no cursor in reply %v", res)
+	}
+
+	cursorM := cursor.Map()
+	batch, ok := cursorM[batchField].(*types.Array)
+	if !ok {
+		return nil, lazyerrors.Errorf("cursorReply: no %s in reply %v", batchField, res)
+	}
+
+	docs := make([]types.Document, batch.Len())
+	for i := range docs {
+		v, err := batch.Get(i)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		if docs[i], ok = v.(types.Document); !ok {
+			return nil, lazyerrors.Errorf("cursorReply: unexpected %T in %s", v, batchField)
+		}
+	}
+
+	reply := &wire.OpReply{
+		CursorID:       cursorM["id"].(int64),
+		NumberReturned: int32(len(docs)),
+		Documents:      docs,
+	}
+	return reply, nil
+}
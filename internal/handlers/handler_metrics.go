@@ -14,7 +14,11 @@
 
 package handlers
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+)
 
 const (
 	namespace = "ferretdb"
@@ -22,33 +26,59 @@ const (
 )
 
 // Metrics represents handler metrics.
+//
+// They are collected from counters shared with the serverStatus command.
 type Metrics struct {
-	requests *prometheus.CounterVec
+	counters  *common.Counters
+	requests  *prometheus.Desc
+	failed    *prometheus.Desc
+	documents *prometheus.Desc
 }
 
-// NewMetrics creates new handler metrics.
-func NewMetrics() *Metrics {
+// NewMetrics creates new handler metrics for the given counters.
+func NewMetrics(counters *common.Counters) *Metrics {
 	return &Metrics{
-		requests: prometheus.NewCounterVec(
-			prometheus.CounterOpts{
-				Namespace: namespace,
-				Subsystem: subsystem,
-				Name:      "requests_total",
-				Help:      "Total number of requests.",
-			},
-			[]string{"opcode", "command"},
+		counters: counters,
+		requests: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "requests_total"),
+			"Total number of requests.",
+			[]string{"opcode", "command"}, nil,
+		),
+		failed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "failed_commands_total"),
+			"Total number of failed commands.",
+			[]string{"command"}, nil,
+		),
+		documents: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "documents_total"),
+			"Total number of deleted, inserted, returned and updated documents.",
+			[]string{"kind"}, nil,
 		),
 	}
 }
 
 // Describe implements prometheus.Collector.
 func (lm *Metrics) Describe(ch chan<- *prometheus.Desc) {
-	lm.requests.Describe(ch)
+	ch <- lm.requests
+	ch <- lm.failed
+	ch <- lm.documents
 }
 
 // Collect implements prometheus.Collector.
 func (lm *Metrics) Collect(ch chan<- prometheus.Metric) {
-	lm.requests.Collect(ch)
+	s := lm.counters.Snapshot()
+
+	for k, v := range s.Requests {
+		ch <- prometheus.MustNewConstMetric(lm.requests, prometheus.CounterValue, float64(v), k.OpCode, k.Command)
+	}
+
+	for command, v := range s.Failed {
+		ch <- prometheus.MustNewConstMetric(lm.failed, prometheus.CounterValue, float64(v), command)
+	}
+
+	for kind, v := range s.Documents {
+		ch <- prometheus.MustNewConstMetric(lm.documents, prometheus.CounterValue, float64(v), kind)
+	}
 }
 
 // check interfaces
@@ -16,6 +16,7 @@ package handlers
 
 import (
 	"context"
+	"math"
 	"os"
 	"runtime"
 	"strconv"
@@ -28,6 +29,7 @@ import (
 	"go.uber.org/zap/zaptest"
 
 	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/handlers/jsonb1"
 	"github.com/FerretDB/FerretDB/internal/handlers/shared"
 	"github.com/FerretDB/FerretDB/internal/handlers/sql"
@@ -48,16 +50,27 @@ func setup(t *testing.T, poolOpts *testutil.PoolOpts) (context.Context, *Handler
 	ctx := testutil.Ctx(t)
 	pool := testutil.Pool(ctx, t, poolOpts)
 	l := zaptest.NewLogger(t)
-	shared := shared.NewHandler(pool, "127.0.0.1:12345")
-	sql := sql.NewStorage(pool, l.Sugar())
-	jsonb1 := jsonb1.NewStorage(pool, l)
+	cursors := common.NewCursors()
+	sessions := common.NewSessions(cursors, 0)
+	counters := common.NewCounters()
+	shared := shared.NewHandler(&shared.NewOpts{
+		PgPool:       pool,
+		PeerAddr:     "127.0.0.1:12345",
+		ConnectionID: 42,
+		Cursors:      cursors,
+		Sessions:     sessions,
+		Counters:     counters,
+	})
+	sql := sql.NewStorage(pool, l.Sugar(), cursors)
+	jsonb1 := jsonb1.NewStorage(pool, l, cursors)
 	handler := New(&NewOpts{
 		PgPool:        pool,
 		Logger:        l,
 		SharedHandler: shared,
 		SQLStorage:    sql,
 		JSONB1Storage: jsonb1,
-		Metrics:       NewMetrics(),
+		Metrics:       NewMetrics(counters),
+		Sessions:      sessions,
 	})
 
 	return ctx, handler, pool
@@ -344,6 +357,46 @@ func TestFind(t *testing.T) {
 				),
 			),
 		},
+		"SkipLimit": {
+			req: types.MustMakeDocument(
+				"find", "actor",
+				"sort", types.MustMakeDocument(
+					"actor_id", int32(1),
+				),
+				"skip", int32(1),
+				"limit", int32(1),
+			),
+			resp: types.MustNewArray(
+				types.MustMakeDocument(
+					"_id", types.ObjectID{0x61, 0x2e, 0xc2, 0x80, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02},
+					"actor_id", int32(2),
+					"first_name", "NICK",
+					"last_name", "WAHLBERG",
+					"last_update", lastUpdate,
+				),
+			),
+		},
+		"NegativeLimit": {
+			req: types.MustMakeDocument(
+				"find", "actor",
+				"sort", types.MustMakeDocument(
+					"actor_id", int32(1),
+				),
+				"skip", int64(2),
+				"limit", float64(-1),
+				"comment", "TestFind/NegativeLimit",
+				"maxTimeMS", int32(10_000),
+			),
+			resp: types.MustNewArray(
+				types.MustMakeDocument(
+					"_id", types.ObjectID{0x61, 0x2e, 0xc2, 0x80, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x03},
+					"actor_id", int32(3),
+					"first_name", "ED",
+					"last_name", "CHASE",
+					"last_update", lastUpdate,
+				),
+			),
+		},
 		"RegexStringOptions": {
 			req: types.MustMakeDocument(
 				"find", "actor",
@@ -367,47 +420,1744 @@ func TestFind(t *testing.T) {
 					"last_update", lastUpdate,
 				),
 			),
-		},
+		},
+		"ProjectionExclusion": {
+			req: types.MustMakeDocument(
+				"find", "actor",
+				"filter", types.MustMakeDocument(
+					"actor_id", int32(28),
+				),
+				"projection", types.MustMakeDocument(
+					"actor_id", int32(0),
+					"last_update", int32(0),
+				),
+			),
+			resp: types.MustNewArray(
+				types.MustMakeDocument(
+					"_id", types.ObjectID{0x61, 0x2e, 0xc2, 0x80, 0x00, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x00, 0x1c},
+					"first_name", "WOODY",
+					"last_name", "HOFFMAN",
+				),
+			),
+		},
+		"ProjectionExpression": {
+			req: types.MustMakeDocument(
+				"find", "actor",
+				"filter", types.MustMakeDocument(
+					"actor_id", int32(28),
+				),
+				"projection", types.MustMakeDocument(
+					"_id", int32(0),
+					"name", types.MustMakeDocument(
+						"$concat", types.MustNewArray("$first_name", " ", "$last_name"),
+					),
+				),
+			),
+			resp: types.MustNewArray(
+				types.MustMakeDocument(
+					"name", "WOODY HOFFMAN",
+				),
+			),
+		},
+	}
+
+	for name, tc := range testCases { //nolint:paralleltest // false positive
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, schema := range []string{"monila", "pagila"} {
+				t.Run(schema, func(t *testing.T) {
+					// not parallel because we modify tc
+
+					tc.req.Set("$db", schema)
+
+					if schema == "pagila" {
+						for i := 0; i < tc.resp.Len(); i++ {
+							doc, err := tc.resp.Get(i)
+							require.NoError(t, err)
+							d := doc.(types.Document)
+							d.Remove("_id")
+							err = tc.resp.Set(i, d)
+							require.NoError(t, err)
+						}
+					}
+
+					actual := handle(ctx, t, handler, tc.req)
+					expected := types.MustMakeDocument(
+						"cursor", types.MustMakeDocument(
+							"firstBatch", tc.resp,
+							"id", int64(0),
+							"ns", schema+".actor",
+						),
+						"ok", float64(1),
+					)
+					assert.Equal(t, expected, actual)
+				})
+			}
+		})
+	}
+}
+
+func TestFindBatchSizeGetMore(t *testing.T) {
+	t.Parallel()
+	ctx, handler, _ := setup(t, &testutil.PoolOpts{
+		ReadOnly: true,
+	})
+
+	for _, schema := range []string{"monila", "pagila"} {
+		schema := schema
+		t.Run(schema, func(t *testing.T) {
+			t.Parallel()
+
+			actual := handle(ctx, t, handler, types.MustMakeDocument(
+				"find", "actor",
+				"projection", types.MustMakeDocument(
+					"actor_id", int32(1),
+					"_id", int32(0),
+				),
+				"sort", types.MustMakeDocument(
+					"actor_id", int32(1),
+				),
+				"limit", int32(3),
+				"batchSize", int32(1),
+				"$db", schema,
+			))
+			cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+			require.NotZero(t, cursorID)
+			firstBatch := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+			assert.Equal(t, types.MustNewArray(types.MustMakeDocument("actor_id", int32(1))), firstBatch)
+
+			actual = handle(ctx, t, handler, types.MustMakeDocument(
+				"getMore", cursorID,
+				"collection", "actor",
+				"batchSize", int32(1),
+				"$db", schema,
+			))
+			expected := types.MustMakeDocument(
+				"cursor", types.MustMakeDocument(
+					"nextBatch", types.MustNewArray(types.MustMakeDocument("actor_id", int32(2))),
+					"id", cursorID,
+					"ns", schema+".actor",
+				),
+				"ok", float64(1),
+			)
+			assert.Equal(t, expected, actual)
+
+			actual = handle(ctx, t, handler, types.MustMakeDocument(
+				"getMore", cursorID,
+				"collection", "actor",
+				"$db", schema,
+			))
+			expected = types.MustMakeDocument(
+				"cursor", types.MustMakeDocument(
+					"nextBatch", types.MustNewArray(types.MustMakeDocument("actor_id", int32(3))),
+					"id", int64(0),
+					"ns", schema+".actor",
+				),
+				"ok", float64(1),
+			)
+			assert.Equal(t, expected, actual)
+
+			actual = handle(ctx, t, handler, types.MustMakeDocument(
+				"killCursors", "actor",
+				"cursors", types.MustNewArray(cursorID),
+				"$db", schema,
+			))
+			expected = types.MustMakeDocument(
+				"cursorsKilled", new(types.Array),
+				"cursorsNotFound", types.MustNewArray(cursorID),
+				"cursorsAlive", new(types.Array),
+				"cursorsUnknown", new(types.Array),
+				"ok", float64(1),
+			)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestCountWithoutQuery(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+
+	// the table is not analyzed yet, so statistics can't be used for counting
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(
+			types.MustMakeDocument("_id", int32(1)),
+			types.MustMakeDocument("_id", int32(2)),
+			types.MustMakeDocument("_id", int32(3)),
+		),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", collection,
+		"$db", db,
+	))
+	assert.Equal(t, types.MustMakeDocument("n", int32(3), "ok", float64(1)), actual)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"delete", collection,
+		"deletes", types.MustNewArray(types.MustMakeDocument(
+			"q", types.MustMakeDocument("_id", int32(1)),
+			"limit", int32(1),
+		)),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", collection,
+		"skip", int32(1),
+		"$db", db,
+	))
+	assert.Equal(t, types.MustMakeDocument("n", int32(1), "ok", float64(1)), actual)
+
+	// analyzed table has statistics, so they are used for counts without query, skip, limit and hint
+	_, err := pool.Exec(ctx, `ANALYZE `+pg.TableIdentifier(db, collection).Sanitize())
+	require.NoError(t, err)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", collection,
+		"$db", db,
+	))
+	assert.Equal(t, types.MustMakeDocument("n", int32(2), "ok", float64(1)), actual)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", collection,
+		"limit", int32(1),
+		"$db", db,
+	))
+	assert.Equal(t, types.MustMakeDocument("n", int32(1), "ok", float64(1)), actual)
+}
+
+func TestOpMsgFlags(t *testing.T) {
+	t.Parallel()
+	ctx, handler, _ := setup(t, &testutil.PoolOpts{
+		ReadOnly: true,
+	})
+
+	handleFlags := func(t *testing.T, flags wire.OpMsgFlags, req types.Document) *wire.OpMsg {
+		t.Helper()
+
+		var reqMsg wire.OpMsg
+		reqMsg.FlagBits = flags
+		err := reqMsg.SetSections(wire.OpMsgSection{
+			Documents: []types.Document{req},
+		})
+		require.NoError(t, err)
+
+		_, resBody, closeConn := handler.Handle(ctx, &wire.MsgHeader{RequestID: 1, OpCode: wire.OP_MSG}, &reqMsg)
+		require.False(t, closeConn, "%s", wire.DumpMsgBody(resBody))
+
+		return resBody.(*wire.OpMsg)
+	}
+
+	t.Run("Checksum", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleFlags(t, wire.OpMsgFlags(wire.OpMsgChecksumPresent), types.MustMakeDocument(
+			"ping", int32(1),
+			"$db", "admin",
+		))
+		assert.True(t, res.FlagBits.FlagSet(wire.OpMsgChecksumPresent))
+
+		res = handleFlags(t, 0, types.MustMakeDocument(
+			"ping", int32(1),
+			"$db", "admin",
+		))
+		assert.False(t, res.FlagBits.FlagSet(wire.OpMsgChecksumPresent))
+	})
+
+	t.Run("ExhaustAllowed", func(t *testing.T) {
+		t.Parallel()
+
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"find", "actor",
+			"limit", int32(3),
+			"batchSize", int32(1),
+			"$db", "monila",
+		))
+		cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+		require.NotZero(t, cursorID)
+
+		getMore := types.MustMakeDocument(
+			"getMore", cursorID,
+			"collection", "actor",
+			"batchSize", int32(1),
+			"$db", "monila",
+		)
+
+		res := handleFlags(t, wire.OpMsgFlags(wire.OpMsgExhaustAllowed), getMore)
+		assert.True(t, res.FlagBits.FlagSet(wire.OpMsgMoreToCome))
+
+		res = handleFlags(t, wire.OpMsgFlags(wire.OpMsgExhaustAllowed), getMore)
+		assert.False(t, res.FlagBits.FlagSet(wire.OpMsgMoreToCome), "cursor should be exhausted")
+	})
+
+	t.Run("AwaitableHello", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleFlags(t, wire.OpMsgFlags(wire.OpMsgExhaustAllowed), types.MustMakeDocument(
+			"hello", int32(1),
+			"topologyVersion", types.MustMakeDocument(
+				"processId", types.ObjectID{1}, // other process, so the reply is immediate
+				"counter", int64(0),
+			),
+			"maxAwaitTimeMS", int32(1),
+			"$db", "admin",
+		))
+		assert.True(t, res.FlagBits.FlagSet(wire.OpMsgMoreToCome))
+
+		document, err := res.Document()
+		require.NoError(t, err)
+		topologyVersion := testutil.GetByPath(t, document, "topologyVersion").(types.Document)
+
+		start := time.Now()
+		res = handleFlags(t, 0, types.MustMakeDocument(
+			"hello", int32(1),
+			"topologyVersion", topologyVersion,
+			"maxAwaitTimeMS", int32(100),
+			"$db", "admin",
+		))
+		assert.False(t, res.FlagBits.FlagSet(wire.OpMsgMoreToCome))
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "current topology should be awaited")
+	})
+
+	t.Run("StreamedHelloStaleProcessID", func(t *testing.T) {
+		t.Parallel()
+
+		var reqMsg wire.OpMsg
+		reqMsg.FlagBits = wire.OpMsgFlags(wire.OpMsgExhaustAllowed)
+		err := reqMsg.SetSections(wire.OpMsgSection{
+			Documents: []types.Document{types.MustMakeDocument(
+				"hello", int32(1),
+				"topologyVersion", types.MustMakeDocument(
+					"processId", types.ObjectID{1}, // other process, so the first reply is immediate
+					"counter", int64(0),
+				),
+				"maxAwaitTimeMS", int32(100),
+				"$db", "admin",
+			)},
+		})
+		require.NoError(t, err)
+
+		header := &wire.MsgHeader{RequestID: 1, OpCode: wire.OP_MSG}
+		start := time.Now()
+		_, resBody, closeConn := handler.Handle(ctx, header, &reqMsg)
+		require.False(t, closeConn, "%s", wire.DumpMsgBody(resBody))
+		assert.True(t, resBody.(*wire.OpMsg).FlagBits.FlagSet(wire.OpMsgMoreToCome))
+		assert.Less(t, time.Since(start), 100*time.Millisecond, "outdated topology should not be awaited")
+
+		// streamed replies wait for maxAwaitTimeMS, as the client got the current topology
+		for i := 0; i < 2; i++ {
+			start = time.Now()
+			_, resBody, closeConn = handler.HandleMoreToCome(ctx, header, &reqMsg)
+			require.False(t, closeConn, "%s", wire.DumpMsgBody(resBody))
+			assert.True(t, resBody.(*wire.OpMsg).FlagBits.FlagSet(wire.OpMsgMoreToCome))
+			assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "streamed reply should be awaited")
+		}
+	})
+
+	t.Run("StreamedHelloZeroMaxAwaitTime", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleFlags(t, wire.OpMsgFlags(wire.OpMsgExhaustAllowed), types.MustMakeDocument(
+			"hello", int32(1),
+			"topologyVersion", types.MustMakeDocument(
+				"processId", types.ObjectID{1},
+				"counter", int64(0),
+			),
+			"maxAwaitTimeMS", int32(0),
+			"$db", "admin",
+		))
+		assert.False(t, res.FlagBits.FlagSet(wire.OpMsgMoreToCome), "replies without delay should not be streamed")
+	})
+
+	t.Run("HelloWithoutExhaustAllowed", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleFlags(t, 0, types.MustMakeDocument(
+			"hello", int32(1),
+			"topologyVersion", types.MustMakeDocument(
+				"processId", types.ObjectID{1},
+				"counter", int64(0),
+			),
+			"maxAwaitTimeMS", int32(1),
+			"$db", "admin",
+		))
+		assert.False(t, res.FlagBits.FlagSet(wire.OpMsgMoreToCome))
+	})
+
+	t.Run("MoreToComeNotCounted", func(t *testing.T) {
+		t.Parallel()
+
+		var reqMsg wire.OpMsg
+		err := reqMsg.SetSections(wire.OpMsgSection{
+			Documents: []types.Document{types.MustMakeDocument(
+				"whatsmyuri", int32(1),
+				"$db", "admin",
+			)},
+		})
+		require.NoError(t, err)
+
+		header := &wire.MsgHeader{RequestID: 1, OpCode: wire.OP_MSG}
+		_, resBody, closeConn := handler.Handle(ctx, header, &reqMsg)
+		require.False(t, closeConn, "%s", wire.DumpMsgBody(resBody))
+		_, resBody, closeConn = handler.HandleMoreToCome(ctx, header, &reqMsg)
+		require.False(t, closeConn, "%s", wire.DumpMsgBody(resBody))
+
+		key := common.RequestKey{OpCode: wire.OP_MSG.String(), Command: "whatsmyuri"}
+		assert.Equal(t, int64(1), handler.metrics.counters.Snapshot().Requests[key])
+	})
+}
+
+func TestLegacyOpcodes(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	schema := testutil.Schema(ctx, t, pool)
+
+	handleLegacy := func(t *testing.T, opCode wire.OpCode, req wire.MsgBody) *wire.OpReply {
+		t.Helper()
+
+		_, resBody, closeConn := handler.Handle(ctx, &wire.MsgHeader{RequestID: 1, OpCode: opCode}, req)
+		require.False(t, closeConn, "%s", wire.DumpMsgBody(resBody))
+
+		if resBody == nil {
+			return nil
+		}
+		return resBody.(*wire.OpReply)
+	}
+
+	res := handleLegacy(t, wire.OP_INSERT, &wire.OpInsert{
+		FullCollectionName: schema + ".legacy",
+		Documents: []types.Document{
+			types.MustMakeDocument("_id", int32(1), "v", "foo"),
+			types.MustMakeDocument("_id", int32(2), "v", "bar"),
+			types.MustMakeDocument("_id", int32(3), "v", "baz"),
+			types.MustMakeDocument("_id", int32(4), "v", "quux"),
+		},
+	})
+	require.Nil(t, res)
+
+	res = handleLegacy(t, wire.OP_UPDATE, &wire.OpUpdate{
+		FullCollectionName: schema + ".legacy",
+		Selector:           types.MustMakeDocument("_id", int32(2)),
+		Update:             types.MustMakeDocument("$set", types.MustMakeDocument("v", "qux")),
+	})
+	require.Nil(t, res)
+
+	res = handleLegacy(t, wire.OP_DELETE, &wire.OpDelete{
+		FullCollectionName: schema + ".legacy",
+		Flags:              wire.OpDeleteFlags(wire.OpDeleteSingleRemove),
+		Selector:           types.MustMakeDocument("_id", int32(3)),
+	})
+	require.Nil(t, res)
+
+	t.Run("Command", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+			FullCollectionName: schema + ".$cmd",
+			NumberToReturn:     -1,
+			Query: types.MustMakeDocument(
+				"$query", types.MustMakeDocument("count", "legacy"),
+				"$readPreference", types.MustMakeDocument("mode", "primary"),
+			),
+		})
+		assert.Equal(t, wire.OpReplyFlags(0), res.ResponseFlags)
+		expected := []types.Document{types.MustMakeDocument("n", int32(3), "ok", float64(1))}
+		assert.Equal(t, expected, res.Documents)
+
+		res = handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+			FullCollectionName: schema + ".$cmd",
+			NumberToReturn:     -1,
+			Query:              types.MustMakeDocument("noSuchCommand", int32(1)),
+		})
+		assert.Equal(t, wire.OpReplyFlags(0), res.ResponseFlags)
+		assert.Equal(t, float64(0), res.Documents[0].Map()["ok"])
+		assert.Equal(t, int32(common.ErrCommandNotFound), res.Documents[0].Map()["code"])
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+			FullCollectionName: schema + ".legacy",
+			NumberToReturn:     1,
+			Query: types.MustMakeDocument(
+				"$query", types.MustMakeDocument(),
+				"$orderby", types.MustMakeDocument("v", int32(1)),
+			),
+			ReturnFieldsSelector: &types.Document{},
+		})
+		assert.Zero(t, res.CursorID)
+		expected := []types.Document{types.MustMakeDocument("_id", int32(1), "v", "foo")}
+		assert.Equal(t, expected, res.Documents)
+
+		res = handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+			FullCollectionName: schema + ".legacy",
+			NumberToReturn:     0,
+			Query: types.MustMakeDocument(
+				"$query", types.MustMakeDocument(),
+				"$explain", true,
+			),
+		})
+		assert.Equal(t, wire.OpReplyFlags(wire.OpReplyQueryFailure), res.ResponseFlags)
+		assert.Contains(t, res.Documents[0].Map(), "$err")
+	})
+
+	t.Run("GetMore", func(t *testing.T) {
+		t.Parallel()
+
+		res := handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+			FullCollectionName: schema + ".legacy",
+			NumberToReturn:     1,
+			Query: types.MustMakeDocument(
+				"$query", types.MustMakeDocument(),
+				"$orderby", types.MustMakeDocument("_id", int32(1)),
+			),
+		})
+		expected := []types.Document{types.MustMakeDocument("_id", int32(1), "v", "foo")}
+		assert.Equal(t, expected, res.Documents)
+		assert.Zero(t, res.CursorID, "numberToReturn 1 closes the cursor")
+
+		res = handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+			FullCollectionName: schema + ".legacy",
+			NumberToReturn:     2,
+			Query: types.MustMakeDocument(
+				"$query", types.MustMakeDocument(),
+				"$orderby", types.MustMakeDocument("_id", int32(-1)),
+			),
+		})
+		require.Len(t, res.Documents, 2)
+		cursorID := res.CursorID
+		require.NotZero(t, cursorID)
+
+		res = handleLegacy(t, wire.OP_GET_MORE, &wire.OpGetMore{
+			FullCollectionName: schema + ".legacy",
+			CursorID:           cursorID,
+		})
+		assert.Equal(t, wire.OpReplyFlags(0), res.ResponseFlags)
+		expected = []types.Document{types.MustMakeDocument("_id", int32(1), "v", "foo")}
+		assert.Equal(t, expected, res.Documents)
+		assert.Zero(t, res.CursorID)
+
+		res = handleLegacy(t, wire.OP_KILL_CURSORS, &wire.OpKillCursors{CursorIDs: []int64{cursorID}})
+		require.Nil(t, res)
+
+		res = handleLegacy(t, wire.OP_GET_MORE, &wire.OpGetMore{
+			FullCollectionName: schema + ".legacy",
+			CursorID:           cursorID,
+		})
+		assert.Equal(t, wire.OpReplyFlags(wire.OpReplyCursorNotFound), res.ResponseFlags)
+	})
+
+	t.Run("SessionCursor", func(t *testing.T) {
+		t.Parallel()
+
+		command := func(cmd types.Document) types.Document {
+			res := handleLegacy(t, wire.OP_QUERY, &wire.OpQuery{
+				FullCollectionName: schema + ".$cmd",
+				NumberToReturn:     -1,
+				Query:              cmd,
+			})
+			require.Len(t, res.Documents, 1)
+			return res.Documents[0]
+		}
+
+		lsid := testutil.GetByPath(t, command(types.MustMakeDocument("startSession", int32(1))), "id").(types.Document)
+
+		actual := command(types.MustMakeDocument(
+			"find", "legacy",
+			"batchSize", int32(1),
+			"lsid", lsid,
+		))
+		cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+		require.NotZero(t, cursorID)
+
+		actual = command(types.MustMakeDocument("killSessions", types.MustNewArray(lsid)))
+		assert.Equal(t, types.MustMakeDocument("ok", float64(1)), actual)
+
+		actual = command(types.MustMakeDocument("getMore", cursorID, "collection", "legacy"))
+		assert.Equal(t, int32(common.ErrCursorNotFound), testutil.GetByPath(t, actual, "code"), "session cursor should be killed")
+	})
+}
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+	ctx, handler, _ := setup(t, &testutil.PoolOpts{
+		ReadOnly: true,
+	})
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"startSession", int32(1),
+		"$db", "admin",
+	))
+	assert.Equal(t, int32(30), testutil.GetByPath(t, actual, "timeoutMinutes"))
+	lsid := testutil.GetByPath(t, actual, "id").(types.Document)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", "actor",
+		"batchSize", int32(1),
+		"lsid", lsid,
+		"$db", "monila",
+	))
+	cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+	require.NotZero(t, cursorID)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"refreshSessions", types.MustNewArray(lsid),
+		"$db", "admin",
+	))
+	assert.Equal(t, types.MustMakeDocument("ok", float64(1)), actual)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"killSessions", types.MustNewArray(lsid),
+		"$db", "admin",
+	))
+	assert.Equal(t, types.MustMakeDocument("ok", float64(1)), actual)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"getMore", cursorID,
+		"collection", "actor",
+		"$db", "monila",
+	))
+	assert.Equal(t, int32(common.ErrCursorNotFound), testutil.GetByPath(t, actual, "code"), "session cursor should be killed")
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"endSessions", types.MustNewArray(lsid),
+		"$db", "admin",
+	))
+	assert.Equal(t, types.MustMakeDocument("ok", float64(1)), actual)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"killAllSessions", new(types.Array),
+		"$db", "admin",
+	))
+	assert.Equal(t, types.MustMakeDocument("ok", float64(1)), actual)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"ping", int32(1),
+		"lsid", types.MustMakeDocument("id", "foo"),
+		"$db", "admin",
+	))
+	assert.Equal(t, int32(common.ErrBadValue), testutil.GetByPath(t, actual, "code"))
+}
+
+func TestRetryableWrites(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"startSession", int32(1),
+		"$db", "admin",
+	))
+	lsid := testutil.GetByPath(t, actual, "id").(types.Document)
+
+	insert := types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(
+			types.MustMakeDocument("_id", int32(1), "v", int32(0)),
+			types.MustMakeDocument("_id", int32(2), "v", int32(0)),
+		),
+		"lsid", lsid,
+		"txnNumber", int64(1),
+		"$db", db,
+	)
+	update := types.MustMakeDocument(
+		"update", collection,
+		"updates", types.MustNewArray(
+			types.MustMakeDocument(
+				"q", types.MustMakeDocument("_id", int32(1)),
+				"u", types.MustMakeDocument("$inc", types.MustMakeDocument("v", int32(1))),
+			),
+		),
+		"lsid", lsid,
+		"txnNumber", int64(2),
+		"$db", db,
+	)
+
+	// the second attempt of each write should return the same result without executing it again
+	for i := 0; i < 2; i++ {
+		actual = handle(ctx, t, handler, insert)
+		assert.Equal(t, types.MustMakeDocument("n", int32(2), "ok", float64(1)), actual)
+	}
+
+	for i := 0; i < 2; i++ {
+		actual = handle(ctx, t, handler, update)
+		assert.Equal(t, types.MustMakeDocument("n", int32(1), "nModified", int32(1), "ok", float64(1)), actual)
+	}
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", collection,
+		"filter", types.MustMakeDocument("_id", int32(1)),
+		"$db", db,
+	))
+	assert.Equal(t, int32(1), testutil.GetByPath(t, actual, "cursor", "firstBatch", "0", "v"))
+
+	actual = handle(ctx, t, handler, insert)
+	assert.Equal(t, int32(common.ErrTransactionTooOld), testutil.GetByPath(t, actual, "code"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(3))),
+		"txnNumber", int64(3),
+		"$db", db,
+	))
+	assert.Equal(t, int32(common.ErrInvalidOptions), testutil.GetByPath(t, actual, "code"))
+}
+
+func TestChangeStreams(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+	ns := db + "." + collection
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"aggregate", collection,
+		"pipeline", types.MustNewArray(
+			types.MustMakeDocument("$changeStream", types.MustMakeDocument("fullDocument", "updateLookup")),
+		),
+		"cursor", types.MustMakeDocument(),
+		"$db", db,
+	))
+	assert.Equal(t, new(types.Array), testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+	assert.IsType(t, "", testutil.GetByPath(t, actual, "cursor", "postBatchResumeToken", "_data"))
+	assert.Equal(t, ns, testutil.GetByPath(t, actual, "cursor", "ns"))
+	cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+	require.NotZero(t, cursorID)
+
+	for _, req := range []types.Document{
+		types.MustMakeDocument(
+			"insert", collection,
+			"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(1), "v", int32(1))),
+			"$db", db,
+		),
+		types.MustMakeDocument(
+			"update", collection,
+			"updates", types.MustNewArray(types.MustMakeDocument(
+				"q", types.MustMakeDocument("_id", int32(1)),
+				"u", types.MustMakeDocument("$set", types.MustMakeDocument("v", int32(2))),
+			)),
+			"$db", db,
+		),
+		types.MustMakeDocument(
+			"delete", collection,
+			"deletes", types.MustNewArray(types.MustMakeDocument(
+				"q", types.MustMakeDocument("_id", int32(1)),
+				"limit", int32(1),
+			)),
+			"$db", db,
+		),
+	} {
+		actual = handle(ctx, t, handler, req)
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+	}
+
+	getMore := types.MustMakeDocument(
+		"getMore", cursorID,
+		"collection", collection,
+		"maxTimeMS", int32(1000),
+		"$db", db,
+	)
+	actual = handle(ctx, t, handler, getMore)
+	events := testutil.GetByPath(t, actual, "cursor", "nextBatch").(*types.Array)
+	require.Equal(t, 3, events.Len(), "%v", events)
+	assert.Equal(t, cursorID, testutil.GetByPath(t, actual, "cursor", "id"))
+
+	assert.Equal(t, "insert", testutil.GetByPath(t, events, "0", "operationType"))
+	assert.Equal(t, types.MustMakeDocument("db", db, "coll", collection), testutil.GetByPath(t, events, "0", "ns"))
+	assert.Equal(t, types.MustMakeDocument("_id", int32(1)), testutil.GetByPath(t, events, "0", "documentKey"))
+	assert.Equal(
+		t, types.MustMakeDocument("_id", int32(1), "v", int32(1)), testutil.GetByPath(t, events, "0", "fullDocument"),
+	)
+
+	assert.Equal(t, "update", testutil.GetByPath(t, events, "1", "operationType"))
+	assert.Equal(
+		t, types.MustMakeDocument("v", int32(2)), testutil.GetByPath(t, events, "1", "updateDescription", "updatedFields"),
+	)
+	assert.Nil(t, testutil.GetByPath(t, events, "1", "fullDocument"), "the document is already deleted")
+
+	assert.Equal(t, "delete", testutil.GetByPath(t, events, "2", "operationType"))
+	assert.Equal(t, types.MustMakeDocument("_id", int32(1)), testutil.GetByPath(t, events, "2", "documentKey"))
+
+	t.Run("ResumeAfter", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"aggregate", collection,
+			"pipeline", types.MustNewArray(
+				types.MustMakeDocument("$changeStream", types.MustMakeDocument(
+					"resumeAfter", testutil.GetByPath(t, events, "0", "_id"),
+				)),
+				types.MustMakeDocument("$match", types.MustMakeDocument("operationType", "delete")),
+			),
+			"cursor", types.MustMakeDocument("batchSize", int32(10)),
+			"$db", db,
+		))
+		firstBatch := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+		require.Equal(t, 1, firstBatch.Len())
+		assert.Equal(t, testutil.GetByPath(t, events, "2", "_id"), testutil.GetByPath(t, firstBatch, "0", "_id"))
+	})
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"drop", collection,
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, getMore)
+	events = testutil.GetByPath(t, actual, "cursor", "nextBatch").(*types.Array)
+	require.Equal(t, 2, events.Len(), "%v", events)
+	assert.Equal(t, "drop", testutil.GetByPath(t, events, "0", "operationType"))
+	assert.Equal(t, "invalidate", testutil.GetByPath(t, events, "1", "operationType"))
+	assert.Equal(t, int64(0), testutil.GetByPath(t, actual, "cursor", "id"), "invalidated cursor should be closed")
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"aggregate", collection,
+		"pipeline", types.MustNewArray(
+			types.MustMakeDocument("$changeStream", types.MustMakeDocument(
+				"resumeAfter", testutil.GetByPath(t, events, "1", "_id"),
+			)),
+		),
+		"cursor", types.MustMakeDocument(),
+		"$db", db,
+	))
+	assert.Equal(t, int32(common.ErrInvalidResumeToken), testutil.GetByPath(t, actual, "code"))
+}
+
+func TestChangeStreamsExistingTable(t *testing.T) {
+	t.Parallel()
+	ctx, _, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := "existing"
+
+	// a table created by an older version without the change log trigger
+	_, err := pool.Exec(ctx, `CREATE TABLE `+pg.TableIdentifier(db, collection).Sanitize()+` (_jsonb jsonb)`)
+	require.NoError(t, err)
+
+	// the trigger is attached when the new pool checks the catalog
+	_, handler, _ := setup(t, nil)
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"aggregate", collection,
+		"pipeline", types.MustNewArray(types.MustMakeDocument("$changeStream", types.MustMakeDocument())),
+		"cursor", types.MustMakeDocument(),
+		"$db", db,
+	))
+	cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+	require.NotZero(t, cursorID)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(1))),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"getMore", cursorID,
+		"collection", collection,
+		"maxTimeMS", int32(1000),
+		"$db", db,
+	))
+	events := testutil.GetByPath(t, actual, "cursor", "nextBatch").(*types.Array)
+	require.Equal(t, 1, events.Len(), "%v", events)
+	assert.Equal(t, "insert", testutil.GetByPath(t, events, "0", "operationType"))
+	assert.Equal(t, types.MustMakeDocument("_id", int32(1)), testutil.GetByPath(t, events, "0", "fullDocument"))
+}
+
+func TestOplog(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+	ns := db + "." + collection
+
+	// changes are recorded only after the first oplog or change stream reader enables the change log
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"find", "oplog.rs",
+		"filter", types.MustMakeDocument("ns", ns),
+		"$db", "local",
+	))
+	require.Zero(t, testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array).Len())
+
+	for _, req := range []types.Document{
+		types.MustMakeDocument(
+			"insert", collection,
+			"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(1), "v", int32(1), "w", int32(1))),
+			"$db", db,
+		),
+		types.MustMakeDocument(
+			"update", collection,
+			"updates", types.MustNewArray(types.MustMakeDocument(
+				"q", types.MustMakeDocument("_id", int32(1)),
+				"u", types.MustMakeDocument(
+					"$set", types.MustMakeDocument("v", int32(2)),
+					"$unset", types.MustMakeDocument("w", ""),
+				),
+			)),
+			"$db", db,
+		),
+	} {
+		actual := handle(ctx, t, handler, req)
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+	}
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", "oplog.rs",
+		"filter", types.MustMakeDocument("ns", ns),
+		"tailable", true,
+		"awaitData", true,
+		"$db", "local",
+	))
+	entries := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+	require.Equal(t, 2, entries.Len(), "%v", entries)
+	assert.Equal(t, "local.oplog.rs", testutil.GetByPath(t, actual, "cursor", "ns"))
+	cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+	require.NotZero(t, cursorID)
+
+	assert.Equal(t, "i", testutil.GetByPath(t, entries, "0", "op"))
+	assert.Equal(t, ns, testutil.GetByPath(t, entries, "0", "ns"))
+	assert.Equal(
+		t, types.MustMakeDocument("_id", int32(1), "v", int32(1), "w", int32(1)), testutil.GetByPath(t, entries, "0", "o"),
+	)
+
+	assert.Equal(t, "u", testutil.GetByPath(t, entries, "1", "op"))
+	assert.Equal(t, types.MustMakeDocument(
+		"$v", int32(1),
+		"$set", types.MustMakeDocument("v", int32(2)),
+		"$unset", types.MustMakeDocument("w", true),
+	), testutil.GetByPath(t, entries, "1", "o"))
+	assert.Equal(t, types.MustMakeDocument("_id", int32(1)), testutil.GetByPath(t, entries, "1", "o2"))
+
+	ts0 := testutil.GetByPath(t, entries, "0", "ts").(types.Timestamp)
+	ts1 := testutil.GetByPath(t, entries, "1", "ts").(types.Timestamp)
+	assert.Less(t, ts0, ts1)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"delete", collection,
+		"deletes", types.MustNewArray(types.MustMakeDocument(
+			"q", types.MustMakeDocument("_id", int32(1)),
+			"limit", int32(1),
+		)),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"getMore", cursorID,
+		"collection", "oplog.rs",
+		"maxTimeMS", int32(1000),
+		"$db", "local",
+	))
+	entries = testutil.GetByPath(t, actual, "cursor", "nextBatch").(*types.Array)
+	require.Equal(t, 1, entries.Len(), "%v", entries)
+	assert.Equal(t, "d", testutil.GetByPath(t, entries, "0", "op"))
+	assert.Equal(t, types.MustMakeDocument("_id", int32(1)), testutil.GetByPath(t, entries, "0", "o"))
+
+	t.Run("Timestamps", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"find", "oplog.rs",
+			"filter", types.MustMakeDocument("ts", types.MustMakeDocument("$gt", ts0, "$lte", ts1)),
+			"$db", "local",
+		))
+		entries := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+		require.Equal(t, 1, entries.Len(), "%v", entries)
+		assert.Equal(t, ts1, testutil.GetByPath(t, entries, "0", "ts"))
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"find", "oplog.rs",
+			"filter", types.MustMakeDocument("ns", ns),
+			"batchSize", int32(1),
+			"$db", "local",
+		))
+		entries := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+		require.Equal(t, 1, entries.Len(), "%v", entries)
+		assert.Equal(t, ts0, testutil.GetByPath(t, entries, "0", "ts"))
+		cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+		require.NotZero(t, cursorID)
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"getMore", cursorID,
+			"collection", "oplog.rs",
+			"$db", "local",
+		))
+		entries = testutil.GetByPath(t, actual, "cursor", "nextBatch").(*types.Array)
+		require.Equal(t, 2, entries.Len(), "%v", entries)
+		assert.Equal(t, ts1, testutil.GetByPath(t, entries, "0", "ts"))
+		assert.Equal(t, int64(0), testutil.GetByPath(t, actual, "cursor", "id"), "non-tailable cursor should be closed")
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"insert", "oplog.rs",
+			"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(1))),
+			"$db", "local",
+		))
+		assert.Equal(t, int32(common.ErrIllegalOperation), testutil.GetByPath(t, actual, "code"))
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"insert", collection,
+			"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(2)), types.MustMakeDocument("_id", int32(3))),
+			"$db", db,
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"find", "oplog.rs",
+			"filter", types.MustMakeDocument("ns", ns, "op", "i", "ts", types.MustMakeDocument("$gt", ts1)),
+			"$db", "local",
+		))
+		entries := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+		require.Equal(t, 2, entries.Len(), "%v", entries)
+
+		// records of the same transaction have the same high 32 bits and consecutive ordinals
+		ts2 := testutil.GetByPath(t, entries, "0", "ts").(types.Timestamp)
+		ts3 := testutil.GetByPath(t, entries, "1", "ts").(types.Timestamp)
+		assert.Equal(t, ts2+1, ts3)
+		assert.Equal(t, types.Timestamp(1), ts2&math.MaxUint32)
+	})
+}
+
+func TestCappedCollections(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	notCapped := testutil.CreateTable(ctx, t, pool, db)
+	collection := notCapped + "_capped"
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"create", collection,
+		"capped", true,
+		"size", int32(1<<20),
+		"max", int32(3),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	insert := func(ids ...int32) {
+		docs := new(types.Array)
+		for _, id := range ids {
+			require.NoError(t, docs.Append(types.MustMakeDocument("_id", id)))
+		}
+
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"insert", collection,
+			"documents", docs,
+			"$db", db,
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+	}
+
+	// inserted out of _id order to check the insertion order
+	insert(int32(5), int32(1), int32(4))
+	insert(int32(3), int32(2))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", collection,
+		"$db", db,
+	))
+	expected := types.MustNewArray(
+		types.MustMakeDocument("_id", int32(4)),
+		types.MustMakeDocument("_id", int32(3)),
+		types.MustMakeDocument("_id", int32(2)),
+	)
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", collection,
+		"sort", types.MustMakeDocument("$natural", int32(-1)),
+		"limit", int32(1),
+		"$db", db,
+	))
+	expected = types.MustNewArray(types.MustMakeDocument("_id", int32(2)))
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"collStats", collection,
+		"$db", db,
+	))
+	assert.Equal(t, true, testutil.GetByPath(t, actual, "capped"))
+	assert.Equal(t, int64(3), testutil.GetByPath(t, actual, "max"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", collection,
+		"filter", types.MustMakeDocument("_id", types.MustMakeDocument("$gte", int32(3))),
+		"tailable", true,
+		"awaitData", true,
+		"$db", db,
+	))
+	expected = types.MustNewArray(
+		types.MustMakeDocument("_id", int32(4)),
+		types.MustMakeDocument("_id", int32(3)),
+	)
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+	cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+	require.NotZero(t, cursorID)
+
+	insert(int32(0), int32(6))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"getMore", cursorID,
+		"collection", collection,
+		"maxTimeMS", int32(5000),
+		"$db", db,
+	))
+	expected = types.MustNewArray(types.MustMakeDocument("_id", int32(6)))
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "nextBatch"))
+	assert.Equal(t, cursorID, testutil.GetByPath(t, actual, "cursor", "id"))
+
+	t.Run("NotCapped", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"find", notCapped,
+			"tailable", true,
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrBadValue), testutil.GetByPath(t, actual, "code"))
+	})
+}
+
+func TestTTLIndexes(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"createIndexes", collection,
+		"indexes", types.MustNewArray(types.MustMakeDocument(
+			"key", types.MustMakeDocument("session.expireAt", int32(1)),
+			"name", "expireAt_1",
+			"expireAfterSeconds", int32(60),
+		)),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	now := time.Now()
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(
+			types.MustMakeDocument("_id", int32(1), "session", types.MustMakeDocument("expireAt", now.Add(-time.Hour))),
+			types.MustMakeDocument("_id", int32(2), "session", types.MustMakeDocument("expireAt", now)),
+			types.MustMakeDocument("_id", int32(3), "session", types.MustMakeDocument("expireAt", "not a date")),
+			types.MustMakeDocument("_id", int32(4)),
+		),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	indexes, err := pool.TTLIndexes(ctx)
+	require.NoError(t, err)
+
+	var idx *pg.TTLIndex
+	for i := range indexes {
+		if indexes[i].DB == db && indexes[i].Collection == collection {
+			idx = &indexes[i]
+		}
+	}
+	require.NotNil(t, idx)
+	assert.Equal(t, pg.TTLIndex{
+		DB:          db,
+		Collection:  collection,
+		Name:        "expireAt_1",
+		Key:         "session.expireAt",
+		ExpireAfter: time.Minute,
+	}, *idx)
+
+	n, err := pool.DeleteExpired(ctx, idx, now, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", collection,
+		"$db", db,
+	))
+	assert.Equal(t, int32(3), testutil.GetByPath(t, actual, "n"))
+
+	t.Run("Conflict", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"createIndexes", collection,
+			"indexes", types.MustNewArray(types.MustMakeDocument(
+				"key", types.MustMakeDocument("session.expireAt", int32(1)),
+				"name", "expireAt_1",
+				"expireAfterSeconds", int32(120),
+			)),
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrIndexOptionsConflict), testutil.GetByPath(t, actual, "code"))
+	})
+
+	t.Run("Compound", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"createIndexes", collection,
+			"indexes", types.MustNewArray(types.MustMakeDocument(
+				"key", types.MustMakeDocument("a", int32(1), "b", int32(1)),
+				"name", "a_1_b_1",
+				"expireAfterSeconds", int32(60),
+			)),
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrCannotCreateIndex), testutil.GetByPath(t, actual, "code"))
+	})
+}
+
+func TestViews(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+	view := collection + "_view"
+	nested := collection + "_nested"
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(
+			types.MustMakeDocument("_id", int32(1), "v", int32(30), "tags", types.MustNewArray("a", "b")),
+			types.MustMakeDocument("_id", int32(2), "v", int32(10), "tags", types.MustNewArray("b")),
+			types.MustMakeDocument("_id", int32(3), "v", int32(20), "tags", "c"),
+			types.MustMakeDocument("_id", int32(4), "v", int32(-1)),
+		),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"create", view,
+		"viewOn", collection,
+		"pipeline", types.MustNewArray(
+			types.MustMakeDocument("$match", types.MustMakeDocument("v", types.MustMakeDocument("$gt", int32(0)))),
+			types.MustMakeDocument("$project", types.MustMakeDocument("v", int32(1), "tags", int32(1))),
+		),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"create", nested,
+		"viewOn", view,
+		"pipeline", types.MustNewArray(types.MustMakeDocument("$sort", types.MustMakeDocument("v", int32(-1)))),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", nested,
+		"filter", types.MustMakeDocument("v", types.MustMakeDocument("$lt", int32(30))),
+		"projection", types.MustMakeDocument("tags", int32(0)),
+		"$db", db,
+	))
+	expected := types.MustNewArray(
+		types.MustMakeDocument("_id", int32(3), "v", int32(20)),
+		types.MustMakeDocument("_id", int32(2), "v", int32(10)),
+	)
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", view,
+		"$db", db,
+	))
+	assert.Equal(t, int32(3), testutil.GetByPath(t, actual, "n"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"distinct", view,
+		"key", "tags",
+		"$db", db,
+	))
+	assert.Equal(t, types.MustNewArray("a", "b", "c"), testutil.GetByPath(t, actual, "values"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"aggregate", view,
+		"pipeline", types.MustNewArray(types.MustMakeDocument("$count", "n")),
+		"cursor", types.MustMakeDocument(),
+		"$db", db,
+	))
+	expected = types.MustNewArray(types.MustMakeDocument("n", int32(3)))
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"dbStats", int32(1),
+		"$db", db,
+	))
+	assert.Equal(t, int32(2), testutil.GetByPath(t, actual, "views"))
+
+	t.Run("Limit", func(t *testing.T) {
+		limited := collection + "_limited"
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"create", limited,
+			"viewOn", collection,
+			"pipeline", types.MustNewArray(
+				types.MustMakeDocument("$match", types.MustMakeDocument("v", types.MustMakeDocument("$gte", int32(10)))),
+				types.MustMakeDocument("$limit", int32(2)),
+				types.MustMakeDocument("$project", types.MustMakeDocument("tags", int32(0))),
+			),
+			"$db", db,
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"count", limited,
+			"$db", db,
+		))
+		assert.Equal(t, int32(2), testutil.GetByPath(t, actual, "n"))
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"find", limited,
+			"limit", int32(1),
+			"$db", db,
+		))
+		firstBatch := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+		require.Equal(t, 1, firstBatch.Len())
+		doc, err := firstBatch.Get(0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"_id", "v"}, doc.(types.Document).Keys())
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"insert", view,
+			"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(5))),
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrCommandNotSupportedOnView), testutil.GetByPath(t, actual, "code"))
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"create", collection,
+			"viewOn", view,
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrNamespaceExists), testutil.GetByPath(t, actual, "code"))
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"drop", nested,
+			"$db", db,
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"find", nested,
+			"$db", db,
+		))
+		assert.Equal(t, types.MustNewArray(), testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+	})
+}
+
+func TestValidation(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db) + "_validated"
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"create", collection,
+		"validator", types.MustMakeDocument(
+			"$jsonSchema", types.MustMakeDocument(
+				"required", types.MustNewArray("v"),
+				"properties", types.MustMakeDocument("v", types.MustMakeDocument("bsonType", "int")),
+			),
+		),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(
+			types.MustMakeDocument("_id", int32(1), "v", int32(1)),
+			types.MustMakeDocument("_id", int32(2)),
+			types.MustMakeDocument("_id", int32(3), "v", int32(3)),
+		),
+		"ordered", false,
+		"$db", db,
+	))
+	assert.Equal(t, int32(2), testutil.GetByPath(t, actual, "n"))
+	assert.Equal(t, int32(1), testutil.GetByPath(t, actual, "writeErrors", "0", "index"))
+	assert.Equal(t, int32(common.ErrDocumentValidationFailure), testutil.GetByPath(t, actual, "writeErrors", "0", "code"))
+	assert.Equal(t, int32(2), testutil.GetByPath(t, actual, "writeErrors", "0", "errInfo", "failingDocumentId"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"update", collection,
+		"updates", types.MustNewArray(types.MustMakeDocument(
+			"q", types.MustMakeDocument("_id", int32(1)),
+			"u", types.MustMakeDocument("$set", types.MustMakeDocument("v", "foo")),
+		)),
+		"$db", db,
+	))
+	assert.Equal(t, int32(common.ErrDocumentValidationFailure), testutil.GetByPath(t, actual, "writeErrors", "0", "code"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(4))),
+		"bypassDocumentValidation", true,
+		"$db", db,
+	))
+	assert.Equal(t, int32(1), testutil.GetByPath(t, actual, "n"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"collMod", collection,
+		"validationAction", "warn",
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(5))),
+		"$db", db,
+	))
+	assert.Equal(t, int32(1), testutil.GetByPath(t, actual, "n"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"count", collection,
+		"$db", db,
+	))
+	assert.Equal(t, int32(4), testutil.GetByPath(t, actual, "n"))
+
+	t.Run("BadValidator", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"collMod", collection,
+			"validator", types.MustMakeDocument("$jsonSchema", types.MustMakeDocument("bsonType", "foo")),
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrBadValue), testutil.GetByPath(t, actual, "code"))
+	})
+}
+
+func TestRenameCollection(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+	renamed := collection + "_renamed"
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(1))),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"createIndexes", collection,
+		"indexes", types.MustNewArray(types.MustMakeDocument(
+			"key", types.MustMakeDocument("expireAt", int32(1)),
+			"name", "expireAt_1",
+			"expireAfterSeconds", int32(60),
+		)),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"renameCollection", db+"."+collection,
+		"to", db+"."+renamed,
+		"$db", "admin",
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"find", renamed,
+		"$db", db,
+	))
+	expected := types.MustNewArray(types.MustMakeDocument("_id", int32(1)))
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"collMod", renamed,
+		"index", types.MustMakeDocument("name", "expireAt_1", "expireAfterSeconds", int32(120), "hidden", true),
+		"$db", db,
+	))
+	require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+	assert.Equal(t, int64(60), testutil.GetByPath(t, actual, "expireAfterSeconds_old"))
+	assert.Equal(t, int64(120), testutil.GetByPath(t, actual, "expireAfterSeconds_new"))
+	assert.Equal(t, true, testutil.GetByPath(t, actual, "hidden_new"))
+
+	indexes, err := pool.TTLIndexes(ctx)
+	require.NoError(t, err)
+
+	var found bool
+	for _, idx := range indexes {
+		if idx.DB == db && idx.Collection == renamed {
+			found = true
+			assert.Equal(t, 2*time.Minute, idx.ExpireAfter)
+			assert.True(t, idx.Hidden)
+		}
 	}
+	assert.True(t, found)
 
-	for name, tc := range testCases { //nolint:paralleltest // false positive
-		name, tc := name, tc
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
+	t.Run("Exists", func(t *testing.T) {
+		target := collection + "_target"
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"create", target,
+			"$db", db,
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
 
-			for _, schema := range []string{"monila", "pagila"} {
-				t.Run(schema, func(t *testing.T) {
-					// not parallel because we modify tc
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"renameCollection", db+"."+renamed,
+			"to", db+"."+target,
+			"$db", "admin",
+		))
+		assert.Equal(t, int32(common.ErrNamespaceExists), testutil.GetByPath(t, actual, "code"))
 
-					tc.req.Set("$db", schema)
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"renameCollection", db+"."+renamed,
+			"to", db+"."+target,
+			"dropTarget", true,
+			"$db", "admin",
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
 
-					if schema == "pagila" {
-						for i := 0; i < tc.resp.Len(); i++ {
-							doc, err := tc.resp.Get(i)
-							require.NoError(t, err)
-							d := doc.(types.Document)
-							d.Remove("_id")
-							err = tc.resp.Set(i, d)
-							require.NoError(t, err)
-						}
-					}
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"count", target,
+			"$db", db,
+		))
+		assert.Equal(t, int32(1), testutil.GetByPath(t, actual, "n"))
 
-					actual := handle(ctx, t, handler, tc.req)
-					expected := types.MustMakeDocument(
-						"cursor", types.MustMakeDocument(
-							"firstBatch", tc.resp,
-							"id", int64(0),
-							"ns", schema+".actor",
-						),
-						"ok", float64(1),
-					)
-					assert.Equal(t, expected, actual)
-				})
-			}
+		// move it back for other subtests
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"renameCollection", db+"."+target,
+			"to", db+"."+renamed,
+			"$db", "admin",
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+	})
+
+	t.Run("OtherDatabase", func(t *testing.T) {
+		toDB := testutil.Schema(ctx, t, pool)
+
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"renameCollection", db+"."+renamed,
+			"to", toDB+"."+collection,
+			"$db", "admin",
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"find", collection,
+			"$db", toDB,
+		))
+		assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"count", renamed,
+			"$db", db,
+		))
+		assert.Equal(t, int32(0), testutil.GetByPath(t, actual, "n"))
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"renameCollection", db+"."+renamed,
+			"to", db+"."+collection,
+			"$db", db,
+		))
+		assert.Equal(t, int32(common.ErrUnauthorized), testutil.GetByPath(t, actual, "code"))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"renameCollection", db+".missing",
+			"to", db+".other",
+			"$db", "admin",
+		))
+		assert.Equal(t, int32(common.ErrNamespaceNotFound), testutil.GetByPath(t, actual, "code"))
+	})
+}
+
+func TestLongNames(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+
+	// names differ only after PostgreSQL identifier length limit
+	prefix := strings.Repeat("long_collection_", 5)
+	collections := []string{prefix + "1", prefix + "2"}
+
+	for i, collection := range collections {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"insert", collection,
+			"documents", types.MustNewArray(types.MustMakeDocument("_id", int32(i))),
+			"$db", db,
+		))
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+	}
+
+	for i, collection := range collections {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"find", collection,
+			"$db", db,
+		))
+		expected := types.MustNewArray(types.MustMakeDocument("_id", int32(i)))
+		assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+	}
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"listCollections", int32(1),
+		"nameOnly", true,
+		"$db", db,
+	))
+	expected := types.MustNewArray(
+		types.MustMakeDocument("name", collections[0], "type", "collection"),
+		types.MustMakeDocument("name", collections[1], "type", "collection"),
+	)
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+	tables, err := pool.Tables(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, collections, tables)
+
+	for name, command := range map[string]types.Document{
+		"Dollar":   types.MustMakeDocument("create", "a$b", "$db", db),
+		"System":   types.MustMakeDocument("create", "system.test", "$db", db),
+		"Insert":   types.MustMakeDocument("insert", "a$b", "documents", types.MustNewArray(types.MustMakeDocument()), "$db", db),
+		"Database": types.MustMakeDocument("create", "test", "$db", "a.b"),
+	} {
+		command := command
+		t.Run(name, func(t *testing.T) {
+			actual := handle(ctx, t, handler, command)
+			assert.Equal(t, int32(common.ErrInvalidNamespace), testutil.GetByPath(t, actual, "code"))
 		})
 	}
 }
 
+func TestListCollections(t *testing.T) {
+	t.Parallel()
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+
+	for _, command := range []types.Document{
+		types.MustMakeDocument("create", "capped", "capped", true, "size", int32(4096), "$db", db),
+		types.MustMakeDocument("create", "plain", "$db", db),
+		types.MustMakeDocument(
+			"create", "validated",
+			"validator", types.MustMakeDocument("v", types.MustMakeDocument("$exists", true)),
+			"$db", db,
+		),
+		types.MustMakeDocument(
+			"create", "view",
+			"viewOn", "plain",
+			"pipeline", types.MustNewArray(types.MustMakeDocument("$match", types.MustMakeDocument("v", int32(1)))),
+			"$db", db,
+		),
+	} {
+		actual := handle(ctx, t, handler, command)
+		require.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"), "%v", command)
+	}
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"listCollections", int32(1),
+		"$db", db,
+	))
+	firstBatch := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+	require.Equal(t, 4, firstBatch.Len())
+
+	capped := testutil.GetByPath(t, actual, "cursor", "firstBatch", "0").(types.Document)
+	assert.Equal(t, "capped", capped.Map()["name"])
+	assert.Equal(t, types.MustMakeDocument("capped", true, "size", int64(4096)), capped.Map()["options"])
+	assert.Equal(t, false, testutil.GetByPath(t, capped, "info", "readOnly"))
+	assert.Equal(t, "_id_", testutil.GetByPath(t, capped, "idIndex", "name"))
+
+	uuid, ok := testutil.GetByPath(t, capped, "info", "uuid").(types.Binary)
+	require.True(t, ok)
+	assert.Equal(t, types.BinaryUUID, uuid.Subtype)
+	assert.Len(t, uuid.B, 16)
+
+	validated := testutil.GetByPath(t, actual, "cursor", "firstBatch", "2").(types.Document)
+	assert.Equal(t, "strict", testutil.GetByPath(t, validated, "options", "validationLevel"))
+
+	expected := types.MustMakeDocument(
+		"name", "view",
+		"type", "view",
+		"options", types.MustMakeDocument(
+			"viewOn", "plain",
+			"pipeline", types.MustNewArray(types.MustMakeDocument("$match", types.MustMakeDocument("v", int32(1)))),
+		),
+		"info", types.MustMakeDocument("readOnly", true),
+	)
+	assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch", "3"))
+
+	t.Run("StableUUID", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"listCollections", int32(1),
+			"filter", types.MustMakeDocument("name", "capped"),
+			"$db", db,
+		))
+		assert.Equal(t, uuid, testutil.GetByPath(t, actual, "cursor", "firstBatch", "0", "info", "uuid"))
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			filter   types.Document
+			expected []string
+		}{
+			"Type":    {types.MustMakeDocument("type", "view"), []string{"view"}},
+			"Options": {types.MustMakeDocument("options.capped", true), []string{"capped"}},
+			"Regex":   {types.MustMakeDocument("name", types.Regex{Pattern: "^p"}), []string{"plain"}},
+		} {
+			tc := tc
+			t.Run(name, func(t *testing.T) {
+				actual := handle(ctx, t, handler, types.MustMakeDocument(
+					"listCollections", int32(1),
+					"filter", tc.filter,
+					"nameOnly", true,
+					"$db", db,
+				))
+				firstBatch := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+				var names []string
+				for i := 0; i < firstBatch.Len(); i++ {
+					d, err := firstBatch.Get(i)
+					require.NoError(t, err)
+					names = append(names, d.(types.Document).Map()["name"].(string))
+				}
+				assert.Equal(t, tc.expected, names)
+			})
+		}
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"listCollections", int32(1),
+			"nameOnly", true,
+			"cursor", types.MustMakeDocument("batchSize", int32(3)),
+			"$db", db,
+		))
+		expected := types.MustNewArray(
+			types.MustMakeDocument("name", "capped", "type", "collection"),
+			types.MustMakeDocument("name", "plain", "type", "collection"),
+			types.MustMakeDocument("name", "validated", "type", "collection"),
+		)
+		assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "firstBatch"))
+
+		cursorID := testutil.GetByPath(t, actual, "cursor", "id").(int64)
+		require.NotZero(t, cursorID)
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"getMore", cursorID,
+			"collection", "$cmd.listCollections",
+			"$db", db,
+		))
+		expected = types.MustNewArray(types.MustMakeDocument("name", "view", "type", "view"))
+		assert.Equal(t, expected, testutil.GetByPath(t, actual, "cursor", "nextBatch"))
+		assert.Equal(t, int64(0), testutil.GetByPath(t, actual, "cursor", "id"))
+	})
+}
+
 func TestReadOnlyHandlers(t *testing.T) {
 	t.Parallel()
 	ctx, handler, _ := setup(t, &testutil.PoolOpts{
@@ -453,6 +2203,7 @@ func TestReadOnlyHandlers(t *testing.T) {
 				"storageSize", int32(1_204_224),
 				"totalIndexSize", int32(0),
 				"totalSize", int32(1_236_992),
+				"capped", false,
 				"scaleFactor", int32(1),
 				"ok", float64(1),
 			),
@@ -504,6 +2255,34 @@ func TestReadOnlyHandlers(t *testing.T) {
 				"ok", float64(1),
 			),
 		},
+		"CountSkipLimitHint": {
+			req: types.MustMakeDocument(
+				"count", "actor",
+				"query", types.MustMakeDocument(
+					"last_name", "HOFFMAN",
+				),
+				"skip", int32(1),
+				"limit", int64(5),
+				"hint", "_id_",
+			),
+			reqSetDB: true,
+			resp: types.MustMakeDocument(
+				"n", int32(2),
+				"ok", float64(1),
+			),
+		},
+		"CountAllActorsSkipLimit": {
+			req: types.MustMakeDocument(
+				"count", "actor",
+				"skip", int32(195),
+				"limit", int32(10),
+			),
+			reqSetDB: true,
+			resp: types.MustMakeDocument(
+				"n", int32(5),
+				"ok", float64(1),
+			),
+		},
 
 		"DBStats": {
 			req: types.MustMakeDocument(
@@ -571,6 +2350,7 @@ func TestReadOnlyHandlers(t *testing.T) {
 				"projection", types.MustMakeDocument(
 					"first_name", int32(1),
 					"last_name", int32(1),
+					"_id", int32(0),
 				),
 				"filter", types.MustMakeDocument(
 					"actor_id", int32(28),
@@ -628,42 +2408,101 @@ func TestReadOnlyHandlers(t *testing.T) {
 		"IsMaster": {
 			req: types.MustMakeDocument(
 				"isMaster", int32(1),
+				"helloOk", true,
+				"compression", types.MustNewArray("snappy", "zlib"),
 			),
 			resp: types.MustMakeDocument(
 				"helloOk", true,
 				"ismaster", true,
+				"topologyVersion", types.MustMakeDocument(
+					"processId", types.ObjectID{}, // set by compareFunc
+					"counter", int64(0),
+				),
 				"maxBsonObjectSize", int32(bson.MaxDocumentLen),
 				"maxMessageSizeBytes", int32(wire.MaxMsgLen),
 				"maxWriteBatchSize", int32(100000),
 				"localTime", time.Now(),
+				"logicalSessionTimeoutMinutes", int32(30),
+				"connectionId", int32(42),
 				"minWireVersion", int32(13),
 				"maxWireVersion", int32(13),
 				"readOnly", false,
+				"compression", new(types.Array),
 				"ok", float64(1),
 			),
-			compareFunc: func(t testing.TB, _ types.Document, actual, expected types.CompositeType) {
+			compareFunc: func(t testing.TB, _ types.Document, expected, actual types.CompositeType) {
 				testutil.CompareAndSetByPathTime(t, expected, actual, time.Second, "localTime")
+				processID := testutil.GetByPath(t, actual, "topologyVersion", "processId")
+				require.IsType(t, types.ObjectID{}, processID)
+				assert.NotEqual(t, types.ObjectID{}, processID)
+				testutil.SetByPath(t, expected, processID, "topologyVersion", "processId")
 				assert.Equal(t, expected, actual)
 			},
 		},
 		"Hello": {
 			req: types.MustMakeDocument(
 				"hello", int32(1),
+				"saslSupportedMechs", "admin.user",
 			),
 			resp: types.MustMakeDocument(
-				"helloOk", true,
-				"ismaster", true,
+				"isWritablePrimary", true,
+				"topologyVersion", types.MustMakeDocument(
+					"processId", types.ObjectID{}, // set by compareFunc
+					"counter", int64(0),
+				),
 				"maxBsonObjectSize", int32(bson.MaxDocumentLen),
 				"maxMessageSizeBytes", int32(wire.MaxMsgLen),
 				"maxWriteBatchSize", int32(100000),
 				"localTime", time.Now(),
+				"logicalSessionTimeoutMinutes", int32(30),
+				"connectionId", int32(42),
 				"minWireVersion", int32(13),
 				"maxWireVersion", int32(13),
 				"readOnly", false,
+				"saslSupportedMechs", new(types.Array),
 				"ok", float64(1),
 			),
-			compareFunc: func(t testing.TB, _ types.Document, actual, expected types.CompositeType) {
+			compareFunc: func(t testing.TB, _ types.Document, expected, actual types.CompositeType) {
+				testutil.CompareAndSetByPathTime(t, expected, actual, time.Second, "localTime")
+				processID := testutil.GetByPath(t, actual, "topologyVersion", "processId")
+				require.IsType(t, types.ObjectID{}, processID)
+				assert.NotEqual(t, types.ObjectID{}, processID)
+				testutil.SetByPath(t, expected, processID, "topologyVersion", "processId")
+				assert.Equal(t, expected, actual)
+			},
+		},
+		"HelloAwaitable": {
+			req: types.MustMakeDocument(
+				"hello", int32(1),
+				"topologyVersion", types.MustMakeDocument(
+					"processId", types.ObjectID{1}, // other process
+					"counter", int64(0),
+				),
+				"maxAwaitTimeMS", int32(10000),
+			),
+			resp: types.MustMakeDocument(
+				"isWritablePrimary", true,
+				"topologyVersion", types.MustMakeDocument(
+					"processId", types.ObjectID{}, // set by compareFunc
+					"counter", int64(0),
+				),
+				"maxBsonObjectSize", int32(bson.MaxDocumentLen),
+				"maxMessageSizeBytes", int32(wire.MaxMsgLen),
+				"maxWriteBatchSize", int32(100000),
+				"localTime", time.Now(),
+				"logicalSessionTimeoutMinutes", int32(30),
+				"connectionId", int32(42),
+				"minWireVersion", int32(13),
+				"maxWireVersion", int32(13),
+				"readOnly", false,
+				"ok", float64(1),
+			),
+			compareFunc: func(t testing.TB, _ types.Document, expected, actual types.CompositeType) {
 				testutil.CompareAndSetByPathTime(t, expected, actual, time.Second, "localTime")
+				processID := testutil.GetByPath(t, actual, "topologyVersion", "processId")
+				require.IsType(t, types.ObjectID{}, processID)
+				assert.NotEqual(t, types.ObjectID{}, processID)
+				testutil.SetByPath(t, expected, processID, "topologyVersion", "processId")
 				assert.Equal(t, expected, actual)
 			},
 		},
@@ -700,6 +2539,22 @@ func TestReadOnlyHandlers(t *testing.T) {
 				"version", "5.0.42",
 				"ok", float64(1),
 			),
+			compareFunc: func(t testing.TB, _ types.Document, expected, actual types.CompositeType) {
+				// the rest of the response depends on the process and other tests
+				for _, key := range []string{"version", "ok"} {
+					assert.Equal(t, testutil.GetByPath(t, expected, key), testutil.GetByPath(t, actual, key))
+				}
+
+				assert.IsType(t, "", testutil.GetByPath(t, actual, "host"))
+				assert.IsType(t, int64(0), testutil.GetByPath(t, actual, "pid"))
+				assert.IsType(t, time.Time{}, testutil.GetByPath(t, actual, "localTime"))
+				assert.IsType(t, int32(0), testutil.GetByPath(t, actual, "connections", "current"))
+				assert.IsType(t, int64(0), testutil.GetByPath(t, actual, "opcounters", "command"))
+				assert.IsType(t, int64(0), testutil.GetByPath(t, actual, "metrics", "document", "returned"))
+
+				total := testutil.GetByPath(t, actual, "metrics", "commands", "serverstatus", "total")
+				assert.Positive(t, total)
+			},
 		},
 	}
 
@@ -761,8 +2616,8 @@ func TestListDropDatabase(t *testing.T) {
 					"empty", true,
 				),
 			),
-			"totalSize", int64(30_286_627),
-			"totalSizeMb", int64(28),
+			"totalSize", int64(20_758_528),
+			"totalSizeMb", int64(19),
 			"ok", float64(1),
 		)
 
@@ -814,6 +2669,54 @@ func TestListDropDatabase(t *testing.T) {
 		)
 		assert.Equal(t, expected, actual)
 	})
+
+	t.Run("Filter", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"listDatabases", int32(1),
+			"filter", types.MustMakeDocument("name", types.Regex{Pattern: "ila$"}),
+			"nameOnly", true,
+			"authorizedDatabases", true,
+		))
+		expected := types.MustMakeDocument(
+			"databases", types.MustNewArray(
+				types.MustMakeDocument("name", "monila"),
+				types.MustMakeDocument("name", "pagila"),
+			),
+			"ok", float64(1),
+		)
+		assert.Equal(t, expected, actual)
+
+		actual = handle(ctx, t, handler, types.MustMakeDocument(
+			"listDatabases", int32(1),
+			"filter", types.MustMakeDocument("empty", false),
+		))
+		databases := testutil.GetByPath(t, actual, "databases").(*types.Array)
+		require.Equal(t, 2, databases.Len())
+
+		var sizes int64
+		for i := 0; i < databases.Len(); i++ {
+			d, err := databases.Get(i)
+			require.NoError(t, err)
+			sizes += d.(types.Document).Map()["sizeOnDisk"].(int64)
+		}
+		assert.Equal(t, sizes, testutil.GetByPath(t, actual, "totalSize"))
+	})
+
+	t.Run("Internal", func(t *testing.T) {
+		actual := handle(ctx, t, handler, types.MustMakeDocument(
+			"listDatabases", int32(1),
+			"filter", types.MustMakeDocument("name", pg.CatalogSchema),
+		))
+		assert.Equal(t, types.MakeArray(0), testutil.GetByPath(t, actual, "databases"))
+
+		for _, db := range []string{pg.CatalogSchema, "pg_catalog", "information_schema"} {
+			actual = handle(ctx, t, handler, types.MustMakeDocument(
+				"dropDatabase", int32(1),
+				"$db", db,
+			))
+			assert.Equal(t, int32(common.ErrInvalidNamespace), testutil.GetByPath(t, actual, "code"), db)
+		}
+	})
 }
 
 //nolint:paralleltest // we test a global list of collections
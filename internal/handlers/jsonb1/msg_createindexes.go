@@ -16,17 +16,77 @@ package jsonb1
 
 import (
 	"context"
+	"errors"
+	"math"
+	"time"
 
+	"go.uber.org/zap"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
+// MsgCreateIndexes creates indexes of a collection.
+//
+// Only TTL indexes are created; other indexes are accepted and ignored.
 func (h *storage) MsgCreateIndexes(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
 	// TODO https://github.com/FerretDB/FerretDB/issues/78
 
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	collection := m[document.Keys()[0]].(string)
+	db := m["$db"].(string)
+
+	indexesV, ok := m["indexes"]
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrMissingField, "BSON field 'createIndexes.indexes' is missing but a required field",
+		)
+	}
+	indexes, ok := indexesV.(*types.Array)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrTypeMismatch, "BSON field 'createIndexes.indexes' is the wrong type, expected array",
+		)
+	}
+
+	var ttlIndexes []*pg.TTLIndex
+	for i := 0; i < indexes.Len(); i++ {
+		v, err := indexes.Get(i)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		spec, ok := v.(types.Document)
+		if !ok {
+			return nil, common.NewErrorMessage(common.ErrTypeMismatch, "The elements of the 'indexes' array must be objects")
+		}
+
+		idx, err := getTTLIndex(spec)
+		if err != nil {
+			return nil, err
+		}
+		if idx != nil {
+			idx.DB, idx.Collection = db, collection
+			ttlIndexes = append(ttlIndexes, idx)
+		}
+	}
+
+	if len(ttlIndexes) > 0 {
+		if err = h.createTTLIndexes(ctx, ttlIndexes); err != nil {
+			return nil, err
+		}
+	}
+
 	var reply wire.OpMsg
-	err := reply.SetSections(wire.OpMsgSection{
+	err = reply.SetSections(wire.OpMsgSection{
 		Documents: []types.Document{types.MustMakeDocument(
 			"ok", float64(1),
 		)},
@@ -37,3 +97,95 @@ func (h *storage) MsgCreateIndexes(ctx context.Context, msg *wire.OpMsg) (*wire.
 
 	return &reply, nil
 }
+
+// createTTLIndexes creates TTL indexes of the same collection, creating it if needed.
+func (h *storage) createTTLIndexes(ctx context.Context, indexes []*pg.TTLIndex) error {
+	db, collection := indexes[0].DB, indexes[0].Collection
+
+	if err := h.pgPool.CreateSchema(ctx, db); err != nil && err != pg.ErrAlreadyExist {
+		return lazyerrors.Error(common.NamespaceError(err, db, collection))
+	}
+
+	if err := h.pgPool.CreateTable(ctx, db, collection); err != nil && err != pg.ErrAlreadyExist {
+		return lazyerrors.Error(common.NamespaceError(err, db, collection))
+	}
+
+	opts, err := h.pgPool.TableOptions(ctx, db, collection)
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+	if opts.Capped {
+		return common.NewErrorMessage(common.ErrCannotCreateIndex, "Cannot create TTL index on a capped collection")
+	}
+
+	for _, idx := range indexes {
+		err = h.pgPool.CreateTTLIndex(ctx, idx)
+		if errors.Is(err, pg.ErrIndexOptionsConflict) {
+			return common.NewErrorMessage(
+				common.ErrIndexOptionsConflict,
+				"An existing index has the same name as the requested index but different key or options: %s", idx.Name,
+			)
+		}
+		if err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		h.l.Info(
+			"Created TTL index.",
+			zap.String("ns", db+"."+collection), zap.String("name", idx.Name), zap.Duration("expireAfter", idx.ExpireAfter),
+		)
+	}
+
+	return nil
+}
+
+// getTTLIndex returns TTL index for the given index specification, or nil if it is not a TTL index.
+func getTTLIndex(spec types.Document) (*pg.TTLIndex, error) {
+	m := spec.Map()
+	if _, ok := m["expireAfterSeconds"]; !ok {
+		return nil, nil
+	}
+
+	name, ok := m["name"].(string)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrFailedToParse, "The 'name' field is a required property of an index specification",
+		)
+	}
+
+	key, ok := m["key"].(types.Document)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrFailedToParse, "The 'key' field is a required property of an index specification",
+		)
+	}
+
+	keys := key.Keys()
+	if len(keys) != 1 {
+		return nil, common.NewErrorMessage(
+			common.ErrCannotCreateIndex, "TTL indexes are single-field indexes, compound indexes do not support TTL",
+		)
+	}
+	if keys[0] == "_id" {
+		return nil, common.NewErrorMessage(
+			common.ErrCannotCreateIndex, "The field 'expireAfterSeconds' is not valid for an _id index specification",
+		)
+	}
+
+	expireAfterSeconds, err := common.GetWholeNumberParam(spec, "expireAfterSeconds", 0)
+	if err != nil {
+		return nil, common.NewErrorMessage(common.ErrCannotCreateIndex, "TTL index 'expireAfterSeconds' option must be numeric")
+	}
+	if expireAfterSeconds < 0 || expireAfterSeconds > math.MaxInt32 {
+		return nil, common.NewErrorMessage(
+			common.ErrCannotCreateIndex,
+			"TTL index 'expireAfterSeconds' option must be within an acceptable range, try a lower number",
+		)
+	}
+
+	return &pg.TTLIndex{
+		Name:        name,
+		Key:         keys[0],
+		ExpireAfter: time.Duration(expireAfterSeconds) * time.Second,
+	}, nil
+}
@@ -16,74 +16,13 @@ package jsonb1
 
 import (
 	"context"
-	"fmt"
-
-	"github.com/jackc/pgx/v4"
 
 	"github.com/FerretDB/FerretDB/internal/handlers/common"
-	"github.com/FerretDB/FerretDB/internal/pg"
-	"github.com/FerretDB/FerretDB/internal/types"
-	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
 // MsgDelete deletes document.
 func (h *storage) MsgDelete(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
-	document, err := msg.Document()
-	if err != nil {
-		return nil, lazyerrors.Error(err)
-	}
-
-	m := document.Map()
-	collection := m[document.Command()].(string)
-	db := m["$db"].(string)
-	docs, _ := m["deletes"].(*types.Array)
-
-	var deleted int32
-	for i := 0; i < docs.Len(); i++ {
-		doc, err := docs.Get(i)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
-		}
-
-		d := doc.(types.Document).Map()
-
-		sql := fmt.Sprintf(`DELETE FROM %s`, pgx.Identifier{db, collection}.Sanitize())
-		var placeholder pg.Placeholder
-
-		elSQL, args, err := where(d["q"].(types.Document), &placeholder)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
-		}
-
-		limit, _ := d["limit"].(int32)
-		if limit != 0 {
-			sql += fmt.Sprintf(" WHERE _jsonb->'_id' IN (SELECT _jsonb->'_id' FROM %s", pgx.Identifier{db, collection}.Sanitize())
-			sql += elSQL
-			sql += " LIMIT 1)"
-		} else {
-			sql += elSQL
-		}
-
-		tag, err := h.pgPool.Exec(ctx, sql, args...)
-		if err != nil {
-			// TODO check error code
-			return nil, common.NewErrorMessage(common.ErrNamespaceNotFound, "MsgDelete: ns not found: %w", err)
-		}
-
-		deleted += int32(tag.RowsAffected())
-	}
-
-	var reply wire.OpMsg
-	err = reply.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"n", deleted,
-			"ok", float64(1),
-		)},
-	})
-	if err != nil {
-		return nil, lazyerrors.Error(err)
-	}
-
-	return &reply, nil
+	// documents are deleted in the _id order, which uses the unique index
+	return common.Delete(ctx, h.pgPool, msg, where, "_jsonb->'_id'")
 }
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
 
 	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
@@ -45,25 +46,47 @@ func (h *storage) MsgFindOrCount(ctx context.Context, msg *wire.OpMsg) (*wire.Op
 	_, isFindOp := m["find"].(string)
 	db := m["$db"].(string)
 
+	opts, err := common.GetFindOptions(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var projection *common.Projection
 	if isFindOp {
 		projectionIn, _ := m["projection"].(types.Document)
-		projectionSQL, projectionArgs, err := projection(projectionIn, &placeholder)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
+		if projection, err = common.NewProjection(projectionIn); err != nil {
+			return nil, err
 		}
-		args = append(args, projectionArgs...)
 
 		collection = m["find"].(string)
 		filter, _ = m["filter"].(types.Document)
-		sql = fmt.Sprintf(`SELECT %s FROM %s`, projectionSQL, pgx.Identifier{db, collection}.Sanitize())
+		sql = fmt.Sprintf(`SELECT _jsonb FROM %s`, pg.TableIdentifier(db, collection).Sanitize())
 	} else {
 		collection = m["count"].(string)
 		filter, _ = m["query"].(types.Document)
-		sql = fmt.Sprintf(`SELECT COUNT(*) FROM %s`, pgx.Identifier{db, collection}.Sanitize())
+		sql = fmt.Sprintf(`SELECT COUNT(*) FROM %s`, pg.TableIdentifier(db, collection).Sanitize())
+		if opts.Skip != 0 || opts.Limit != 0 {
+			// skip and limit are applied by the subquery, see below
+			sql = fmt.Sprintf(`SELECT 1 FROM %s`, pg.TableIdentifier(db, collection).Sanitize())
+		}
 	}
 
 	sort, _ := m["sort"].(types.Document)
-	limit, _ := m["limit"].(int32)
+
+	var tableOpts *pg.TableOptions
+	if isFindOp {
+		if tableOpts, err = h.pgPool.TableOptions(ctx, db, collection); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		if opts.Tailable {
+			if !tableOpts.Capped {
+				return nil, common.TailableNotCapped(db + "." + collection)
+			}
+
+			return h.findTail(ctx, db, collection, filter, sort, projection, opts)
+		}
+	}
 
 	whereSQL, whereArgs, err := where(filter, &placeholder)
 	if err != nil {
@@ -74,7 +97,16 @@ func (h *storage) MsgFindOrCount(ctx context.Context, msg *wire.OpMsg) (*wire.Op
 	sql += whereSQL
 
 	sortMap := sort.Map()
-	if len(sortMap) != 0 {
+	if _, ok := sortMap["$natural"]; ok && isFindOp && len(sortMap) == 1 {
+		// natural order of other collections is undefined
+		if tableOpts.Capped {
+			order, err := naturalOrder(sort)
+			if err != nil {
+				return nil, err
+			}
+			sql += " ORDER BY _seq " + order
+		}
+	} else if isFindOp && len(sortMap) != 0 {
 		sql += " ORDER BY"
 
 		for i, k := range sort.Keys() {
@@ -92,70 +124,112 @@ func (h *storage) MsgFindOrCount(ctx context.Context, msg *wire.OpMsg) (*wire.Op
 				sql += " DESC"
 			}
 		}
+	} else if isFindOp && tableOpts.Capped {
+		// capped collections return documents in the insertion order
+		sql += " ORDER BY _seq"
 	}
 
-	switch {
-	case limit == 0:
-		// undefined or zero - no limit
-	case limit > 0:
+	if opts.Limit != 0 {
 		sql += " LIMIT " + placeholder.Next()
-		args = append(args, limit)
-	default:
-		// TODO https://github.com/FerretDB/FerretDB/issues/79
-		return nil, common.NewErrorMessage(common.ErrNotImplemented, "MsgFind: negative limit values are not supported")
+		args = append(args, opts.Limit)
 	}
 
-	rows, err := h.pgPool.Query(ctx, sql, args...)
-	if err != nil {
-		return nil, lazyerrors.Error(err)
+	if opts.Skip != 0 {
+		sql += " OFFSET " + placeholder.Next()
+		args = append(args, opts.Skip)
 	}
-	defer rows.Close()
 
-	var reply wire.OpMsg
-	if isFindOp { //nolint:nestif // FIXME: I have no idead to fix this lint
-		var docs types.Array
-		for {
-			doc, err := nextRow(rows)
+	if !isFindOp && (opts.Skip != 0 || opts.Limit != 0) {
+		sql = `SELECT COUNT(*) FROM (` + sql + `) AS q`
+	}
+
+	sql = pg.Comment(opts.Comment) + sql
+
+	if opts.Comment != "" {
+		h.l.Debug("MsgFindOrCount", zap.String("ns", db+"."+collection), zap.String("comment", opts.Comment))
+	}
+
+	var docs []types.Document
+	var count int64
+	var counted bool
+	if !isFindOp && len(filter.Keys()) == 0 && opts.Skip == 0 && opts.Limit == 0 && opts.Hint == nil {
+		// fast count from table statistics, like MongoDB uses collection metadata for count without a query
+		estimated, err := h.pgPool.EstimatedRows(ctx, db, collection)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+		if estimated >= 0 {
+			count, counted = estimated, true
+		}
+	}
+
+	if !counted {
+		err = h.pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+			if err := pg.SetStatementTimeout(ctx, tx, opts.MaxTime); err != nil {
+				return err
+			}
+
+			rows, err := tx.Query(ctx, sql, args...)
 			if err != nil {
-				return nil, lazyerrors.Error(err)
+				return lazyerrors.Error(err)
 			}
-			if doc == nil {
-				break
+			defer rows.Close()
+
+			if !isFindOp {
+				for rows.Next() {
+					if err := rows.Scan(&count); err != nil {
+						return lazyerrors.Error(err)
+					}
+				}
+				return rows.Err()
 			}
 
-			if err = docs.Append(*doc); err != nil {
-				return nil, lazyerrors.Error(err)
+			for {
+				doc, err := nextRow(rows)
+				if err != nil {
+					return lazyerrors.Error(err)
+				}
+				if doc == nil {
+					return nil
+				}
+
+				projected, err := projection.Project(*doc, filter)
+				if err != nil {
+					return err
+				}
+
+				docs = append(docs, projected)
+			}
+		})
+		if err != nil {
+			if pg.IsStatementTimeout(err) {
+				return nil, common.MaxTimeMSExpired()
 			}
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	var reply wire.OpMsg
+	if isFindOp {
+		firstBatch, cursorID, err := h.cursors.FirstBatch(db+"."+collection, docs, opts.BatchSize, opts.SingleBatch)
+		if err != nil {
+			return nil, err
 		}
+
 		err = reply.SetSections(wire.OpMsgSection{
 			Documents: []types.Document{types.MustMakeDocument(
 				"cursor", types.MustMakeDocument(
-					"firstBatch", &docs,
-					"id", int64(0), // TODO
+					"firstBatch", firstBatch,
+					"id", cursorID,
 					"ns", db+"."+collection,
 				),
 				"ok", float64(1),
 			)},
 		})
 	} else {
-		var count int32
-		for rows.Next() {
-			err := rows.Scan(&count)
-			if err != nil {
-				return nil, lazyerrors.Error(err)
-			}
-		}
-		// in psql, the SELECT * FROM table limit `x` ignores the value of the limit,
-		// so, we need this `if` statement to support this kind of query `db.actor.find().limit(10).count()`
-		if count > limit && limit != 0 {
-			count = limit
-		}
-		if err != nil {
-			return nil, lazyerrors.Error(err)
-		}
 		err = reply.SetSections(wire.OpMsgSection{
 			Documents: []types.Document{types.MustMakeDocument(
-				"n", count,
+				"n", common.CountValue(count),
 				"ok", float64(1),
 			)},
 		})
@@ -17,15 +17,27 @@ package jsonb1
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/jackc/pgx/v4"
 
 	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
+const (
+	// insertBatchMaxBytes is the approximate maximal total size of documents inserted by a single statement.
+	insertBatchMaxBytes = 1 << 20
+
+	// insertBatchMaxDocuments is the maximal number of documents inserted by a single statement;
+	// it is well below PostgreSQL's limit of 65535 bind parameters.
+	insertBatchMaxDocuments = 1000
+)
+
 // MsgInsert inserts a document or documents into a collection.
 func (h *storage) MsgInsert(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
 	document, err := msg.Document()
@@ -38,33 +50,102 @@ func (h *storage) MsgInsert(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 	db := m["$db"].(string)
 	docs, _ := m["documents"].(*types.Array)
 
-	var inserted int32
-	for i := 0; i < docs.Len(); i++ {
+	ordered, err := common.GetBoolParam(document, "ordered", true)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := common.GetRetryableWrite(document)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := common.GetValidator(ctx, h.pgPool, document, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := db + "." + collection
+
+	// marshal and validate all documents upfront to split them into batches by size;
+	// invalid documents are reported by statement function
+	values := make([][]byte, docs.Len())
+	validationErrs := make([]error, docs.Len())
+	for i := range values {
 		doc, err := docs.Get(i)
 		if err != nil {
 			return nil, lazyerrors.Error(err)
 		}
 
-		d := doc.(types.Document)
-		sql := fmt.Sprintf("INSERT INTO %s (_jsonb) VALUES ($1)", pgx.Identifier{db, collection}.Sanitize())
-		b, err := bson.MustConvertDocument(d).MarshalJSON()
+		d, ok := doc.(types.Document)
+		if !ok {
+			return nil, common.NewErrorMessage(common.ErrBadValue, "Invalid document at index %d", i)
+		}
+
+		if values[i], err = bson.MustConvertDocument(d).MarshalJSON(); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		validationErrs[i] = validator.Check(h.l, ns, d)
+	}
+
+	table := pg.TableIdentifier(db, collection).Sanitize()
+
+	batchEnd := func(from int) int {
+		var size int
+		to := from
+		for to < len(values) && to-from < insertBatchMaxDocuments && size < insertBatchMaxBytes {
+			size += len(values[to])
+			to++
+		}
+		return to
+	}
+
+	batch := func(tx pgx.Tx, from, to int) (int64, int64, error) {
+		for _, err := range validationErrs[from:to] {
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+
+		var placeholder pg.Placeholder
+		placeholders := make([]string, 0, to-from)
+		args := make([]any, 0, to-from)
+		for _, v := range values[from:to] {
+			placeholders = append(placeholders, "("+placeholder.Next()+")")
+			args = append(args, v)
+		}
+
+		sql := fmt.Sprintf("INSERT INTO %s (_jsonb) VALUES %s", table, strings.Join(placeholders, ", "))
+		tag, err := tx.Exec(ctx, sql, args...)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
+		}
+
+		return tag.RowsAffected(), 0, nil
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s (_jsonb) VALUES ($1)", table)
+	statement := func(tx pgx.Tx, i int) (int64, int64, error) {
+		if validationErrs[i] != nil {
+			return 0, 0, validationErrs[i]
 		}
 
-		if _, err = h.pgPool.Exec(ctx, sql, b); err != nil {
-			return nil, err
+		if _, err := tx.Exec(ctx, sql, values[i]); err != nil {
+			return 0, 0, err
 		}
 
-		inserted++
+		return 1, 0, nil
+	}
+
+	res, err := common.BulkWriteBatches(ctx, h.pgPool, ns, len(values), ordered, retry, batchEnd, batch, statement)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
 	}
 
 	var reply wire.OpMsg
 	err = reply.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"n", inserted,
-			"ok", float64(1),
-		)},
+		Documents: []types.Document{res.Reply(false)},
 	})
 	if err != nil {
 		return nil, lazyerrors.Error(err)
@@ -16,11 +16,15 @@ package jsonb1
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"strings"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 
-	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
@@ -39,112 +43,363 @@ func (h *storage) MsgUpdate(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 	docs, _ := m["updates"].(*types.Array)
 	db := m["$db"].(string)
 
-	var selected, updated int32
-	for i := 0; i < docs.Len(); i++ {
+	ordered, err := common.GetBoolParam(document, "ordered", true)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := common.GetRetryableWrite(document)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := common.GetValidator(ctx, h.pgPool, document, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := db + "." + collection
+	res, err := common.BulkWrite(ctx, h.pgPool, ns, docs.Len(), ordered, retry, func(tx pgx.Tx, i int) (int64, int64, error) {
 		doc, err := docs.Get(i)
 		if err != nil {
-			return nil, lazyerrors.Error(err)
+			return 0, 0, lazyerrors.Error(err)
 		}
 
-		docM := doc.(types.Document).Map()
+		return h.update(ctx, tx, db, collection, doc.(types.Document), validator)
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
 
-		sql := fmt.Sprintf(`SELECT _jsonb FROM %s`, pgx.Identifier{db, collection}.Sanitize())
-		var placeholder pg.Placeholder
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{res.Reply(true)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
 
-		whereSQL, args, err := where(docM["q"].(types.Document), &placeholder)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
+	return &reply, nil
+}
+
+// update executes a single update statement and returns the number of matched and modified documents.
+//
+// Simple updates are executed atomically by a single UPDATE statement, see updateSQL.
+// Other updates, all updates of collections with validators,
+// and updates of documents with Decimal128 values of $inc fields
+// lock matched documents with SELECT ... FOR UPDATE and modify them in Go.
+func (h *storage) update(
+	ctx context.Context, tx pgx.Tx, db, collection string, statement types.Document, validator *common.Validator,
+) (int64, int64, error) {
+	docM := statement.Map()
+
+	u, _ := docM["u"].(types.Document)
+	if err := common.ValidateUpdateOperators(u); err != nil {
+		return 0, 0, err
+	}
+
+	var placeholder pg.Placeholder
+
+	q, _ := docM["q"].(types.Document)
+	whereSQL, whereArgs, err := where(q, &placeholder)
+	if err != nil {
+		return 0, 0, lazyerrors.Error(err)
+	}
+
+	table := pg.TableIdentifier(db, collection).Sanitize()
+
+	// placeholders of the fallback query continue after the filter ones
+	decimalPlaceholder := placeholder
+
+	setSQL, changedSQL, okSQL, setArgs, err := updateSQL(u, &placeholder)
+	if err != nil {
+		return 0, 0, lazyerrors.Error(err)
+	}
+	if setSQL == "" || validator != nil {
+		return h.updateForUpdate(ctx, tx, db+"."+collection, table, whereSQL, whereArgs, u, validator)
+	}
+
+	filterSQL := strings.TrimPrefix(whereSQL, " WHERE")
+	if filterSQL == "" {
+		filterSQL = " TRUE"
+	}
+
+	// PostgreSQL numeric values can't be converted to Decimal128 with the same rounding rules,
+	// so documents with Decimal128 values of $inc fields are skipped and updated in Go below.
+	decimalSQL, decimalArgs := incDecimalSQL(u, &placeholder)
+	filterSQL = "(" + filterSQL + ") AND NOT " + decimalSQL
+
+	// Documents that wouldn't be changed are not updated, but still counted as matched by the last subquery.
+	// It sees the table as it was before the update, like all parts of the statement.
+	// Documents with values of wrong types are "updated" to themselves to be reported as errors.
+	sql := "WITH u AS (" +
+		"UPDATE " + table + " SET _jsonb = CASE WHEN " + okSQL + " THEN " + setSQL + " ELSE _jsonb END" +
+		" WHERE (" + filterSQL + ") AND (" + changedSQL + " OR NOT " + okSQL + ")" +
+		" RETURNING " + okSQL + " AS ok" +
+		") SELECT " +
+		"(SELECT COUNT(*) FROM u), " +
+		"(SELECT COUNT(*) FROM u WHERE NOT ok), " +
+		"(SELECT COUNT(*) FROM " + table + " WHERE (" + filterSQL + ") AND NOT (" + changedSQL + " OR NOT " + okSQL + "))"
+
+	var updated, failed, unchanged int64
+	args := append(append(whereArgs, setArgs...), decimalArgs...)
+	err = tx.QueryRow(ctx, sql, args...).Scan(&updated, &failed, &unchanged)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.NumericValueOutOfRange {
+			return 0, 0, common.NewErrorMessage(common.ErrBadValue, "Failed to apply $inc operations to current value")
 		}
+		return 0, 0, err
+	}
+
+	if failed > 0 {
+		return 0, 0, common.NewErrorMessage(common.ErrTypeMismatch, "Cannot apply $inc to a value of non-numeric type")
+	}
+
+	if decimalSQL == "FALSE" {
+		return updated + unchanged, updated, nil
+	}
+
+	decimalSQL, decimalArgs = incDecimalSQL(u, &decimalPlaceholder)
+	decimalWhereSQL := " WHERE " + decimalSQL
+	if whereSQL != "" {
+		decimalWhereSQL = whereSQL + " AND " + decimalSQL
+	}
+
+	matched, modified, err := h.updateForUpdate(
+		ctx, tx, db+"."+collection, table, decimalWhereSQL, append(whereArgs, decimalArgs...), u, validator,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
 
-		sql += whereSQL
+	return updated + unchanged + matched, updated + modified, nil
+}
 
-		rows, err := h.pgPool.Query(ctx, sql, args...)
+// updateForUpdate executes update statement by locking and reading matched documents,
+// modifying and validating them in Go, and writing them back.
+func (h *storage) updateForUpdate(
+	ctx context.Context, tx pgx.Tx, ns, table, whereSQL string, whereArgs []any, u types.Document, validator *common.Validator,
+) (int64, int64, error) {
+	rows, err := tx.Query(ctx, "SELECT _jsonb FROM "+table+whereSQL+" FOR UPDATE", whereArgs...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var updateDocs []types.Document
+	for {
+		updateDoc, err := nextRow(rows)
 		if err != nil {
-			return nil, err
+			rows.Close()
+			return 0, 0, err
+		}
+		if updateDoc == nil {
+			break
 		}
-		defer rows.Close()
 
-		var updateDocs types.Array
+		updateDocs = append(updateDocs, *updateDoc)
+	}
+	rows.Close()
 
-		for {
-			updateDoc, err := nextRow(rows)
-			if err != nil {
-				return nil, err
+	sql := "UPDATE " + table + " SET _jsonb = $1 WHERE _jsonb->'_id' = $2"
+
+	var modified int64
+	for _, d := range updateDocs {
+		exempt := validator.Exempt(d)
+
+		changed, err := common.UpdateDocument(&d, u)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !changed {
+			continue
+		}
+
+		if !exempt {
+			if err = validator.Check(h.l, ns, d); err != nil {
+				return 0, 0, err
 			}
-			if updateDoc == nil {
-				break
+		}
+
+		b, err := fjson.Marshal(d)
+		if err != nil {
+			return 0, 0, lazyerrors.Error(err)
+		}
+
+		idb, err := fjson.Marshal(d.Map()["_id"])
+		if err != nil {
+			return 0, 0, lazyerrors.Error(err)
+		}
+
+		tag, err := tx.Exec(ctx, sql, b, idb)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		modified += tag.RowsAffected()
+	}
+
+	return int64(len(updateDocs)), modified, nil
+}
+
+// updateSQL compiles simple update operators into SQL expressions.
+//
+// Only $set, $unset and $inc of top-level fields other than _id are supported;
+// for other update documents, empty setSQL is returned.
+// setSQL is the new value of the _jsonb column that preserves fjson key order in $k.
+// changedSQL is true for documents that would be changed by the update.
+// okSQL is false for documents with values of wrong types for $inc.
+// All expressions refer to the old value of the _jsonb column.
+func updateSQL(u types.Document, p *pg.Placeholder) (setSQL, changedSQL, okSQL string, args []any, err error) {
+	uM := u.Map()
+
+	for _, op := range u.Keys() {
+		for _, k := range uM[op].(types.Document).Keys() {
+			if k == "_id" || strings.Contains(k, ".") || strings.HasPrefix(k, "$") {
+				return "", "", "", nil, nil
 			}
 
-			if err = updateDocs.Append(*updateDoc); err != nil {
-				return nil, lazyerrors.Error(err)
+			// PostgreSQL numeric values can't be converted to Decimal128 with the same rounding rules
+			if _, ok := uM[op].(types.Document).Map()[k].(types.Decimal128); ok && op == "$inc" {
+				return "", "", "", nil, nil
 			}
 		}
+	}
 
-		selected += int32(updateDocs.Len())
+	setSQL = "_jsonb"
+	keysSQL := "(_jsonb->'$k')"
+	var changed, ok []string
 
-		for i := 0; i < updateDocs.Len(); i++ {
-			updateDoc, err := updateDocs.Get(i)
-			if err != nil {
-				return nil, lazyerrors.Error(err)
-			}
+	for _, op := range u.Keys() {
+		fields := uM[op].(types.Document)
+		fieldsM := fields.Map()
+
+		for _, k := range fields.Keys() {
+			key := p.Next() + "::text"
+			args = append(args, k)
+			cur := "(_jsonb->" + key + ")"
 
-			d := updateDoc.(types.Document)
-
-			for updateOp, updateV := range docM["u"].(types.Document).Map() {
-				switch updateOp {
-				case "$set":
-					for k, v := range updateV.(types.Document).Map() {
-						if err := d.Set(k, v); err != nil {
-							return nil, lazyerrors.Error(err)
-						}
-					}
-				default:
-					return nil, lazyerrors.Errorf("unhandled operation %q", updateOp)
+			// add a new key to the end of $k
+			addKeySQL := " || CASE WHEN _jsonb ? " + key + " THEN '[]'::jsonb ELSE jsonb_build_array(" + key + ") END"
+
+			switch op {
+			case "$set":
+				var b []byte
+				if b, err = fjson.Marshal(fieldsM[k]); err != nil {
+					return
 				}
-			}
 
-			if err = updateDocs.Set(i, d); err != nil {
-				return nil, lazyerrors.Error(err)
+				value := p.Next() + "::jsonb"
+				args = append(args, string(b))
+
+				setSQL = "jsonb_set(" + setSQL + ", ARRAY[" + key + "], " + value + ")"
+				keysSQL += addKeySQL
+				changed = append(changed, cur+" IS DISTINCT FROM "+value)
+
+			case "$unset":
+				setSQL = "(" + setSQL + " - " + key + ")"
+				keysSQL = "(" + keysSQL + " - " + key + ")"
+				changed = append(changed, "_jsonb ? "+key)
+
+			case "$inc":
+				inc := fieldsM[k]
+				var value string
+				if value, err = incSQL(cur, inc, p); err != nil {
+					return
+				}
+				args = append(args, inc)
+
+				setSQL = "jsonb_set(" + setSQL + ", ARRAY[" + key + "], " + value + ")"
+				keysSQL += addKeySQL
+				ok = append(ok, "("+cur+" IS NULL OR jsonb_typeof("+cur+") = 'number' OR "+
+					isObjectWithKey(cur, "$f")+" OR "+isObjectWithKey(cur, "$l")+")")
+
+				if types.Compare(inc, int32(0)) == types.Equal {
+					changed = append(changed, "NOT (_jsonb ? "+key+")")
+				} else {
+					changed = append(changed, "TRUE")
+				}
 			}
 		}
+	}
 
-		for i := 0; i < updateDocs.Len(); i++ {
-			updateDoc, err := updateDocs.Get(i)
-			if err != nil {
-				return nil, lazyerrors.Error(err)
-			}
+	if len(changed) == 0 {
+		return "", "", "", nil, nil
+	}
 
-			sql = fmt.Sprintf("UPDATE %s SET _jsonb = $1 WHERE _jsonb->'_id' = $2", pgx.Identifier{db, collection}.Sanitize())
-			d := updateDoc.(types.Document)
-			db, err := bson.MustConvertDocument(d).MarshalJSON()
-			if err != nil {
-				return nil, err
-			}
+	setSQL = "jsonb_set(" + setSQL + ", '{$k}', " + keysSQL + ")"
+	changedSQL = "(" + strings.Join(changed, " OR ") + ")"
+	okSQL = "TRUE"
+	if len(ok) > 0 {
+		okSQL = "(" + strings.Join(ok, " AND ") + ")"
+	}
 
-			idb, err := bson.ObjectID(d.Map()["_id"].(types.ObjectID)).MarshalJSON()
-			if err != nil {
-				return nil, err
-			}
-			tag, err := h.pgPool.Exec(ctx, sql, db, idb)
-			if err != nil {
-				return nil, err
-			}
+	return
+}
 
-			updated += int32(tag.RowsAffected())
-		}
+// incDecimalSQL returns SQL expression that is true for documents with Decimal128 values of $inc fields.
+//
+// Key placeholders are taken from p; the caller should add returned arguments.
+func incDecimalSQL(u types.Document, p *pg.Placeholder) (string, []any) {
+	inc, _ := u.Map()["$inc"].(types.Document)
+	if len(inc.Keys()) == 0 {
+		return "FALSE", nil
 	}
 
-	var reply wire.OpMsg
-	err = reply.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"n", selected,
-			"nModified", updated,
-			"ok", float64(1),
-		)},
-	})
-	if err != nil {
-		return nil, lazyerrors.Error(err)
+	res := make([]string, len(inc.Keys()))
+	args := make([]any, len(inc.Keys()))
+	for i, k := range inc.Keys() {
+		res[i] = isObjectWithKey("(_jsonb->"+p.Next()+"::text)", "$n")
+		args[i] = k
 	}
 
-	return &reply, nil
+	return "(" + strings.Join(res, " OR ") + ")", args
+}
+
+// isObjectWithKey returns SQL expression that checks that the jsonb value is an object with the given key.
+func isObjectWithKey(v, key string) string {
+	return "(jsonb_typeof(" + v + ") = 'object' AND " + v + " ? '" + key + "')"
+}
+
+// incSQL returns SQL expression for the $inc operator applied to the current fjson value.
+//
+// The argument placeholder is taken from p; the caller should add the argument.
+// The expression is valid only if the current value is missing or numeric, but not Decimal128.
+func incSQL(cur string, inc any, p *pg.Placeholder) (string, error) {
+	f := "COALESCE(" + cur + "->>'$f', " + cur + "->>'$l', " + cur + "#>>'{}')::float8"
+	l := "COALESCE(" + cur + "->>'$l', " + cur + "#>>'{}')::int8"
+
+	var n string
+	switch inc.(type) {
+	case float64:
+		n = p.Next() + "::float8"
+	case int64:
+		n = p.Next() + "::int8"
+	case int32:
+		n = p.Next() + "::int4"
+	default:
+		return "", lazyerrors.Errorf("incSQL: unexpected type %T", inc)
+	}
+
+	var res string
+	switch inc.(type) {
+	case float64:
+		res = "CASE WHEN " + cur + " IS NULL THEN jsonb_build_object('$f', " + n + ")" +
+			" ELSE jsonb_build_object('$f', " + f + " + " + n + ") END"
+
+	case int64:
+		res = "CASE WHEN " + cur + " IS NULL THEN jsonb_build_object('$l', " + n + "::text)" +
+			" WHEN " + isObjectWithKey(cur, "$f") + " THEN jsonb_build_object('$f', " + f + " + " + n + ")" +
+			" ELSE jsonb_build_object('$l', (" + l + " + " + n + ")::text) END"
+
+	case int32:
+		sum := "(" + l + " + " + n + ")"
+		res = "CASE WHEN " + cur + " IS NULL THEN to_jsonb(" + n + ")" +
+			" WHEN " + isObjectWithKey(cur, "$f") + " THEN jsonb_build_object('$f', " + f + " + " + n + ")" +
+			" WHEN " + isObjectWithKey(cur, "$l") + " THEN jsonb_build_object('$l', " + sum + "::text)" +
+			" WHEN " + sum + " BETWEEN -2147483648 AND 2147483647 THEN to_jsonb(" + sum + ")" +
+			" ELSE jsonb_build_object('$l', " + sum + "::text) END"
+	}
+
+	return res, nil
 }
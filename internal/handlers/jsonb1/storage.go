@@ -22,13 +22,15 @@ import (
 )
 
 type storage struct {
-	pgPool *pg.Pool
-	l      *zap.Logger
+	pgPool  *pg.Pool
+	l       *zap.Logger
+	cursors *common.Cursors
 }
 
-func NewStorage(pgPool *pg.Pool, l *zap.Logger) common.Storage {
+func NewStorage(pgPool *pg.Pool, l *zap.Logger, cursors *common.Cursors) common.Storage {
 	return &storage{
-		pgPool: pgPool,
-		l:      l,
+		pgPool:  pgPool,
+		l:       l,
+		cursors: cursors,
 	}
 }
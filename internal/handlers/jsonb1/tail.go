@@ -0,0 +1,235 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonb1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// cappedMaxBatch is the maximal number of capped collection's documents read at once by tailable cursors.
+const cappedMaxBatch = 1000
+
+// naturalOrder returns SQL order of {$natural: 1} or {$natural: -1} sort.
+func naturalOrder(sort types.Document) (string, error) {
+	natural, err := common.GetWholeNumberParam(sort, "$natural", 1)
+	if err != nil {
+		return "", err
+	}
+
+	switch natural {
+	case 1:
+		return "ASC", nil
+	case -1:
+		return "DESC", nil
+	default:
+		return "", common.NewErrorMessage(common.ErrBadValue, "$natural sort cannot be set to a value other than -1 or 1")
+	}
+}
+
+// findTail handles find with tailable cursor on the capped collection.
+func (h *storage) findTail(
+	ctx context.Context, db, collection string, filter, sort types.Document, projection *common.Projection, opts *common.FindOptions,
+) (*wire.OpMsg, error) {
+	tailableSort := common.NewErrorMessage(common.ErrBadValue, "cannot use tailable option with a sort other than {$natural: 1}")
+	if keys := sort.Keys(); len(keys) != 0 {
+		if len(keys) != 1 || keys[0] != "$natural" {
+			return nil, tailableSort
+		}
+
+		order, err := naturalOrder(sort)
+		if err != nil {
+			return nil, err
+		}
+		if order != "ASC" {
+			return nil, tailableSort
+		}
+	}
+
+	var placeholder pg.Placeholder
+	whereSQL, args, err := where(filter, &placeholder)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	if whereSQL == "" {
+		whereSQL = " WHERE"
+	} else {
+		whereSQL += " AND"
+	}
+
+	sql := fmt.Sprintf(
+		"SELECT _seq, _jsonb FROM %s%s _seq > %s ORDER BY _seq LIMIT %s",
+		pg.TableIdentifier(db, collection).Sanitize(), whereSQL, placeholder.Next(), placeholder.Next(),
+	)
+
+	tail := &cappedTail{
+		pgPool:     h.pgPool,
+		sql:        sql,
+		args:       args,
+		filter:     filter,
+		projection: projection,
+		skip:       opts.Skip,
+		limit:      opts.Limit,
+		awaitData:  opts.AwaitData,
+	}
+
+	ns := db + "." + collection
+
+	// the first batch is returned without waiting for documents, as in MongoDB
+	var done bool
+	firstBatch := new(types.Array)
+	if opts.BatchSize > 0 {
+		var docs []types.Document
+		if docs, done, err = tail.read(ctx, opts.BatchSize); err != nil {
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			if err = firstBatch.Append(doc); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+	}
+
+	var cursorID int64
+	if !done && !opts.SingleBatch {
+		cursorID = h.cursors.AddTail(ns, tail)
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursor", types.MustMakeDocument(
+				"firstBatch", firstBatch,
+				"id", cursorID,
+				"ns", ns,
+			),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// cappedTail reads capped collection's documents in the insertion order; it implements common.Tail interface.
+//
+// Writes to capped collections are serialized, so documents are never committed out of that order.
+type cappedTail struct {
+	pgPool     *pg.Pool
+	sql        string // the last read sequence value and the limit are the last two parameters
+	args       []any
+	filter     types.Document
+	projection *common.Projection
+	seq        int64 // the last read sequence value
+	skip       int64 // remaining number of matching documents to skip
+	limit      int64 // remaining number of documents to return; zero means no limit
+	awaitData  bool
+}
+
+// Next implements common.Tail interface.
+func (t *cappedTail) Next(ctx context.Context, batchSize int64, maxAwait time.Duration) ([]types.Document, bool, error) {
+	if !t.awaitData {
+		maxAwait = 0
+	}
+
+	return common.AwaitChangeLog(ctx, t.pgPool, maxAwait, func() ([]types.Document, bool, error) {
+		return t.read(ctx, batchSize)
+	})
+}
+
+// ResumeToken implements common.Tail interface.
+func (t *cappedTail) ResumeToken() (types.Document, bool) {
+	return types.Document{}, false
+}
+
+// read returns up to n (zero means any number) new matching documents,
+// and done = true if the limit is reached.
+func (t *cappedTail) read(ctx context.Context, n int64) ([]types.Document, bool, error) {
+	var docs []types.Document
+	for {
+		args := append(append([]any{}, t.args...), t.seq, int64(cappedMaxBatch))
+		rows, err := t.pgPool.Query(ctx, t.sql, args...)
+		if err != nil {
+			return nil, false, lazyerrors.Error(err)
+		}
+
+		var seqs []int64
+		var batch []types.Document
+		for rows.Next() {
+			var seq int64
+			var b []byte
+			if err = rows.Scan(&seq, &b); err != nil {
+				break
+			}
+
+			var doc bson.Document
+			if err = doc.UnmarshalJSON(b); err != nil {
+				break
+			}
+
+			seqs = append(seqs, seq)
+			batch = append(batch, types.MustConvertDocument(&doc))
+		}
+		rows.Close()
+
+		if err == nil {
+			err = rows.Err()
+		}
+		if err != nil {
+			return nil, false, lazyerrors.Error(err)
+		}
+
+		for i, doc := range batch {
+			t.seq = seqs[i]
+
+			if t.skip > 0 {
+				t.skip--
+				continue
+			}
+
+			projected, err := t.projection.Project(doc, t.filter)
+			if err != nil {
+				return nil, false, err
+			}
+			docs = append(docs, projected)
+
+			if t.limit > 0 {
+				if t.limit--; t.limit == 0 {
+					return docs, true, nil
+				}
+			}
+
+			if n > 0 && int64(len(docs)) == n {
+				return docs, false, nil
+			}
+		}
+
+		if len(batch) < cappedMaxBatch {
+			return docs, false, nil
+		}
+	}
+}
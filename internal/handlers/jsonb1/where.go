@@ -41,6 +41,9 @@ func scalar(v any, p *pg.Placeholder) (sql string, args []any, err error) {
 			return
 		}
 		arg = string(b)
+	case types.Decimal128:
+		sql = "jsonb_build_object('$n', " + p.Next() + "::text)"
+		arg = v.String()
 	case types.Regex:
 		var options string
 		for _, o := range v.Options {
@@ -64,6 +67,31 @@ func scalar(v any, p *pg.Placeholder) (sql string, args []any, err error) {
 	return
 }
 
+// numericOperators maps comparison operators to SQL operators.
+var numericOperators = map[string]string{
+	"$eq":  "=",
+	"$ne":  "<>",
+	"$lt":  "<",
+	"$lte": "<=",
+	"$gt":  ">",
+	"$gte": ">=",
+}
+
+// decimalExpr returns SQL expression that compares the field with Decimal128 value.
+//
+// Values of all numeric types are cast to PostgreSQL numeric for that;
+// values of other types do not match.
+func decimalExpr(field, op string, value types.Decimal128, p *pg.Placeholder) (sql string, args []any) {
+	v := "_jsonb->" + p.Next()
+	sql = "(CASE jsonb_typeof(" + v + ")" +
+		" WHEN 'number' THEN (" + v + ")::numeric" +
+		" WHEN 'object' THEN COALESCE(" + v + "->>'$n', " + v + "->>'$l', " + v + "->>'$f')::numeric" +
+		" END) " + op + " " + p.Next() + "::numeric"
+	args = []any{field, value.String()}
+
+	return
+}
+
 // fieldExpr handles {field: {expr}}.
 func fieldExpr(field string, expr types.Document, p *pg.Placeholder) (sql string, args []any, err error) {
 	filterKeys := expr.Keys()
@@ -105,6 +133,14 @@ func fieldExpr(field string, expr types.Document, p *pg.Placeholder) (sql string
 		if sql != "" {
 			sql += " "
 		}
+
+		if d, ok := value.(types.Decimal128); ok && numericOperators[op] != "" {
+			argSql, arg = decimalExpr(field, numericOperators[op], d, p)
+			sql += argSql
+			args = append(args, arg...)
+			continue
+		}
+
 		args = append(args, field)
 
 		switch op {
@@ -204,6 +240,10 @@ func wherePair(key string, value any, p *pg.Placeholder) (sql string, args []any
 		// {field: {expr}}
 		sql, args, err = fieldExpr(key, value, p)
 
+	case types.Decimal128:
+		// {field: decimal}
+		sql, args = decimalExpr(key, "=", value, p)
+
 	default:
 		// {field: value}
 		switch value.(type) {
@@ -258,3 +298,10 @@ func where(filter types.Document, p *pg.Placeholder) (sql string, args []any, er
 
 	return
 }
+
+// Where returns SQL WHERE clause for documents of jsonb1 tables matching the given filter.
+//
+// It is used by handlers that read jsonb1 tables without storage, for example, for views.
+func Where(filter types.Document, p *pg.Placeholder) (sql string, args []any, err error) {
+	return where(filter, p)
+}
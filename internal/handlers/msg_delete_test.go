@@ -158,3 +158,81 @@ func TestDelete(t *testing.T) {
 		}
 	})
 }
+
+//nolint:paralleltest // we use a stable table name
+func TestDeleteOne(t *testing.T) {
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+
+	for name, tc := range map[string]struct {
+		statement types.Document
+		n         int32
+		code      int32
+	}{
+		"LimitOne": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument("v", "x"), "limit", int32(1)),
+			n:         1,
+		},
+		"LimitDouble": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument(), "limit", float64(1), "hint", "_id_"),
+			n:         1,
+		},
+		"LimitZero": {
+			statement: types.MustMakeDocument(
+				"q", types.MustMakeDocument("v", "x"), "limit", int32(0), "collation", types.MustMakeDocument("locale", "simple"),
+			),
+			n: 3,
+		},
+		"LimitInvalid": {
+			statement: types.MustMakeDocument("q", types.MustMakeDocument(), "limit", int32(2)),
+			code:      9,
+		},
+		"CollationNotImplemented": {
+			statement: types.MustMakeDocument(
+				"q", types.MustMakeDocument(), "limit", int32(1), "collation", types.MustMakeDocument("locale", "en"),
+			),
+			code: 238,
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			collection := testutil.CreateTable(ctx, t, pool, db)
+
+			handle(ctx, t, handler, types.MustMakeDocument(
+				"insert", collection,
+				"documents", types.MustNewArray(
+					types.MustMakeDocument("_id", int32(3), "v", "x"),
+					types.MustMakeDocument("_id", int32(1), "v", "x"),
+					types.MustMakeDocument("_id", int32(2), "v", "x"),
+				),
+				"$db", db,
+			))
+
+			actual := handle(ctx, t, handler, types.MustMakeDocument(
+				"delete", collection,
+				"deletes", types.MustNewArray(tc.statement),
+				"$db", db,
+			))
+
+			if tc.code != 0 {
+				assert.Equal(t, tc.code, testutil.GetByPath(t, actual, "writeErrors", "0", "code"))
+				return
+			}
+
+			assert.Equal(t, tc.n, testutil.GetByPath(t, actual, "n"))
+
+			if tc.n == 1 {
+				// the document with the smallest _id is deleted
+				actual = handle(ctx, t, handler, types.MustMakeDocument(
+					"find", collection,
+					"sort", types.MustMakeDocument("_id", int32(1)),
+					"$db", db,
+				))
+				firstBatch := testutil.GetByPath(t, actual, "cursor", "firstBatch").(*types.Array)
+				require.Equal(t, 2, firstBatch.Len())
+				assert.Equal(t, int32(2), testutil.GetByPath(t, firstBatch, "0", "_id"))
+				assert.Equal(t, int32(3), testutil.GetByPath(t, firstBatch, "1", "_id"))
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+//nolint:paralleltest // we use a stable table name
+func TestInsertOrdered(t *testing.T) {
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+
+	for name, tc := range map[string]struct {
+		ordered bool
+		n       int32
+		indexes []int32
+	}{
+		"Ordered": {
+			ordered: true,
+			n:       1,
+			indexes: []int32{1},
+		},
+		"Unordered": {
+			ordered: false,
+			n:       2,
+			indexes: []int32{1, 3},
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			collection := testutil.CreateTable(ctx, t, pool, db)
+
+			actual := handle(ctx, t, handler, types.MustMakeDocument(
+				"insert", collection,
+				"documents", types.MustNewArray(
+					types.MustMakeDocument("_id", types.ObjectID{1}),
+					types.MustMakeDocument("_id", types.ObjectID{1}),
+					types.MustMakeDocument("_id", types.ObjectID{2}),
+					types.MustMakeDocument("_id", types.ObjectID{2}),
+				),
+				"ordered", tc.ordered,
+				"$db", db,
+			))
+
+			assert.Equal(t, tc.n, testutil.GetByPath(t, actual, "n"))
+			assert.Equal(t, float64(1), testutil.GetByPath(t, actual, "ok"))
+
+			writeErrors := testutil.GetByPath(t, actual, "writeErrors").(*types.Array)
+			require.Equal(t, len(tc.indexes), writeErrors.Len())
+			for i, index := range tc.indexes {
+				assert.Equal(t, index, testutil.GetByPath(t, writeErrors, strconv.Itoa(i), "index"))
+				assert.Equal(t, int32(11000), testutil.GetByPath(t, writeErrors, strconv.Itoa(i), "code"))
+			}
+
+			actual = handle(ctx, t, handler, types.MustMakeDocument(
+				"count", collection,
+				"query", types.MustMakeDocument(),
+				"$db", db,
+			))
+			assert.Equal(t, tc.n, testutil.GetByPath(t, actual, "n"))
+		})
+	}
+}
+
+//nolint:paralleltest // we use a stable table name
+func TestInsertBatches(t *testing.T) {
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+	collection := testutil.CreateTable(ctx, t, pool, db)
+
+	// enough documents for several batches, with a duplicate in the middle of the second one
+	docs := make([]any, 2500)
+	for i := range docs {
+		docs[i] = types.MustMakeDocument("_id", int32(i))
+	}
+	docs[1500] = types.MustMakeDocument("_id", int32(0))
+
+	actual := handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(docs...),
+		"$db", db,
+	))
+
+	assert.Equal(t, int32(1500), testutil.GetByPath(t, actual, "n"))
+	assert.Equal(t, int32(1500), testutil.GetByPath(t, actual, "writeErrors", "0", "index"))
+	assert.Equal(t, int32(11000), testutil.GetByPath(t, actual, "writeErrors", "0", "code"))
+
+	actual = handle(ctx, t, handler, types.MustMakeDocument(
+		"insert", collection,
+		"documents", types.MustNewArray(docs[1500:]...),
+		"ordered", false,
+		"$db", db,
+	))
+
+	assert.Equal(t, int32(999), testutil.GetByPath(t, actual, "n"))
+	assert.Equal(t, int32(0), testutil.GetByPath(t, actual, "writeErrors", "0", "index"))
+}
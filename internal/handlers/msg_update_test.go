@@ -15,9 +15,11 @@
 package handlers
 
 import (
+	"math"
 	"strconv"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/FerretDB/FerretDB/internal/types"
@@ -79,3 +81,109 @@ func TestUpdate(t *testing.T) {
 	_, _, closeConn := h.Handle(ctx, header, &msg)
 	require.False(t, closeConn)
 }
+
+//nolint:paralleltest // we use a stable table name
+func TestUpdateOperators(t *testing.T) {
+	ctx, handler, pool := setup(t, nil)
+	db := testutil.Schema(ctx, t, pool)
+
+	for name, tc := range map[string]struct {
+		document  types.Document // inserted document, the default one if nil
+		update    types.Document
+		expected  types.Document
+		nModified int32
+		code      int32
+	}{
+		"SetIncUnset": {
+			update: types.MustMakeDocument(
+				"$set", types.MustMakeDocument("s", "b", "new", int32(1)),
+				"$inc", types.MustMakeDocument("i", int32(1), "l", int32(1), "d", int32(1), "missing", int64(1)),
+				"$unset", types.MustMakeDocument("u", ""),
+			),
+			expected: types.MustMakeDocument(
+				"_id", int32(1),
+				"s", "b",
+				"i", int64(math.MaxInt32+1),
+				"l", int64(2),
+				"d", 2.5,
+				"new", int32(1),
+				"missing", int64(1),
+			),
+			nModified: 1,
+		},
+		"NotModified": {
+			update: types.MustMakeDocument(
+				"$set", types.MustMakeDocument("s", "a"),
+				"$inc", types.MustMakeDocument("i", int32(0)),
+			),
+			expected: types.MustMakeDocument(
+				"_id", int32(1), "s", "a", "i", int32(math.MaxInt32), "l", int64(1), "d", 1.5, "u", int32(1),
+			),
+		},
+		"DotNotation": {
+			update: types.MustMakeDocument("$set", types.MustMakeDocument("sub.s", "c")),
+			expected: types.MustMakeDocument(
+				"_id", int32(1), "s", "a", "i", int32(math.MaxInt32), "l", int64(1), "d", 1.5, "u", int32(1),
+				"sub", types.MustMakeDocument("s", "c"),
+			),
+			nModified: 1,
+		},
+		"IncDecimal": {
+			document: types.MustMakeDocument(
+				"_id", int32(1), "n", types.MustParseDecimal128("0.1"), "i", int32(1),
+			),
+			update: types.MustMakeDocument(
+				"$inc", types.MustMakeDocument("n", 1.1, "i", int32(1)),
+			),
+			expected: types.MustMakeDocument(
+				"_id", int32(1), "n", types.MustParseDecimal128("0.1").Add(types.NewDecimal128FromFloat64(1.1)), "i", int32(2),
+			),
+			nModified: 1,
+		},
+		"IncNonNumeric": {
+			update: types.MustMakeDocument("$inc", types.MustMakeDocument("s", int32(1))),
+			code:   14,
+		},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			collection := testutil.CreateTable(ctx, t, pool, db)
+
+			document := tc.document
+			if len(document.Keys()) == 0 {
+				document = types.MustMakeDocument(
+					"_id", int32(1), "s", "a", "i", int32(math.MaxInt32), "l", int64(1), "d", 1.5, "u", int32(1),
+				)
+			}
+
+			handle(ctx, t, handler, types.MustMakeDocument(
+				"insert", collection,
+				"documents", types.MustNewArray(document),
+				"$db", db,
+			))
+
+			actual := handle(ctx, t, handler, types.MustMakeDocument(
+				"update", collection,
+				"updates", types.MustNewArray(types.MustMakeDocument(
+					"q", types.MustMakeDocument("_id", int32(1)),
+					"u", tc.update,
+				)),
+				"$db", db,
+			))
+
+			if tc.code != 0 {
+				assert.Equal(t, tc.code, testutil.GetByPath(t, actual, "writeErrors", "0", "code"))
+				return
+			}
+
+			assert.Equal(t, int32(1), testutil.GetByPath(t, actual, "n"))
+			assert.Equal(t, tc.nModified, testutil.GetByPath(t, actual, "nModified"))
+
+			actual = handle(ctx, t, handler, types.MustMakeDocument(
+				"find", collection,
+				"$db", db,
+			))
+			assert.Equal(t, tc.expected, testutil.GetByPath(t, actual, "cursor", "firstBatch", "0"))
+		})
+	}
+}
@@ -53,6 +53,7 @@ func (h *Handler) Close() {
 // Handle "handles" the message by sending it to another wire protocol compatible service.
 //
 // Returned error is something fatal.
+// If no reply is expected (see wire.ExpectsReply), nil header and body are returned.
 func (h *Handler) Handle(ctx context.Context, header *wire.MsgHeader, body wire.MsgBody) (*wire.MsgHeader, wire.MsgBody, error) {
 	deadline, _ := ctx.Deadline()
 	h.conn.SetDeadline(deadline)
@@ -65,5 +66,9 @@ func (h *Handler) Handle(ctx context.Context, header *wire.MsgHeader, body wire.
 		return nil, nil, lazyerrors.Error(err)
 	}
 
+	if !wire.ExpectsReply(body) {
+		return nil, nil, nil
+	}
+
 	return wire.ReadMessage(h.bufr)
 }
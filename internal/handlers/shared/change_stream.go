@@ -0,0 +1,591 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+const (
+	// changeStreamMaxBatch is the maximal number of change log records read at once.
+	changeStreamMaxBatch = 1000
+)
+
+// resumeToken identifies a position in the change stream.
+type resumeToken struct {
+	pos        pg.ChangeLogPosition
+	time       time.Time // event creation time, or the reading time for tokens of positions between events
+	invalidate bool
+}
+
+// resumeTokenLen is the length of encoded resume token.
+const resumeTokenLen = 8 + 8 + 8 + 1
+
+// Document returns the resume token document.
+func (t resumeToken) Document() types.Document {
+	b := make([]byte, resumeTokenLen)
+	binary.BigEndian.PutUint64(b[0:], uint64(t.pos.XID))
+	binary.BigEndian.PutUint64(b[8:], uint64(t.pos.ID))
+	binary.BigEndian.PutUint64(b[16:], uint64(t.time.UnixMicro()))
+	if t.invalidate {
+		b[24] = 1
+	}
+
+	return types.MustMakeDocument("_data", strings.ToUpper(hex.EncodeToString(b)))
+}
+
+// getResumeToken decodes the resume token document returned by Document.
+func getResumeToken(v any) (resumeToken, error) {
+	var res resumeToken
+
+	doc, ok := v.(types.Document)
+	if !ok {
+		return res, common.NewErrorMessage(common.ErrTypeMismatch, "resume token must be a document, got %T", v)
+	}
+
+	data, _ := doc.Map()["_data"].(string)
+	b, err := hex.DecodeString(data)
+	if err != nil || len(b) != resumeTokenLen || b[24] > 1 {
+		return res, common.NewErrorMessage(common.ErrInvalidResumeToken, "Invalid resume token")
+	}
+
+	res.pos.XID = int64(binary.BigEndian.Uint64(b[0:]))
+	res.pos.ID = int64(binary.BigEndian.Uint64(b[8:]))
+	res.time = time.UnixMicro(int64(binary.BigEndian.Uint64(b[16:]))).UTC()
+	res.invalidate = b[24] == 1
+
+	return res, nil
+}
+
+// changeStreamStage is a pipeline stage applied to change events.
+//
+// It returns false if the event should be skipped.
+type changeStreamStage func(event types.Document) (types.Document, bool, error)
+
+// changeStream is a source of change events for $changeStream aggregation stage.
+//
+// Events are read from the change log that is populated by triggers of collections' tables.
+// Update events are always reported as "update"; replacement updates are not supported yet.
+type changeStream struct {
+	pgPool *pg.Pool
+
+	db         string // empty for streams on the whole deployment
+	collection string // empty for streams on databases or the whole deployment
+
+	fullDocument             string
+	fullDocumentBeforeChange string
+	stages                   []changeStreamStage
+
+	pos     pg.ChangeLogPosition
+	posTime time.Time
+}
+
+// newChangeStream creates a new change stream for the given $changeStream stage specification
+// and following pipeline stages.
+func newChangeStream(
+	ctx context.Context, pgPool *pg.Pool, db, collection string, spec types.Document, pipeline []types.Document,
+) (*changeStream, error) {
+	cs := &changeStream{
+		pgPool:                   pgPool,
+		db:                       db,
+		collection:               collection,
+		fullDocument:             "default",
+		fullDocumentBeforeChange: "off",
+	}
+
+	var allChangesForCluster bool
+	var resumeAfter, startAfter, startAtOperationTime any
+
+	m := spec.Map()
+	for _, k := range spec.Keys() {
+		v := m[k]
+
+		var err error
+		switch k {
+		case "fullDocument":
+			cs.fullDocument, err = getChangeStreamOption(k, v, "default", "updateLookup", "whenAvailable", "required")
+		case "fullDocumentBeforeChange":
+			cs.fullDocumentBeforeChange, err = getChangeStreamOption(k, v, "off", "whenAvailable", "required")
+		case "resumeAfter":
+			resumeAfter = v
+		case "startAfter":
+			startAfter = v
+		case "startAtOperationTime":
+			startAtOperationTime = v
+		case "allChangesForCluster":
+			allChangesForCluster, err = common.GetBoolParam(spec, k, false)
+		case "showExpandedEvents":
+			// there are no expanded events yet
+		default:
+			err = common.NewErrorMessage(common.ErrFailedToParse, "BSON field '$changeStream.%s' is an unknown field.", k)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case allChangesForCluster && (db != "admin" || collection != ""):
+		return nil, common.NewErrorMessage(
+			common.ErrInvalidNamespace,
+			"A $changeStream with 'allChangesForCluster:true' may only be opened on the 'admin' database, "+
+				"and with no collection name",
+		)
+	case allChangesForCluster:
+		cs.db = ""
+	case db == "admin":
+		return nil, common.NewErrorMessage(
+			common.ErrInvalidNamespace, "$changeStream may not be opened on the internal admin database",
+		)
+	}
+
+	for _, stage := range pipeline {
+		s, err := newChangeStreamStage(stage)
+		if err != nil {
+			return nil, err
+		}
+
+		cs.stages = append(cs.stages, s)
+	}
+
+	if err := cs.start(ctx, resumeAfter, startAfter, startAtOperationTime); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// getChangeStreamOption returns the value of string option, checking that it is one of allowed values.
+func getChangeStreamOption(key string, v any, allowed ...string) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", common.NewErrorMessage(
+			common.ErrTypeMismatch, "BSON field '$changeStream.%s' is the wrong type '%T', expected type 'string'", key, v,
+		)
+	}
+
+	for _, a := range allowed {
+		if s == a {
+			return s, nil
+		}
+	}
+
+	return "", common.NewErrorMessage(common.ErrBadValue, "Enumeration value '%s' for field '%s' is not a valid value.", s, key)
+}
+
+// newChangeStreamStage returns a change events pipeline stage for the given stage specification.
+func newChangeStreamStage(stage types.Document) (changeStreamStage, error) {
+	name := stage.Keys()[0]
+	value := stage.Map()[name]
+
+	switch name {
+	case "$match":
+		filter, ok := value.(types.Document)
+		if !ok {
+			return nil, common.NewErrorMessage(common.ErrBadValue, "the match filter must be an expression in an object")
+		}
+
+		return func(event types.Document) (types.Document, bool, error) {
+			ok, err := common.FilterDocument(event, filter)
+			return event, ok, err
+		}, nil
+
+	case "$project":
+		spec, ok := value.(types.Document)
+		if !ok {
+			return nil, common.NewErrorMessage(common.ErrBadValue, "$project specification must be an object")
+		}
+
+		projection, err := common.NewProjection(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(event types.Document) (types.Document, bool, error) {
+			res, err := projection.Project(event, types.MustMakeDocument())
+			return res, true, err
+		}, nil
+
+	case "$changeStream":
+		return nil, common.NewErrorMessage(common.ErrBadValue, "$changeStream is only valid as the first stage in a pipeline")
+
+	default:
+		return nil, common.NewErrorMessage(common.ErrNotImplemented, "%s stage is not implemented in $changeStream pipeline", name)
+	}
+}
+
+// start sets the initial position of the change stream.
+func (cs *changeStream) start(ctx context.Context, resumeAfter, startAfter, startAtOperationTime any) error {
+	var n int
+	for _, v := range []any{resumeAfter, startAfter, startAtOperationTime} {
+		if v != nil {
+			n++
+		}
+	}
+	if n > 1 {
+		return common.NewErrorMessage(common.ErrBadValue, "Only one type of resume option is allowed, but multiple were found.")
+	}
+
+	var err error
+	switch {
+	case resumeAfter != nil || startAfter != nil:
+		v := resumeAfter
+		if v == nil {
+			v = startAfter
+		}
+
+		var token resumeToken
+		if token, err = getResumeToken(v); err != nil {
+			return err
+		}
+
+		if token.invalidate && resumeAfter != nil {
+			return common.NewErrorMessage(
+				common.ErrInvalidResumeToken,
+				"Attempting to resume a change stream using 'resumeAfter' is not allowed from an invalidate notification.",
+			)
+		}
+
+		if err = cs.checkRetained(ctx, token.time); err != nil {
+			return err
+		}
+
+		cs.pos, cs.posTime = token.pos, token.time
+
+	case startAtOperationTime != nil:
+		ts, ok := startAtOperationTime.(types.Timestamp)
+		if !ok {
+			return common.NewErrorMessage(
+				common.ErrTypeMismatch,
+				"BSON field '$changeStream.startAtOperationTime' is the wrong type '%T', expected type 'timestamp'",
+				startAtOperationTime,
+			)
+		}
+
+		t := time.Unix(int64(ts>>32), 0).UTC()
+		if err = cs.checkRetained(ctx, t); err != nil {
+			return err
+		}
+
+		if cs.pos, err = cs.pgPool.ChangeLogStartAt(ctx, t); err != nil {
+			return lazyerrors.Error(err)
+		}
+		cs.posTime = time.Now().UTC()
+
+	default:
+		if cs.pos, err = cs.pgPool.ChangeLogStart(ctx); err != nil {
+			return lazyerrors.Error(err)
+		}
+		cs.posTime = time.Now().UTC()
+	}
+
+	return nil
+}
+
+// checkRetained returns an error if change log records created after the given time could be deleted.
+func (cs *changeStream) checkRetained(ctx context.Context, t time.Time) error {
+	retained, err := cs.pgPool.ChangeLogRetained(ctx, t)
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	if !retained {
+		return common.NewErrorMessage(
+			common.ErrChangeStreamHistoryLost,
+			"Resume of change stream was not possible, as the resume point may no longer be in the oplog.",
+		)
+	}
+
+	return nil
+}
+
+// Next implements common.Tail interface.
+func (cs *changeStream) Next(ctx context.Context, batchSize int64, maxAwait time.Duration) ([]types.Document, bool, error) {
+	limit := batchSize
+	if limit <= 0 || limit > changeStreamMaxBatch {
+		limit = changeStreamMaxBatch
+	}
+
+	return common.AwaitChangeLog(ctx, cs.pgPool, maxAwait, func() ([]types.Document, bool, error) {
+		return cs.read(ctx, limit)
+	})
+}
+
+// ResumeToken implements common.Tail interface.
+func (cs *changeStream) ResumeToken() (types.Document, bool) {
+	return resumeToken{pos: cs.pos, time: cs.posTime}.Document(), true
+}
+
+// read reads up to limit change log records and returns events passed through the pipeline.
+//
+// It returns true if the stream was invalidated.
+func (cs *changeStream) read(ctx context.Context, limit int64) ([]types.Document, bool, error) {
+	records, next, err := cs.pgPool.ChangeLog(ctx, cs.pos, cs.db, cs.collection, limit)
+	if err != nil {
+		return nil, false, lazyerrors.Error(err)
+	}
+
+	var res []types.Document
+	for i := range records {
+		r := &records[i]
+
+		event, err := cs.event(ctx, r)
+		if err != nil {
+			return nil, false, err
+		}
+
+		event, ok, err := cs.apply(event)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			res = append(res, event)
+		}
+
+		cs.pos, cs.posTime = r.ChangeLogPosition, r.CreatedAt
+
+		if cs.invalidatedBy(r) {
+			token := resumeToken{pos: r.ChangeLogPosition, time: r.CreatedAt, invalidate: true}
+			res = append(res, types.MustMakeDocument(
+				"_id", token.Document(),
+				"operationType", "invalidate",
+				"clusterTime", clusterTime(r.CreatedAt),
+				"wallTime", r.CreatedAt,
+			))
+
+			return res, true, nil
+		}
+	}
+
+	if next != cs.pos {
+		cs.pos, cs.posTime = next, time.Now().UTC()
+	}
+
+	return res, false, nil
+}
+
+// invalidatedBy returns true if the change stream is invalidated by the given record.
+func (cs *changeStream) invalidatedBy(r *pg.ChangeLogRecord) bool {
+	switch {
+	case cs.collection != "":
+		return (r.Op == "drop" || r.Op == "rename") && r.DB == cs.db && r.Collection == cs.collection
+	case cs.db != "":
+		return r.Op == "dropDatabase" && r.DB == cs.db
+	default:
+		return false
+	}
+}
+
+// apply applies pipeline stages to the event.
+//
+// It returns false if the event was filtered out.
+func (cs *changeStream) apply(event types.Document) (types.Document, bool, error) {
+	id := event.Map()["_id"]
+
+	for _, stage := range cs.stages {
+		var ok bool
+		var err error
+		if event, ok, err = stage(event); err != nil || !ok {
+			return types.Document{}, false, err
+		}
+	}
+
+	if !equalValues(id, event.Map()["_id"]) {
+		return types.Document{}, false, common.NewErrorMessage(
+			common.ErrChangeStreamFatalError,
+			"Encountered an event whose _id field, which contains the resume token, was modified by the pipeline. "+
+				"Modifying the _id field of an event makes it impossible to resume the stream from that point. "+
+				"Only transformations that retain the unmodified _id field are allowed.",
+		)
+	}
+
+	return event, true, nil
+}
+
+// event returns change event document for the given change log record.
+func (cs *changeStream) event(ctx context.Context, r *pg.ChangeLogRecord) (types.Document, error) {
+	token := resumeToken{pos: r.ChangeLogPosition, time: r.CreatedAt}
+	event := types.MustMakeDocument(
+		"_id", token.Document(),
+		"operationType", r.Op,
+		"clusterTime", clusterTime(r.CreatedAt),
+		"wallTime", r.CreatedAt,
+	)
+
+	ns := types.MustMakeDocument("db", r.DB)
+	if r.Collection != "" {
+		ns.Set("coll", r.Collection)
+	}
+
+	if r.Op != "insert" && r.Op != "update" && r.Op != "delete" {
+		event.Set("ns", ns)
+
+		if r.Op == "rename" {
+			to, err := unmarshalDocument(r.NewDocument)
+			if err != nil {
+				return types.Document{}, err
+			}
+			event.Set("to", *to)
+		}
+
+		return event, nil
+	}
+
+	oldDoc, err := unmarshalDocument(r.OldDocument)
+	if err != nil {
+		return types.Document{}, err
+	}
+
+	newDoc, err := unmarshalDocument(r.NewDocument)
+	if err != nil {
+		return types.Document{}, err
+	}
+
+	switch {
+	case r.Op == "insert":
+		event.Set("fullDocument", *newDoc)
+	case r.Op == "update" && cs.fullDocument == "updateLookup":
+		fullDocument, err := cs.lookup(ctx, r.DB, r.Collection, newDoc.Map()["_id"])
+		if err != nil {
+			return types.Document{}, err
+		}
+		event.Set("fullDocument", fullDocument)
+	case r.Op == "update" && cs.fullDocument != "default":
+		event.Set("fullDocument", *newDoc)
+	}
+
+	event.Set("ns", ns)
+
+	key := newDoc
+	if key == nil {
+		key = oldDoc
+	}
+	event.Set("documentKey", types.MustMakeDocument("_id", key.Map()["_id"]))
+
+	if r.Op == "update" {
+		event.Set("updateDescription", updateDescription(*oldDoc, *newDoc))
+	}
+
+	if r.Op != "insert" && cs.fullDocumentBeforeChange != "off" {
+		event.Set("fullDocumentBeforeChange", *oldDoc)
+	}
+
+	return event, nil
+}
+
+// lookup returns the current version of the document with the given _id, or nil if it does not exist.
+func (cs *changeStream) lookup(ctx context.Context, db, collection string, id any) (any, error) {
+	idb, err := fjson.Marshal(id)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	var b []byte
+	sql := "SELECT _jsonb FROM " + pg.TableIdentifier(db, collection).Sanitize() + " WHERE _jsonb->'_id' = $1"
+	err = cs.pgPool.QueryRow(ctx, sql, idb).Scan(&b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	doc, err := unmarshalDocument(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return *doc, nil
+}
+
+// updateDescription returns update event's description of changed top-level fields.
+func updateDescription(oldDoc, newDoc types.Document) types.Document {
+	oldM := oldDoc.Map()
+	newM := newDoc.Map()
+
+	updatedFields := types.MustMakeDocument()
+	for _, k := range newDoc.Keys() {
+		if v, ok := oldM[k]; !ok || !equalValues(v, newM[k]) {
+			updatedFields.Set(k, newM[k])
+		}
+	}
+
+	removedFields := new(types.Array)
+	for _, k := range oldDoc.Keys() {
+		if _, ok := newM[k]; !ok {
+			removedFields.Append(k)
+		}
+	}
+
+	return types.MustMakeDocument(
+		"updatedFields", updatedFields,
+		"removedFields", removedFields,
+		"truncatedArrays", new(types.Array),
+	)
+}
+
+// unmarshalDocument decodes change log document; nil data is decoded as nil document.
+func unmarshalDocument(b []byte) (*types.Document, error) {
+	if b == nil {
+		return nil, nil
+	}
+
+	v, err := fjson.Unmarshal(b)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	doc, ok := v.(types.Document)
+	if !ok {
+		return nil, lazyerrors.Errorf("expected document, got %T", v)
+	}
+
+	return &doc, nil
+}
+
+// equalValues returns true if given values are the same, including types.
+func equalValues(a, b any) bool {
+	ab, err := fjson.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bb, err := fjson.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ab, bb)
+}
+
+// clusterTime returns change event's clusterTime for the given creation time.
+func clusterTime(t time.Time) types.Timestamp {
+	return types.Timestamp(uint64(t.Unix())<<32 | 1)
+}
@@ -14,18 +14,39 @@
 
 package shared
 
-import "github.com/FerretDB/FerretDB/internal/pg"
+import (
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
+)
 
 // Handler data struct.
 type Handler struct {
-	pgPool   *pg.Pool
-	peerAddr string
+	pgPool       *pg.Pool
+	peerAddr     string
+	connectionID int32
+	cursors      *common.Cursors
+	sessions     *common.Sessions
+	counters     *common.Counters
 }
 
-// NewHandler returns a pointer to a new Handler, populated with the pgPool and peerAddr.
-func NewHandler(pgPool *pg.Pool, peerAddr string) *Handler {
+// NewOpts represents handler configuration.
+type NewOpts struct {
+	PgPool       *pg.Pool
+	PeerAddr     string
+	ConnectionID int32
+	Cursors      *common.Cursors
+	Sessions     *common.Sessions
+	Counters     *common.Counters
+}
+
+// NewHandler returns a pointer to a new Handler, populated with the given options.
+func NewHandler(opts *NewOpts) *Handler {
 	return &Handler{
-		pgPool:   pgPool,
-		peerAddr: peerAddr,
+		pgPool:       opts.PgPool,
+		peerAddr:     opts.PeerAddr,
+		connectionID: opts.ConnectionID,
+		cursors:      opts.Cursors,
+		sessions:     opts.Sessions,
+		counters:     opts.Counters,
 	}
 }
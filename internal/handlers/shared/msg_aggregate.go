@@ -0,0 +1,166 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// MsgAggregate runs an aggregation pipeline.
+//
+// Only pipelines on views and pipelines starting with $changeStream stage are supported for now.
+func (h *Handler) MsgAggregate(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	db := m["$db"].(string)
+
+	// collection name, or 1 for database-level aggregations
+	var collection string
+	if collection, _ = m["aggregate"].(string); collection == "" {
+		if n, err := common.GetWholeNumberParam(document, "aggregate", 0); err != nil || n != 1 {
+			return nil, common.NewErrorMessage(
+				common.ErrFailedToParse, "Invalid command format: the 'aggregate' field must specify a collection name or 1",
+			)
+		}
+	}
+
+	pipelineArray, ok := m["pipeline"].(*types.Array)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrTypeMismatch, "'pipeline' option must be specified as an array")
+	}
+
+	pipeline := make([]types.Document, pipelineArray.Len())
+	for i := range pipeline {
+		v, err := pipelineArray.Get(i)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		stage, ok := v.(types.Document)
+		if !ok {
+			return nil, common.NewErrorMessage(common.ErrTypeMismatch, "Each element of the 'pipeline' array must be an object")
+		}
+		if len(stage.Keys()) != 1 {
+			return nil, common.NewErrorMessage(
+				common.ErrFailedToParse, "A pipeline stage specification object must contain exactly one field.",
+			)
+		}
+
+		pipeline[i] = stage
+	}
+
+	cursor, ok := m["cursor"].(types.Document)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrFailedToParse, "The 'cursor' option is required, except for aggregate with the explain argument",
+		)
+	}
+
+	batchSize, err := common.GetWholeNumberParam(cursor, "batchSize", common.DefaultBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if batchSize < 0 {
+		return nil, common.NewErrorMessage(common.ErrBadValue, "Cursor batchSize must not be negative")
+	}
+
+	changeStream := len(pipeline) != 0 && pipeline[0].Keys()[0] == "$changeStream"
+
+	if collection != "" {
+		view, err := h.pgPool.GetView(ctx, db, collection)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		if view != nil {
+			if changeStream {
+				return nil, common.NewErrorMessage(
+					common.ErrCommandNotSupportedOnView, "$changeStream is not supported on views",
+				)
+			}
+
+			return h.aggregateView(ctx, view, pipelineArray, batchSize)
+		}
+	}
+
+	if !changeStream {
+		return nil, common.NewErrorMessage(common.ErrNotImplemented, "aggregate is implemented only for $changeStream pipelines")
+	}
+
+	spec, ok := pipeline[0].Map()["$changeStream"].(types.Document)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrFailedToParse, "the $changeStream stage must be specified as an object")
+	}
+
+	cs, err := newChangeStream(ctx, h.pgPool, db, collection, spec, pipeline[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	ns := db + ".$cmd.aggregate"
+	if collection != "" {
+		ns = db + "." + collection
+	}
+
+	// the first batch is returned without waiting for events, as in MongoDB
+	var done bool
+	firstBatch := new(types.Array)
+	if batchSize > 0 {
+		var events []types.Document
+		if events, done, err = cs.Next(ctx, batchSize, 0); err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			if err = firstBatch.Append(event); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+	}
+
+	var cursorID int64
+	if !done {
+		cursorID = h.cursors.AddTail(ns, cs)
+	}
+
+	token, _ := cs.ResumeToken()
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursor", types.MustMakeDocument(
+				"firstBatch", firstBatch,
+				"postBatchResumeToken", token,
+				"id", cursorID,
+				"ns", ns,
+			),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// MsgCollMod changes collection options.
+//
+// Only validator, validationLevel, validationAction options,
+// and expireAfterSeconds and hidden options of TTL indexes are supported for now.
+func (h *Handler) MsgCollMod(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	command := document.Keys()[0]
+	db := m["$db"].(string)
+
+	collection, ok := m[command].(string)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrBadValue, "collection name has invalid type %T", m[command])
+	}
+
+	for _, k := range document.Keys()[1:] {
+		switch k {
+		case "validator", "validationLevel", "validationAction", "index":
+		case "lsid", "comment", "writeConcern":
+		case "viewOn", "pipeline":
+			return nil, common.NewErrorMessage(common.ErrNotImplemented, "collMod: %s is not supported", k)
+		default:
+			if k[0] != '$' {
+				return nil, common.NewErrorMessage(common.ErrInvalidOptions, "unknown option to collMod: %s", k)
+			}
+		}
+	}
+
+	// options are also updated if none are given to check that the collection exists
+	err = h.pgPool.UpdateTableOptions(ctx, db, collection, func(opts *pg.TableOptions) error {
+		return setValidationOptions(opts, m["validator"], m["validationLevel"], m["validationAction"])
+	})
+	if err != nil {
+		if err == pg.ErrNotExist {
+			return nil, common.NewErrorMessage(common.ErrNamespaceNotFound, "ns does not exist")
+		}
+		return nil, lazyerrors.Error(err)
+	}
+
+	res := types.MustMakeDocument()
+	if spec, ok := m["index"]; ok {
+		if res, err = h.collModIndex(ctx, db, collection, spec); err != nil {
+			return nil, err
+		}
+	}
+	res.Set("ok", float64(1))
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{res},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// collModIndex changes options of the TTL index given by collMod's index option,
+// and returns old and new values of changed options.
+func (h *Handler) collModIndex(ctx context.Context, db, collection string, v any) (types.Document, error) {
+	spec, ok := v.(types.Document)
+	if !ok {
+		return types.Document{}, common.NewErrorMessage(common.ErrTypeMismatch, "'index' option must be a document")
+	}
+
+	m := spec.Map()
+	keyPattern, hasKeyPattern := m["keyPattern"].(types.Document)
+	name, hasName := m["name"].(string)
+	if hasKeyPattern == hasName {
+		return types.Document{}, common.NewErrorMessage(
+			common.ErrInvalidOptions, "must specify either index name or key pattern for collMod",
+		)
+	}
+
+	_, hasExpire := m["expireAfterSeconds"]
+	_, hasHidden := m["hidden"]
+	if !hasExpire && !hasHidden {
+		return types.Document{}, common.NewErrorMessage(
+			common.ErrInvalidOptions, "no expireAfterSeconds or hidden field",
+		)
+	}
+
+	if name == "_id_" || (hasKeyPattern && len(keyPattern.Keys()) == 1 && keyPattern.Keys()[0] == "_id") {
+		if hasHidden {
+			return types.Document{}, common.NewErrorMessage(common.ErrBadValue, "can't hide _id index")
+		}
+		return types.Document{}, common.NewErrorMessage(common.ErrInvalidOptions, "no expireAfterSeconds field to update")
+	}
+
+	indexes, err := h.pgPool.TTLIndexes(ctx)
+	if err != nil {
+		return types.Document{}, lazyerrors.Error(err)
+	}
+
+	// only TTL indexes are stored, other indexes are not found
+	var idx *pg.TTLIndex
+	for i := range indexes {
+		if indexes[i].DB != db || indexes[i].Collection != collection {
+			continue
+		}
+
+		if (hasName && indexes[i].Name == name) ||
+			(hasKeyPattern && len(keyPattern.Keys()) == 1 && keyPattern.Keys()[0] == indexes[i].Key) {
+			idx = &indexes[i]
+			break
+		}
+	}
+
+	if idx == nil {
+		desc := name
+		if hasKeyPattern {
+			desc = strings.Join(keyPattern.Keys(), ", ")
+		}
+		return types.Document{}, common.NewErrorMessage(
+			common.ErrIndexNotFound, "cannot find index %s for ns %s.%s", desc, db, collection,
+		)
+	}
+
+	res := types.MustMakeDocument()
+	updated := *idx
+
+	if hasExpire {
+		expireAfterSeconds, err := common.GetWholeNumberParam(spec, "expireAfterSeconds", 0)
+		if err != nil || expireAfterSeconds < 0 || expireAfterSeconds > math.MaxInt32 {
+			return types.Document{}, common.NewErrorMessage(
+				common.ErrBadValue, "TTL index 'expireAfterSeconds' option must be within an acceptable range",
+			)
+		}
+
+		updated.ExpireAfter = time.Duration(expireAfterSeconds) * time.Second
+		res.Set("expireAfterSeconds_old", int64(idx.ExpireAfter/time.Second))
+		res.Set("expireAfterSeconds_new", expireAfterSeconds)
+	}
+
+	if hasHidden {
+		if updated.Hidden, ok = m["hidden"].(bool); !ok {
+			return types.Document{}, common.NewErrorMessage(common.ErrTypeMismatch, "'hidden' option must be a boolean")
+		}
+
+		res.Set("hidden_old", idx.Hidden)
+		res.Set("hidden_new", updated.Hidden)
+	}
+
+	if err = h.pgPool.UpdateTTLIndex(ctx, &updated); err != nil {
+		return types.Document{}, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
@@ -41,18 +41,30 @@ func (h *Handler) MsgCollStats(ctx context.Context, msg *wire.OpMsg) (*wire.OpMs
 		return nil, lazyerrors.Error(err)
 	}
 
+	opts, err := h.pgPool.TableOptions(ctx, db, collection)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	res := types.MustMakeDocument(
+		"ns", db+"."+collection,
+		"count", stats.Rows,
+		"size", stats.SizeTotal,
+		"storageSize", stats.SizeTable,
+		"totalIndexSize", stats.SizeIndexes,
+		"totalSize", stats.SizeTotal,
+		"capped", opts.Capped,
+	)
+	if opts.Capped {
+		res.Set("max", opts.Max)
+		res.Set("maxSize", opts.Size)
+	}
+	res.Set("scaleFactor", int32(1))
+	res.Set("ok", float64(1))
+
 	var reply wire.OpMsg
 	err = reply.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"ns", db+"."+collection,
-			"count", stats.Rows,
-			"size", stats.SizeTotal,
-			"storageSize", stats.SizeTable,
-			"totalIndexSize", stats.SizeIndexes,
-			"totalSize", stats.SizeTotal,
-			"scaleFactor", int32(1),
-			"ok", float64(1),
-		)},
+		Documents: []types.Document{res},
 	})
 	if err != nil {
 		return nil, lazyerrors.Error(err)
@@ -17,6 +17,7 @@ package shared
 import (
 	"context"
 
+	"github.com/FerretDB/FerretDB/internal/fjson"
 	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
@@ -35,14 +36,38 @@ func (h *Handler) MsgCreate(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 	collection := m[document.Command()].(string)
 	db := m["$db"].(string)
 
+	view, err := getView(document)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := getTableOptions(document)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := h.pgPool.CreateSchema(ctx, db); err != nil && err != pg.ErrAlreadyExist {
+		if err == pg.ErrInvalidDatabaseName {
+			return nil, common.NamespaceError(err, db, collection)
+		}
 		return nil, lazyerrors.Error(err)
 	}
 
-	if err = h.pgPool.CreateTable(ctx, db, collection); err != nil {
-		if err == pg.ErrAlreadyExist {
-			return nil, common.NewErrorMessage(common.ErrNamespaceExists, "Collection already exists. NS: %s.%s", db, collection)
-		}
+	if view != nil {
+		view.DB = db
+		view.Name = collection
+		err = h.pgPool.CreateView(ctx, view)
+	} else {
+		err = h.pgPool.CreateTableWithOptions(ctx, db, collection, opts)
+	}
+
+	switch err {
+	case nil:
+	case pg.ErrAlreadyExist:
+		return nil, common.NewErrorMessage(common.ErrNamespaceExists, "Collection already exists. NS: %s.%s", db, collection)
+	case pg.ErrInvalidCollectionName:
+		return nil, common.NamespaceError(err, db, collection)
+	default:
 		return nil, lazyerrors.Error(err)
 	}
 
@@ -58,3 +83,140 @@ func (h *Handler) MsgCreate(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 
 	return &reply, nil
 }
+
+// getTableOptions returns collection options of the create command.
+//
+// Other options are ignored.
+func getTableOptions(document types.Document) (*pg.TableOptions, error) {
+	var opts pg.TableOptions
+	var err error
+
+	m := document.Map()
+	if err = setValidationOptions(&opts, m["validator"], m["validationLevel"], m["validationAction"]); err != nil {
+		return nil, err
+	}
+
+	if opts.Capped, err = common.GetBoolParam(document, "capped", false); err != nil {
+		return nil, err
+	}
+
+	if !opts.Capped {
+		return &opts, nil
+	}
+
+	if _, ok := document.Map()["size"]; !ok {
+		return nil, common.NewErrorMessage(common.ErrInvalidOptions, "the 'size' field is required when 'capped' is true")
+	}
+
+	if opts.Size, err = common.GetWholeNumberParam(document, "size", 0); err != nil {
+		return nil, err
+	}
+	if opts.Size <= 0 {
+		return nil, common.NewErrorMessage(common.ErrBadValue, "size has to be a positive number, got %d", opts.Size)
+	}
+
+	if opts.Max, err = common.GetWholeNumberParam(document, "max", 0); err != nil {
+		return nil, err
+	}
+	if opts.Max < 0 {
+		opts.Max = 0
+	}
+
+	return &opts, nil
+}
+
+// getView returns the view definition of the create command, or nil if viewOn is not given.
+func getView(document types.Document) (*pg.View, error) {
+	m := document.Map()
+
+	v, ok := m["viewOn"]
+	if !ok {
+		if _, ok = m["pipeline"]; ok {
+			return nil, common.NewErrorMessage(common.ErrInvalidOptions, "'pipeline' requires 'viewOn' to also be specified")
+		}
+		return nil, nil
+	}
+
+	viewOn, ok := v.(string)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrTypeMismatch, "'viewOn' must be of type string, got %T", v)
+	}
+	if viewOn == "" {
+		return nil, common.NewErrorMessage(common.ErrBadValue, "'viewOn' cannot be empty")
+	}
+
+	if capped, _ := m["capped"].(bool); capped {
+		return nil, common.NewErrorMessage(common.ErrInvalidOptions, "Cannot create a view with capped options")
+	}
+
+	pipeline := new(types.Array)
+	if v, ok := m["pipeline"]; ok {
+		if pipeline, ok = v.(*types.Array); !ok {
+			return nil, common.NewErrorMessage(common.ErrTypeMismatch, "'pipeline' must be of type array, got %T", v)
+		}
+	}
+
+	// validate stages now, so invalid views are not stored
+	if _, err := common.NewPipeline(pipeline); err != nil {
+		return nil, err
+	}
+
+	b, err := fjson.Marshal(pipeline)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &pg.View{ViewOn: viewOn, Pipeline: b}, nil
+}
+
+// setValidationOptions sets validator, validation level and action options given in create or collMod command;
+// nil values are not changed.
+func setValidationOptions(opts *pg.TableOptions, validator, level, action any) error {
+	if validator != nil {
+		doc, ok := validator.(types.Document)
+		if !ok {
+			return common.NewErrorMessage(common.ErrTypeMismatch, "'validator' must be of type object, got %T", validator)
+		}
+
+		b, err := fjson.Marshal(doc)
+		if err != nil {
+			return lazyerrors.Error(err)
+		}
+		opts.Validator = b
+	}
+
+	for _, o := range []struct {
+		name  string
+		value any
+		dst   *string
+	}{
+		{"validationLevel", level, &opts.ValidationLevel},
+		{"validationAction", action, &opts.ValidationAction},
+	} {
+		if o.value == nil {
+			continue
+		}
+
+		s, ok := o.value.(string)
+		if !ok {
+			return common.NewErrorMessage(common.ErrTypeMismatch, "'%s' must be of type string, got %T", o.name, o.value)
+		}
+		*o.dst = s
+	}
+
+	// check the resulting combination of options
+	validatorDoc := types.Document{}
+	if len(opts.Validator) != 0 {
+		v, err := fjson.Unmarshal(opts.Validator)
+		if err != nil {
+			return lazyerrors.Error(err)
+		}
+		validatorDoc = v.(types.Document)
+	}
+
+	if _, err := common.NewValidator(validatorDoc, opts.ValidationLevel, opts.ValidationAction); err != nil {
+		return err
+	}
+
+	return nil
+}
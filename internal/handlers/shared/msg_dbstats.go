@@ -40,13 +40,17 @@ func (h *Handler) MsgDBStats(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 		return nil, lazyerrors.Error(err)
 	}
 
+	views, err := h.pgPool.Views(ctx, db)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
 	var reply wire.OpMsg
 	err = reply.SetSections(wire.OpMsgSection{
 		Documents: []types.Document{types.MustMakeDocument(
 			"db", db,
 			"collections", stats.CountTables,
-			// TODO https://github.com/FerretDB/FerretDB/issues/176
-			"views", int32(0),
+			"views", int32(len(views)),
 			"objects", stats.CountRows,
 			"avgObjSize", float64(stats.SizeSchema)/float64(stats.CountRows),
 			"dataSize", float64(stats.SizeSchema)/scale,
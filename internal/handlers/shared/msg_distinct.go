@@ -0,0 +1,115 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+	"sort"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// MsgDistinct returns distinct values of the given field in a collection or view.
+//
+// Array values are flattened, as in MongoDB. Documents are filtered by FerretDB,
+// so all documents of the collection are read.
+func (h *Handler) MsgDistinct(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	db := m["$db"].(string)
+
+	collection, ok := m[document.Keys()[0]].(string)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrBadValue, "collection name has invalid type %T", m[document.Keys()[0]])
+	}
+
+	key, ok := m["key"].(string)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrTypeMismatch, "'key' must be of type string")
+	}
+
+	var query types.Document
+	if v, ok := m["query"]; ok && v != nil {
+		if query, ok = v.(types.Document); !ok {
+			return nil, common.NewErrorMessage(common.ErrTypeMismatch, "'query' must be of type object")
+		}
+	}
+
+	view, err := h.pgPool.GetView(ctx, db, collection)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	docs, err := h.pipelineDocuments(ctx, db, collection, view, findStages(query, types.Document{}, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	var values []any
+	add := func(v any) {
+		for _, seen := range values {
+			if types.CompareOrder(v, seen) == types.Equal {
+				return
+			}
+		}
+		values = append(values, v)
+	}
+
+	for _, doc := range docs {
+		for _, v := range common.GetPathValues(doc, key) {
+			arr, ok := v.(*types.Array)
+			if !ok {
+				add(v)
+				continue
+			}
+
+			for i := 0; i < arr.Len(); i++ {
+				el, _ := arr.Get(i)
+				add(el)
+			}
+		}
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return types.CompareOrder(values[i], values[j]) == types.Less
+	})
+
+	res := new(types.Array)
+	for _, v := range values {
+		if err = res.Append(v); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"values", res,
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
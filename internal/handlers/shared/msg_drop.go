@@ -35,7 +35,12 @@ func (h *Handler) MsgDrop(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, er
 	collection := m[document.Command()].(string)
 	db := m["$db"].(string)
 
-	if err = h.pgPool.DropTable(ctx, db, collection); err != nil {
+	err = h.pgPool.DropTable(ctx, db, collection)
+	if err == pg.ErrNotExist {
+		err = h.pgPool.DropView(ctx, db, collection)
+	}
+
+	if err != nil {
 		if err == pg.ErrNotExist {
 			return nil, common.NewErrorMessage(common.ErrNamespaceNotFound, "ns not found")
 		}
@@ -17,6 +17,7 @@ package shared
 import (
 	"context"
 
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
@@ -43,6 +44,8 @@ func (h *Handler) MsgDropDatabase(ctx context.Context, msg *wire.OpMsg) (*wire.O
 		res.Set("dropped", db)
 	case pg.ErrNotExist:
 		// nothing
+	case pg.ErrInvalidDatabaseName:
+		return nil, common.NamespaceError(err, db, "")
 	default:
 		return nil, lazyerrors.Error(err)
 	}
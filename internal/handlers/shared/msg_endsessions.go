@@ -12,37 +12,34 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package jsonb1
+package shared
 
 import (
-	"github.com/FerretDB/FerretDB/internal/pg"
+	"context"
+
 	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
-func projection(projection types.Document, p *pg.Placeholder) (sql string, args []any, err error) {
-	projectionMap := projection.Map()
-	if len(projectionMap) == 0 {
-		sql = "_jsonb"
-		return
+// MsgEndSessions ends given logical sessions and kills their cursors.
+func (h *Handler) MsgEndSessions(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	ids, err := getSessionIDs(msg)
+	if err != nil {
+		return nil, err
 	}
 
-	ks := ""
-	for i, k := range projection.Keys() {
-		if i != 0 {
-			ks += ", "
-		}
-		ks += p.Next()
-		args = append(args, k)
-	}
-	sql = "json_build_object('$k', array[" + ks + "],"
-	for i, k := range projection.Keys() {
-		if i != 0 {
-			sql += ", "
-		}
-		sql += p.Next() + "::text, _jsonb->" + p.Next()
-		args = append(args, k, k)
+	h.sessions.Kill(ids...)
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
 	}
-	sql += ")"
 
-	return
+	return &reply, nil
 }
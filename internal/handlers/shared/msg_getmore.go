@@ -0,0 +1,93 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// defaultMaxAwaitTimeMS is the default time to wait for new documents of tailable cursors, as in MongoDB.
+const defaultMaxAwaitTimeMS = 1000
+
+// MsgGetMore returns the next batch of documents from the cursor.
+func (h *Handler) MsgGetMore(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	db := m["$db"].(string)
+
+	id, ok := m["getMore"].(int64)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrTypeMismatch, "cursor id must be a long, got %T", m["getMore"])
+	}
+
+	collection, ok := m["collection"].(string)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrTypeMismatch, "collection must be a string, got %T", m["collection"])
+	}
+
+	batchSize, err := common.GetWholeNumberParam(document, "batchSize", 0)
+	if err != nil {
+		return nil, err
+	}
+	if batchSize < 0 {
+		return nil, common.NewErrorMessage(
+			common.ErrBadValue, "Batch size for getMore must be non-negative, but received: %d", batchSize,
+		)
+	}
+
+	// for tailable cursors, maxTimeMS is the time to wait for new documents
+	maxTimeMS, err := common.GetWholeNumberParam(document, "maxTimeMS", defaultMaxAwaitTimeMS)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := db + "." + collection
+	nextBatch, cursorID, err := h.cursors.NextBatch(ctx, id, ns, batchSize, time.Duration(maxTimeMS)*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := types.MustMakeDocument(
+		"nextBatch", nextBatch,
+	)
+	if token, ok := h.cursors.ResumeToken(cursorID); ok {
+		cursor.Set("postBatchResumeToken", token)
+	}
+	cursor.Set("id", cursorID)
+	cursor.Set("ns", ns)
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursor", cursor,
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
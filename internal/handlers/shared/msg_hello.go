@@ -19,31 +19,47 @@ import (
 	"time"
 
 	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
+// topologyProcessID identifies this process in topologyVersion of hello replies.
+//
+// Topology never changes during the process lifetime, so the counter is always zero.
+var topologyProcessID = types.NewObjectID()
+
+// TopologyVersion returns the current topologyVersion of hello replies.
+func TopologyVersion() types.Document {
+	return types.MustMakeDocument(
+		"processId", topologyProcessID,
+		"counter", int64(0),
+	)
+}
+
 // MsgHello returns a document that describes the role of the instance.
+//
+// Awaitable hello requests (with topologyVersion and maxAwaitTimeMS) that match the current topology
+// wait for maxAwaitTimeMS before replying, as there are no topology changes to report.
 func (h *Handler) MsgHello(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	if err = awaitTopologyChange(ctx, document); err != nil {
+		return nil, err
+	}
+
+	res, err := h.hello(document)
+	if err != nil {
+		return nil, err
+	}
+
 	var reply wire.OpMsg
-	err := reply.SetSections(wire.OpMsgSection{
-		// TODO merge with QueryCmd
-		Documents: []types.Document{types.MustMakeDocument(
-			"helloOk", true,
-			"ismaster", true,
-			// topologyVersion
-			"maxBsonObjectSize", int32(bson.MaxDocumentLen),
-			"maxMessageSizeBytes", int32(wire.MaxMsgLen),
-			"maxWriteBatchSize", int32(100000),
-			"localTime", time.Now(),
-			// logicalSessionTimeoutMinutes
-			// connectionId
-			"minWireVersion", int32(13),
-			"maxWireVersion", int32(13),
-			"readOnly", false,
-			"ok", float64(1),
-		)},
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{res},
 	})
 	if err != nil {
 		return nil, lazyerrors.Error(err)
@@ -51,3 +67,115 @@ func (h *Handler) MsgHello(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, e
 
 	return &reply, nil
 }
+
+// hello returns hello or isMaster reply document for the given request.
+func (h *Handler) hello(req types.Document) (types.Document, error) {
+	reqM := req.Map()
+
+	var pairs []any
+	if helloOk, _ := reqM["helloOk"].(bool); helloOk {
+		pairs = append(pairs, "helloOk", true)
+	}
+
+	primaryKey := "ismaster"
+	if req.Command() == "hello" {
+		primaryKey = "isWritablePrimary"
+	}
+
+	pairs = append(pairs,
+		primaryKey, true,
+		"topologyVersion", TopologyVersion(),
+		"maxBsonObjectSize", int32(bson.MaxDocumentLen),
+		"maxMessageSizeBytes", int32(wire.MaxMsgLen),
+		"maxWriteBatchSize", int32(common.MaxWriteBatchSize),
+		"localTime", time.Now(),
+		"logicalSessionTimeoutMinutes", int32(h.sessions.Timeout()/time.Minute),
+		"connectionId", h.connectionID,
+		"minWireVersion", int32(13),
+		"maxWireVersion", int32(13),
+		"readOnly", false,
+	)
+
+	if v, ok := reqM["compression"]; ok {
+		if _, ok = v.(*types.Array); !ok {
+			return types.Document{}, common.NewErrorMessage(common.ErrTypeMismatch, "compression must be an array, got %T", v)
+		}
+
+		// OP_COMPRESSED is not supported, so no compressors are negotiated
+		pairs = append(pairs, "compression", new(types.Array))
+	}
+
+	if v, ok := reqM["saslSupportedMechs"]; ok {
+		if _, ok = v.(string); !ok {
+			return types.Document{}, common.NewErrorMessage(
+				common.ErrTypeMismatch, "saslSupportedMechs must be a string, got %T", v,
+			)
+		}
+
+		// authentication is not supported, so there are no mechanisms for any user
+		pairs = append(pairs, "saslSupportedMechs", new(types.Array))
+	}
+
+	pairs = append(pairs, "ok", float64(1))
+
+	res, err := types.MakeDocument(pairs...)
+	if err != nil {
+		return types.Document{}, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// awaitTopologyChange waits for maxAwaitTimeMS if the request is an awaitable hello
+// with the current topologyVersion.
+func awaitTopologyChange(ctx context.Context, req types.Document) error {
+	reqM := req.Map()
+
+	tv, hasTV := reqM["topologyVersion"]
+	_, hasMaxAwait := reqM["maxAwaitTimeMS"]
+
+	switch {
+	case !hasTV && !hasMaxAwait:
+		return nil
+	case !hasMaxAwait:
+		return common.NewErrorMessage(
+			common.ErrFailedToParse, "A request with a 'topologyVersion' must include 'maxAwaitTimeMS'",
+		)
+	case !hasTV:
+		return common.NewErrorMessage(
+			common.ErrFailedToParse, "A request with 'maxAwaitTimeMS' must include a 'topologyVersion'",
+		)
+	}
+
+	maxAwaitTimeMS, err := common.GetWholeNumberParam(req, "maxAwaitTimeMS", 0)
+	if err != nil {
+		return err
+	}
+	if maxAwaitTimeMS < 0 {
+		return common.NewErrorMessage(common.ErrBadValue, "maxAwaitTimeMS must be a non-negative integer")
+	}
+
+	tvDoc, ok := tv.(types.Document)
+	if !ok {
+		return common.NewErrorMessage(common.ErrTypeMismatch, "topologyVersion must be a document, got %T", tv)
+	}
+
+	// the client has an outdated topology (for example, from the previous process), so reply immediately
+	tvM := tvDoc.Map()
+	if processID, _ := tvM["processId"].(types.ObjectID); processID != topologyProcessID {
+		return nil
+	}
+	if counter, _ := tvM["counter"].(int64); counter != 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(maxAwaitTimeMS) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return lazyerrors.Error(ctx.Err())
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// MsgKillCursors closes given cursors.
+func (h *Handler) MsgKillCursors(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	ids, ok := document.Map()["cursors"].(*types.Array)
+	if !ok {
+		return nil, common.NewErrorMessage(common.ErrTypeMismatch, "cursors must be an array")
+	}
+
+	var killed, notFound types.Array
+	for i := 0; i < ids.Len(); i++ {
+		v, err := ids.Get(i)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		id, ok := v.(int64)
+		if !ok {
+			return nil, common.NewErrorMessage(common.ErrTypeMismatch, "cursor id must be a long, got %T", v)
+		}
+
+		if h.cursors.Kill(id) {
+			err = killed.Append(id)
+		} else {
+			err = notFound.Append(id)
+		}
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursorsKilled", &killed,
+			"cursorsNotFound", &notFound,
+			"cursorsAlive", new(types.Array),
+			"cursorsUnknown", new(types.Array),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
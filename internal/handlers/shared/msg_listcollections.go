@@ -17,7 +17,9 @@ package shared
 import (
 	"context"
 
+	"github.com/FerretDB/FerretDB/internal/fjson"
 	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
@@ -25,6 +27,8 @@ import (
 
 // MsgListCollections retrieves information (i.e. the name and options)
 // about the collections and views in a database.
+//
+// Authentication is not supported, so authorizedCollections does not change the result.
 func (h *Handler) MsgListCollections(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
 	document, err := msg.Document()
 	if err != nil {
@@ -33,49 +37,106 @@ func (h *Handler) MsgListCollections(ctx context.Context, msg *wire.OpMsg) (*wir
 
 	m := document.Map()
 
-	filter, ok := m["filter"].(types.Document)
-	if ok && len(filter.Map()) != 0 {
-		return nil, common.NewErrorMessage(common.ErrNotImplemented, "MsgListCollections: filter is not supported")
+	db, ok := m["$db"].(string)
+	if !ok {
+		return nil, lazyerrors.New("no db")
 	}
 
-	cursor, ok := m["cursor"].(types.Document)
-	if ok && len(cursor.Map()) != 0 {
-		return nil, common.NewErrorMessage(common.ErrNotImplemented, "MsgListCollections: cursor is not supported")
+	var filter types.Document
+	if v, ok := m["filter"]; ok && v != nil {
+		if filter, ok = v.(types.Document); !ok {
+			return nil, common.NewErrorMessage(
+				common.ErrTypeMismatch, "BSON field 'listCollections.filter' is the wrong type '%T', expected type 'object'", v,
+			)
+		}
 	}
 
-	nameOnly, ok := m["nameOnly"].(bool)
-	if ok && !nameOnly {
-		return nil, common.NewErrorMessage(common.ErrNotImplemented, "MsgListCollections: nameOnly=false is not supported")
+	batchSize := int64(common.DefaultBatchSize)
+	if v, ok := m["cursor"]; ok {
+		cursor, ok := v.(types.Document)
+		if !ok {
+			return nil, common.NewErrorMessage(
+				common.ErrTypeMismatch, "BSON field 'listCollections.cursor' is the wrong type '%T', expected type 'object'", v,
+			)
+		}
+
+		if batchSize, err = common.GetWholeNumberParam(cursor, "batchSize", common.DefaultBatchSize); err != nil {
+			return nil, err
+		}
+		if batchSize < 0 {
+			return nil, common.NewErrorMessage(common.ErrBadValue, "Cursor batchSize must not be negative")
+		}
 	}
 
-	db, ok := m["$db"].(string)
-	if !ok {
-		return nil, lazyerrors.New("no db")
+	nameOnly, err := common.GetBoolParam(document, "nameOnly", false)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = common.GetBoolParam(document, "authorizedCollections", false); err != nil {
+		return nil, err
 	}
 
-	names, err := h.pgPool.Tables(ctx, db)
+	collections, err := h.pgPool.Collections(ctx, db)
 	if err != nil {
 		return nil, lazyerrors.Error(err)
 	}
 
-	collections := types.MakeArray(len(names))
-	for _, n := range names {
-		d := types.MustMakeDocument(
-			"name", n,
-			"type", "collection",
-		)
-		if err = collections.Append(d); err != nil {
-			return nil, lazyerrors.Error(err)
+	views, err := h.pgPool.Views(ctx, db)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	docs := make([]types.Document, 0, len(collections)+len(views))
+	for i := range collections {
+		d, err := collectionInfo(&collections[i])
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+
+	for i := range views {
+		d, err := viewInfo(&views[i])
+		if err != nil {
+			return nil, err
 		}
+		docs = append(docs, d)
+	}
+
+	res := make([]types.Document, 0, len(docs))
+	for _, d := range docs {
+		matches, err := common.FilterDocument(d, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+
+		if nameOnly {
+			d = types.MustMakeDocument(
+				"name", d.Map()["name"],
+				"type", d.Map()["type"],
+			)
+		}
+
+		res = append(res, d)
+	}
+
+	ns := db + ".$cmd.listCollections"
+	firstBatch, cursorID, err := h.cursors.FirstBatch(ns, res, batchSize, false)
+	if err != nil {
+		return nil, err
 	}
 
 	var reply wire.OpMsg
 	err = reply.SetSections(wire.OpMsgSection{
 		Documents: []types.Document{types.MustMakeDocument(
 			"cursor", types.MustMakeDocument(
-				"id", int64(0),
-				"ns", db+".$cmd.listCollections",
-				"firstBatch", collections,
+				"id", cursorID,
+				"ns", ns,
+				"firstBatch", firstBatch,
 			),
 			"ok", float64(1),
 		)},
@@ -86,3 +147,88 @@ func (h *Handler) MsgListCollections(ctx context.Context, msg *wire.OpMsg) (*wir
 
 	return &reply, nil
 }
+
+// collectionInfo returns the full listCollections entry of the collection.
+func collectionInfo(c *pg.Collection) (types.Document, error) {
+	options := types.MustMakeDocument()
+
+	if c.Options.Capped {
+		if err := options.Set("capped", true); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+		if err := options.Set("size", c.Options.Size); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+		if c.Options.Max > 0 {
+			if err := options.Set("max", c.Options.Max); err != nil {
+				return types.Document{}, lazyerrors.Error(err)
+			}
+		}
+	}
+
+	if len(c.Options.Validator) != 0 {
+		validator, err := fjson.Unmarshal(c.Options.Validator)
+		if err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+
+		level, action := c.Options.ValidationLevel, c.Options.ValidationAction
+		if level == "" {
+			level = "strict"
+		}
+		if action == "" {
+			action = "error"
+		}
+
+		if err = options.Set("validator", validator); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+		if err = options.Set("validationLevel", level); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+		if err = options.Set("validationAction", action); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+	}
+
+	info := types.MustMakeDocument(
+		"readOnly", false,
+	)
+	if c.UUID != nil {
+		if err := info.Set("uuid", types.Binary{Subtype: types.BinaryUUID, B: c.UUID}); err != nil {
+			return types.Document{}, lazyerrors.Error(err)
+		}
+	}
+
+	return types.MustMakeDocument(
+		"name", c.Name,
+		"type", "collection",
+		"options", options,
+		"info", info,
+		"idIndex", types.MustMakeDocument(
+			"v", int32(2),
+			"key", types.MustMakeDocument("_id", int32(1)),
+			"name", "_id_",
+		),
+	), nil
+}
+
+// viewInfo returns the full listCollections entry of the view.
+func viewInfo(v *pg.View) (types.Document, error) {
+	pipeline, err := fjson.Unmarshal(v.Pipeline)
+	if err != nil {
+		return types.Document{}, lazyerrors.Error(err)
+	}
+
+	return types.MustMakeDocument(
+		"name", v.Name,
+		"type", "view",
+		"options", types.MustMakeDocument(
+			"viewOn", v.ViewOn,
+			"pipeline", pipeline,
+		),
+		"info", types.MustMakeDocument(
+			"readOnly", true,
+		),
+	), nil
+}
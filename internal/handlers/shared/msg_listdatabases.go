@@ -17,62 +17,97 @@ package shared
 import (
 	"context"
 
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
 // MsgListDatabases command provides a list of all existing databases along with basic statistics about them.
+//
+// Authentication is not supported, so authorizedDatabases does not change the result.
 func (h *Handler) MsgListDatabases(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
-	databaseNames, err := h.pgPool.Schemas(ctx)
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	var filter types.Document
+	if v, ok := document.Map()["filter"]; ok && v != nil {
+		if filter, ok = v.(types.Document); !ok {
+			return nil, common.NewErrorMessage(
+				common.ErrTypeMismatch, "BSON field 'listDatabases.filter' is the wrong type '%T', expected type 'object'", v,
+			)
+		}
+	}
+
+	nameOnly, err := common.GetBoolParam(document, "nameOnly", false)
 	if err != nil {
 		return nil, err
 	}
 
+	if _, err = common.GetBoolParam(document, "authorizedDatabases", false); err != nil {
+		return nil, err
+	}
+
+	databaseNames, err := h.pgPool.Schemas(ctx)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	var totalSize int64
 	databases := types.MakeArray(len(databaseNames))
 	for _, databaseName := range databaseNames {
-		tables, err := h.pgPool.Tables(ctx, databaseName)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
-		}
-
-		// iterate over result to collect sizes
+		var d types.Document
 		var sizeOnDisk int64
-		for _, name := range tables {
-			var tableSize int64
-			fullName := databaseName + "." + name
-			err = h.pgPool.QueryRow(ctx, "SELECT pg_total_relation_size($1)", fullName).Scan(&tableSize)
-			if err != nil {
+		if nameOnly {
+			d = types.MustMakeDocument(
+				"name", databaseName,
+			)
+		} else {
+			if sizeOnDisk, err = h.pgPool.DatabaseSize(ctx, databaseName); err != nil {
 				return nil, lazyerrors.Error(err)
 			}
 
-			sizeOnDisk += tableSize
+			d = types.MustMakeDocument(
+				"name", databaseName,
+				"sizeOnDisk", sizeOnDisk,
+				"empty", sizeOnDisk == 0,
+			)
 		}
 
-		d := types.MustMakeDocument(
-			"name", databaseName,
-			"sizeOnDisk", sizeOnDisk,
-			"empty", sizeOnDisk == 0,
-		)
+		matches, err := common.FilterDocument(d, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+
+		totalSize += sizeOnDisk
 		if err = databases.Append(d); err != nil {
 			return nil, lazyerrors.Error(err)
 		}
 	}
 
-	var totalSize int64
-	err = h.pgPool.QueryRow(ctx, "SELECT pg_database_size(current_database())").Scan(&totalSize)
-	if err != nil {
-		return nil, err
+	res := types.MustMakeDocument(
+		"databases", databases,
+	)
+	if !nameOnly {
+		if err = res.Set("totalSize", totalSize); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+		if err = res.Set("totalSizeMb", totalSize/1024/1024); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+	if err = res.Set("ok", float64(1)); err != nil {
+		return nil, lazyerrors.Error(err)
 	}
 
 	var reply wire.OpMsg
 	err = reply.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"databases", databases,
-			"totalSize", totalSize,
-			"totalSizeMb", totalSize/1024/1024,
-			"ok", float64(1),
-		)},
+		Documents: []types.Document{res},
 	})
 	if err != nil {
 		return nil, lazyerrors.Error(err)
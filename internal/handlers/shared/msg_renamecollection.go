@@ -0,0 +1,114 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// MsgRenameCollection renames a collection, possibly moving it to another database.
+func (h *Handler) MsgRenameCollection(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	m := document.Map()
+	command := document.Keys()[0]
+
+	if m["$db"] != "admin" {
+		return nil, common.NewErrorMessage(
+			common.ErrUnauthorized, "renameCollection may only be run against the admin database.",
+		)
+	}
+
+	from, ok := m[command].(string)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrTypeMismatch, "collection name has invalid type %T", m[command],
+		)
+	}
+
+	toV, ok := m["to"]
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrMissingField, "BSON field 'renameCollection.to' is missing but a required field",
+		)
+	}
+	to, ok := toV.(string)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrTypeMismatch, "BSON field 'renameCollection.to' is the wrong type %T, expected type 'string'", toV,
+		)
+	}
+
+	dropTarget, err := common.GetBoolParam(document, "dropTarget", false)
+	if err != nil {
+		return nil, err
+	}
+
+	db, collection, err := common.SplitNamespace(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toDB, toCollection, err := common.SplitNamespace(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == to {
+		return nil, common.NewErrorMessage(common.ErrIllegalOperation, "Can't rename a collection to itself")
+	}
+
+	err = h.pgPool.RenameTable(ctx, db, collection, toDB, toCollection, dropTarget)
+	switch err {
+	case nil:
+	case pg.ErrNotExist:
+		view, err := h.pgPool.GetView(ctx, db, collection)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+		if view != nil {
+			return nil, common.NewErrorMessage(common.ErrCommandNotSupportedOnView, "cannot rename view: %s", from)
+		}
+
+		return nil, common.NewErrorMessage(common.ErrNamespaceNotFound, "Source collection %s does not exist", from)
+	case pg.ErrAlreadyExist:
+		return nil, common.NewErrorMessage(common.ErrNamespaceExists, "target namespace exists")
+	case pg.ErrInvalidDatabaseName, pg.ErrInvalidCollectionName:
+		return nil, common.NamespaceError(err, toDB, toCollection)
+	default:
+		return nil, lazyerrors.Error(err)
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
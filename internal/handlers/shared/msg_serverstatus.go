@@ -16,18 +16,83 @@ package shared
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
+// maxIncomingConnections is the number of connections reported as available by serverStatus
+// in addition to current connections; FerretDB does not limit them, so it is MongoDB's default limit.
+const maxIncomingConnections = 1_000_000
+
 // MsgServerStatus OpMsg used to get a server status.
+//
+// Counters are shared with Prometheus metrics.
 func (h *Handler) MsgServerStatus(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	s := h.counters.Snapshot()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	opCounters := s.OpCounters()
+
 	var reply wire.OpMsg
-	err := reply.SetSections(wire.OpMsgSection{
+	err = reply.SetSections(wire.OpMsgSection{
 		Documents: []types.Document{types.MustMakeDocument(
+			"host", hostname,
 			"version", versionValue,
+			"process", filepath.Base(os.Args[0]),
+			"pid", int64(os.Getpid()),
+			"uptime", s.Uptime.Seconds(),
+			"uptimeMillis", s.Uptime.Milliseconds(),
+			"uptimeEstimate", int64(s.Uptime/time.Second),
+			"localTime", time.Now(),
+			"connections", types.MustMakeDocument(
+				"current", int32(s.ConnectionsCurrent),
+				"available", int32(maxIncomingConnections-s.ConnectionsCurrent),
+				"totalCreated", int32(s.ConnectionsTotal),
+			),
+			"network", types.MustMakeDocument(
+				"bytesIn", s.BytesIn,
+				"bytesOut", s.BytesOut,
+				"numRequests", s.NumRequests,
+			),
+			"opcounters", types.MustMakeDocument(
+				"insert", opCounters["insert"],
+				"query", opCounters["query"],
+				"update", opCounters["update"],
+				"delete", opCounters["delete"],
+				"getmore", opCounters["getmore"],
+				"command", opCounters["command"],
+			),
+			"mem", types.MustMakeDocument(
+				"bits", int32(strconv.IntSize),
+				"resident", int32((mem.Sys-mem.HeapReleased)>>20),
+				"virtual", int32(mem.Sys>>20),
+				"supported", true,
+			),
+			"metrics", types.MustMakeDocument(
+				"commands", commandsMetrics(s),
+				"document", types.MustMakeDocument(
+					"deleted", s.Documents["deleted"],
+					"inserted", s.Documents["inserted"],
+					"returned", s.Documents["returned"],
+					"updated", s.Documents["updated"],
+				),
+			),
 			"ok", float64(1),
 		)},
 	})
@@ -37,3 +102,24 @@ func (h *Handler) MsgServerStatus(ctx context.Context, msg *wire.OpMsg) (*wire.O
 
 	return &reply, nil
 }
+
+// commandsMetrics returns total and failed numbers of commands sorted by name.
+func commandsMetrics(s *common.CountersSnapshot) types.Document {
+	commands := s.Commands()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]any, 0, len(names)*2)
+	for _, name := range names {
+		pairs = append(pairs, name, types.MustMakeDocument(
+			"failed", s.Failed[name],
+			"total", commands[name],
+		))
+	}
+
+	return types.MustMakeDocument(pairs...)
+}
@@ -0,0 +1,406 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+const (
+	// oplogDB and oplogCollection name the emulated read-only oplog collection.
+	oplogDB         = "local"
+	oplogCollection = "oplog.rs"
+
+	// oplogMaxBatch is the maximal number of change log records read at once.
+	oplogMaxBatch = 1000
+)
+
+// IsOplog returns true if the given command targets emulated local.oplog.rs collection.
+func IsOplog(document types.Document) bool {
+	m := document.Map()
+	collection, _ := m[document.Keys()[0]].(string)
+	return m["$db"] == oplogDB && collection == oplogCollection
+}
+
+// MsgOplog handles commands against emulated local.oplog.rs collection built from the change log.
+//
+// Only find is supported; writes are rejected.
+func (h *Handler) MsgOplog(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	switch cmd := document.Command(); cmd {
+	case "find":
+		return h.findOplog(ctx, document)
+	case "count":
+		return nil, common.NewErrorMessage(common.ErrNotImplemented, "count is not implemented for %s.%s", oplogDB, oplogCollection)
+	default:
+		return nil, common.NewErrorMessage(
+			common.ErrIllegalOperation, "%s.%s is read-only, %s is not allowed", oplogDB, oplogCollection, cmd,
+		)
+	}
+}
+
+// findOplog handles find command against local.oplog.rs, including tailable and awaitData cursors.
+func (h *Handler) findOplog(ctx context.Context, document types.Document) (*wire.OpMsg, error) {
+	m := document.Map()
+
+	opts, err := common.GetFindOptions(document)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, _ := m["filter"].(types.Document)
+
+	projectionIn, _ := m["projection"].(types.Document)
+	projection, err := common.NewProjection(projectionIn)
+	if err != nil {
+		return nil, err
+	}
+
+	sort, _ := m["sort"].(types.Document)
+	reverse, err := oplogReverse(sort)
+	if err != nil {
+		return nil, err
+	}
+	if reverse && opts.Tailable {
+		return nil, common.NewErrorMessage(common.ErrBadValue, "tailable cursor requested with reverse $natural sort")
+	}
+
+	tail := &oplogTail{
+		pgPool:     h.pgPool,
+		filter:     filter,
+		projection: projection,
+		skip:       opts.Skip,
+		limit:      opts.Limit,
+		reverse:    reverse,
+		tailable:   opts.Tailable,
+		awaitData:  opts.AwaitData,
+	}
+	tail.after, tail.before = oplogBounds(filter)
+
+	ns := oplogDB + "." + oplogCollection
+
+	// The first batch is returned without waiting for entries, as in MongoDB.
+	// Other batches are read by getMore, so the oplog is never read into memory as a whole.
+	var done bool
+	firstBatch := new(types.Array)
+	if opts.BatchSize > 0 {
+		var docs []types.Document
+		if docs, done, err = tail.read(ctx, opts.BatchSize); err != nil {
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			if err = firstBatch.Append(doc); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+	}
+
+	var cursorID int64
+	if !done && !opts.SingleBatch {
+		cursorID = h.cursors.AddTail(ns, tail)
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursor", types.MustMakeDocument(
+				"firstBatch", firstBatch,
+				"id", cursorID,
+				"ns", ns,
+			),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// oplogReverse returns true if oplog entries should be returned in reverse order.
+//
+// Only natural order is supported.
+func oplogReverse(sort types.Document) (bool, error) {
+	keys := sort.Keys()
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	if len(keys) != 1 || keys[0] != "$natural" {
+		return false, common.NewErrorMessage(
+			common.ErrNotImplemented, "%s.%s can be sorted only by $natural", oplogDB, oplogCollection,
+		)
+	}
+
+	natural, err := common.GetWholeNumberParam(sort, "$natural", 1)
+	if err != nil {
+		return false, err
+	}
+
+	switch natural {
+	case 1:
+		return false, nil
+	case -1:
+		return true, nil
+	default:
+		return false, common.NewErrorMessage(common.ErrBadValue, "$natural sort cannot be set to a value other than -1 or 1")
+	}
+}
+
+// oplogBounds returns the exclusive range (after, before) of oplog timestamps
+// for the top-level ts condition of the filter, so it can use the change log index;
+// zero before means no upper bound.
+//
+// The whole filter is still applied to entries, so unsupported conditions are ignored there.
+func oplogBounds(filter types.Document) (after, before int64) {
+	// timestamps with the highest bit set are never assigned, so ignore them too
+	bound := func(v any) (int64, bool) {
+		ts, ok := v.(types.Timestamp)
+		if !ok || ts > math.MaxInt64-1 {
+			return 0, false
+		}
+		return int64(ts), true
+	}
+
+	setAfter := func(ts int64) {
+		if ts > after {
+			after = ts
+		}
+	}
+	setBefore := func(ts int64) {
+		if before == 0 || ts < before {
+			before = ts
+		}
+	}
+
+	if ts, ok := bound(filter.Map()["ts"]); ok {
+		setAfter(ts - 1)
+		setBefore(ts + 1)
+		return
+	}
+
+	cond, _ := filter.Map()["ts"].(types.Document)
+	for op, v := range cond.Map() {
+		ts, ok := bound(v)
+		if !ok {
+			continue
+		}
+
+		switch op {
+		case "$eq":
+			setAfter(ts - 1)
+			setBefore(ts + 1)
+		case "$gt":
+			setAfter(ts)
+		case "$gte":
+			setAfter(ts - 1)
+		case "$lt":
+			setBefore(ts)
+		case "$lte":
+			setBefore(ts + 1)
+		}
+	}
+
+	return
+}
+
+// oplogTail reads oplog entries from the change log; it implements common.Tail interface.
+type oplogTail struct {
+	pgPool     *pg.Pool
+	filter     types.Document
+	projection *common.Projection
+	after      int64 // advanced as entries are read in natural order
+	before     int64 // advanced as entries are read in reverse order; zero means no upper bound
+	skip       int64 // remaining number of matching entries to skip
+	limit      int64 // remaining number of entries to return; zero means no limit
+	reverse    bool
+	tailable   bool // if false, the cursor is done at the end of the oplog
+	awaitData  bool
+}
+
+// Next implements common.Tail interface.
+func (t *oplogTail) Next(ctx context.Context, batchSize int64, maxAwait time.Duration) ([]types.Document, bool, error) {
+	if !t.awaitData {
+		maxAwait = 0
+	}
+
+	return common.AwaitChangeLog(ctx, t.pgPool, maxAwait, func() ([]types.Document, bool, error) {
+		return t.read(ctx, batchSize)
+	})
+}
+
+// ResumeToken implements common.Tail interface.
+func (t *oplogTail) ResumeToken() (types.Document, bool) {
+	return types.Document{}, false
+}
+
+// read returns up to n (zero means oplogMaxBatch) matching entries available now,
+// and done = true if the limit or the end of the oplog for non-tailable cursor is reached.
+func (t *oplogTail) read(ctx context.Context, n int64) ([]types.Document, bool, error) {
+	if n <= 0 {
+		n = oplogMaxBatch
+	}
+
+	var docs []types.Document
+	for {
+		records, err := t.pgPool.OplogRecords(ctx, t.after, t.before, oplogMaxBatch, t.reverse)
+		if err != nil {
+			return nil, false, lazyerrors.Error(err)
+		}
+
+		for i := range records {
+			r := &records[i]
+			if t.reverse {
+				t.before = r.TS
+			} else {
+				t.after = r.TS
+			}
+
+			entry, err := oplogEntry(r)
+			if err != nil {
+				return nil, false, err
+			}
+
+			matches, err := common.FilterDocument(entry, t.filter)
+			if err != nil {
+				return nil, false, err
+			}
+			if !matches {
+				continue
+			}
+
+			if t.skip > 0 {
+				t.skip--
+				continue
+			}
+
+			projected, err := t.projection.Project(entry, t.filter)
+			if err != nil {
+				return nil, false, err
+			}
+			docs = append(docs, projected)
+
+			if t.limit > 0 {
+				if t.limit--; t.limit == 0 {
+					return docs, true, nil
+				}
+			}
+
+			if int64(len(docs)) == n {
+				return docs, false, nil
+			}
+		}
+
+		if len(records) < oplogMaxBatch {
+			return docs, !t.tailable, nil
+		}
+	}
+}
+
+// oplogEntry returns the oplog entry for the given change log record.
+func oplogEntry(r *pg.ChangeLogRecord) (types.Document, error) {
+	ns := r.DB + "." + r.Collection
+
+	var op string
+	var o, o2 any
+	switch r.Op {
+	case "insert", "update", "delete":
+		oldDoc, err := unmarshalDocument(r.OldDocument)
+		if err != nil {
+			return types.Document{}, err
+		}
+
+		newDoc, err := unmarshalDocument(r.NewDocument)
+		if err != nil {
+			return types.Document{}, err
+		}
+
+		switch r.Op {
+		case "insert":
+			op, o = "i", *newDoc
+		case "update":
+			op, o, o2 = "u", oplogUpdate(*oldDoc, *newDoc), types.MustMakeDocument("_id", newDoc.Map()["_id"])
+		case "delete":
+			op, o = "d", types.MustMakeDocument("_id", oldDoc.Map()["_id"])
+		}
+
+	case "rename":
+		to, err := unmarshalDocument(r.NewDocument)
+		if err != nil {
+			return types.Document{}, err
+		}
+
+		toM := to.Map()
+		o = types.MustMakeDocument("renameCollection", ns, "to", toM["db"].(string)+"."+toM["coll"].(string))
+		op, ns = "c", r.DB+".$cmd"
+	case "drop":
+		op, ns, o = "c", r.DB+".$cmd", types.MustMakeDocument("drop", r.Collection)
+	case "dropDatabase":
+		op, ns, o = "c", r.DB+".$cmd", types.MustMakeDocument("dropDatabase", int32(1))
+	default:
+		return types.Document{}, lazyerrors.Errorf("unexpected change log operation %q", r.Op)
+	}
+
+	entry := types.MustMakeDocument(
+		"op", op,
+		"ns", ns,
+		"o", o,
+	)
+	if o2 != nil {
+		entry.Set("o2", o2)
+	}
+	entry.Set("ts", types.Timestamp(r.TS))
+	entry.Set("t", int64(1))
+	entry.Set("v", int64(2))
+	entry.Set("wall", r.CreatedAt)
+
+	return entry, nil
+}
+
+// oplogUpdate returns update entry's o field with $set and $unset of changed top-level fields.
+func oplogUpdate(oldDoc, newDoc types.Document) types.Document {
+	desc := updateDescription(oldDoc, newDoc).Map()
+
+	o := types.MustMakeDocument("$v", int32(1))
+
+	if set := desc["updatedFields"].(types.Document); len(set.Keys()) > 0 {
+		o.Set("$set", set)
+	}
+
+	if removed := desc["removedFields"].(*types.Array); removed.Len() > 0 {
+		unset := types.MustMakeDocument()
+		for i := 0; i < removed.Len(); i++ {
+			k, _ := removed.Get(i)
+			unset.Set(k.(string), true)
+		}
+		o.Set("$unset", unset)
+	}
+
+	return o
+}
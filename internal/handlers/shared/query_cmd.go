@@ -16,9 +16,7 @@ package shared
 
 import (
 	"context"
-	"time"
 
-	"github.com/FerretDB/FerretDB/internal/bson"
 	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/wire"
@@ -27,26 +25,14 @@ import (
 func (h *Handler) QueryCmd(ctx context.Context, query *wire.OpQuery) (*wire.OpReply, error) {
 	switch cmd := query.Query.Command(); cmd {
 	case "ismaster":
-		// TODO merge with MsgHello
+		res, err := h.hello(query.Query)
+		if err != nil {
+			return nil, err
+		}
+
 		reply := &wire.OpReply{
 			NumberReturned: 1,
-			Documents: []types.Document{
-				types.MustMakeDocument(
-					"helloOk", true,
-					"ismaster", true,
-					// topologyVersion
-					"maxBsonObjectSize", int32(bson.MaxDocumentLen),
-					"maxMessageSizeBytes", int32(wire.MaxMsgLen),
-					"maxWriteBatchSize", int32(100000),
-					"localTime", time.Now(),
-					// logicalSessionTimeoutMinutes
-					// connectionId
-					"minWireVersion", int32(13),
-					"maxWireVersion", int32(13),
-					"readOnly", false,
-					"ok", float64(1),
-				),
-			},
+			Documents:      []types.Document{res},
 		}
 		return reply, nil
 
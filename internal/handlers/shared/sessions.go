@@ -0,0 +1,52 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// getSessionIDs returns session IDs from the array of lsid documents given as the command value.
+func getSessionIDs(msg *wire.OpMsg) ([]common.SessionID, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	command := document.Keys()[0]
+	arr, ok := document.Map()[command].(*types.Array)
+	if !ok {
+		return nil, common.NewErrorMessage(
+			common.ErrTypeMismatch, "%s must be an array, got %T", command, document.Map()[command],
+		)
+	}
+
+	ids := make([]common.SessionID, arr.Len())
+	for i := range ids {
+		v, err := arr.Get(i)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		if ids[i], err = common.GetSessionID(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shared
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+
+	"github.com/FerretDB/FerretDB/internal/fjson"
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
+	"github.com/FerretDB/FerretDB/internal/handlers/jsonb1"
+	"github.com/FerretDB/FerretDB/internal/pg"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+	"github.com/FerretDB/FerretDB/internal/wire"
+)
+
+// maxViewDepth is the maximal depth of views defined on other views, as in MongoDB.
+const maxViewDepth = 20
+
+// GetView returns the view targeted by the given command, or nil if the target is not a view.
+func (h *Handler) GetView(ctx context.Context, document types.Document) (*pg.View, error) {
+	m := document.Map()
+	name, _ := m[document.Keys()[0]].(string)
+	db, _ := m["$db"].(string)
+	if name == "" || db == "" {
+		return nil, nil
+	}
+
+	view, err := h.pgPool.GetView(ctx, db, name)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return view, nil
+}
+
+// MsgView runs find or count command against the view; other commands are rejected.
+func (h *Handler) MsgView(ctx context.Context, msg *wire.OpMsg, view *pg.View) (*wire.OpMsg, error) {
+	document, err := msg.Document()
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	switch document.Command() {
+	case "find":
+		return h.findView(ctx, document, view)
+	case "count":
+		return h.countView(ctx, document, view)
+	default:
+		return nil, viewNotCollection(view)
+	}
+}
+
+// viewNotCollection returns the error for commands that are not supported on views.
+func viewNotCollection(view *pg.View) error {
+	return common.NewErrorMessage(
+		common.ErrCommandNotSupportedOnView, "Namespace %s.%s is a view, not a collection", view.DB, view.Name,
+	)
+}
+
+// findView handles find command against the view.
+func (h *Handler) findView(ctx context.Context, document types.Document, view *pg.View) (*wire.OpMsg, error) {
+	m := document.Map()
+	ns := view.DB + "." + view.Name
+
+	opts, err := common.GetFindOptions(document)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Tailable {
+		return nil, common.TailableNotCapped(ns)
+	}
+
+	projectionIn, _ := m["projection"].(types.Document)
+	projection, err := common.NewProjection(projectionIn)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, _ := m["filter"].(types.Document)
+	sort, _ := m["sort"].(types.Document)
+
+	docs, err := h.pipelineDocuments(ctx, view.DB, view.Name, view, findStages(filter, sort, opts.Skip, opts.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, doc := range docs {
+		if docs[i], err = projection.Project(doc, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	firstBatch, cursorID, err := h.cursors.FirstBatch(ns, docs, opts.BatchSize, opts.SingleBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursor", types.MustMakeDocument(
+				"firstBatch", firstBatch,
+				"id", cursorID,
+				"ns", ns,
+			),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// countView handles count command against the view.
+func (h *Handler) countView(ctx context.Context, document types.Document, view *pg.View) (*wire.OpMsg, error) {
+	opts, err := common.GetFindOptions(document)
+	if err != nil {
+		return nil, err
+	}
+
+	query, _ := document.Map()["query"].(types.Document)
+
+	docs, err := h.pipelineDocuments(ctx, view.DB, view.Name, view, findStages(query, types.Document{}, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"n", common.CountValue(opts.CountSkipLimit(int64(len(docs)))),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// aggregateView runs the aggregation pipeline on documents of the view.
+func (h *Handler) aggregateView(
+	ctx context.Context, view *pg.View, stages *types.Array, batchSize int64,
+) (*wire.OpMsg, error) {
+	docs, err := h.pipelineDocuments(ctx, view.DB, view.Name, view, stages)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := view.DB + "." + view.Name
+	firstBatch, cursorID, err := h.cursors.FirstBatch(ns, docs, batchSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{types.MustMakeDocument(
+			"cursor", types.MustMakeDocument(
+				"firstBatch", firstBatch,
+				"id", cursorID,
+				"ns", ns,
+			),
+			"ok", float64(1),
+		)},
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &reply, nil
+}
+
+// findStages returns aggregation pipeline stages that filter, sort, skip and limit documents;
+// zero limit means no limit.
+func findStages(filter, sort types.Document, skip, limit int64) *types.Array {
+	var stages []any
+
+	if len(filter.Keys()) != 0 {
+		stages = append(stages, types.MustMakeDocument("$match", filter))
+	}
+	if len(sort.Keys()) != 0 {
+		stages = append(stages, types.MustMakeDocument("$sort", sort))
+	}
+	if skip > 0 {
+		stages = append(stages, types.MustMakeDocument("$skip", skip))
+	}
+	if limit > 0 {
+		stages = append(stages, types.MustMakeDocument("$limit", limit))
+	}
+
+	return types.MustNewArray(stages...)
+}
+
+// pipelineDocuments runs the given pipeline stages on documents of the collection or view
+// and returns the result; missing collection has no documents.
+// The view should be nil for collections.
+//
+// Pipelines of views run first, starting from the underlying collection.
+// The leading $match and $limit stages are applied by PostgreSQL if possible,
+// and other stages run on documents as they are read.
+func (h *Handler) pipelineDocuments(
+	ctx context.Context, db, collection string, view *pg.View, stages *types.Array,
+) ([]types.Document, error) {
+	viewStages := []*types.Array{stages}
+
+	for depth := 0; view != nil; depth++ {
+		if depth == maxViewDepth {
+			return nil, common.NewErrorMessage(
+				common.ErrViewDepthLimitExceeded, "View depth too deep or view cycle detected. Maximum depth is %d", maxViewDepth,
+			)
+		}
+
+		s, err := viewPipeline(view)
+		if err != nil {
+			return nil, err
+		}
+		viewStages = append([]*types.Array{s}, viewStages...)
+		collection = view.ViewOn
+
+		if view, err = h.pgPool.GetView(ctx, db, collection); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+	}
+
+	all := new(types.Array)
+	for _, s := range viewStages {
+		for i := 0; i < s.Len(); i++ {
+			stage, err := s.Get(i)
+			if err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+			if err = all.Append(stage); err != nil {
+				return nil, lazyerrors.Error(err)
+			}
+		}
+	}
+
+	p, err := common.NewPipeline(all)
+	if err != nil {
+		return nil, err
+	}
+
+	// filters that can't be converted to SQL are applied by the pipeline
+	var placeholder pg.Placeholder
+	filter, limit, rest := p.Leading()
+	whereSQL, args, err := jsonb1.Where(filter, &placeholder)
+	if err != nil {
+		whereSQL, args, limit, rest = "", nil, 0, p
+	}
+
+	sql := `SELECT _jsonb FROM ` + pg.TableIdentifier(db, collection).Sanitize() + whereSQL
+	if limit != 0 {
+		sql += ` LIMIT ` + placeholder.Next()
+		args = append(args, limit)
+	}
+
+	stream := rest.Stream()
+
+	rows, err := h.pgPool.Query(ctx, sql, args...)
+	if err != nil {
+		var e *pgconn.PgError
+		if !errors.As(err, &e) {
+			return nil, lazyerrors.Error(err)
+		}
+
+		switch e.Code {
+		case pgerrcode.UndefinedTable, pgerrcode.InvalidSchemaName:
+			return stream.Result()
+		case pgerrcode.UndefinedColumn:
+			return nil, common.NewErrorMessage(common.ErrNotImplemented, "views on SQL tables are not supported")
+		default:
+			return nil, lazyerrors.Error(err)
+		}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		doc, err := unmarshalDocument(b)
+		if err != nil {
+			return nil, err
+		}
+
+		done, err := stream.Push([]types.Document{*doc})
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return stream.Result()
+}
+
+// viewPipeline decodes the pipeline stored in the catalog.
+func viewPipeline(view *pg.View) (*types.Array, error) {
+	v, err := fjson.Unmarshal(view.Pipeline)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	stages, ok := v.(*types.Array)
+	if !ok {
+		return nil, lazyerrors.Errorf("expected array, got %T", v)
+	}
+
+	return stages, nil
+}
@@ -16,75 +16,13 @@ package sql
 
 import (
 	"context"
-	"fmt"
-
-	"github.com/jackc/pgx/v4"
 
 	"github.com/FerretDB/FerretDB/internal/handlers/common"
-	"github.com/FerretDB/FerretDB/internal/pg"
-	"github.com/FerretDB/FerretDB/internal/types"
-	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
 	"github.com/FerretDB/FerretDB/internal/wire"
 )
 
 // MsgDelete deletes document.
 func (h *storage) MsgDelete(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg, error) {
-	document, err := msg.Document()
-	if err != nil {
-		return nil, lazyerrors.Error(err)
-	}
-
-	m := document.Map()
-	collection := m[document.Command()].(string)
-	db := m["$db"].(string)
-	docs, _ := m["deletes"].(*types.Array)
-
-	var deleted int32
-	for i := 0; i < docs.Len(); i++ {
-		doc, err := docs.Get(i)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
-		}
-
-		d := doc.(types.Document).Map()
-
-		sql := fmt.Sprintf(`DELETE FROM %s`, pgx.Identifier{db, collection}.Sanitize())
-		var placeholder pg.Placeholder
-
-		elSQL, args, err := where(d["q"].(types.Document), &placeholder)
-		if err != nil {
-			return nil, lazyerrors.Error(err)
-		}
-
-		limit, _ := d["limit"].(int32)
-		if limit != 0 {
-			sql += fmt.Sprintf(
-				"WHERE %s IN (SELECT %s FROM %s LIMIT 1)",
-				placeholder.Next(), placeholder.Next(), pgx.Identifier{db, collection}.Sanitize(),
-			)
-		} else {
-			sql += elSQL
-		}
-
-		tag, err := h.pgPool.Exec(ctx, sql, args...)
-		if err != nil {
-			// TODO check error code
-			return nil, common.NewErrorMessage(common.ErrNamespaceNotFound, "MsgDelete: ns not found: %w", err)
-		}
-
-		deleted += int32(tag.RowsAffected())
-	}
-
-	var reply wire.OpMsg
-	err = reply.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"n", deleted,
-			"ok", float64(1),
-		)},
-	})
-	if err != nil {
-		return nil, lazyerrors.Error(err)
-	}
-
-	return &reply, nil
+	// SQL tables have no _id, so rows are deleted in the physical order
+	return common.Delete(ctx, h.pgPool, msg, where, "ctid")
 }
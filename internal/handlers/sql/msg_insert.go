@@ -20,6 +20,7 @@ import (
 
 	"github.com/jackc/pgx/v4"
 
+	"github.com/FerretDB/FerretDB/internal/handlers/common"
 	"github.com/FerretDB/FerretDB/internal/pg"
 	"github.com/FerretDB/FerretDB/internal/types"
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
@@ -38,25 +39,36 @@ func (h *storage) MsgInsert(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 	db := m["$db"].(string)
 	docs, _ := m["documents"].(*types.Array)
 
-	ordered, ok := m["ordered"].(bool)
-	if !ok {
-		ordered = true
+	ordered, err := common.GetBoolParam(document, "ordered", true)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := common.GetRetryableWrite(document)
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO https://github.com/FerretDB/FerretDB/issues/200
-	_ = ordered
+	validator, err := common.GetValidator(ctx, h.pgPool, document, db, collection)
+	if err != nil {
+		return nil, err
+	}
 
-	var inserted int32
-	for i := 0; i < docs.Len(); i++ {
+	ns := db + "." + collection
+	res, err := common.BulkWrite(ctx, h.pgPool, ns, docs.Len(), ordered, retry, func(tx pgx.Tx, i int) (int64, int64, error) {
 		doc, err := docs.Get(i)
 		if err != nil {
-			return nil, lazyerrors.Error(err)
+			return 0, 0, lazyerrors.Error(err)
 		}
 
 		d := doc.(types.Document)
 		m := d.Map()
 
-		sql := fmt.Sprintf("INSERT INTO %s (", pgx.Identifier{db, collection}.Sanitize())
+		if err = validator.Check(h.l.Desugar(), ns, d); err != nil {
+			return 0, 0, err
+		}
+
+		sql := fmt.Sprintf("INSERT INTO %s (", pg.TableIdentifier(db, collection).Sanitize())
 		var args []any
 
 		for _, k := range d.Keys() {
@@ -84,24 +96,23 @@ func (h *storage) MsgInsert(ctx context.Context, msg *wire.OpMsg) (*wire.OpMsg,
 
 		sql += ")"
 
-		_, err = h.pgPool.Exec(ctx, sql, args...)
-		if err != nil {
-			return nil, err
+		if _, err = tx.Exec(ctx, sql, args...); err != nil {
+			return 0, 0, err
 		}
 
-		inserted++
+		return 1, 0, nil
+	})
+	if err != nil {
+		return nil, lazyerrors.Error(err)
 	}
 
-	var res wire.OpMsg
-	err = res.SetSections(wire.OpMsgSection{
-		Documents: []types.Document{types.MustMakeDocument(
-			"n", inserted,
-			"ok", float64(1),
-		)},
+	var reply wire.OpMsg
+	err = reply.SetSections(wire.OpMsgSection{
+		Documents: []types.Document{res.Reply(false)},
 	})
 	if err != nil {
 		return nil, lazyerrors.Error(err)
 	}
 
-	return &res, nil
+	return &reply, nil
 }
@@ -22,13 +22,15 @@ import (
 )
 
 type storage struct {
-	pgPool *pg.Pool
-	l      *zap.SugaredLogger
+	pgPool  *pg.Pool
+	l       *zap.SugaredLogger
+	cursors *common.Cursors
 }
 
-func NewStorage(pgPool *pg.Pool, l *zap.SugaredLogger) common.Storage {
+func NewStorage(pgPool *pg.Pool, l *zap.SugaredLogger, cursors *common.Cursors) common.Storage {
 	return &storage{
-		pgPool: pgPool,
-		l:      l,
+		pgPool:  pgPool,
+		l:       l,
+		cursors: cursors,
 	}
 }
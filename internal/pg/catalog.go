@@ -0,0 +1,373 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// CatalogSchema is the PostgreSQL schema for FerretDB's own tables.
+//
+// It contains '$' that is not allowed in MongoDB database names, so it never clashes with them.
+const CatalogSchema = "$ferretdb"
+
+// FerretDB catalog tables and functions.
+const (
+	// retryableWritesTable stores executed statements of retryable writes.
+	retryableWritesTable = "retryable_writes"
+
+	// changeLogTable stores changes of collections for change streams.
+	changeLogTable = "change_log"
+
+	// changeLogTrimmedTable stores a single row with the time before which change log records were deleted.
+	changeLogTrimmedTable = "change_log_trimmed"
+
+	// changeLogEnabledTable stores a single row if changes are recorded in the change log, see enableChangeLog.
+	changeLogEnabledTable = "change_log_enabled"
+
+	// changeLogTrigger is a trigger function that records changes of collection's documents.
+	changeLogTrigger = "change_log_trigger"
+
+	// changeLogChannel is a channel that is notified about new change log records.
+	changeLogChannel = "ferretdb_change_log"
+
+	// collectionsTable stores options of collections.
+	collectionsTable = "collections"
+
+	// ttlIndexesTable stores TTL indexes.
+	ttlIndexesTable = "ttl_indexes"
+
+	// viewsTable stores views.
+	viewsTable = "views"
+
+	// namesTable stores FerretDB names of PostgreSQL schemas and tables.
+	namesTable = "names"
+
+	// cappedTrigger is a trigger function that evicts the oldest documents of capped collections.
+	cappedTrigger = "capped_trigger"
+)
+
+// createCatalog creates FerretDB catalog schema, tables and functions if they do not exist yet.
+func (pgPool *Pool) createCatalog(ctx context.Context) error {
+	pgPool.catalogM.Lock()
+	defer pgPool.catalogM.Unlock()
+
+	if pgPool.catalogCreated {
+		return nil
+	}
+
+	sqls := []string{
+		`CREATE SCHEMA IF NOT EXISTS ` + pgx.Identifier{CatalogSchema}.Sanitize(),
+
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, retryableWritesTable}.Sanitize() + ` (
+			lsid uuid NOT NULL,
+			txn_number bigint NOT NULL,
+			stmt_id integer NOT NULL,
+			n bigint NOT NULL,
+			n_modified bigint NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			PRIMARY KEY (lsid, txn_number, stmt_id)
+		)`,
+
+		// records are ordered by transaction ID first, see ChangeLogPosition
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + ` (
+			xid bigint NOT NULL DEFAULT txid_current(),
+			id bigserial NOT NULL,
+			created_at timestamptz NOT NULL DEFAULT now(),
+			db text NOT NULL,
+			collection text NOT NULL,
+			op text NOT NULL,
+			old_document jsonb,
+			new_document jsonb,
+			PRIMARY KEY (xid, id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS change_log_created_at ON ` +
+			pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + ` (created_at)`,
+
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, changeLogTrimmedTable}.Sanitize() + ` (
+			id boolean PRIMARY KEY DEFAULT true CHECK (id),
+			trimmed_before timestamptz NOT NULL
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, changeLogEnabledTable}.Sanitize() + ` (
+			id boolean PRIMARY KEY DEFAULT true CHECK (id),
+			enabled_at timestamptz NOT NULL DEFAULT now()
+		)`,
+
+		// changes were always recorded before, so existing change logs stay enabled for resuming readers
+		`INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogEnabledTable}.Sanitize() + ` (enabled_at)
+			SELECT now() WHERE EXISTS (SELECT 1 FROM ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + `)
+			ON CONFLICT (id) DO NOTHING`,
+
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() + ` (
+			db text NOT NULL,
+			collection text NOT NULL,
+			options jsonb NOT NULL,
+			uuid uuid NOT NULL DEFAULT gen_random_uuid(),
+			capped_size bigint,
+			capped_count bigint,
+			PRIMARY KEY (db, collection)
+		)`,
+		`ALTER TABLE ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() +
+			` ADD COLUMN IF NOT EXISTS uuid uuid NOT NULL DEFAULT gen_random_uuid()`,
+		`ALTER TABLE ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() + ` ADD COLUMN IF NOT EXISTS capped_size bigint`,
+		`ALTER TABLE ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() + ` ADD COLUMN IF NOT EXISTS capped_count bigint`,
+
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, ttlIndexesTable}.Sanitize() + ` (
+			db text NOT NULL,
+			collection text NOT NULL,
+			name text NOT NULL,
+			key text NOT NULL,
+			expire_after_seconds bigint NOT NULL,
+			hidden boolean NOT NULL DEFAULT false,
+			PRIMARY KEY (db, collection, name)
+		)`,
+		`ALTER TABLE ` + pgx.Identifier{CatalogSchema, ttlIndexesTable}.Sanitize() +
+			` ADD COLUMN IF NOT EXISTS hidden boolean NOT NULL DEFAULT false`,
+
+		// pipeline is fjson-encoded, as documents
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, viewsTable}.Sanitize() + ` (
+			db text NOT NULL,
+			collection text NOT NULL,
+			view_on text NOT NULL,
+			pipeline jsonb NOT NULL,
+			PRIMARY KEY (db, collection)
+		)`,
+
+		// table_name is empty for schemas
+		`CREATE TABLE IF NOT EXISTS ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() + ` (
+			schema_name text NOT NULL,
+			table_name text NOT NULL,
+			db text NOT NULL,
+			collection text NOT NULL,
+			PRIMARY KEY (schema_name, table_name)
+		)`,
+
+		// tables created before options were stored get catalog records, so they have stable UUIDs
+		`INSERT INTO ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() + ` (db, collection, options)
+			SELECT COALESCE(n.db, t.table_schema), COALESCE(n.collection, t.table_name), '{}'
+			FROM information_schema.tables AS t
+			LEFT JOIN ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() + ` AS n
+				ON n.schema_name = t.table_schema AND n.table_name = t.table_name
+			WHERE t.table_type = 'BASE TABLE' AND t.table_schema NOT IN ('information_schema', '` + CatalogSchema + `')
+				AND t.table_schema NOT LIKE 'pg\_%'
+			ON CONFLICT (db, collection) DO NOTHING`,
+
+		// evictions of capped collections' documents are not recorded, as in MongoDB;
+		// nothing is recorded until the first change stream or oplog reader enables the change log
+		`CREATE OR REPLACE FUNCTION ` + pgx.Identifier{CatalogSchema, changeLogTrigger}.Sanitize() + `()
+		RETURNS trigger LANGUAGE plpgsql AS $$
+		DECLARE
+			ns_db text;
+			ns_collection text;
+		BEGIN
+			IF pg_trigger_depth() > 1 THEN
+				RETURN NULL;
+			END IF;
+
+			IF NOT EXISTS (SELECT 1 FROM ` + pgx.Identifier{CatalogSchema, changeLogEnabledTable}.Sanitize() + `) THEN
+				RETURN NULL;
+			END IF;
+
+			SELECT db, collection INTO ns_db, ns_collection FROM ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() + `
+				WHERE schema_name = TG_TABLE_SCHEMA AND table_name = TG_TABLE_NAME;
+			IF NOT FOUND THEN
+				ns_db := TG_TABLE_SCHEMA;
+				ns_collection := TG_TABLE_NAME;
+			END IF;
+
+			IF TG_OP = 'INSERT' THEN
+				INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + `
+					(db, collection, op, new_document) VALUES (ns_db, ns_collection, 'insert', NEW._jsonb);
+			ELSIF TG_OP = 'UPDATE' THEN
+				INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + `
+					(db, collection, op, old_document, new_document)
+					VALUES (ns_db, ns_collection, 'update', OLD._jsonb, NEW._jsonb);
+			ELSE
+				INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + `
+					(db, collection, op, old_document) VALUES (ns_db, ns_collection, 'delete', OLD._jsonb);
+			END IF;
+
+			PERFORM pg_notify('` + changeLogChannel + `', '');
+			RETURN NULL;
+		END
+		$$`,
+
+		// tables created before the change log existed get the trigger, so their changes are not missed
+		`DO $$
+		DECLARE
+			t record;
+		BEGIN
+			FOR t IN
+				SELECT c.table_schema, c.table_name
+				FROM information_schema.columns AS c
+				JOIN information_schema.tables AS tb
+					ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+				WHERE tb.table_type = 'BASE TABLE' AND c.column_name = '_jsonb' AND c.data_type = 'jsonb'
+					AND c.table_schema NOT IN ('information_schema', '` + CatalogSchema + `')
+					AND c.table_schema NOT LIKE 'pg\_%'
+					AND NOT EXISTS (
+						SELECT 1 FROM pg_trigger
+						WHERE tgrelid = format('%I.%I', c.table_schema, c.table_name)::regclass
+							AND tgname = '` + changeLogTable + `'
+					)
+			LOOP
+				BEGIN
+					EXECUTE format(
+						'CREATE TRIGGER %I AFTER INSERT OR UPDATE OR DELETE ON %I.%I FOR EACH ROW EXECUTE PROCEDURE %I.%I()',
+						'` + changeLogTable + `', t.table_schema, t.table_name, '` + CatalogSchema + `', '` + changeLogTrigger + `'
+					);
+				EXCEPTION WHEN duplicate_object THEN
+					-- created by another FerretDB instance concurrently
+					NULL;
+				END;
+			END LOOP;
+		END
+		$$`,
+
+		// Total size and number of capped collection's documents are kept in the catalog row;
+		// NULL values mean that they are not known yet, and they are computed once.
+		// BEFORE statement trigger locks that row, so inserts and updates are serialized,
+		// sequence values are assigned in the commit order, and tailable cursors do not skip documents.
+		// AFTER statement triggers update totals from transition tables, and evict the oldest documents
+		// while the total size or number of documents exceeds collection's limits.
+		`CREATE OR REPLACE FUNCTION ` + pgx.Identifier{CatalogSchema, cappedTrigger}.Sanitize() + `()
+		RETURNS trigger LANGUAGE plpgsql AS $$
+		DECLARE
+			ns_db text;
+			ns_collection text;
+			opts jsonb;
+			total_size bigint;
+			total_count bigint;
+			delta_size bigint;
+			delta_count bigint;
+			evicted bigint;
+		BEGIN
+			-- evictions are accounted by the trigger that does them
+			IF pg_trigger_depth() > 1 THEN
+				RETURN NULL;
+			END IF;
+
+			SELECT db, collection INTO ns_db, ns_collection FROM ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() + `
+				WHERE schema_name = TG_TABLE_SCHEMA AND table_name = TG_TABLE_NAME;
+			IF NOT FOUND THEN
+				ns_db := TG_TABLE_SCHEMA;
+				ns_collection := TG_TABLE_NAME;
+			END IF;
+
+			SELECT options, capped_size, capped_count INTO opts, total_size, total_count
+				FROM ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() + `
+				WHERE db = ns_db AND collection = ns_collection FOR UPDATE;
+			IF NOT FOUND THEN
+				RETURN NULL;
+			END IF;
+
+			IF total_size IS NULL THEN
+				EXECUTE format(
+					'SELECT COALESCE(sum(pg_column_size(_jsonb)), 0), count(*) FROM %I.%I', TG_TABLE_SCHEMA, TG_TABLE_NAME
+				) INTO total_size, total_count;
+			ELSIF TG_WHEN = 'AFTER' THEN
+				IF TG_OP = 'INSERT' THEN
+					SELECT COALESCE(sum(pg_column_size(_jsonb)), 0), count(*) INTO delta_size, delta_count FROM new_rows;
+				ELSIF TG_OP = 'UPDATE' THEN
+					SELECT COALESCE(sum(pg_column_size(_jsonb)), 0), 0 INTO delta_size, delta_count FROM new_rows;
+					total_size := total_size - (SELECT COALESCE(sum(pg_column_size(_jsonb)), 0) FROM old_rows);
+				ELSE
+					SELECT -COALESCE(sum(pg_column_size(_jsonb)), 0), -count(*) INTO delta_size, delta_count FROM old_rows;
+				END IF;
+
+				total_size := total_size + delta_size;
+				total_count := total_count + delta_count;
+			END IF;
+
+			IF TG_WHEN = 'AFTER' AND TG_OP <> 'DELETE' THEN
+				WHILE total_count > 1 AND (
+					total_size > (opts->>'size')::bigint OR
+					(COALESCE((opts->>'max')::bigint, 0) > 0 AND total_count > (opts->>'max')::bigint)
+				) LOOP
+					EXECUTE format(
+						'DELETE FROM %I.%I WHERE _seq = (SELECT min(_seq) FROM %I.%I) RETURNING pg_column_size(_jsonb)',
+						TG_TABLE_SCHEMA, TG_TABLE_NAME, TG_TABLE_SCHEMA, TG_TABLE_NAME
+					) INTO evicted;
+
+					total_size := total_size - evicted;
+					total_count := total_count - 1;
+				END LOOP;
+			END IF;
+
+			UPDATE ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() + `
+				SET capped_size = total_size, capped_count = total_count
+				WHERE db = ns_db AND collection = ns_collection;
+
+			RETURN NULL;
+		END
+		$$`,
+
+		// capped collections created before totals were kept get new AFTER triggers
+		`DO $$
+		DECLARE
+			t record;
+		BEGIN
+			FOR t IN SELECT tgrelid::regclass AS rel FROM pg_trigger WHERE tgname = 'capped_after' LOOP
+				BEGIN
+					EXECUTE format('DROP TRIGGER capped_after ON %s', t.rel);
+					EXECUTE format('` + cappedAfterTriggersSQL("%s") + `', t.rel, t.rel, t.rel);
+				EXCEPTION WHEN undefined_object OR duplicate_object THEN
+					-- migrated by another FerretDB instance concurrently
+					NULL;
+				END;
+			END LOOP;
+		END
+		$$`,
+	}
+
+	for _, sql := range sqls {
+		_, err := pgPool.Exec(ctx, sql)
+
+		// IF NOT EXISTS is not concurrency-safe, so another FerretDB instance could create them first
+		var e *pgconn.PgError
+		if errors.As(err, &e) && e.Code == pgerrcode.UniqueViolation {
+			err = nil
+		}
+
+		if err != nil {
+			return lazyerrors.Error(err)
+		}
+	}
+
+	pgPool.catalogCreated = true
+
+	return nil
+}
+
+// cappedAfterTriggersSQL returns SQL statements that create AFTER triggers of the given capped table;
+// see cappedTrigger.
+func cappedAfterTriggersSQL(table string) string {
+	procedure := pgx.Identifier{CatalogSchema, cappedTrigger}.Sanitize()
+
+	return `CREATE TRIGGER capped_insert AFTER INSERT ON ` + table +
+		` REFERENCING NEW TABLE AS new_rows FOR EACH STATEMENT EXECUTE PROCEDURE ` + procedure + `(); ` +
+		`CREATE TRIGGER capped_update AFTER UPDATE ON ` + table +
+		` REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows FOR EACH STATEMENT EXECUTE PROCEDURE ` + procedure + `(); ` +
+		`CREATE TRIGGER capped_delete AFTER DELETE ON ` + table +
+		` REFERENCING OLD TABLE AS old_rows FOR EACH STATEMENT EXECUTE PROCEDURE ` + procedure + `()`
+}
@@ -0,0 +1,437 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// DefaultChangeLogRetention is the default time for which change log records are kept.
+const DefaultChangeLogRetention = 24 * time.Hour
+
+// changeLogReconnectDelay is the delay before reconnecting after change log listening connection failure.
+const changeLogReconnectDelay = time.Second
+
+// ChangeLogPosition is a position in the change log.
+//
+// Records are ordered by transaction ID first, and then by record ID.
+// Records of a transaction are returned only after all older transactions are finished,
+// so records of concurrent transactions are never skipped regardless of their commit order.
+type ChangeLogPosition struct {
+	XID int64
+	ID  int64
+}
+
+// less returns true if the position is before the other one.
+func (p ChangeLogPosition) less(other ChangeLogPosition) bool {
+	if p.XID != other.XID {
+		return p.XID < other.XID
+	}
+
+	return p.ID < other.ID
+}
+
+// ChangeLogRecord represents a single change of collection's document, a collection rename or drop, or a database drop.
+type ChangeLogRecord struct {
+	ChangeLogPosition
+	CreatedAt   time.Time
+	DB          string
+	Collection  string // empty for dropDatabase
+	Op          string // insert, update, delete, rename, drop, or dropDatabase
+	OldDocument []byte // for update and delete
+	NewDocument []byte // for insert and update; for rename, {db, coll} document of the new namespace
+	TS          int64  // oplog timestamp derived from the position; set by OplogRecords only
+}
+
+// changeLogNotifier listens to change log notifications and wakes up waiting readers.
+type changeLogNotifier struct {
+	m      sync.Mutex
+	ch     chan struct{}
+	cancel context.CancelFunc
+}
+
+// broadcast wakes up all waiting readers.
+func (n *changeLogNotifier) broadcast() {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
+
+// run listens to notifications until ctx is canceled, reconnecting on errors.
+func (n *changeLogNotifier) run(ctx context.Context, connConfig *pgx.ConnConfig) {
+	for ctx.Err() == nil {
+		conn, err := pgx.ConnectConfig(ctx, connConfig)
+		if err == nil {
+			_, err = conn.Exec(ctx, `LISTEN `+pgx.Identifier{changeLogChannel}.Sanitize())
+		}
+
+		for err == nil {
+			if _, err = conn.WaitForNotification(ctx); err == nil {
+				n.broadcast()
+			}
+		}
+
+		if conn != nil {
+			_ = conn.Close(context.Background())
+		}
+
+		// notifications could be missed, so let readers check the change log
+		n.broadcast()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(changeLogReconnectDelay):
+		}
+	}
+}
+
+// ChangeLogNotify returns a channel that is closed when new change log records may be available.
+//
+// It should be called before reading the change log, so records committed after that are not missed.
+// Notifications are delivered on a dedicated connection that is closed by Close.
+func (pgPool *Pool) ChangeLogNotify() <-chan struct{} {
+	pgPool.changeLogNotifierOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		pgPool.changeLogNotifier = &changeLogNotifier{
+			ch:     make(chan struct{}),
+			cancel: cancel,
+		}
+
+		go pgPool.changeLogNotifier.run(ctx, pgPool.Config().ConnConfig)
+	})
+
+	n := pgPool.changeLogNotifier
+	if n == nil {
+		// the pool is closed
+		return nil
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	return n.ch
+}
+
+// Close stops listening to change log notifications and closes all connections in the pool.
+func (pgPool *Pool) Close() {
+	// wait for the notifier to be started if that happens concurrently, and prevent starting it later
+	pgPool.changeLogNotifierOnce.Do(func() {})
+
+	if n := pgPool.changeLogNotifier; n != nil {
+		n.cancel()
+	}
+
+	pgPool.Pool.Close()
+}
+
+// enableChangeLog makes change log triggers record changes of collections' documents, if they do not yet.
+//
+// Documents are not copied to the change log until the first change stream or oplog reader calls it.
+// Changes of transactions running concurrently with that call may be not recorded.
+// Earlier changes are reported as deleted by ChangeLogRetained.
+func (pgPool *Pool) enableChangeLog(ctx context.Context) error {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	pgPool.changeLogEnabledM.Lock()
+	defer pgPool.changeLogEnabledM.Unlock()
+
+	if pgPool.changeLogEnabled {
+		return nil
+	}
+
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogEnabledTable}.Sanitize() +
+			` DEFAULT VALUES ON CONFLICT (id) DO NOTHING`
+		tag, err := tx.Exec(ctx, sql)
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+
+		sql = `INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTrimmedTable}.Sanitize() +
+			` (trimmed_before) VALUES (now())` +
+			` ON CONFLICT (id) DO UPDATE SET trimmed_before = GREATEST(EXCLUDED.trimmed_before, ` +
+			pgx.Identifier{changeLogTrimmedTable}.Sanitize() + `.trimmed_before)`
+		_, err = tx.Exec(ctx, sql)
+		return err
+	})
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	pgPool.changeLogEnabled = true
+	return nil
+}
+
+// ChangeLogStart returns the position of the change log before records of not yet finished transactions.
+//
+// It enables the change log, see enableChangeLog.
+func (pgPool *Pool) ChangeLogStart(ctx context.Context) (ChangeLogPosition, error) {
+	if err := pgPool.enableChangeLog(ctx); err != nil {
+		return ChangeLogPosition{}, err
+	}
+
+	var xmin int64
+	if err := pgPool.QueryRow(ctx, `SELECT txid_snapshot_xmin(txid_current_snapshot())`).Scan(&xmin); err != nil {
+		return ChangeLogPosition{}, lazyerrors.Error(err)
+	}
+
+	return ChangeLogPosition{XID: xmin - 1, ID: math.MaxInt64}, nil
+}
+
+// ChangeLogStartAt returns the position of the change log before the first record created at or after the given time.
+func (pgPool *Pool) ChangeLogStartAt(ctx context.Context, t time.Time) (ChangeLogPosition, error) {
+	start, err := pgPool.ChangeLogStart(ctx)
+	if err != nil {
+		return ChangeLogPosition{}, err
+	}
+
+	sql := `SELECT xid, id FROM ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() +
+		` WHERE created_at >= $1 ORDER BY xid, id LIMIT 1`
+
+	var first ChangeLogPosition
+	err = pgPool.QueryRow(ctx, sql, t).Scan(&first.XID, &first.ID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return start, nil
+	case err != nil:
+		return ChangeLogPosition{}, lazyerrors.Error(err)
+	case start.less(first):
+		return start, nil
+	default:
+		first.ID--
+		return first, nil
+	}
+}
+
+// ChangeLogRetained returns true if change log records created at or after the given time were not deleted.
+func (pgPool *Pool) ChangeLogRetained(ctx context.Context, t time.Time) (bool, error) {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return false, err
+	}
+
+	sql := `SELECT NOT EXISTS (SELECT 1 FROM ` + pgx.Identifier{CatalogSchema, changeLogTrimmedTable}.Sanitize() +
+		` WHERE trimmed_before > $1)`
+
+	var res bool
+	if err := pgPool.QueryRow(ctx, sql, t).Scan(&res); err != nil {
+		return false, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// ChangeLog returns up to limit change log records after the given position
+// for the given database and collection; empty values mean all databases or collections.
+//
+// It also returns the position to read the next records from.
+func (pgPool *Pool) ChangeLog(
+	ctx context.Context, after ChangeLogPosition, db, collection string, limit int64,
+) ([]ChangeLogRecord, ChangeLogPosition, error) {
+	var xmin int64
+	if err := pgPool.QueryRow(ctx, `SELECT txid_snapshot_xmin(txid_current_snapshot())`).Scan(&xmin); err != nil {
+		return nil, after, lazyerrors.Error(err)
+	}
+
+	sql := `SELECT xid, id, created_at, db, collection, op, old_document, new_document FROM ` +
+		pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() +
+		` WHERE (xid, id) > ($1, $2) AND xid < $3 AND ($4 = '' OR db = $4) AND ($5 = '' OR collection = $5)` +
+		` ORDER BY xid, id LIMIT $6`
+	rows, err := pgPool.Query(ctx, sql, after.XID, after.ID, xmin, db, collection, limit)
+	if err != nil {
+		return nil, after, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	var res []ChangeLogRecord
+	for rows.Next() {
+		var r ChangeLogRecord
+		err = rows.Scan(&r.XID, &r.ID, &r.CreatedAt, &r.DB, &r.Collection, &r.Op, &r.OldDocument, &r.NewDocument)
+		if err != nil {
+			return nil, after, lazyerrors.Error(err)
+		}
+
+		res = append(res, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, after, lazyerrors.Error(err)
+	}
+
+	next := after
+	if len(res) > 0 {
+		next = res[len(res)-1].ChangeLogPosition
+	}
+
+	// all records of finished transactions were read
+	if int64(len(res)) < limit {
+		if end := (ChangeLogPosition{XID: xmin - 1, ID: math.MaxInt64}); next.less(end) {
+			next = end
+		}
+	}
+
+	return res, next, nil
+}
+
+// OplogRecords returns up to limit change log records of finished transactions
+// with oplog timestamps in (after, before) range; zero before means no upper bound.
+//
+// Timestamps are derived from positions: transaction ID is in high 32 bits,
+// and the ordinal of the record among records of its transaction is in low 32 bits.
+// So records are ordered by timestamps as by positions, in descending order if reverse is true,
+// and records are never added before read ones.
+// That holds while transaction IDs fit into 32 bits, before the first PostgreSQL transaction ID wraparound.
+// It enables the change log, see enableChangeLog.
+func (pgPool *Pool) OplogRecords(ctx context.Context, after, before, limit int64, reverse bool) ([]ChangeLogRecord, error) {
+	if err := pgPool.enableChangeLog(ctx); err != nil {
+		return nil, err
+	}
+
+	order := "ASC"
+	if reverse {
+		order = "DESC"
+	}
+
+	table := pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize()
+
+	// the first record ID of the transaction; record IDs of a transaction are increasing, and are deleted together
+	firstID := func(xid string) string {
+		return `(SELECT min(f.id) FROM ` + table + ` AS f WHERE f.xid = ` + xid + `)`
+	}
+
+	var placeholder Placeholder
+	xid, ordinal := placeholder.Next(), placeholder.Next()
+	sql := `SELECT xid, id, id - ` + firstID("c.xid") + ` + 1, created_at, db, collection, op, old_document, new_document` +
+		` FROM ` + table + ` AS c` +
+		` WHERE xid < txid_snapshot_xmin(txid_current_snapshot())` +
+		` AND (xid, id) > (` + xid + `::bigint, COALESCE(` + firstID(xid+"::bigint") + `, 0) + ` + ordinal + ` - 1)`
+	args := []any{after >> 32, after & math.MaxUint32}
+
+	if before != 0 {
+		xid, ordinal = placeholder.Next(), placeholder.Next()
+		sql += ` AND (xid, id) < (` + xid + `::bigint, COALESCE(` + firstID(xid+"::bigint") + `, 0) + ` + ordinal + ` - 1)`
+		args = append(args, before>>32, before&math.MaxUint32)
+	}
+
+	sql += ` ORDER BY xid ` + order + `, id ` + order + ` LIMIT ` + placeholder.Next()
+	args = append(args, limit)
+
+	rows, err := pgPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	var res []ChangeLogRecord
+	for rows.Next() {
+		var r ChangeLogRecord
+		var n int64
+		err = rows.Scan(&r.XID, &r.ID, &n, &r.CreatedAt, &r.DB, &r.Collection, &r.Op, &r.OldDocument, &r.NewDocument)
+		if err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		r.TS = r.XID<<32 | n
+		res = append(res, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// changeLogEnabledSQL is a condition that is true if the change log is enabled, see enableChangeLog.
+var changeLogEnabledSQL = `EXISTS (SELECT 1 FROM ` + pgx.Identifier{CatalogSchema, changeLogEnabledTable}.Sanitize() + `)`
+
+// insertChangeLog records a drop of the collection, or of the database if collection is empty.
+func insertChangeLog(ctx context.Context, tx pgx.Tx, db, collection string) error {
+	op := "drop"
+	if collection == "" {
+		op = "dropDatabase"
+	}
+
+	sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() +
+		` (db, collection, op) SELECT $1, $2, $3 WHERE ` + changeLogEnabledSQL
+	tag, err := tx.Exec(ctx, sql, db, collection, op)
+	if err != nil || tag.RowsAffected() == 0 {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `SELECT pg_notify($1, '')`, changeLogChannel)
+	return err
+}
+
+// insertRenameChangeLog records a rename of the collection.
+func insertRenameChangeLog(ctx context.Context, tx pgx.Tx, db, collection, toDB, toCollection string) error {
+	// the new namespace is encoded as fjson document, as other documents of the change log
+	sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() +
+		` (db, collection, op, new_document) SELECT $1, $2, 'rename',` +
+		` jsonb_build_object('$k', jsonb_build_array('db', 'coll'), 'db', $3::text, 'coll', $4::text)` +
+		` WHERE ` + changeLogEnabledSQL
+	tag, err := tx.Exec(ctx, sql, db, collection, toDB, toCollection)
+	if err != nil || tag.RowsAffected() == 0 {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `SELECT pg_notify($1, '')`, changeLogChannel)
+	return err
+}
+
+// DeleteChangeLog deletes change log records created before the given time.
+//
+// It returns the number of deleted records.
+func (pgPool *Pool) DeleteChangeLog(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		sql := `DELETE FROM ` + pgx.Identifier{CatalogSchema, changeLogTable}.Sanitize() + ` WHERE created_at < $1`
+		tag, err := tx.Exec(ctx, sql, before)
+		if err != nil {
+			return err
+		}
+		n = tag.RowsAffected()
+
+		sql = `INSERT INTO ` + pgx.Identifier{CatalogSchema, changeLogTrimmedTable}.Sanitize() +
+			` (trimmed_before) VALUES ($1)` +
+			` ON CONFLICT (id) DO UPDATE SET trimmed_before = GREATEST(EXCLUDED.trimmed_before, ` +
+			pgx.Identifier{changeLogTrimmedTable}.Sanitize() + `.trimmed_before)`
+		_, err = tx.Exec(ctx, sql, before)
+		return err
+	})
+
+	// nothing to delete if there were no changes yet
+	var e *pgconn.PgError
+	if errors.As(err, &e) && (e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, lazyerrors.Error(err)
+	}
+
+	return n, nil
+}
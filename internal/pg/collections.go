@@ -0,0 +1,95 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// Collection represents FerretDB collection with its catalog record.
+type Collection struct {
+	Name    string
+	UUID    []byte // nil if there is no catalog record yet
+	Options TableOptions
+}
+
+// Collections returns a sorted list of FerretDB collections of the given database.
+//
+// The catalog is not created there, so it works for read-only users.
+func (pgPool *Pool) Collections(ctx context.Context, db string) ([]Collection, error) {
+	names, err := tables(ctx, pgPool, db)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Collection, len(names))
+	for i, name := range names {
+		res[i].Name = name
+	}
+
+	if len(res) == 0 {
+		return res, nil
+	}
+
+	sql := `SELECT collection, uuid, options FROM ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() +
+		` WHERE db = $1`
+	rows, err := pgPool.Query(ctx, sql, db)
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) &&
+		(e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName || e.Code == pgerrcode.UndefinedColumn) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	records := make(map[string]Collection, len(res))
+	for rows.Next() {
+		var c Collection
+		var uuid [16]byte
+		var b []byte
+		if err = rows.Scan(&c.Name, &uuid, &b); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		c.UUID = uuid[:]
+		if err = json.Unmarshal(b, &c.Options); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		records[c.Name] = c
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	for i, c := range res {
+		if r, ok := records[c.Name]; ok {
+			res[i] = r
+		}
+	}
+
+	return res, nil
+}
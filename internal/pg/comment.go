@@ -0,0 +1,32 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import "strings"
+
+// Comment returns an SQL block comment with the given text, followed by a space,
+// or an empty string if the text is empty.
+//
+// PostgreSQL block comments nest, so both opening and closing sequences are escaped.
+func Comment(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	s = strings.ReplaceAll(s, "/*", "/ *")
+	s = strings.ReplaceAll(s, "*/", "* /")
+
+	return "/* " + s + " */ "
+}
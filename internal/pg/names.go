@@ -0,0 +1,172 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+const (
+	// maxIdentifierLength is the maximum length of PostgreSQL identifiers in bytes (NAMEDATALEN - 1);
+	// longer identifiers are silently truncated by PostgreSQL.
+	maxIdentifierLength = 63
+
+	// maxDatabaseNameLength is the maximum length of MongoDB database names in bytes.
+	maxDatabaseNameLength = 63
+
+	// maxNamespaceLength is the maximum length of MongoDB namespaces (database.collection) in bytes.
+	maxNamespaceLength = 255
+)
+
+var (
+	ErrInvalidDatabaseName   = fmt.Errorf("invalid database name")
+	ErrInvalidCollectionName = fmt.Errorf("invalid collection name")
+)
+
+// ValidateDatabaseName returns ErrInvalidDatabaseName if the name can't be used
+// for a new FerretDB database, following MongoDB rules.
+//
+// Names of PostgreSQL and FerretDB internal schemas are reserved.
+func ValidateDatabaseName(db string) error {
+	if db == "" || len(db) > maxDatabaseNameLength || !utf8.ValidString(db) || strings.ContainsAny(db, "/\\. \"$\x00") {
+		return ErrInvalidDatabaseName
+	}
+
+	if internalSchema(db) {
+		return ErrInvalidDatabaseName
+	}
+
+	return nil
+}
+
+// ValidateCollectionName returns ErrInvalidCollectionName if the name can't be used
+// for a new FerretDB collection in the given database, following MongoDB rules.
+//
+// Names with the "system." prefix are reserved, and '$' is not allowed.
+func ValidateCollectionName(db, collection string) error {
+	switch {
+	case collection == "",
+		len(db)+len(".")+len(collection) > maxNamespaceLength,
+		!utf8.ValidString(collection),
+		strings.HasPrefix(collection, "."),
+		strings.HasPrefix(collection, "system."),
+		strings.ContainsAny(collection, "$\x00"):
+		return ErrInvalidCollectionName
+	default:
+		return nil
+	}
+}
+
+// identifier returns PostgreSQL identifier for the given FerretDB database or collection name.
+//
+// Short names are used as is. Longer names are truncated, and a hash of the full name is appended after '$',
+// so names that differ only in the truncated part do not collide.
+// Valid names can't contain '$', so they do not collide with truncated names either.
+func identifier(name string) string {
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+
+	hash := sha256.Sum256([]byte(name))
+	suffix := "$" + hex.EncodeToString(hash[:8])
+
+	prefix := name[:maxIdentifierLength-len(suffix)]
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	return prefix + suffix
+}
+
+// SchemaIdentifier returns PostgreSQL identifier of the schema for the given FerretDB database.
+func SchemaIdentifier(db string) pgx.Identifier {
+	return pgx.Identifier{identifier(db)}
+}
+
+// TableIdentifier returns PostgreSQL identifier of the table for the given FerretDB database and collection.
+func TableIdentifier(db, collection string) pgx.Identifier {
+	return pgx.Identifier{identifier(db), identifier(collection)}
+}
+
+// insertName records FerretDB names of the schema (if collection is empty) or of the table in the catalog,
+// so they could be restored from PostgreSQL identifiers.
+func insertName(ctx context.Context, tx pgx.Tx, db, collection string) error {
+	var table string
+	if collection != "" {
+		table = identifier(collection)
+	}
+
+	sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() +
+		` (schema_name, table_name, db, collection) VALUES ($1, $2, $3, $4)` +
+		` ON CONFLICT (schema_name, table_name) DO UPDATE SET db = EXCLUDED.db, collection = EXCLUDED.collection`
+	if _, err := tx.Exec(ctx, sql, identifier(db), table, db, collection); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	return nil
+}
+
+// catalogNames returns FerretDB names recorded in the catalog by insertName, keyed by PostgreSQL identifiers:
+// database names of schemas if db is empty, and collection names of the database's tables otherwise.
+//
+// The catalog is not created there, so it works for read-only users;
+// identifiers that are not recorded are FerretDB names themselves.
+func catalogNames(ctx context.Context, q querier, db string) (map[string]string, error) {
+	sql := `SELECT schema_name, db FROM ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() + ` WHERE table_name = ''`
+	var args []any
+	if db != "" {
+		sql = `SELECT table_name, collection FROM ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() +
+			` WHERE schema_name = $1 AND table_name <> ''`
+		args = append(args, identifier(db))
+	}
+
+	res := map[string]string{}
+
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		var e *pgconn.PgError
+		if errors.As(err, &e) && (e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName) {
+			return res, nil
+		}
+		return nil, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		res[id] = name
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNames(t *testing.T) {
+	t.Parallel()
+
+	for db, expected := range map[string]error{
+		"test":                  nil,
+		"Test_DB-1":             nil,
+		"тест":                  nil,
+		"":                      ErrInvalidDatabaseName,
+		"a.b":                   ErrInvalidDatabaseName,
+		"a b":                   ErrInvalidDatabaseName,
+		"a/b":                   ErrInvalidDatabaseName,
+		`a\b`:                   ErrInvalidDatabaseName,
+		`a"b`:                   ErrInvalidDatabaseName,
+		"$ferretdb":             ErrInvalidDatabaseName,
+		"a\x00b":                ErrInvalidDatabaseName,
+		"pg_catalog":            ErrInvalidDatabaseName,
+		"information_schema":    ErrInvalidDatabaseName,
+		strings.Repeat("a", 63): nil,
+		strings.Repeat("a", 64): ErrInvalidDatabaseName,
+	} {
+		assert.Equal(t, expected, ValidateDatabaseName(db), "%q", db)
+	}
+
+	for collection, expected := range map[string]error{
+		"test":                   nil,
+		"a.b":                    nil,
+		"a b":                    nil,
+		"systems":                nil,
+		strings.Repeat("a", 250): nil,
+		strings.Repeat("a", 251): ErrInvalidCollectionName,
+		"":                       ErrInvalidCollectionName,
+		".a":                     ErrInvalidCollectionName,
+		"system.views":           ErrInvalidCollectionName,
+		"a$b":                    ErrInvalidCollectionName,
+		"$cmd":                   ErrInvalidCollectionName,
+		"a\x00b":                 ErrInvalidCollectionName,
+		"\xff":                   ErrInvalidCollectionName,
+	} {
+		assert.Equal(t, expected, ValidateCollectionName("test", collection), "%q", collection)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	t.Parallel()
+
+	short := strings.Repeat("a", maxIdentifierLength)
+	assert.Equal(t, short, identifier(short))
+
+	long1 := strings.Repeat("a", maxIdentifierLength) + "1"
+	long2 := strings.Repeat("a", maxIdentifierLength) + "2"
+	assert.NotEqual(t, identifier(long1), identifier(long2))
+	assert.Equal(t, identifier(long1), identifier(long1))
+
+	for _, name := range []string{long1, long2, strings.Repeat("я", 100), "a" + strings.Repeat("я", 100)} {
+		id := identifier(name)
+		assert.LessOrEqual(t, len(id), maxIdentifierLength, "%q", name)
+		assert.True(t, utf8.ValidString(id), "%q", name)
+		assert.Contains(t, id, "$", "%q", name)
+	}
+
+	assert.Equal(t, pgx.Identifier{"db", identifier(long1)}, TableIdentifier("db", long1))
+}
@@ -16,8 +16,13 @@ package pg
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
@@ -46,6 +51,15 @@ var (
 // Pool data struct for *pgxpool.Pool.
 type Pool struct {
 	*pgxpool.Pool
+
+	catalogM       sync.Mutex
+	catalogCreated bool
+
+	changeLogNotifierOnce sync.Once
+	changeLogNotifier     *changeLogNotifier
+
+	changeLogEnabledM sync.Mutex
+	changeLogEnabled  bool
 }
 
 // TableStats describes some statistics for a table.
@@ -177,7 +191,7 @@ func (p *Pool) checkConnection(ctx context.Context) error {
 	return nil
 }
 
-// Schemas returns a sorted list of FerretDB database / PostgreSQL schema names.
+// Schemas returns a sorted list of FerretDB database names of PostgreSQL schemas.
 func (pgPool *Pool) Schemas(ctx context.Context) ([]string, error) {
 	sql := "SELECT schema_name FROM information_schema.schemata ORDER BY schema_name"
 	rows, err := pgPool.Query(ctx, sql)
@@ -193,7 +207,7 @@ func (pgPool *Pool) Schemas(ctx context.Context) ([]string, error) {
 			return nil, lazyerrors.Error(err)
 		}
 
-		if strings.HasPrefix(name, "pg_") || name == "information_schema" {
+		if internalSchema(name) {
 			continue
 		}
 
@@ -203,13 +217,30 @@ func (pgPool *Pool) Schemas(ctx context.Context) ([]string, error) {
 		return nil, lazyerrors.Error(err)
 	}
 
-	return res, nil
+	return restoreNames(ctx, pgPool, "", res)
 }
 
-// Tables returns a sorted list of FerretDB collection / PostgreSQL table names.
+// Tables returns a sorted list of FerretDB collection names of PostgreSQL tables.
 func (pgPool *Pool) Tables(ctx context.Context, db string) ([]string, error) {
+	return tables(ctx, pgPool, db)
+}
+
+// querier is implemented by both *Pool and pgx.Tx.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// internalSchema returns true if PostgreSQL schema is used by PostgreSQL itself or by FerretDB internally
+// (for the catalog, the change log and sessions), so it is not a FerretDB database.
+func internalSchema(schema string) bool {
+	return strings.HasPrefix(schema, "pg_") || schema == "information_schema" || schema == CatalogSchema
+}
+
+// tables returns a sorted list of FerretDB collection names of PostgreSQL tables
+// using the given pool or transaction.
+func tables(ctx context.Context, q querier, db string) ([]string, error) {
 	sql := "SELECT table_name FROM information_schema.tables WHERE table_schema = $1 ORDER BY table_name"
-	rows, err := pgPool.Query(ctx, sql, db)
+	rows, err := q.Query(ctx, sql, identifier(db))
 	if err != nil {
 		return nil, lazyerrors.Error(err)
 	}
@@ -228,17 +259,62 @@ func (pgPool *Pool) Tables(ctx context.Context, db string) ([]string, error) {
 		return nil, lazyerrors.Error(err)
 	}
 
-	return res, nil
+	return restoreNames(ctx, q, db, res)
+}
+
+// restoreNames replaces PostgreSQL identifiers of schemas (if db is empty) or of the database's tables
+// with FerretDB names recorded in the catalog, and sorts them.
+func restoreNames(ctx context.Context, q querier, db string, ids []string) ([]string, error) {
+	names, err := catalogNames(ctx, q, db)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		if name, ok := names[id]; ok {
+			ids[i] = name
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// tableExists returns true if PostgreSQL table for FerretDB collection exists.
+func tableExists(ctx context.Context, tx pgx.Tx, db, collection string) (bool, error) {
+	var exists bool
+	sql := `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = $1 AND table_name = $2)`
+	if err := tx.QueryRow(ctx, sql, identifier(db), identifier(collection)).Scan(&exists); err != nil {
+		return false, lazyerrors.Error(err)
+	}
+
+	return exists, nil
 }
 
 // CreateSchema creates a new FerretDB database / PostgreSQL schema.
 //
-// It returns ErrAlreadyExist if schema already exist.
+// It returns ErrInvalidDatabaseName if the name is not valid, and ErrAlreadyExist if schema already exist.
 func (pgPool *Pool) CreateSchema(ctx context.Context, db string) error {
-	sql := `CREATE SCHEMA ` + pgx.Identifier{db}.Sanitize()
-	_, err := pgPool.Exec(ctx, sql)
+	if err := ValidateDatabaseName(db); err != nil {
+		return err
+	}
+
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		sql := `CREATE SCHEMA ` + SchemaIdentifier(db).Sanitize()
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
 
-	if e, ok := err.(*pgconn.PgError); ok && e.Code == pgerrcode.DuplicateSchema {
+		return insertName(ctx, tx, db, "")
+	})
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.DuplicateSchema {
 		return ErrAlreadyExist
 	}
 
@@ -247,12 +323,44 @@ func (pgPool *Pool) CreateSchema(ctx context.Context, db string) error {
 
 // DropSchema drops FerretDB database / PostgreSQL schema.
 //
-// It returns ErrNotExist if schema does not exist.
+// It returns ErrInvalidDatabaseName for internal schemas, and ErrNotExist if schema does not exist.
+//
+// Drops of all its tables and of the schema itself are recorded in the change log.
 func (pgPool *Pool) DropSchema(ctx context.Context, db string) error {
-	sql := `DROP SCHEMA ` + pgx.Identifier{db}.Sanitize() + ` CASCADE`
-	_, err := pgPool.Exec(ctx, sql)
+	if internalSchema(identifier(db)) {
+		return ErrInvalidDatabaseName
+	}
+
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
 
-	if e, ok := err.(*pgconn.PgError); ok && e.Code == pgerrcode.InvalidSchemaName {
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		tables, err := tables(ctx, tx, db)
+		if err != nil {
+			return err
+		}
+
+		sql := `DROP SCHEMA ` + SchemaIdentifier(db).Sanitize() + ` CASCADE`
+		if _, err = tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+
+		if err = deleteTableMetadata(ctx, tx, db, ""); err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			if err = insertChangeLog(ctx, tx, db, table); err != nil {
+				return err
+			}
+		}
+
+		return insertChangeLog(ctx, tx, db, "")
+	})
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.InvalidSchemaName {
 		return ErrNotExist
 	}
 
@@ -261,12 +369,65 @@ func (pgPool *Pool) DropSchema(ctx context.Context, db string) error {
 
 // CreateTable creates a new FerretDB collection / PostgreSQL jsonb table.
 //
-// It returns ErrAlreadyExist if table already exist.
+// It returns ErrInvalidCollectionName if the name is not valid, and ErrAlreadyExist if table already exist.
+//
+// Unique index on _id is created too, so duplicate _id values are rejected as in MongoDB.
+// Changes of documents are recorded in the change log by a trigger.
 func (pgPool *Pool) CreateTable(ctx context.Context, db, collection string) error {
-	sql := `CREATE TABLE ` + pgx.Identifier{db, collection}.Sanitize() + ` (_jsonb jsonb)`
-	_, err := pgPool.Exec(ctx, sql)
+	return pgPool.CreateTableWithOptions(ctx, db, collection, new(TableOptions))
+}
+
+// CreateTableWithOptions creates a new FerretDB collection / PostgreSQL jsonb table with the given options,
+// as CreateTable does.
+func (pgPool *Pool) CreateTableWithOptions(ctx context.Context, db, collection string, opts *TableOptions) error {
+	if err := ValidateCollectionName(db, collection); err != nil {
+		return err
+	}
+
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		if err := lockName(ctx, tx, db, collection); err != nil {
+			return err
+		}
+
+		exists, err := viewExists(ctx, tx, db, collection)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyExist
+		}
+
+		table := TableIdentifier(db, collection).Sanitize()
+		sql := `CREATE TABLE ` + table + ` (_jsonb jsonb)`
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
 
-	if e, ok := err.(*pgconn.PgError); ok && e.Code == pgerrcode.DuplicateTable {
+		sql = `CREATE UNIQUE INDEX ON ` + table + ` ((_jsonb->'_id'))`
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+
+		sql = `CREATE TRIGGER ` + pgx.Identifier{changeLogTable}.Sanitize() +
+			` AFTER INSERT OR UPDATE OR DELETE ON ` + table +
+			` FOR EACH ROW EXECUTE PROCEDURE ` + pgx.Identifier{CatalogSchema, changeLogTrigger}.Sanitize() + `()`
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+
+		if err := insertName(ctx, tx, db, collection); err != nil {
+			return err
+		}
+
+		return createTableOptions(ctx, tx, db, collection, opts)
+	})
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.DuplicateTable {
 		return ErrAlreadyExist
 	}
 
@@ -276,20 +437,225 @@ func (pgPool *Pool) CreateTable(ctx context.Context, db, collection string) erro
 // DropTable drops FerretDB collection / PostgreSQL table.
 //
 // It returns ErrNotExist is table does not exist.
+//
+// The drop is recorded in the change log.
 func (pgPool *Pool) DropTable(ctx context.Context, db, collection string) error {
-	// TODO probably not CASCADE
-	sql := `DROP TABLE ` + pgx.Identifier{db, collection}.Sanitize() + `CASCADE`
-	_, err := pgPool.Exec(ctx, sql)
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	err := pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		// TODO probably not CASCADE
+		sql := `DROP TABLE ` + TableIdentifier(db, collection).Sanitize() + `CASCADE`
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
 
-	if e, ok := err.(*pgconn.PgError); ok && e.Code == pgerrcode.UndefinedTable {
+		if err := deleteTableMetadata(ctx, tx, db, collection); err != nil {
+			return err
+		}
+
+		return insertChangeLog(ctx, tx, db, collection)
+	})
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.UndefinedTable {
 		return ErrNotExist
 	}
 
 	return err
 }
 
+// renameTmpTable is a temporary name of the table moved to another schema.
+//
+// It can't be used by a collection, as collection names and their identifiers can't start with '$'.
+const renameTmpTable = "$ferretdb_rename"
+
+// RenameTable renames FerretDB collection / PostgreSQL table,
+// possibly moving it to another FerretDB database / PostgreSQL schema that is created if needed.
+//
+// It returns ErrInvalidDatabaseName or ErrInvalidCollectionName if the target names are not valid,
+// and ErrNotExist if the table does not exist. If the target table or view exists,
+// it returns ErrAlreadyExist if dropTarget is false, or drops the target otherwise.
+// Catalog records of the table are moved in the same transaction.
+// The rename and the drop of the target are recorded in the change log.
+func (pgPool *Pool) RenameTable(ctx context.Context, db, collection, toDB, toCollection string, dropTarget bool) error {
+	if err := ValidateDatabaseName(toDB); err != nil {
+		return err
+	}
+
+	if err := ValidateCollectionName(toDB, toCollection); err != nil {
+		return err
+	}
+
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	return pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		// lock names in the same order to avoid deadlocks
+		names := [][2]string{{db, collection}, {toDB, toCollection}}
+		if db+"."+collection > toDB+"."+toCollection {
+			names[0], names[1] = names[1], names[0]
+		}
+		for _, n := range names {
+			if err := lockName(ctx, tx, n[0], n[1]); err != nil {
+				return err
+			}
+		}
+
+		exists, err := tableExists(ctx, tx, db, collection)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotExist
+		}
+
+		if err = dropRenameTarget(ctx, tx, toDB, toCollection, dropTarget); err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS `+SchemaIdentifier(toDB).Sanitize()); err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		if err = insertName(ctx, tx, toDB, ""); err != nil {
+			return err
+		}
+
+		table := TableIdentifier(db, collection).Sanitize()
+		if db == toDB {
+			sql := `ALTER TABLE ` + table + ` RENAME TO ` + pgx.Identifier{identifier(toCollection)}.Sanitize()
+			if _, err = tx.Exec(ctx, sql); err != nil {
+				return lazyerrors.Error(err)
+			}
+		} else {
+			if err = moveTable(ctx, tx, db, collection, toDB, toCollection); err != nil {
+				return err
+			}
+		}
+
+		for _, t := range []string{collectionsTable, ttlIndexesTable} {
+			sql := `UPDATE ` + pgx.Identifier{CatalogSchema, t}.Sanitize() +
+				` SET db = $3, collection = $4 WHERE db = $1 AND collection = $2`
+			if _, err = tx.Exec(ctx, sql, db, collection, toDB, toCollection); err != nil {
+				return lazyerrors.Error(err)
+			}
+		}
+
+		sql := `DELETE FROM ` + pgx.Identifier{CatalogSchema, namesTable}.Sanitize() + ` WHERE db = $1 AND collection = $2`
+		if _, err = tx.Exec(ctx, sql, db, collection); err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		if err = insertName(ctx, tx, toDB, toCollection); err != nil {
+			return err
+		}
+
+		return insertRenameChangeLog(ctx, tx, db, collection, toDB, toCollection)
+	})
+}
+
+// dropRenameTarget drops the existing target table or view of the rename if dropTarget is true,
+// or returns ErrAlreadyExist.
+func dropRenameTarget(ctx context.Context, tx pgx.Tx, db, collection string, dropTarget bool) error {
+	isTable, err := tableExists(ctx, tx, db, collection)
+	if err != nil {
+		return err
+	}
+
+	isView, err := viewExists(ctx, tx, db, collection)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !isTable && !isView:
+		return nil
+	case !dropTarget:
+		return ErrAlreadyExist
+	case isView:
+		sql := `DELETE FROM ` + pgx.Identifier{CatalogSchema, viewsTable}.Sanitize() + ` WHERE db = $1 AND collection = $2`
+		if _, err = tx.Exec(ctx, sql, db, collection); err != nil {
+			return lazyerrors.Error(err)
+		}
+		return nil
+	}
+
+	if _, err = tx.Exec(ctx, `DROP TABLE `+TableIdentifier(db, collection).Sanitize()+` CASCADE`); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	if err = deleteTableMetadata(ctx, tx, db, collection); err != nil {
+		return err
+	}
+
+	return insertChangeLog(ctx, tx, db, collection)
+}
+
+// moveTable moves the table to another schema with a new name.
+//
+// Indexes and sequences are moved with the table; they are renamed first,
+// so their names don't conflict with names of objects in the target schema.
+func moveTable(ctx context.Context, tx pgx.Tx, db, collection, toDB, toCollection string) error {
+	sql := `SELECT DISTINCT c.oid, c.relname, c.relkind::text FROM pg_class AS c` +
+		` JOIN pg_depend AS d ON d.objid = c.oid` +
+		` WHERE d.refobjid = $1::regclass AND c.relkind IN ('i', 'S')`
+	rows, err := tx.Query(ctx, sql, TableIdentifier(db, collection).Sanitize())
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	var renames []string
+	for rows.Next() {
+		var oid uint32
+		var name string
+		var kind string
+		if err = rows.Scan(&oid, &name, &kind); err != nil {
+			rows.Close()
+			return lazyerrors.Error(err)
+		}
+
+		objectType := "INDEX"
+		if kind == "S" {
+			objectType = "SEQUENCE"
+		}
+
+		// object IDs are unique in the whole database
+		newName := "ferretdb_" + strconv.FormatUint(uint64(oid), 10)
+		renames = append(renames, `ALTER `+objectType+` `+pgx.Identifier{identifier(db), name}.Sanitize()+
+			` RENAME TO `+pgx.Identifier{newName}.Sanitize())
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	for _, sql := range renames {
+		if _, err = tx.Exec(ctx, sql); err != nil {
+			return lazyerrors.Error(err)
+		}
+	}
+
+	schema, toSchema := identifier(db), identifier(toDB)
+	tmp := pgx.Identifier{renameTmpTable}.Sanitize()
+	for _, sql := range []string{
+		`ALTER TABLE ` + TableIdentifier(db, collection).Sanitize() + ` RENAME TO ` + tmp,
+		`ALTER TABLE ` + pgx.Identifier{schema, renameTmpTable}.Sanitize() + ` SET SCHEMA ` + pgx.Identifier{toSchema}.Sanitize(),
+		`ALTER TABLE ` + pgx.Identifier{toSchema, renameTmpTable}.Sanitize() +
+			` RENAME TO ` + pgx.Identifier{identifier(toCollection)}.Sanitize(),
+	} {
+		if _, err = tx.Exec(ctx, sql); err != nil {
+			return lazyerrors.Error(err)
+		}
+	}
+
+	return nil
+}
+
 // TableStats returns a set of statistics for a table.
-func (pgPool *Pool) TableStats(ctx context.Context, db, table string) (*TableStats, error) {
+func (pgPool *Pool) TableStats(ctx context.Context, db, collection string) (*TableStats, error) {
 	res := new(TableStats)
 	sql := `
     SELECT table_name, table_type,
@@ -304,12 +670,14 @@ func (pgPool *Pool) TableStats(ctx context.Context, db, table string) (*TableSta
      WHERE t.table_schema = $1
        AND t.table_name = $2`
 
-	err := pgPool.QueryRow(ctx, sql, db, table).
+	err := pgPool.QueryRow(ctx, sql, identifier(db), identifier(collection)).
 		Scan(&res.Table, &res.TableType, &res.SizeTotal, &res.SizeIndexes, &res.SizeTable, &res.Rows)
 	if err != nil {
 		return nil, lazyerrors.Error(err)
 	}
 
+	res.Table = collection
+
 	return res, nil
 }
 
@@ -333,7 +701,7 @@ func (pgPool *Pool) DBStats(ctx context.Context, db string) (*DBStats, error) {
      WHERE t.table_schema = $1`
 
 	res.Name = db
-	err := pgPool.QueryRow(ctx, sql, db).
+	err := pgPool.QueryRow(ctx, sql, identifier(db)).
 		Scan(&res.CountTables, &res.CountRows, &res.SizeTotal, &res.SizeIndexes, &res.SizeSchema, &res.CountIndexes)
 	if err != nil {
 		return nil, lazyerrors.Error(err)
@@ -341,3 +709,104 @@ func (pgPool *Pool) DBStats(ctx context.Context, db string) (*DBStats, error) {
 
 	return res, nil
 }
+
+// DatabaseSize returns the total size of FerretDB database's tables in bytes, including indexes and TOAST data.
+//
+// It returns 0 if the database does not exist.
+func (pgPool *Pool) DatabaseSize(ctx context.Context, db string) (int64, error) {
+	sql := `
+    SELECT COALESCE(SUM(pg_total_relation_size(c.oid)), 0)::bigint
+      FROM pg_class AS c
+      JOIN pg_namespace AS n ON n.oid = c.relnamespace
+     WHERE n.nspname = $1
+       AND c.relkind = 'r'`
+
+	var size int64
+	if err := pgPool.QueryRow(ctx, sql, identifier(db)).Scan(&size); err != nil {
+		return 0, lazyerrors.Error(err)
+	}
+
+	return size, nil
+}
+
+// EstimatedRows returns the planner's estimate of the number of rows in the table.
+//
+// It returns -1 if the table does not exist or was never analyzed or vacuumed,
+// so there is no estimate, and the exact count should be used.
+func (pgPool *Pool) EstimatedRows(ctx context.Context, db, collection string) (int64, error) {
+	sql := `
+    SELECT c.reltuples::bigint
+      FROM pg_class AS c
+      JOIN pg_namespace AS n ON n.oid = c.relnamespace
+     WHERE n.nspname = $1
+       AND c.relname = $2
+       AND c.relkind = 'r'`
+
+	var rows int64
+	err := pgPool.QueryRow(ctx, sql, identifier(db), identifier(collection)).Scan(&rows)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return -1, nil
+	case err != nil:
+		return 0, lazyerrors.Error(err)
+	case rows <= 0:
+		// -1 means no estimate since PostgreSQL 14, and 0 is used for that by older versions
+		return -1, nil
+	default:
+		return rows, nil
+	}
+}
+
+// InTransaction uses a transaction to run given function.
+//
+// The transaction is committed if the function returns nil, and rolled back otherwise.
+// The function's error is returned as is, so callers can check it with errors.As.
+func (pgPool *Pool) InTransaction(ctx context.Context, f func(pgx.Tx) error) (err error) {
+	var tx pgx.Tx
+	if tx, err = pgPool.Begin(ctx); err != nil {
+		err = lazyerrors.Error(err)
+		return
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rerr := tx.Rollback(ctx); rerr != nil && rerr != pgx.ErrTxClosed {
+			err = lazyerrors.Errorf("%w (rollback error: %s)", err, rerr)
+		}
+	}()
+
+	if err = f(tx); err != nil {
+		return
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		err = lazyerrors.Error(err)
+	}
+
+	return
+}
+
+// SetStatementTimeout sets the statement timeout for the rest of the given transaction.
+//
+// Zero or negative duration means no timeout.
+func SetStatementTimeout(ctx context.Context, tx pgx.Tx, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	// SET does not accept placeholders
+	sql := `SET LOCAL statement_timeout = ` + strconv.FormatInt(d.Milliseconds(), 10)
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	return nil
+}
+
+// IsStatementTimeout returns true if error was caused by the statement timeout.
+func IsStatementTimeout(err error) bool {
+	var e *pgconn.PgError
+	return errors.As(err, &e) && e.Code == pgerrcode.QueryCanceled
+}
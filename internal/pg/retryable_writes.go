@@ -0,0 +1,126 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// RetryableWrite is a record of a single executed statement of a retryable write.
+type RetryableWrite struct {
+	TxnNumber int64
+	StmtID    int32
+	N         int64
+	NModified int64
+}
+
+// RetryableWrites locks the given session for the rest of the transaction and returns
+// records of its executed statements with txnNumber greater than or equal to the given one.
+//
+// Concurrent retries of the same write wait for each other, so they can't both execute it.
+func (pgPool *Pool) RetryableWrites(ctx context.Context, tx pgx.Tx, lsid [16]byte, txnNumber int64) ([]RetryableWrite, error) {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1::uuid::text, 0))`, lsid); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	sql := `SELECT txn_number, stmt_id, n, n_modified FROM ` +
+		pgx.Identifier{CatalogSchema, retryableWritesTable}.Sanitize() +
+		` WHERE lsid = $1 AND txn_number >= $2 ORDER BY txn_number, stmt_id`
+	rows, err := tx.Query(ctx, sql, lsid, txnNumber)
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	var res []RetryableWrite
+	for rows.Next() {
+		var rw RetryableWrite
+		if err = rows.Scan(&rw.TxnNumber, &rw.StmtID, &rw.N, &rw.NModified); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		res = append(res, rw)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// InsertRetryableWrites records executed statements of a retryable write.
+//
+// It should be called in the same transaction (or savepoint) as statements themselves,
+// after RetryableWrites.
+func (pgPool *Pool) InsertRetryableWrites(ctx context.Context, tx pgx.Tx, lsid [16]byte, rws ...RetryableWrite) error {
+	if len(rws) == 0 {
+		return nil
+	}
+
+	var placeholder Placeholder
+	lsidPlaceholder := placeholder.Next()
+	values := make([]string, len(rws))
+	args := make([]any, 0, 1+4*len(rws))
+	args = append(args, lsid)
+	for i, rw := range rws {
+		values[i] = fmt.Sprintf(
+			"(%s, %s, %s, %s, %s)",
+			lsidPlaceholder, placeholder.Next(), placeholder.Next(), placeholder.Next(), placeholder.Next(),
+		)
+		args = append(args, rw.TxnNumber, rw.StmtID, rw.N, rw.NModified)
+	}
+
+	sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, retryableWritesTable}.Sanitize() +
+		` (lsid, txn_number, stmt_id, n, n_modified) VALUES ` + strings.Join(values, ", ")
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	return nil
+}
+
+// DeleteRetryableWrites deletes records of retryable writes executed before the given time.
+//
+// It returns the number of deleted records.
+func (pgPool *Pool) DeleteRetryableWrites(ctx context.Context, before time.Time) (int64, error) {
+	sql := `DELETE FROM ` + pgx.Identifier{CatalogSchema, retryableWritesTable}.Sanitize() + ` WHERE created_at < $1`
+	tag, err := pgPool.Exec(ctx, sql, before)
+
+	// nothing to delete if there were no retryable writes yet
+	var e *pgconn.PgError
+	if errors.As(err, &e) && (e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, lazyerrors.Error(err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// TableOptions represents FerretDB collection options stored in the catalog.
+type TableOptions struct {
+	Capped bool  `json:"capped,omitempty"`
+	Size   int64 `json:"size,omitempty"` // maximal total size of capped collection's documents in bytes
+	Max    int64 `json:"max,omitempty"`  // maximal number of capped collection's documents; 0 means no limit
+
+	Validator        json.RawMessage `json:"validator,omitempty"`        // fjson-encoded validator document
+	ValidationLevel  string          `json:"validationLevel,omitempty"`  // "off", "strict" or "moderate"; empty means "strict"
+	ValidationAction string          `json:"validationAction,omitempty"` // "error" or "warn"; empty means "error"
+}
+
+// TableOptions returns options of the given FerretDB collection / PostgreSQL table.
+//
+// Tables created without options, or before options were stored, have zero options.
+// The catalog is not created there, so it works for read-only users.
+func (pgPool *Pool) TableOptions(ctx context.Context, db, collection string) (*TableOptions, error) {
+	var b []byte
+	sql := `SELECT options FROM ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() +
+		` WHERE db = $1 AND collection = $2`
+	err := pgPool.QueryRow(ctx, sql, db, collection).Scan(&b)
+
+	var e *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) ||
+		(errors.As(err, &e) && (e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName)) {
+		return new(TableOptions), nil
+	}
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	var res TableOptions
+	if err = json.Unmarshal(b, &res); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return &res, nil
+}
+
+// UpdateTableOptions changes options of the given FerretDB collection / PostgreSQL table
+// with the given function in a transaction.
+//
+// It returns ErrNotExist if table does not exist, or the function's error.
+func (pgPool *Pool) UpdateTableOptions(
+	ctx context.Context, db, collection string, update func(opts *TableOptions) error,
+) error {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	return pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		if err := lockName(ctx, tx, db, collection); err != nil {
+			return err
+		}
+
+		exists, err := tableExists(ctx, tx, db, collection)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotExist
+		}
+
+		var opts TableOptions
+		var b []byte
+		sql := `SELECT options FROM ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() +
+			` WHERE db = $1 AND collection = $2 FOR UPDATE`
+		err = tx.QueryRow(ctx, sql, db, collection).Scan(&b)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			return lazyerrors.Error(err)
+		default:
+			if err = json.Unmarshal(b, &opts); err != nil {
+				return lazyerrors.Error(err)
+			}
+		}
+
+		if err = update(&opts); err != nil {
+			return err
+		}
+
+		if b, err = json.Marshal(&opts); err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		sql = `INSERT INTO ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() +
+			` (db, collection, options) VALUES ($1, $2, $3)` +
+			` ON CONFLICT (db, collection) DO UPDATE SET options = EXCLUDED.options`
+		if _, err = tx.Exec(ctx, sql, db, collection, b); err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		return nil
+	})
+}
+
+// createTableOptions stores options of the new table and creates objects they require.
+func createTableOptions(ctx context.Context, tx pgx.Tx, db, collection string, opts *TableOptions) error {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, collectionsTable}.Sanitize() +
+		` (db, collection, options) VALUES ($1, $2, $3)`
+	if _, err = tx.Exec(ctx, sql, db, collection, b); err != nil {
+		return err
+	}
+
+	if !opts.Capped {
+		return nil
+	}
+
+	// documents are evicted and returned to tailable cursors in the insertion order
+	table := TableIdentifier(db, collection).Sanitize()
+	if _, err = tx.Exec(ctx, `ALTER TABLE `+table+` ADD COLUMN _seq bigserial NOT NULL`); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, `CREATE UNIQUE INDEX ON `+table+` (_seq)`); err != nil {
+		return err
+	}
+
+	sql = `CREATE TRIGGER capped_before BEFORE INSERT OR UPDATE ON ` + table +
+		` FOR EACH STATEMENT EXECUTE PROCEDURE ` + pgx.Identifier{CatalogSchema, cappedTrigger}.Sanitize() + `()`
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	// limits are read from the options stored above
+	if _, err = tx.Exec(ctx, cappedAfterTriggersSQL(table)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// deleteTableMetadata deletes catalog records of the given table (options, TTL indexes and names),
+// or of all database's tables, views and of the database itself if collection is empty.
+func deleteTableMetadata(ctx context.Context, tx pgx.Tx, db, collection string) error {
+	tables := []string{collectionsTable, ttlIndexesTable, namesTable}
+	if collection == "" {
+		tables = append(tables, viewsTable)
+	}
+
+	for _, t := range tables {
+		sql := `DELETE FROM ` + pgx.Identifier{CatalogSchema, t}.Sanitize() + ` WHERE db = $1`
+		args := []any{db}
+		if collection != "" {
+			sql += ` AND collection = $2`
+			args = append(args, collection)
+		}
+
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return lazyerrors.Error(err)
+		}
+	}
+
+	return nil
+}
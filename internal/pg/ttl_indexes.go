@@ -0,0 +1,181 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// ErrIndexOptionsConflict indicates that the index with the same name but different key or options already exists.
+var ErrIndexOptionsConflict = errors.New("index options conflict")
+
+// TTLIndex represents TTL index stored in the catalog.
+type TTLIndex struct {
+	DB          string
+	Collection  string
+	Name        string
+	Key         string // dot notation path of date field
+	ExpireAfter time.Duration
+	Hidden      bool // hidden from the query planner in MongoDB; FerretDB does not choose indexes itself
+}
+
+// ttlExpression returns SQL expression of the TTL index: milliseconds of date at key path, or NULL for other values.
+//
+// Arrays of dates are not supported yet.
+func ttlExpression(key string) string {
+	path := "_jsonb"
+	for _, k := range strings.Split(key, ".") {
+		path += "->" + quoteLiteral(k)
+	}
+
+	return `(CASE WHEN jsonb_typeof(` + path + `->'$d') = 'number' THEN (` + path + `->>'$d')::bigint END)`
+}
+
+// quoteLiteral returns SQL string literal for the given string.
+func quoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// CreateTTLIndex stores TTL index in the catalog and creates PostgreSQL index on its date path.
+//
+// It returns ErrIndexOptionsConflict if TTL index with the same name but different key or expiration exists,
+// and does nothing if the same index exists.
+func (pgPool *Pool) CreateTTLIndex(ctx context.Context, idx *TTLIndex) error {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	return pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		var key string
+		var expireAfterSeconds int64
+		sql := `SELECT key, expire_after_seconds FROM ` + pgx.Identifier{CatalogSchema, ttlIndexesTable}.Sanitize() +
+			` WHERE db = $1 AND collection = $2 AND name = $3 FOR UPDATE`
+		err := tx.QueryRow(ctx, sql, idx.DB, idx.Collection, idx.Name).Scan(&key, &expireAfterSeconds)
+
+		switch {
+		case err == nil:
+			if key != idx.Key || time.Duration(expireAfterSeconds)*time.Second != idx.ExpireAfter {
+				return ErrIndexOptionsConflict
+			}
+			return nil
+
+		case errors.Is(err, pgx.ErrNoRows):
+			// create below
+
+		default:
+			return lazyerrors.Error(err)
+		}
+
+		sql = `INSERT INTO ` + pgx.Identifier{CatalogSchema, ttlIndexesTable}.Sanitize() +
+			` (db, collection, name, key, expire_after_seconds) VALUES ($1, $2, $3, $4, $5)`
+		args := []any{idx.DB, idx.Collection, idx.Name, idx.Key, int64(idx.ExpireAfter / time.Second)}
+		if _, err = tx.Exec(ctx, sql, args...); err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		// PostgreSQL index names are unique within schema, so let PostgreSQL choose it
+		sql = `CREATE INDEX ON ` + TableIdentifier(idx.DB, idx.Collection).Sanitize() + ` (` + ttlExpression(idx.Key) + `)`
+		if _, err = tx.Exec(ctx, sql); err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		return nil
+	})
+}
+
+// TTLIndexes returns all TTL indexes.
+//
+// The catalog is not created there, so it works for read-only users.
+func (pgPool *Pool) TTLIndexes(ctx context.Context) ([]TTLIndex, error) {
+	sql := `SELECT db, collection, name, key, expire_after_seconds, hidden FROM ` +
+		pgx.Identifier{CatalogSchema, ttlIndexesTable}.Sanitize() + ` ORDER BY db, collection, name`
+	rows, err := pgPool.Query(ctx, sql)
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && (e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	var res []TTLIndex
+	for rows.Next() {
+		var idx TTLIndex
+		var expireAfterSeconds int64
+		if err = rows.Scan(&idx.DB, &idx.Collection, &idx.Name, &idx.Key, &expireAfterSeconds, &idx.Hidden); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		idx.ExpireAfter = time.Duration(expireAfterSeconds) * time.Second
+		res = append(res, idx)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// UpdateTTLIndex changes expiration time and hidden flag of the stored TTL index with the same name.
+//
+// It returns ErrNotExist if the index does not exist.
+func (pgPool *Pool) UpdateTTLIndex(ctx context.Context, idx *TTLIndex) error {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	sql := `UPDATE ` + pgx.Identifier{CatalogSchema, ttlIndexesTable}.Sanitize() +
+		` SET expire_after_seconds = $4, hidden = $5 WHERE db = $1 AND collection = $2 AND name = $3`
+	args := []any{idx.DB, idx.Collection, idx.Name, int64(idx.ExpireAfter / time.Second), idx.Hidden}
+	tag, err := pgPool.Exec(ctx, sql, args...)
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotExist
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes up to limit documents with dates at TTL index's path
+// that are older than its expiration time before now.
+//
+// It returns the number of deleted documents.
+func (pgPool *Pool) DeleteExpired(ctx context.Context, idx *TTLIndex, now time.Time, limit int64) (int64, error) {
+	table := TableIdentifier(idx.DB, idx.Collection).Sanitize()
+
+	// the same expression as in the index, so it is used
+	sql := `DELETE FROM ` + table + ` WHERE ctid IN (SELECT ctid FROM ` + table +
+		` WHERE ` + ttlExpression(idx.Key) + ` < $1 LIMIT $2)`
+	tag, err := pgPool.Exec(ctx, sql, now.Add(-idx.ExpireAfter).UnixMilli(), limit)
+	if err != nil {
+		return 0, lazyerrors.Error(err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// View represents FerretDB read-only view stored in the catalog.
+type View struct {
+	DB       string
+	Name     string
+	ViewOn   string // collection or view name in the same database
+	Pipeline []byte // fjson-encoded array of aggregation pipeline stages
+}
+
+// lockName takes a transaction-level lock of collection or view name,
+// so they are not created with the same name concurrently.
+func lockName(ctx context.Context, tx pgx.Tx, db, name string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, db+"."+name); err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	return nil
+}
+
+// viewExists returns true if the view with the given name exists.
+func viewExists(ctx context.Context, tx pgx.Tx, db, name string) (bool, error) {
+	var exists bool
+	sql := `SELECT EXISTS (SELECT 1 FROM ` + pgx.Identifier{CatalogSchema, viewsTable}.Sanitize() +
+		` WHERE db = $1 AND collection = $2)`
+	if err := tx.QueryRow(ctx, sql, db, name).Scan(&exists); err != nil {
+		return false, lazyerrors.Error(err)
+	}
+
+	return exists, nil
+}
+
+// CreateView stores the view in the catalog.
+//
+// It returns ErrInvalidCollectionName if the name is not valid,
+// and ErrAlreadyExist if the view or the table with the same name already exist.
+func (pgPool *Pool) CreateView(ctx context.Context, v *View) error {
+	if err := ValidateCollectionName(v.DB, v.Name); err != nil {
+		return err
+	}
+
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	return pgPool.InTransaction(ctx, func(tx pgx.Tx) error {
+		if err := lockName(ctx, tx, v.DB, v.Name); err != nil {
+			return err
+		}
+
+		exists, err := tableExists(ctx, tx, v.DB, v.Name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrAlreadyExist
+		}
+
+		sql := `INSERT INTO ` + pgx.Identifier{CatalogSchema, viewsTable}.Sanitize() +
+			` (db, collection, view_on, pipeline) VALUES ($1, $2, $3, $4)`
+		_, err = tx.Exec(ctx, sql, v.DB, v.Name, v.ViewOn, v.Pipeline)
+
+		var e *pgconn.PgError
+		if errors.As(err, &e) && e.Code == pgerrcode.UniqueViolation {
+			return ErrAlreadyExist
+		}
+		if err != nil {
+			return lazyerrors.Error(err)
+		}
+
+		return nil
+	})
+}
+
+// GetView returns the view with the given name, or nil if it does not exist.
+//
+// The catalog is not created there, so it works for read-only users.
+func (pgPool *Pool) GetView(ctx context.Context, db, name string) (*View, error) {
+	views, err := pgPool.views(ctx, db, name)
+	if err != nil || len(views) == 0 {
+		return nil, err
+	}
+
+	return &views[0], nil
+}
+
+// Views returns all views of the database sorted by name.
+//
+// The catalog is not created there, so it works for read-only users.
+func (pgPool *Pool) Views(ctx context.Context, db string) ([]View, error) {
+	return pgPool.views(ctx, db, "")
+}
+
+// views returns views of the database with the given name, or all of them if name is empty.
+func (pgPool *Pool) views(ctx context.Context, db, name string) ([]View, error) {
+	sql := `SELECT db, collection, view_on, pipeline FROM ` + pgx.Identifier{CatalogSchema, viewsTable}.Sanitize() +
+		` WHERE db = $1`
+	args := []any{db}
+	if name != "" {
+		sql += ` AND collection = $2`
+		args = append(args, name)
+	}
+	sql += ` ORDER BY collection`
+
+	rows, err := pgPool.Query(ctx, sql, args...)
+
+	var e *pgconn.PgError
+	if errors.As(err, &e) && (e.Code == pgerrcode.UndefinedTable || e.Code == pgerrcode.InvalidSchemaName) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+	defer rows.Close()
+
+	var res []View
+	for rows.Next() {
+		var v View
+		if err = rows.Scan(&v.DB, &v.Name, &v.ViewOn, &v.Pipeline); err != nil {
+			return nil, lazyerrors.Error(err)
+		}
+
+		res = append(res, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, lazyerrors.Error(err)
+	}
+
+	return res, nil
+}
+
+// DropView deletes the view from the catalog.
+//
+// It returns ErrNotExist if the view does not exist.
+func (pgPool *Pool) DropView(ctx context.Context, db, name string) error {
+	if err := pgPool.createCatalog(ctx); err != nil {
+		return err
+	}
+
+	sql := `DELETE FROM ` + pgx.Identifier{CatalogSchema, viewsTable}.Sanitize() + ` WHERE db = $1 AND collection = $2`
+	tag, err := pgPool.Exec(ctx, sql, db, name)
+	if err != nil {
+		return lazyerrors.Error(err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotExist
+	}
+
+	return nil
+}
@@ -0,0 +1,367 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+//go:generate ../../bin/stringer -linecomment -type CompareResult
+
+// CompareResult represents the result of a comparison.
+type CompareResult int8
+
+const (
+	Equal        CompareResult = 0  // ==
+	Less         CompareResult = -1 // <
+	Greater      CompareResult = 1  // >
+	Incomparable CompareResult = 2  // ≹
+)
+
+// typeOrder represents the BSON comparison order of types.
+//
+// See https://docs.mongodb.com/manual/reference/bson-type-comparison-order/.
+type typeOrder int8
+
+const (
+	orderMinKey typeOrder = iota + 1
+	orderUndefined
+	orderNull
+	orderNumbers
+	orderString
+	orderDocument
+	orderArray
+	orderBinary
+	orderObjectID
+	orderBool
+	orderDateTime
+	orderTimestamp
+	orderRegex
+	orderDBPointer
+	orderJavaScript
+	orderJavaScriptScope
+	orderMaxKey
+)
+
+// detectTypeOrder returns the comparison order of the given value's type.
+func detectTypeOrder(v any) typeOrder {
+	switch v.(type) {
+	case MinKey:
+		return orderMinKey
+	case Undefined:
+		return orderUndefined
+	case nil:
+		return orderNull
+	case float64, int32, int64, Decimal128:
+		return orderNumbers
+	case string, CString, Symbol:
+		return orderString
+	case Document:
+		return orderDocument
+	case *Array:
+		return orderArray
+	case Binary:
+		return orderBinary
+	case ObjectID:
+		return orderObjectID
+	case bool:
+		return orderBool
+	case time.Time:
+		return orderDateTime
+	case Timestamp:
+		return orderTimestamp
+	case Regex:
+		return orderRegex
+	case DBPointer:
+		return orderDBPointer
+	case JavaScript:
+		return orderJavaScript
+	case JavaScriptScope:
+		return orderJavaScriptScope
+	case MaxKey:
+		return orderMaxKey
+	default:
+		panic(fmt.Sprintf("types.detectTypeOrder: unexpected type %T", v))
+	}
+}
+
+// Compare compares two values of the same type class (for example, any two numbers).
+//
+// It returns Incomparable for values of different type classes, as MongoDB query operators
+// such as $lt only match values of the same type class (type bracketing).
+// MinKey and MaxKey are comparable with any value, as they are used as range bounds.
+// Use CompareOrder for a total order that is used for sorting.
+func Compare(a, b any) CompareResult {
+	aOrder, bOrder := detectTypeOrder(a), detectTypeOrder(b)
+	switch {
+	case aOrder == bOrder:
+	case aOrder == orderMinKey, aOrder == orderMaxKey, bOrder == orderMinKey, bOrder == orderMaxKey:
+	default:
+		return Incomparable
+	}
+
+	return CompareOrder(a, b)
+}
+
+// CompareOrder compares two values using BSON comparison order.
+//
+// Values of different types are ordered by their type class, so the result is never Incomparable.
+func CompareOrder(a, b any) CompareResult {
+	aOrder, bOrder := detectTypeOrder(a), detectTypeOrder(b)
+	if aOrder != bOrder {
+		return compareOrdered(aOrder, bOrder)
+	}
+
+	switch a := a.(type) {
+	case MinKey, Undefined, nil, MaxKey:
+		return Equal
+	case float64, int32, int64, Decimal128:
+		return compareNumbers(a, b)
+	case string:
+		return compareStrings(a, b)
+	case CString:
+		return compareStrings(string(a), b)
+	case Symbol:
+		return compareStrings(string(a), b)
+	case Document:
+		return compareDocuments(a, b.(Document))
+	case *Array:
+		return compareArrays(a, b.(*Array))
+	case Binary:
+		b := b.(Binary)
+		if c := compareOrdered(len(a.B), len(b.B)); c != Equal {
+			return c
+		}
+		if c := compareOrdered(a.Subtype, b.Subtype); c != Equal {
+			return c
+		}
+		return CompareResult(bytes.Compare(a.B, b.B))
+	case ObjectID:
+		b := b.(ObjectID)
+		return CompareResult(bytes.Compare(a[:], b[:]))
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return Equal
+		case b:
+			return Less
+		default:
+			return Greater
+		}
+	case time.Time:
+		return compareOrdered(a.UnixMilli(), b.(time.Time).UnixMilli())
+	case Timestamp:
+		return compareOrdered(a, b.(Timestamp))
+	case Regex:
+		b := b.(Regex)
+		if a == b {
+			return Equal
+		}
+		if c := compareOrdered(a.Pattern, b.Pattern); c != Equal {
+			return c
+		}
+		return compareOrdered(a.Options, b.Options)
+	case DBPointer:
+		b := b.(DBPointer)
+		if c := compareOrdered(a.Namespace, b.Namespace); c != Equal {
+			return c
+		}
+		return CompareResult(bytes.Compare(a.ID[:], b.ID[:]))
+	case JavaScript:
+		return compareOrdered(a, b.(JavaScript))
+	case JavaScriptScope:
+		b := b.(JavaScriptScope)
+		if c := compareOrdered(a.Code, b.Code); c != Equal {
+			return c
+		}
+		return compareDocuments(a.Scope, b.Scope)
+	default:
+		panic(fmt.Sprintf("types.CompareOrder: unexpected type %T", a))
+	}
+}
+
+// ordered is a constraint for types that support < and > operators.
+type ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~float64 | ~string
+}
+
+// compareOrdered compares two values of ordered type.
+func compareOrdered[T ordered](a, b T) CompareResult {
+	switch {
+	case a < b:
+		return Less
+	case a > b:
+		return Greater
+	default:
+		return Equal
+	}
+}
+
+// compareStrings compares string with string, CString or Symbol.
+func compareStrings(a string, b any) CompareResult {
+	switch b := b.(type) {
+	case string:
+		return CompareResult(strings.Compare(a, b))
+	case CString:
+		return CompareResult(strings.Compare(a, string(b)))
+	case Symbol:
+		return CompareResult(strings.Compare(a, string(b)))
+	default:
+		panic(fmt.Sprintf("types.compareStrings: unexpected type %T", b))
+	}
+}
+
+// compareNumbers compares two numbers of any numeric types.
+//
+// NaN is equal to NaN and less than any other number, as in MongoDB.
+func compareNumbers(a, b any) CompareResult {
+	if aNaN, bNaN := isNaN(a), isNaN(b); aNaN || bNaN {
+		return compareOrdered(boolToInt(!aNaN), boolToInt(!bNaN))
+	}
+
+	if aInf, bInf := isInf(a), isInf(b); aInf != 0 || bInf != 0 {
+		return compareOrdered(aInf, bInf)
+	}
+
+	ai, aIsInt := asInt64(a)
+	bi, bIsInt := asInt64(b)
+	if aIsInt && bIsInt {
+		return compareOrdered(ai, bi)
+	}
+
+	_, aIsDecimal := a.(Decimal128)
+	_, bIsDecimal := b.(Decimal128)
+	if aIsDecimal || bIsDecimal {
+		return CompareResult(asBigRat(a).Cmp(asBigRat(b)))
+	}
+
+	// use exact comparison for big int64 values that can't be represented as float64
+	return CompareResult(asBigFloat(a).Cmp(asBigFloat(b)))
+}
+
+// isNaN returns true if the number is float64 or Decimal128 NaN.
+func isNaN(v any) bool {
+	switch v := v.(type) {
+	case float64:
+		return math.IsNaN(v)
+	case Decimal128:
+		return v.IsNaN()
+	default:
+		return false
+	}
+}
+
+// isInf returns 1 for positive infinity, -1 for negative infinity, and 0 for other numbers.
+func isInf(v any) int {
+	switch v := v.(type) {
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return 1
+		case math.IsInf(v, -1):
+			return -1
+		}
+	case Decimal128:
+		return v.IsInf()
+	}
+
+	return 0
+}
+
+// boolToInt returns 1 for true and 0 for false.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// asInt64 returns integer number as int64.
+func asInt64(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// asBigFloat returns finite number other than Decimal128 as *big.Float.
+func asBigFloat(v any) *big.Float {
+	switch v := v.(type) {
+	case float64:
+		return big.NewFloat(v)
+	case int32:
+		return new(big.Float).SetInt64(int64(v))
+	case int64:
+		return new(big.Float).SetInt64(v)
+	default:
+		panic(fmt.Sprintf("types.asBigFloat: unexpected type %T", v))
+	}
+}
+
+// asBigRat returns finite number as *big.Rat.
+func asBigRat(v any) *big.Rat {
+	switch v := v.(type) {
+	case float64:
+		return new(big.Rat).SetFloat64(v)
+	case int32:
+		return new(big.Rat).SetInt64(int64(v))
+	case int64:
+		return new(big.Rat).SetInt64(v)
+	case Decimal128:
+		return v.Rat()
+	default:
+		panic(fmt.Sprintf("types.asBigRat: unexpected type %T", v))
+	}
+}
+
+// compareDocuments compares documents field by field: first by key, then by value.
+func compareDocuments(a, b Document) CompareResult {
+	aKeys, bKeys := a.Keys(), b.Keys()
+	for i := 0; i < len(aKeys) && i < len(bKeys); i++ {
+		aV, bV := a.m[aKeys[i]], b.m[bKeys[i]]
+		if c := compareOrdered(detectTypeOrder(aV), detectTypeOrder(bV)); c != Equal {
+			return c
+		}
+		if c := compareOrdered(aKeys[i], bKeys[i]); c != Equal {
+			return c
+		}
+		if c := CompareOrder(aV, bV); c != Equal {
+			return c
+		}
+	}
+
+	return compareOrdered(len(aKeys), len(bKeys))
+}
+
+// compareArrays compares arrays element by element.
+func compareArrays(a, b *Array) CompareResult {
+	for i := 0; i < a.Len() && i < b.Len(); i++ {
+		if c := CompareOrder(a.s[i], b.s[i]); c != Equal {
+			return c
+		}
+	}
+
+	return compareOrdered(a.Len(), b.Len())
+}
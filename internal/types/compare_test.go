@@ -0,0 +1,188 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		a        any
+		b        any
+		expected CompareResult
+	}{
+		"Int32Int64": {
+			a:        int32(42),
+			b:        int64(42),
+			expected: Equal,
+		},
+		"Int64Double": {
+			a:        int64(41),
+			b:        42.5,
+			expected: Less,
+		},
+		"BigInt64Double": {
+			a:        int64(1<<53 + 1),
+			b:        float64(1 << 53),
+			expected: Greater,
+		},
+		"NaN": {
+			a:        math.NaN(),
+			b:        math.Inf(-1),
+			expected: Less,
+		},
+		"NaNNaN": {
+			a:        math.NaN(),
+			b:        math.NaN(),
+			expected: Equal,
+		},
+		"DecimalInt32": {
+			a:        MustParseDecimal128("42.00"),
+			b:        int32(42),
+			expected: Equal,
+		},
+		"DecimalDouble": {
+			a:        MustParseDecimal128("0.1"),
+			b:        0.1,
+			expected: Less, // 0.1 double is slightly greater than 0.1
+		},
+		"DecimalInf": {
+			a:        Decimal128Inf(1),
+			b:        math.Inf(1),
+			expected: Equal,
+		},
+		"DecimalNaN": {
+			a:        Decimal128NaN(),
+			b:        MustParseDecimal128("-1E+6144"),
+			expected: Less,
+		},
+		"StringCString": {
+			a:        "foo",
+			b:        CString("bar"),
+			expected: Greater,
+		},
+		"DifferentTypes": {
+			a:        "42",
+			b:        int32(42),
+			expected: Incomparable,
+		},
+		"Null": {
+			a:        nil,
+			b:        nil,
+			expected: Equal,
+		},
+		"Documents": {
+			a:        MustMakeDocument("a", int32(1), "b", "x"),
+			b:        MustMakeDocument("a", 1.0, "b", "y"),
+			expected: Less,
+		},
+		"DocumentsKeys": {
+			a:        MustMakeDocument("b", int32(1)),
+			b:        MustMakeDocument("a", int32(1)),
+			expected: Greater,
+		},
+		"Arrays": {
+			a:        MustNewArray(int32(1), "foo"),
+			b:        MustNewArray(int32(1), "foo", nil),
+			expected: Less,
+		},
+		"DateTime": {
+			a:        time.Date(2021, 11, 1, 10, 18, 42, 0, time.UTC),
+			b:        time.Date(2021, 11, 1, 10, 18, 42, 0, time.UTC).Local(),
+			expected: Equal,
+		},
+		"Bool": {
+			a:        true,
+			b:        false,
+			expected: Greater,
+		},
+		"StringSymbol": {
+			a:        "foo",
+			b:        Symbol("foo"),
+			expected: Equal,
+		},
+		"MinKey": {
+			a:        MinKey{},
+			b:        MinKey{},
+			expected: Equal,
+		},
+		"MaxKeyString": {
+			a:        MaxKey{},
+			b:        "foo",
+			expected: Greater,
+		},
+		"DBPointer": {
+			a:        DBPointer{Namespace: "test.foo", ID: ObjectID{1}},
+			b:        DBPointer{Namespace: "test.foo", ID: ObjectID{2}},
+			expected: Less,
+		},
+		"JavaScriptScope": {
+			a:        JavaScriptScope{Code: "x", Scope: MustMakeDocument("x", int32(2))},
+			b:        JavaScriptScope{Code: "x", Scope: MustMakeDocument("x", int32(1))},
+			expected: Greater,
+		},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Compare(tc.a, tc.b))
+		})
+	}
+}
+
+func TestCompareOrder(t *testing.T) {
+	t.Parallel()
+
+	values := []any{
+		MinKey{},
+		Undefined{},
+		nil,
+		int32(1),
+		"foo",
+		MustMakeDocument("foo", "bar"),
+		MustNewArray(int32(1)),
+		Binary{Subtype: BinaryGeneric, B: []byte{1}},
+		ObjectID{1},
+		false,
+		time.Unix(0, 0),
+		Timestamp(1),
+		Regex{Pattern: "foo"},
+		DBPointer{Namespace: "test.foo", ID: ObjectID{1}},
+		JavaScript("foo"),
+		JavaScriptScope{Code: "foo", Scope: MustMakeDocument()},
+		MaxKey{},
+	}
+
+	for i := range values {
+		for j := range values {
+			expected := Equal
+			switch {
+			case i < j:
+				expected = Less
+			case i > j:
+				expected = Greater
+			}
+			assert.Equal(t, expected, CompareOrder(values[i], values[j]), "%v (%[1]T) vs %v (%[2]T)", values[i], values[j])
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Code generated by "stringer -linecomment -type CompareResult"; DO NOT EDIT.
+
+package types
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[Equal-0]
+	_ = x[Less - -1]
+	_ = x[Greater-1]
+	_ = x[Incomparable-2]
+}
+
+const _CompareResult_name = "<==>≹"
+
+var _CompareResult_index = [...]uint8{0, 1, 3, 4, 7}
+
+func (i CompareResult) String() string {
+	idx := int(i) - -1
+	if i < -1 || idx >= len(_CompareResult_index)-1 {
+		return "CompareResult(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _CompareResult_name[_CompareResult_index[idx]:_CompareResult_index[idx+1]]
+}
@@ -0,0 +1,403 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal128 represents BSON Decimal128 data type:
+// IEEE 754-2008 128-bit decimal floating point number in the binary integer decimal (BID) encoding.
+type Decimal128 struct {
+	H uint64 // high 64 bits
+	L uint64 // low 64 bits
+}
+
+const (
+	decimal128ExponentBias = 6176
+	decimal128MinExponent  = -6176
+	decimal128MaxExponent  = 6111
+	decimal128MaxDigits    = 34
+
+	decimal128SignBit   = uint64(1) << 63
+	decimal128NaNBits   = uint64(0x1f) << 58
+	decimal128InfBits   = uint64(0x1e) << 58
+	decimal128Form2Bits = uint64(3) << 61
+)
+
+var (
+	bigTen = big.NewInt(10)
+
+	// decimal128MaxCoefficient is the maximal coefficient of canonical Decimal128 values: 10^34-1.
+	decimal128MaxCoefficient = new(big.Int).Sub(
+		new(big.Int).Exp(bigTen, big.NewInt(decimal128MaxDigits), nil), big.NewInt(1),
+	)
+)
+
+// Decimal128NaN returns Decimal128 NaN value.
+func Decimal128NaN() Decimal128 {
+	return Decimal128{H: decimal128NaNBits}
+}
+
+// Decimal128Inf returns Decimal128 positive infinity if sign >= 0, negative infinity if sign < 0.
+func Decimal128Inf(sign int) Decimal128 {
+	if sign < 0 {
+		return Decimal128{H: decimal128SignBit | decimal128InfBits}
+	}
+	return Decimal128{H: decimal128InfBits}
+}
+
+// NewDecimal128FromInt64 returns Decimal128 value equal to the given integer.
+func NewDecimal128FromInt64(v int64) Decimal128 {
+	d, _ := newDecimal128(v < 0, new(big.Int).Abs(big.NewInt(v)), 0)
+	return d
+}
+
+// NewDecimal128FromFloat64 returns Decimal128 value for the given float64 rounded to 15 significant digits,
+// as MongoDB does.
+func NewDecimal128FromFloat64(v float64) Decimal128 {
+	switch {
+	case math.IsNaN(v):
+		return Decimal128NaN()
+	case math.IsInf(v, 0):
+		return Decimal128Inf(int(math.Copysign(1, v)))
+	}
+
+	d, err := ParseDecimal128(strconv.FormatFloat(v, 'E', 14, 64))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// ParseDecimal128 parses Decimal128 value from its string representation.
+//
+// It accepts decimal numbers with optional exponent, "NaN", "Inf" and "Infinity" (case-insensitive) with an optional sign.
+// Values with more than 34 significant digits are rounded.
+func ParseDecimal128(s string) (Decimal128, error) {
+	str := s
+	var neg bool
+	switch {
+	case strings.HasPrefix(str, "-"):
+		neg = true
+		str = str[1:]
+	case strings.HasPrefix(str, "+"):
+		str = str[1:]
+	}
+
+	switch strings.ToLower(str) {
+	case "nan":
+		return Decimal128NaN(), nil
+	case "inf", "infinity":
+		if neg {
+			return Decimal128Inf(-1), nil
+		}
+		return Decimal128Inf(1), nil
+	}
+
+	var exp int
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.Atoi(str[i+1:]); err != nil {
+			return Decimal128{}, fmt.Errorf("types.ParseDecimal128: invalid exponent in %q", s)
+		}
+		str = str[:i]
+	}
+
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		exp -= len(str) - i - 1
+		str = str[:i] + str[i+1:]
+	}
+
+	if str == "" || strings.IndexFunc(str, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return Decimal128{}, fmt.Errorf("types.ParseDecimal128: invalid syntax %q", s)
+	}
+
+	coef, _ := new(big.Int).SetString(str, 10)
+	d, ok := newDecimal128(neg, coef, exp)
+	if !ok {
+		return Decimal128{}, fmt.Errorf("types.ParseDecimal128: value %q is out of range", s)
+	}
+
+	return d, nil
+}
+
+// MustParseDecimal128 is a ParseDecimal128 that panics on error.
+func MustParseDecimal128(s string) Decimal128 {
+	d, err := ParseDecimal128(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// newDecimal128 returns Decimal128 value equal to (-1)^neg * coef * 10^exp.
+//
+// The coefficient is rounded (half to even) to 34 digits if needed, and the exponent is clamped.
+// If the value is too large, infinity and false are returned.
+func newDecimal128(neg bool, coef *big.Int, exp int) (Decimal128, bool) {
+	coef = new(big.Int).Set(coef)
+
+	if coef.Sign() == 0 && exp < decimal128MinExponent {
+		exp = decimal128MinExponent
+	}
+
+	// drop all extra digits at once, so the value is rounded only once
+	n := len(coef.String()) - decimal128MaxDigits
+	if d := decimal128MinExponent - exp; d > n {
+		n = d
+	}
+
+	if n > 0 && coef.Sign() != 0 {
+		coef = roundDivPow10(coef, n)
+		exp += n
+
+		// rounding up 99...9 gives 10^34 that is exactly divisible by 10
+		if coef.Cmp(decimal128MaxCoefficient) > 0 {
+			coef.Quo(coef, bigTen)
+			exp++
+		}
+	}
+
+	for exp > decimal128MaxExponent {
+		if coef.Sign() == 0 {
+			exp = decimal128MaxExponent
+			break
+		}
+
+		c := new(big.Int).Mul(coef, bigTen)
+		if c.Cmp(decimal128MaxCoefficient) > 0 {
+			if neg {
+				return Decimal128Inf(-1), false
+			}
+			return Decimal128Inf(1), false
+		}
+
+		coef = c
+		exp--
+	}
+
+	var l, h uint64
+	l = new(big.Int).And(coef, new(big.Int).SetUint64(math.MaxUint64)).Uint64()
+	h = new(big.Int).Rsh(coef, 64).Uint64()
+	h |= uint64(exp+decimal128ExponentBias) << 49
+
+	if neg {
+		h |= decimal128SignBit
+	}
+
+	return Decimal128{H: h, L: l}, true
+}
+
+// roundDivPow10 returns v / 10^n rounded half to even.
+func roundDivPow10(v *big.Int, n int) *big.Int {
+	div := new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+	q, r := new(big.Int).QuoRem(v, div, new(big.Int))
+
+	// compare the remainder with the half of the divisor
+	switch c := r.Lsh(r, 1).Cmp(div); {
+	case c > 0, c == 0 && q.Bit(0) == 1:
+		q.Add(q, big.NewInt(1))
+	}
+
+	return q
+}
+
+// IsNaN returns true if d is NaN.
+func (d Decimal128) IsNaN() bool {
+	return d.H&decimal128NaNBits == decimal128NaNBits
+}
+
+// IsInf returns 1 for positive infinity, -1 for negative infinity, and 0 otherwise.
+func (d Decimal128) IsInf() int {
+	if d.H&decimal128NaNBits != decimal128InfBits {
+		return 0
+	}
+	if d.Signbit() {
+		return -1
+	}
+	return 1
+}
+
+// Signbit returns true if d is negative or negative zero.
+func (d Decimal128) Signbit() bool {
+	return d.H&decimal128SignBit != 0
+}
+
+// Parts returns the absolute value of the coefficient and the exponent of finite d.
+//
+// Non-canonical coefficients (larger than 10^34-1) are treated as zero, as IEEE 754 requires.
+func (d Decimal128) Parts() (*big.Int, int) {
+	if d.H&decimal128Form2Bits == decimal128Form2Bits {
+		return new(big.Int), int(d.H>>47&(1<<14-1)) - decimal128ExponentBias
+	}
+
+	exp := int(d.H>>49&(1<<14-1)) - decimal128ExponentBias
+
+	coef := new(big.Int).SetUint64(d.H & (1<<49 - 1))
+	coef.Lsh(coef, 64)
+	coef.Or(coef, new(big.Int).SetUint64(d.L))
+
+	if coef.Cmp(decimal128MaxCoefficient) > 0 {
+		coef.SetInt64(0)
+	}
+
+	return coef, exp
+}
+
+// Rat returns the exact value of finite d.
+func (d Decimal128) Rat() *big.Rat {
+	coef, exp := d.Parts()
+	if d.Signbit() {
+		coef.Neg(coef)
+	}
+
+	scale := new(big.Int).Exp(bigTen, big.NewInt(int64(abs(exp))), nil)
+	if exp >= 0 {
+		return new(big.Rat).SetInt(coef.Mul(coef, scale))
+	}
+	return new(big.Rat).SetFrac(coef, scale)
+}
+
+// Float64 returns the nearest float64 value.
+func (d Decimal128) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Add returns d + other with the result rounded to 34 digits.
+func (d Decimal128) Add(other Decimal128) Decimal128 {
+	switch {
+	case d.IsNaN() || other.IsNaN():
+		return Decimal128NaN()
+	case d.IsInf() != 0 && other.IsInf() != 0 && d.IsInf() != other.IsInf():
+		return Decimal128NaN()
+	case d.IsInf() != 0:
+		return d
+	case other.IsInf() != 0:
+		return other
+	}
+
+	ac, aExp := d.Parts()
+	bc, bExp := other.Parts()
+	if d.Signbit() {
+		ac.Neg(ac)
+	}
+	if other.Signbit() {
+		bc.Neg(bc)
+	}
+
+	exp := aExp
+	if bExp < exp {
+		exp = bExp
+	}
+	ac.Mul(ac, new(big.Int).Exp(bigTen, big.NewInt(int64(aExp-exp)), nil))
+	bc.Mul(bc, new(big.Int).Exp(bigTen, big.NewInt(int64(bExp-exp)), nil))
+
+	sum := ac.Add(ac, bc)
+	neg := sum.Sign() < 0 || (sum.Sign() == 0 && d.Signbit() && other.Signbit())
+
+	res, _ := newDecimal128(neg, sum.Abs(sum), exp)
+	return res
+}
+
+// Neg returns -d.
+func (d Decimal128) Neg() Decimal128 {
+	d.H ^= decimal128SignBit
+	return d
+}
+
+// Mul returns d * other with the result rounded to 34 digits.
+func (d Decimal128) Mul(other Decimal128) Decimal128 {
+	neg := d.Signbit() != other.Signbit()
+
+	switch {
+	case d.IsNaN() || other.IsNaN():
+		return Decimal128NaN()
+	case d.IsInf() != 0 || other.IsInf() != 0:
+		ac, _ := d.Parts()
+		bc, _ := other.Parts()
+		if (d.IsInf() == 0 && ac.Sign() == 0) || (other.IsInf() == 0 && bc.Sign() == 0) {
+			return Decimal128NaN()
+		}
+		if neg {
+			return Decimal128Inf(-1)
+		}
+		return Decimal128Inf(1)
+	}
+
+	ac, aExp := d.Parts()
+	bc, bExp := other.Parts()
+
+	res, _ := newDecimal128(neg, ac.Mul(ac, bc), aExp+bExp)
+	return res
+}
+
+// String returns the string representation of d as defined by the BSON Decimal128 specification.
+func (d Decimal128) String() string {
+	if d.IsNaN() {
+		return "NaN"
+	}
+
+	var sign string
+	if d.Signbit() {
+		sign = "-"
+	}
+
+	if d.IsInf() != 0 {
+		return sign + "Infinity"
+	}
+
+	coef, exp := d.Parts()
+	s := coef.String()
+	adjusted := exp + len(s) - 1
+
+	if exp > 0 || adjusted < -6 {
+		// scientific notation
+		res := s[:1]
+		if len(s) > 1 {
+			res += "." + s[1:]
+		}
+		res += "E"
+		if adjusted >= 0 {
+			res += "+"
+		}
+		return sign + res + strconv.Itoa(adjusted)
+	}
+
+	if exp == 0 {
+		return sign + s
+	}
+
+	if n := -exp; len(s) > n {
+		s = s[:len(s)-n] + "." + s[len(s)-n:]
+	} else {
+		s = "0." + strings.Repeat("0", n-len(s)) + s
+	}
+
+	return sign + s
+}
+
+// abs returns the absolute value of v.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal128(t *testing.T) {
+	t.Parallel()
+
+	// test cases from BSON corpus: https://github.com/mongodb/specifications/tree/master/source/bson-corpus
+	for name, tc := range map[string]struct {
+		s        string
+		expected Decimal128
+		canonS   string // if different from s
+	}{
+		"Zero":         {s: "0", expected: Decimal128{H: 0x3040000000000000}},
+		"NegZero":      {s: "-0", expected: Decimal128{H: 0xb040000000000000}},
+		"One":          {s: "1", expected: Decimal128{H: 0x3040000000000000, L: 1}},
+		"NegOne":       {s: "-1", expected: Decimal128{H: 0xb040000000000000, L: 1}},
+		"Tenth":        {s: "0.1", expected: Decimal128{H: 0x303e000000000000, L: 1}},
+		"TrailingZero": {s: "1.0", expected: Decimal128{H: 0x303e000000000000, L: 10}},
+		"Small":        {s: "0.001234", expected: Decimal128{H: 0x3034000000000000, L: 1234}},
+		"Exponent":     {s: "1E+3", expected: Decimal128{H: 0x3046000000000000, L: 1}},
+		"NegExponent":  {s: "1.234E-7", expected: Decimal128{H: 0x302c000000000000, L: 1234}},
+		"Lowercase":    {s: "1e3", expected: Decimal128{H: 0x3046000000000000, L: 1}, canonS: "1E+3"},
+		"Max": {
+			s:        "9.999999999999999999999999999999999E+6144",
+			expected: Decimal128{H: 0x5fffed09bead87c0, L: 0x378d8e63ffffffff},
+		},
+		"Clamped": {s: "1E+6112", expected: Decimal128{H: 0x5ffe000000000000, L: 10}, canonS: "1.0E+6112"},
+		"Rounded": {
+			s: "12345678901234567890123456789012345", canonS: "1.234567890123456789012345678901234E+34",
+			expected: Decimal128{H: 0x30423cde6fff9732, L: 0xde825cd07e96aff2},
+		},
+		"NaN":    {s: "NaN", expected: Decimal128{H: 0x7c00000000000000}},
+		"Inf":    {s: "Infinity", expected: Decimal128{H: 0x7800000000000000}},
+		"NegInf": {s: "-Inf", expected: Decimal128{H: 0xf800000000000000}, canonS: "-Infinity"},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseDecimal128(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+
+			canonS := tc.canonS
+			if canonS == "" {
+				canonS = tc.s
+			}
+			assert.Equal(t, canonS, actual.String())
+		})
+	}
+
+	for _, s := range []string{"", ".", "1.2.3", "1e", "e3", "0x10", "1E+6145"} {
+		_, err := ParseDecimal128(s)
+		assert.Error(t, err, "%q", s)
+	}
+}
+
+func TestDecimal128Rounding(t *testing.T) {
+	t.Parallel()
+
+	// values with extra digits are rounded half to even once, not digit by digit
+	for name, tc := range map[string]struct {
+		s        string
+		expected string
+	}{
+		"TieEven":         {"12345678901234567890123456789012345", "1.234567890123456789012345678901234E+34"},
+		"TieOdd":          {"12345678901234567890123456789012335", "1.234567890123456789012345678901234E+34"},
+		"TwoDigitsTie":    {"123456789012345678901234567890123250", "1.234567890123456789012345678901232E+35"},
+		"TwoDigitsTieOdd": {"123456789012345678901234567890123350", "1.234567890123456789012345678901234E+35"},
+		"TwoDigitsAbove":  {"123456789012345678901234567890123251", "1.234567890123456789012345678901233E+35"},
+		"TwoDigitsBelow":  {"123456789012345678901234567890123349", "1.234567890123456789012345678901233E+35"},
+		"NegAbove":        {"-123456789012345678901234567890123251", "-1.234567890123456789012345678901233E+35"},
+		"ManyDigitsAbove": {"1234567890123456789012345678901232500000000001", "1.234567890123456789012345678901233E+45"},
+		"ManyDigitsBelow": {"1234567890123456789012345678901232499999999999", "1.234567890123456789012345678901232E+45"},
+		"Carry":           {"99999999999999999999999999999999995", "1.000000000000000000000000000000000E+35"},
+		"SubnormalTie":    {"15E-6177", "2E-6176"},
+		"SubnormalTieOdd": {"-35E-6177", "-4E-6176"},
+		"SubnormalAbove":  {"251E-6178", "3E-6176"},
+		"Underflow":       {"4E-6178", "0E-6176"},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseDecimal128(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.String())
+		})
+	}
+}
+
+func TestDecimal128Arithmetic(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		actual   Decimal128
+		expected string
+	}{
+		"Add":        {MustParseDecimal128("1.10").Add(MustParseDecimal128("2.2")), "3.30"},
+		"AddNeg":     {MustParseDecimal128("1").Add(MustParseDecimal128("-1.5")), "-0.5"},
+		"AddInf":     {Decimal128Inf(1).Add(MustParseDecimal128("1")), "Infinity"},
+		"AddInfInf":  {Decimal128Inf(1).Add(Decimal128Inf(-1)), "NaN"},
+		"AddRounded": {MustParseDecimal128("1E+34").Add(MustParseDecimal128("1")), "1.000000000000000000000000000000000E+34"},
+		"AddRoundedOnce": {
+			MustParseDecimal128("1.234567890123456789012345678901232E+35").Add(MustParseDecimal128("51")),
+			"1.234567890123456789012345678901233E+35",
+		},
+		"Mul":        {MustParseDecimal128("1.5").Mul(MustParseDecimal128("-2")), "-3.0"},
+		"FromInt64":  {NewDecimal128FromInt64(-42), "-42"},
+		"FromDouble": {NewDecimal128FromFloat64(0.1), "0.100000000000000"},
+	} {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.actual.String())
+		})
+	}
+}
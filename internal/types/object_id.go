@@ -0,0 +1,63 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// objectIDProcess is the process-unique random value of generated ObjectIDs.
+	objectIDProcess [5]byte
+
+	// objectIDCounter is the last used counter value of generated ObjectIDs.
+	objectIDCounter uint32
+)
+
+func init() {
+	if _, err := rand.Read(objectIDProcess[:]); err != nil {
+		panic(err)
+	}
+
+	var counter [4]byte
+	if _, err := rand.Read(counter[:]); err != nil {
+		panic(err)
+	}
+	objectIDCounter = binary.BigEndian.Uint32(counter[:])
+}
+
+// NewObjectID returns a new unique ObjectID:
+// 4-byte timestamp in seconds, 5-byte process-unique random value, and 3-byte incrementing counter.
+func NewObjectID() ObjectID {
+	return newObjectIDTime(time.Now())
+}
+
+// newObjectIDTime returns a new unique ObjectID with the given timestamp.
+func newObjectIDTime(t time.Time) ObjectID {
+	var res ObjectID
+
+	binary.BigEndian.PutUint32(res[0:4], uint32(t.Unix()))
+	copy(res[4:9], objectIDProcess[:])
+
+	c := atomic.AddUint32(&objectIDCounter, 1)
+	res[9] = byte(c >> 16)
+	res[10] = byte(c >> 8)
+	res[11] = byte(c)
+
+	return res
+}
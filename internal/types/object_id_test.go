@@ -0,0 +1,35 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewObjectID(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	a, b := newObjectIDTime(ts), newObjectIDTime(ts)
+	assert.NotEqual(t, a, b)
+	assert.Equal(t, []byte{0x62, 0x21, 0x9e, 0x3f}, a[:4])
+	assert.Equal(t, a[:9], b[:9])
+
+	counter := func(id ObjectID) uint32 { return uint32(id[9])<<16 | uint32(id[10])<<8 | uint32(id[11]) }
+	assert.Equal(t, (counter(a)+1)&0xffffff, counter(b))
+}
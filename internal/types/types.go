@@ -39,8 +39,15 @@
 //  int32            *bson.Int32      *fjson.Int32
 //  types.Timestamp  *bson.Timestamp  *fjson.Timestamp
 //  int64            *bson.Int64      *fjson.Int64
-//  TODO Decimal128
+//  types.Decimal128 *bson.Decimal128 *fjson.Decimal128
 //  types.CString    *bson.CString    *fjson.CString
+//  types.MinKey          *bson.MinKey          *fjson.MinKey
+//  types.MaxKey          *bson.MaxKey          *fjson.MaxKey
+//  types.Undefined       *bson.Undefined       *fjson.Undefined
+//  types.Symbol          *bson.Symbol          *fjson.Symbol
+//  types.JavaScript      *bson.JavaScript      *fjson.JavaScript
+//  types.JavaScriptScope *bson.JavaScriptScope *fjson.JavaScriptScope
+//  types.DBPointer       *bson.DBPointer       *fjson.DBPointer
 package types
 
 import (
@@ -66,6 +73,33 @@ type (
 	}
 
 	Timestamp uint64
+
+	// MinKey represents BSON MinKey data type that is less than any other value.
+	MinKey struct{}
+
+	// MaxKey represents BSON MaxKey data type that is greater than any other value.
+	MaxKey struct{}
+
+	// Undefined represents deprecated BSON Undefined data type.
+	Undefined struct{}
+
+	// Symbol represents deprecated BSON Symbol data type.
+	Symbol string
+
+	// JavaScript represents BSON JavaScript code data type.
+	JavaScript string
+
+	// JavaScriptScope represents deprecated BSON JavaScript code with scope data type.
+	JavaScriptScope struct {
+		Code  string
+		Scope Document
+	}
+
+	// DBPointer represents deprecated BSON DBPointer data type.
+	DBPointer struct {
+		Namespace string
+		ID        ObjectID
+	}
 )
 
 // validateValue validates value.
@@ -99,8 +133,24 @@ func validateValue(value any) error {
 		return nil
 	case int64:
 		return nil
+	case Decimal128:
+		return nil
 	case CString:
 		return nil
+	case MinKey:
+		return nil
+	case MaxKey:
+		return nil
+	case Undefined:
+		return nil
+	case Symbol:
+		return nil
+	case JavaScript:
+		return nil
+	case JavaScriptScope:
+		return value.Scope.validate()
+	case DBPointer:
+		return nil
 	default:
 		return fmt.Errorf("types.validateValue: unsupported type: %[1]T (%[1]v)", value)
 	}
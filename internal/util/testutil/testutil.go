@@ -73,7 +73,7 @@ func Schema(ctx context.Context, tb testing.TB, pool *pg.Pool) string {
 		tb.Skip("skipping in -short mode")
 	}
 
-	schema := strings.ToLower(tb.Name())
+	schema := SchemaName(tb)
 	tb.Logf("Using schema %q.", schema)
 
 	err := pool.DropSchema(ctx, schema)
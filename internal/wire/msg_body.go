@@ -17,8 +17,10 @@ package wire
 import (
 	"bufio"
 	"encoding"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"io"
 
 	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
@@ -64,6 +66,18 @@ func ReadMessage(r *bufio.Reader) (*MsgHeader, MsgBody, error) {
 			return nil, nil, lazyerrors.Error(err)
 		}
 
+		if msg.FlagBits.FlagSet(OpMsgChecksumPresent) {
+			checksum, err := msgChecksum(&header, b)
+			if err != nil {
+				return nil, nil, lazyerrors.Error(err)
+			}
+			if checksum != msg.Checksum {
+				return nil, nil, lazyerrors.Errorf(
+					"OP_MSG checksum does not match contents: expected %#08x, got %#08x", checksum, msg.Checksum,
+				)
+			}
+		}
+
 		return &header, &msg, nil
 
 	case OP_QUERY:
@@ -75,16 +89,46 @@ func ReadMessage(r *bufio.Reader) (*MsgHeader, MsgBody, error) {
 		return &header, &query, nil
 
 	case OP_UPDATE:
-		fallthrough
+		var update OpUpdate
+		if err := update.UnmarshalBinary(b); err != nil {
+			return nil, nil, lazyerrors.Error(err)
+		}
+
+		return &header, &update, nil
+
 	case OP_INSERT:
-		fallthrough
-	case OP_GET_BY_OID:
-		fallthrough
+		var insert OpInsert
+		if err := insert.UnmarshalBinary(b); err != nil {
+			return nil, nil, lazyerrors.Error(err)
+		}
+
+		return &header, &insert, nil
+
 	case OP_GET_MORE:
-		fallthrough
+		var getMore OpGetMore
+		if err := getMore.UnmarshalBinary(b); err != nil {
+			return nil, nil, lazyerrors.Error(err)
+		}
+
+		return &header, &getMore, nil
+
 	case OP_DELETE:
-		fallthrough
+		var del OpDelete
+		if err := del.UnmarshalBinary(b); err != nil {
+			return nil, nil, lazyerrors.Error(err)
+		}
+
+		return &header, &del, nil
+
 	case OP_KILL_CURSORS:
+		var kill OpKillCursors
+		if err := kill.UnmarshalBinary(b); err != nil {
+			return nil, nil, lazyerrors.Error(err)
+		}
+
+		return &header, &kill, nil
+
+	case OP_GET_BY_OID:
 		fallthrough
 	case OP_COMPRESSED:
 		fallthrough
@@ -94,6 +138,25 @@ func ReadMessage(r *bufio.Reader) (*MsgHeader, MsgBody, error) {
 	}
 }
 
+// ExpectsReply returns true if the client waits for a reply to the given message.
+//
+// Legacy OP_INSERT, OP_UPDATE, OP_DELETE and OP_KILL_CURSORS messages and OP_MSG with moreToCome flag
+// don't have replies.
+func ExpectsReply(msg MsgBody) bool {
+	switch msg := msg.(type) {
+	case *OpInsert, *OpUpdate, *OpDelete, *OpKillCursors:
+		return false
+	case *OpMsg:
+		return !msg.FlagBits.FlagSet(OpMsgMoreToCome)
+	default:
+		return true
+	}
+}
+
+// WriteMessage writes the message to the writer.
+//
+// If OP_MSG has checksumPresent flag set, the checksum is calculated and written;
+// the Checksum field value is ignored.
 func WriteMessage(w *bufio.Writer, header *MsgHeader, msg MsgBody) error {
 	b, err := msg.MarshalBinary()
 	if err != nil {
@@ -107,6 +170,14 @@ func WriteMessage(w *bufio.Writer, header *MsgHeader, msg MsgBody) error {
 		))
 	}
 
+	if msg, ok := msg.(*OpMsg); ok && msg.FlagBits.FlagSet(OpMsgChecksumPresent) {
+		checksum, err := msgChecksum(header, b)
+		if err != nil {
+			return lazyerrors.Error(err)
+		}
+		binary.LittleEndian.PutUint32(b[len(b)-4:], checksum)
+	}
+
 	if err := header.writeTo(w); err != nil {
 		return lazyerrors.Error(err)
 	}
@@ -117,3 +188,23 @@ func WriteMessage(w *bufio.Writer, header *MsgHeader, msg MsgBody) error {
 
 	return nil
 }
+
+// crc32cTable is the CRC-32C (Castagnoli) table used for OP_MSG checksums.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// msgChecksum returns CRC-32C checksum of the whole OP_MSG message except the checksum itself.
+//
+// The body must include the checksum as the last 4 bytes.
+func msgChecksum(header *MsgHeader, body []byte) (uint32, error) {
+	if len(body) < 4 {
+		return 0, lazyerrors.Errorf("wire.msgChecksum: body is too short (%d bytes)", len(body))
+	}
+
+	h, err := header.MarshalBinary()
+	if err != nil {
+		return 0, lazyerrors.Error(err)
+	}
+
+	checksum := crc32.Update(0, crc32cTable, h)
+	return crc32.Update(checksum, crc32cTable, body[:len(body)-4]), nil
+}
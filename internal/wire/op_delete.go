@@ -0,0 +1,124 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// OpDelete is a legacy message used to delete documents from a collection.
+type OpDelete struct {
+	FullCollectionName string
+	Flags              OpDeleteFlags
+	Selector           types.Document
+}
+
+func (del *OpDelete) msgbody() {}
+
+func (del *OpDelete) readFrom(bufr *bufio.Reader) error {
+	var zero int32
+	if err := binary.Read(bufr, binary.LittleEndian, &zero); err != nil {
+		return lazyerrors.Errorf("wire.OpDelete.ReadFrom (binary.Read): %w", err)
+	}
+	if zero != 0 {
+		return lazyerrors.Errorf("wire.OpDelete.ReadFrom: reserved field is %d, expected 0", zero)
+	}
+
+	var col bson.CString
+	if err := col.ReadFrom(bufr); err != nil {
+		return err
+	}
+	del.FullCollectionName = string(col)
+
+	if err := binary.Read(bufr, binary.LittleEndian, &del.Flags); err != nil {
+		return lazyerrors.Errorf("wire.OpDelete.ReadFrom (binary.Read): %w", err)
+	}
+
+	var selector bson.Document
+	if err := selector.ReadFrom(bufr); err != nil {
+		return err
+	}
+	del.Selector = types.MustConvertDocument(&selector)
+
+	return nil
+}
+
+// UnmarshalBinary reads an OpDelete from a byte array.
+func (del *OpDelete) UnmarshalBinary(b []byte) error {
+	br := bytes.NewReader(b)
+	bufr := bufio.NewReader(br)
+
+	if err := del.readFrom(bufr); err != nil {
+		return lazyerrors.Errorf("wire.OpDelete.UnmarshalBinary: %w", err)
+	}
+
+	if _, err := bufr.Peek(1); err != io.EOF {
+		return lazyerrors.Errorf("unexpected end of the OpDelete: %v", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary writes an OpDelete to a byte array.
+func (del *OpDelete) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	bufw := bufio.NewWriter(&buf)
+
+	if err := binary.Write(bufw, binary.LittleEndian, int32(0)); err != nil {
+		return nil, err
+	}
+
+	if err := bson.CString(del.FullCollectionName).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(bufw, binary.LittleEndian, del.Flags); err != nil {
+		return nil, err
+	}
+
+	if err := bson.MustConvertDocument(del.Selector).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+
+	if err := bufw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON writes an OpDelete in JSON format to a byte array.
+func (del *OpDelete) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"FullCollectionName": del.FullCollectionName,
+		"Flags":              del.Flags,
+		"Selector":           bson.MustConvertDocument(del.Selector),
+	}
+
+	return json.Marshal(m)
+}
+
+// check interfaces
+var (
+	_ MsgBody = (*OpDelete)(nil)
+)
@@ -0,0 +1,47 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+var deleteTestCases = []testCase{{
+	name:      "delete",
+	expectedB: testutil.MustParseDumpFile("testdata", "delete.hex"),
+	msgHeader: &MsgHeader{
+		MessageLength: 50,
+		RequestID:     3,
+		ResponseTo:    0,
+		OpCode:        OP_DELETE,
+	},
+	msgBody: &OpDelete{
+		FullCollectionName: "test.values",
+		Flags:              OpDeleteFlags(OpDeleteSingleRemove),
+		Selector:           types.MustMakeDocument("_id", int32(1)),
+	},
+}}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+	testMessages(t, deleteTestCases)
+}
+
+func FuzzDelete(f *testing.F) {
+	fuzzMessages(f, deleteTestCases)
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+var getMoreTestCases = []testCase{{
+	name:      "getMore",
+	expectedB: testutil.MustParseDumpFile("testdata", "get_more.hex"),
+	msgHeader: &MsgHeader{
+		MessageLength: 44,
+		RequestID:     4,
+		ResponseTo:    0,
+		OpCode:        OP_GET_MORE,
+	},
+	msgBody: &OpGetMore{
+		FullCollectionName: "test.values",
+		NumberToReturn:     2,
+		CursorID:           0x1234,
+	},
+}}
+
+func TestGetMore(t *testing.T) {
+	t.Parallel()
+	testMessages(t, getMoreTestCases)
+}
+
+func FuzzGetMore(f *testing.F) {
+	fuzzMessages(f, getMoreTestCases)
+}
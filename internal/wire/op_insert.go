@@ -0,0 +1,129 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// OpInsert is a legacy message used to insert documents into a collection.
+type OpInsert struct {
+	Flags              OpInsertFlags
+	FullCollectionName string
+	Documents          []types.Document
+}
+
+func (insert *OpInsert) msgbody() {}
+
+func (insert *OpInsert) readFrom(bufr *bufio.Reader) error {
+	if err := binary.Read(bufr, binary.LittleEndian, &insert.Flags); err != nil {
+		return lazyerrors.Errorf("wire.OpInsert.ReadFrom (binary.Read): %w", err)
+	}
+
+	var col bson.CString
+	if err := col.ReadFrom(bufr); err != nil {
+		return err
+	}
+	insert.FullCollectionName = string(col)
+
+	for {
+		if _, err := bufr.Peek(1); err != nil {
+			break
+		}
+
+		var doc bson.Document
+		if err := doc.ReadFrom(bufr); err != nil {
+			return err
+		}
+		insert.Documents = append(insert.Documents, types.MustConvertDocument(&doc))
+	}
+
+	if len(insert.Documents) == 0 {
+		return lazyerrors.New("wire.OpInsert.ReadFrom: no documents")
+	}
+
+	return nil
+}
+
+// UnmarshalBinary reads an OpInsert from a byte array.
+func (insert *OpInsert) UnmarshalBinary(b []byte) error {
+	br := bytes.NewReader(b)
+	bufr := bufio.NewReader(br)
+
+	if err := insert.readFrom(bufr); err != nil {
+		return lazyerrors.Errorf("wire.OpInsert.UnmarshalBinary: %w", err)
+	}
+
+	if _, err := bufr.Peek(1); err != io.EOF {
+		return lazyerrors.Errorf("unexpected end of the OpInsert: %v", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary writes an OpInsert to a byte array.
+func (insert *OpInsert) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	bufw := bufio.NewWriter(&buf)
+
+	if err := binary.Write(bufw, binary.LittleEndian, insert.Flags); err != nil {
+		return nil, err
+	}
+
+	if err := bson.CString(insert.FullCollectionName).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+
+	for _, doc := range insert.Documents {
+		if err := bson.MustConvertDocument(doc).WriteTo(bufw); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := bufw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON writes an OpInsert in JSON format to a byte array.
+func (insert *OpInsert) MarshalJSON() ([]byte, error) {
+	docs := make([]any, len(insert.Documents))
+	for i, d := range insert.Documents {
+		docs[i] = bson.MustConvertDocument(d)
+	}
+
+	m := map[string]any{
+		"Flags":              insert.Flags,
+		"FullCollectionName": insert.FullCollectionName,
+		"Documents":          docs,
+	}
+
+	return json.Marshal(m)
+}
+
+// check interfaces
+var (
+	_ MsgBody = (*OpInsert)(nil)
+)
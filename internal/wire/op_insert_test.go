@@ -0,0 +1,50 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+var insertTestCases = []testCase{{
+	name:      "insert",
+	expectedB: testutil.MustParseDumpFile("testdata", "insert.hex"),
+	msgHeader: &MsgHeader{
+		MessageLength: 82,
+		RequestID:     1,
+		ResponseTo:    0,
+		OpCode:        OP_INSERT,
+	},
+	msgBody: &OpInsert{
+		Flags:              OpInsertFlags(OpInsertContinueOnError),
+		FullCollectionName: "test.values",
+		Documents: []types.Document{
+			types.MustMakeDocument("_id", int32(1), "v", "foo"),
+			types.MustMakeDocument("_id", int32(2), "v", "bar"),
+		},
+	},
+}}
+
+func TestInsert(t *testing.T) {
+	t.Parallel()
+	testMessages(t, insertTestCases)
+}
+
+func FuzzInsert(f *testing.F) {
+	fuzzMessages(f, insertTestCases)
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// maxKillCursors is the maximal number of cursors in a single OpKillCursors message.
+const maxKillCursors = 100_000
+
+// OpKillCursors is a legacy message used to close cursors.
+type OpKillCursors struct {
+	CursorIDs []int64
+}
+
+func (kill *OpKillCursors) msgbody() {}
+
+func (kill *OpKillCursors) readFrom(bufr *bufio.Reader) error {
+	var zero int32
+	if err := binary.Read(bufr, binary.LittleEndian, &zero); err != nil {
+		return lazyerrors.Errorf("wire.OpKillCursors.ReadFrom (binary.Read): %w", err)
+	}
+	if zero != 0 {
+		return lazyerrors.Errorf("wire.OpKillCursors.ReadFrom: reserved field is %d, expected 0", zero)
+	}
+
+	var n int32
+	if err := binary.Read(bufr, binary.LittleEndian, &n); err != nil {
+		return lazyerrors.Errorf("wire.OpKillCursors.ReadFrom (binary.Read): %w", err)
+	}
+	if n < 1 || n > maxKillCursors {
+		return lazyerrors.Errorf("wire.OpKillCursors.ReadFrom: invalid number of cursors %d", n)
+	}
+
+	kill.CursorIDs = make([]int64, n)
+	if err := binary.Read(bufr, binary.LittleEndian, kill.CursorIDs); err != nil {
+		return lazyerrors.Errorf("wire.OpKillCursors.ReadFrom (binary.Read): %w", err)
+	}
+
+	return nil
+}
+
+// UnmarshalBinary reads an OpKillCursors from a byte array.
+func (kill *OpKillCursors) UnmarshalBinary(b []byte) error {
+	br := bytes.NewReader(b)
+	bufr := bufio.NewReader(br)
+
+	if err := kill.readFrom(bufr); err != nil {
+		return lazyerrors.Errorf("wire.OpKillCursors.UnmarshalBinary: %w", err)
+	}
+
+	if _, err := bufr.Peek(1); err != io.EOF {
+		return lazyerrors.Errorf("unexpected end of the OpKillCursors: %v", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary writes an OpKillCursors to a byte array.
+func (kill *OpKillCursors) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	bufw := bufio.NewWriter(&buf)
+
+	if err := binary.Write(bufw, binary.LittleEndian, int32(0)); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(bufw, binary.LittleEndian, int32(len(kill.CursorIDs))); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(bufw, binary.LittleEndian, kill.CursorIDs); err != nil {
+		return nil, err
+	}
+
+	if err := bufw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON writes an OpKillCursors in JSON format to a byte array.
+func (kill *OpKillCursors) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"CursorIDs": kill.CursorIDs,
+	}
+
+	return json.Marshal(m)
+}
+
+// check interfaces
+var (
+	_ MsgBody = (*OpKillCursors)(nil)
+)
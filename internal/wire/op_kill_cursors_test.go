@@ -0,0 +1,44 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+var killCursorsTestCases = []testCase{{
+	name:      "killCursors",
+	expectedB: testutil.MustParseDumpFile("testdata", "kill_cursors.hex"),
+	msgHeader: &MsgHeader{
+		MessageLength: 40,
+		RequestID:     5,
+		ResponseTo:    0,
+		OpCode:        OP_KILL_CURSORS,
+	},
+	msgBody: &OpKillCursors{
+		CursorIDs: []int64{0x1234, 0x5678},
+	},
+}}
+
+func TestKillCursors(t *testing.T) {
+	t.Parallel()
+	testMessages(t, killCursorsTestCases)
+}
+
+func FuzzKillCursors(f *testing.F) {
+	fuzzMessages(f, killCursorsTestCases)
+}
@@ -194,7 +194,7 @@ func (msg *OpMsg) readFrom(bufr *bufio.Reader) error {
 		return lazyerrors.Error(err)
 	}
 
-	// TODO validate checksum
+	// checksum is validated by ReadMessage as it covers the header too
 
 	return nil
 }
@@ -143,6 +143,28 @@ var msgTestCases = []testCase{{
 	name:      "dollar_dot",
 	expectedB: testutil.MustParseDumpFile("testdata", "dollar_dot.hex"),
 	err:       `types.Document.validate: invalid key: "$."`,
+}, {
+	name:      "msg_checksum",
+	expectedB: testutil.MustParseDumpFile("testdata", "msg_checksum.hex"),
+	msgHeader: &MsgHeader{
+		MessageLength: 55,
+		RequestID:     5,
+		OpCode:        OP_MSG,
+	},
+	msgBody: &OpMsg{
+		FlagBits: OpMsgFlags(OpMsgChecksumPresent),
+		Checksum: 0x91a515b3,
+		sections: []OpMsgSection{{
+			Documents: []types.Document{types.MustMakeDocument(
+				"ping", int32(1),
+				"$db", "admin",
+			)},
+		}},
+	},
+}, {
+	name:      "msg_checksum_invalid",
+	expectedB: testutil.MustParseDumpFile("testdata", "msg_checksum_invalid.hex"),
+	err:       `OP_MSG checksum does not match contents: expected 0x91a515b3, got 0x91a515b2`,
 }, {
 	name:      "msg_fuzz1",
 	expectedB: testutil.MustParseDumpFile("testdata", "msg_fuzz1.hex"),
@@ -0,0 +1,135 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/FerretDB/FerretDB/internal/bson"
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/lazyerrors"
+)
+
+// OpUpdate is a legacy message used to update documents in a collection.
+type OpUpdate struct {
+	FullCollectionName string
+	Flags              OpUpdateFlags
+	Selector           types.Document
+	Update             types.Document
+}
+
+func (update *OpUpdate) msgbody() {}
+
+func (update *OpUpdate) readFrom(bufr *bufio.Reader) error {
+	var zero int32
+	if err := binary.Read(bufr, binary.LittleEndian, &zero); err != nil {
+		return lazyerrors.Errorf("wire.OpUpdate.ReadFrom (binary.Read): %w", err)
+	}
+	if zero != 0 {
+		return lazyerrors.Errorf("wire.OpUpdate.ReadFrom: reserved field is %d, expected 0", zero)
+	}
+
+	var col bson.CString
+	if err := col.ReadFrom(bufr); err != nil {
+		return err
+	}
+	update.FullCollectionName = string(col)
+
+	if err := binary.Read(bufr, binary.LittleEndian, &update.Flags); err != nil {
+		return lazyerrors.Errorf("wire.OpUpdate.ReadFrom (binary.Read): %w", err)
+	}
+
+	var selector bson.Document
+	if err := selector.ReadFrom(bufr); err != nil {
+		return err
+	}
+	update.Selector = types.MustConvertDocument(&selector)
+
+	var u bson.Document
+	if err := u.ReadFrom(bufr); err != nil {
+		return err
+	}
+	update.Update = types.MustConvertDocument(&u)
+
+	return nil
+}
+
+// UnmarshalBinary reads an OpUpdate from a byte array.
+func (update *OpUpdate) UnmarshalBinary(b []byte) error {
+	br := bytes.NewReader(b)
+	bufr := bufio.NewReader(br)
+
+	if err := update.readFrom(bufr); err != nil {
+		return lazyerrors.Errorf("wire.OpUpdate.UnmarshalBinary: %w", err)
+	}
+
+	if _, err := bufr.Peek(1); err != io.EOF {
+		return lazyerrors.Errorf("unexpected end of the OpUpdate: %v", err)
+	}
+
+	return nil
+}
+
+// MarshalBinary writes an OpUpdate to a byte array.
+func (update *OpUpdate) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	bufw := bufio.NewWriter(&buf)
+
+	if err := binary.Write(bufw, binary.LittleEndian, int32(0)); err != nil {
+		return nil, err
+	}
+
+	if err := bson.CString(update.FullCollectionName).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(bufw, binary.LittleEndian, update.Flags); err != nil {
+		return nil, err
+	}
+
+	if err := bson.MustConvertDocument(update.Selector).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+	if err := bson.MustConvertDocument(update.Update).WriteTo(bufw); err != nil {
+		return nil, err
+	}
+
+	if err := bufw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON writes an OpUpdate in JSON format to a byte array.
+func (update *OpUpdate) MarshalJSON() ([]byte, error) {
+	m := map[string]any{
+		"FullCollectionName": update.FullCollectionName,
+		"Flags":              update.Flags,
+		"Selector":           bson.MustConvertDocument(update.Selector),
+		"Update":             bson.MustConvertDocument(update.Update),
+	}
+
+	return json.Marshal(m)
+}
+
+// check interfaces
+var (
+	_ MsgBody = (*OpUpdate)(nil)
+)
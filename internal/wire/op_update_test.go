@@ -0,0 +1,48 @@
+// Copyright 2021 FerretDB Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/FerretDB/FerretDB/internal/types"
+	"github.com/FerretDB/FerretDB/internal/util/testutil"
+)
+
+var updateTestCases = []testCase{{
+	name:      "update",
+	expectedB: testutil.MustParseDumpFile("testdata", "update.hex"),
+	msgHeader: &MsgHeader{
+		MessageLength: 79,
+		RequestID:     2,
+		ResponseTo:    0,
+		OpCode:        OP_UPDATE,
+	},
+	msgBody: &OpUpdate{
+		FullCollectionName: "test.values",
+		Flags:              OpUpdateFlags(OpUpdateUpsert | OpUpdateMultiUpdate),
+		Selector:           types.MustMakeDocument("v", "foo"),
+		Update:             types.MustMakeDocument("$set", types.MustMakeDocument("v", "baz")),
+	},
+}}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+	testMessages(t, updateTestCases)
+}
+
+func FuzzUpdate(f *testing.F) {
+	fuzzMessages(f, updateTestCases)
+}
@@ -0,0 +1,24 @@
+// Code generated by "stringer -linecomment -type OpDeleteFlagBit"; DO NOT EDIT.
+
+package wire
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[OpDeleteSingleRemove-1]
+}
+
+const _OpDeleteFlagBit_name = "SingleRemove"
+
+var _OpDeleteFlagBit_index = [...]uint8{0, 12}
+
+func (i OpDeleteFlagBit) String() string {
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_OpDeleteFlagBit_index)-1 {
+		return "OpDeleteFlagBit(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _OpDeleteFlagBit_name[_OpDeleteFlagBit_index[idx]:_OpDeleteFlagBit_index[idx+1]]
+}
@@ -0,0 +1,24 @@
+// Code generated by "stringer -linecomment -type OpInsertFlagBit"; DO NOT EDIT.
+
+package wire
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[OpInsertContinueOnError-1]
+}
+
+const _OpInsertFlagBit_name = "ContinueOnError"
+
+var _OpInsertFlagBit_index = [...]uint8{0, 15}
+
+func (i OpInsertFlagBit) String() string {
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_OpInsertFlagBit_index)-1 {
+		return "OpInsertFlagBit(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _OpInsertFlagBit_name[_OpInsertFlagBit_index[idx]:_OpInsertFlagBit_index[idx+1]]
+}
@@ -0,0 +1,25 @@
+// Code generated by "stringer -linecomment -type OpUpdateFlagBit"; DO NOT EDIT.
+
+package wire
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[OpUpdateUpsert-1]
+	_ = x[OpUpdateMultiUpdate-2]
+}
+
+const _OpUpdateFlagBit_name = "UpsertMultiUpdate"
+
+var _OpUpdateFlagBit_index = [...]uint8{0, 6, 17}
+
+func (i OpUpdateFlagBit) String() string {
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_OpUpdateFlagBit_index)-1 {
+		return "OpUpdateFlagBit(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _OpUpdateFlagBit_name[_OpUpdateFlagBit_index[idx]:_OpUpdateFlagBit_index[idx+1]]
+}
@@ -147,3 +147,16 @@ func fuzzMessages(f *testing.F, testCases []testCase) {
 		}
 	})
 }
+
+func TestExpectsReply(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, ExpectsReply(new(OpQuery)))
+	assert.True(t, ExpectsReply(new(OpGetMore)))
+	assert.True(t, ExpectsReply(new(OpMsg)))
+	assert.False(t, ExpectsReply(&OpMsg{FlagBits: OpMsgFlags(OpMsgMoreToCome)}))
+	assert.False(t, ExpectsReply(new(OpInsert)))
+	assert.False(t, ExpectsReply(new(OpUpdate)))
+	assert.False(t, ExpectsReply(new(OpDelete)))
+	assert.False(t, ExpectsReply(new(OpKillCursors)))
+}